@@ -28,6 +28,59 @@ func registerMethods(p *glightning.Plugin) {
 	rpcRebalancePush.Category = "utility"
 	p.RegisterMethod(rpcRebalancePush)
 
+	rpcBalance := glightning.NewRpcMethod(&rebalance.Balance{}, "Balance a channel")
+	rpcBalance.LongDesc = "Drain or fill the channel `scid` towards `target` (ratio of local balance, default 0.5), " +
+		"rebalancing it with the channel that has the opposite imbalance"
+	rpcBalance.Category = "utility"
+	p.RegisterMethod(rpcBalance)
+
+	rpcTopK := glightning.NewRpcMethod(&rebalance.TopKChannels{}, "List the most imbalanced local channels")
+	rpcTopK.LongDesc = "List the `k` local channels (default 10) whose ratio of local balance is the farthest from " +
+		"`target` (default 0.5), with their exact and believed balances, most imbalanced first"
+	rpcTopK.Category = "utility"
+	p.RegisterMethod(rpcTopK)
+
+	rpcWhatIf := glightning.NewRpcMethod(&rebalance.WhatIf{}, "Compare the routes of a rebalance at different maxppm")
+	rpcWhatIf.LongDesc = "Without paying, show the route and fee that a rebalance from `outscid` to `inscid` would use " +
+		"for `points` values of maxppm between `minppm` and `maxppm`"
+	rpcWhatIf.Category = "utility"
+	p.RegisterMethod(rpcWhatIf)
+
+	rpcEnqueue := glightning.NewRpcMethod(&rebalance.Enqueue{}, "Queue a rebalance with a priority")
+	rpcEnqueue.LongDesc = "Queue a rebalance from `outscid` to `inscid`. Queued rebalances run by `priority`, highest first. " +
+		"Without a priority, the emptier `inscid` is on our side the higher the priority"
+	rpcEnqueue.Category = "utility"
+	p.RegisterMethod(rpcEnqueue)
+
+	rpcQueue := glightning.NewRpcMethod(&rebalance.Queue{}, "Show the queue of rebalances")
+	rpcQueue.LongDesc = "Show the running rebalances, the pending ones in the order they will run and the last finished ones"
+	rpcQueue.Category = "utility"
+	p.RegisterMethod(rpcQueue)
+
+	rpcRouteByScids := glightning.NewRpcMethod(&rebalance.RouteByScids{}, "Build a route from a list of scids")
+	rpcRouteByScids.LongDesc = "Build and cost the route going through the channels `scids`, in order, starting from our node. With `send` the route is also paid, if it ends at our node"
+	rpcRouteByScids.Category = "utility"
+	p.RegisterMethod(rpcRouteByScids)
+
+	rpcFeeStats := glightning.NewRpcMethod(&node.FeeStats{}, "Get the fee percentiles of the graph")
+	rpcFeeStats.LongDesc = "Get the 10th, 50th and 90th percentiles of the fee ppm of the channels of the graph for `amount` (sats), " +
+		"also among the channels believed to be able to forward it, and of their base fee"
+	rpcFeeStats.Category = "utility"
+	p.RegisterMethod(rpcFeeStats)
+
+	rpcRouteDiff := glightning.NewRpcMethod(&node.RouteDiff{}, "Compare the routes of two routers")
+	rpcRouteDiff.LongDesc = "Search a route of `amount` sats from `source` to `destination` with the routers `a` and `b` " +
+		"(dijkstra, probability, cached) and return both routes and their differences, without paying"
+	rpcRouteDiff.Category = "utility"
+	p.RegisterMethod(rpcRouteDiff)
+
+	rpcAging := glightning.NewRpcMethod(&node.Aging{}, "Inspect and tune the aging of the liquidity beliefs")
+	rpcAging.LongDesc = "Get the aging parameters, how many beliefs will be reset at the next check and how far they will move on average. " +
+		"`liquidityrefresh` (minutes) changes the age after which a belief goes back to half the capacity, the change is kept across restarts. " +
+		"With `scid`, also show what the beliefs of that channel will be after the next check"
+	rpcAging.Category = "utility"
+	p.RegisterMethod(rpcAging)
+
 	rpcStats := glightning.NewRpcMethod(&node.Stats{}, "Get stats")
 	rpcStats.LongDesc = "Get the stats of the usage of circular"
 	rpcStats.Category = "utility"
@@ -38,6 +91,91 @@ func registerMethods(p *glightning.Plugin) {
 	rpfDeleteStats.Category = "utility"
 	p.RegisterMethod(rpfDeleteStats)
 
+	rpcChannels := glightning.NewRpcMethod(&node.ChannelInfo{}, "Query the channels of the graph")
+	rpcChannels.LongDesc = "List the channels of the graph sorted by `sortby` (ppm, liquidity or capacity), filtered by ppm and liquidity ratio, with `limit` and `offset`"
+	rpcChannels.Category = "utility"
+	p.RegisterMethod(rpcChannels)
+
+	rpcExport := glightning.NewRpcMethod(&node.ExportLiquidity{}, "Export the believed liquidity of the channels")
+	rpcExport.LongDesc = "Export the believed liquidity of our channels (and of our peers' channels with `neighbors`) as `json` or `csv`"
+	rpcExport.Category = "utility"
+	p.RegisterMethod(rpcExport)
+
+	rpcRefreshGraph := glightning.NewRpcMethod(&node.RefreshGraph{}, "Refresh the graph")
+	rpcRefreshGraph.LongDesc = "Refresh the graph now instead of waiting for the next scheduled refresh. Use `wait` to wait for a refresh that is already running"
+	rpcRefreshGraph.Category = "utility"
+	p.RegisterMethod(rpcRefreshGraph)
+
+	rpcRefreshPeers := glightning.NewRpcMethod(&node.RefreshPeers{}, "Refresh the peers")
+	rpcRefreshPeers.LongDesc = "Refresh the peers now instead of waiting for the next scheduled refresh. Use `wait` to wait for a refresh that is already running"
+	rpcRefreshPeers.Category = "utility"
+	p.RegisterMethod(rpcRefreshPeers)
+
+	rpcPruneChannel := glightning.NewRpcMethod(&node.PruneChannel{}, "Delete a channel from the graph")
+	rpcPruneChannel.LongDesc = "Delete both directions of the channel `scid` from the graph, so that routes stop using it right away. The next refresh adds it back if lightningd still knows it"
+	rpcPruneChannel.Category = "utility"
+	p.RegisterMethod(rpcPruneChannel)
+
+	rpcBanChannel := glightning.NewRpcMethod(&node.BanChannel{}, "Keep a channel out of the graph for a while")
+	rpcBanChannel.LongDesc = "Delete both directions of the channel `scid` from the graph and keep it out of the refreshes for `minutes` (default 60). Bans are lost on restart"
+	rpcBanChannel.Category = "utility"
+	p.RegisterMethod(rpcBanChannel)
+
+	rpcExportBeliefs := glightning.NewRpcMethod(&node.ExportBeliefs{}, "Export the liquidity beliefs to a file")
+	rpcExportBeliefs.LongDesc = "Export the liquidity beliefs learned by circular to `file`, so that another node can import them"
+	rpcExportBeliefs.Category = "utility"
+	p.RegisterMethod(rpcExportBeliefs)
+
+	rpcImportBeliefs := glightning.NewRpcMethod(&node.ImportBeliefs{}, "Import the liquidity beliefs from a file")
+	rpcImportBeliefs.LongDesc = "Merge the liquidity beliefs exported by another node in `file` into the graph. Newer beliefs win, our own channels are never overwritten"
+	rpcImportBeliefs.Category = "utility"
+	p.RegisterMethod(rpcImportBeliefs)
+
+	rpcReliability := glightning.NewRpcMethod(&node.Reliability{}, "Get the reliability of the nodes")
+	rpcReliability.LongDesc = "Get the reliability score of the nodes that circular tried to route through, or only of node `id`"
+	rpcReliability.Category = "utility"
+	p.RegisterMethod(rpcReliability)
+
+	rpcAllowlist := glightning.NewRpcMethod(&node.Allowlist{}, "Manage the allowlist")
+	rpcAllowlist.LongDesc = "Add the nodes in `add` to the allowlist and remove the ones in `remove`, then return it. " +
+		"With circular-allowlist, only the nodes in the allowlist can be intermediate hops"
+	rpcAllowlist.Category = "utility"
+	p.RegisterMethod(rpcAllowlist)
+
+	rpcLastError := glightning.NewRpcMethod(&node.LastError{}, "Get the last errors")
+	rpcLastError.LongDesc = "Get the last errors of rebalances by category (route-not-found, too-expensive, sendpay-failure, timeout, stalled), " +
+		"with the parameters of the failing command, or only the ones of `category`"
+	rpcLastError.Category = "utility"
+	p.RegisterMethod(rpcLastError)
+
+	rpcHealth := glightning.NewRpcMethod(&node.GraphHealth{}, "Get graph health")
+	rpcHealth.LongDesc = "Get the health of the graph: last successful refresh, consecutive failures and staleness"
+	rpcHealth.Category = "utility"
+	p.RegisterMethod(rpcHealth)
+
+	rpcProgress := glightning.NewRpcMethod(&rebalance.ProgressRequest{}, "Get the progress of a rebalance")
+	rpcProgress.LongDesc = "Get the progress of the rebalance with `id`: current attempt, parts sent and settled, amount delivered " +
+		"and fees paid so far, and its result once it's done"
+	rpcProgress.Category = "utility"
+	p.RegisterMethod(rpcProgress)
+
+	rpcCancel := glightning.NewRpcMethod(&rebalance.Cancel{}, "Cancel a rebalance")
+	rpcCancel.LongDesc = "Cancel the rebalance with `id`: it stops before its next attempt, the payments already sent are not recalled"
+	rpcCancel.Category = "utility"
+	p.RegisterMethod(rpcCancel)
+
+	rpcPreimage := glightning.NewRpcMethod(&node.Preimage{}, "Get the proof of payment of a rebalance")
+	rpcPreimage.LongDesc = "Get the preimage, the route and the time of the successful rebalance payment with `hash`. " +
+		"Requires circular-save-preimages"
+	rpcPreimage.Category = "utility"
+	p.RegisterMethod(rpcPreimage)
+
+	rpcFeeHistory := glightning.NewRpcMethod(&node.FeeHistory{}, "Get the fees advertised over time by a channel")
+	rpcFeeHistory.LongDesc = "Get the fee history of both directions of the channel with `scid`, oldest first. " +
+		"Requires circular-fee-history"
+	rpcFeeHistory.Category = "utility"
+	p.RegisterMethod(rpcFeeHistory)
+
 	rpcStop := glightning.NewRpcMethod(&node.Stop{}, "Stop circular")
 	rpcStop.LongDesc = "Stop future htlcs from being fired"
 	rpcStop.Category = "utility"
@@ -3,11 +3,47 @@ package main
 import (
 	"circular/graph"
 	"circular/node"
+	"circular/rebalance"
 	"github.com/elementsproject/glightning/glightning"
 	"log"
 )
 
 func registerOptions(p *glightning.Plugin) {
+	if err := p.RegisterNewOption("circular-profile",
+		"A bundle of settings for the other options: economical or aggressive (empty for none)",
+		node.PROFILE_NONE); err != nil {
+
+		log.Fatalln("error registering option circular-profile:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-default-maxppm",
+		"The maxppm of the rebalances that don't set one",
+		rebalance.DEFAULT_MAXPPM); err != nil {
+
+		log.Fatalln("error registering option circular-default-maxppm:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-maxppm-scale-reference",
+		"The amount at which maxppm applies as it is, when scaling it by amount (sats, 0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-maxppm-scale-reference:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-maxppm-scale-exponent",
+		"How steeply maxppm is scaled by amount (hundredths)",
+		50); err != nil {
+
+		log.Fatalln("error registering option circular-maxppm-scale-exponent:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-default-attempts",
+		"The number of attempts of the rebalances that don't set one",
+		rebalance.DEFAULT_ATTEMPTS); err != nil {
+
+		log.Fatalln("error registering option circular-default-attempts:", err)
+	}
+
 	if err := p.RegisterNewIntOption("circular-graph-refresh",
 		"How often the gossip graph gets refreshed (minutes)",
 		graph.DEFAULT_GRAPH_REFRESH_INTERVAL); err != nil {
@@ -15,6 +51,13 @@ func registerOptions(p *glightning.Plugin) {
 		log.Fatalln("error registering option circular-graph-refresh:", err)
 	}
 
+	if err := p.RegisterNewIntOption("circular-alias-refresh",
+		"How often the aliases of the nodes get refreshed (minutes)",
+		graph.DEFAULT_ALIAS_REFRESH_INTERVAL); err != nil {
+
+		log.Fatalln("error registering option circular-alias-refresh:", err)
+	}
+
 	if err := p.RegisterNewIntOption("circular-peer-refresh",
 		"How often the peer list gets refreshed (seconds)",
 		node.DEFAULT_PEER_REFRESH_INTERVAL); err != nil {
@@ -22,6 +65,13 @@ func registerOptions(p *glightning.Plugin) {
 		log.Fatalln("error registering option circular-peer-refresh:", err)
 	}
 
+	if err := p.RegisterNewBoolOption("circular-disable-aging",
+		"Whether the liquidity beliefs should only change with payments and forwards, instead of going back to 50/50 with time",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-disable-aging:", err)
+	}
+
 	if err := p.RegisterNewIntOption("circular-liquidity-refresh",
 		"The period of time after which the liquidity is reset (minutes)",
 		node.DEFAULT_LIQUIDITY_RESET_INTERVAL); err != nil {
@@ -29,10 +79,479 @@ func registerOptions(p *glightning.Plugin) {
 		log.Fatalln("error registering option circular-liquidity-reset:", err)
 	}
 
+	if err := p.RegisterNewIntOption("circular-graph-stale-threshold",
+		"The period of time without a successful graph refresh after which the graph is considered stale (minutes)",
+		node.DEFAULT_GRAPH_STALE_THRESHOLD); err != nil {
+
+		log.Fatalln("error registering option circular-graph-stale-threshold:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-graph-max-age",
+		"The maximum age of the graph after which a refresh is forced, regardless of the refresh interval (minutes, 0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-graph-max-age:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-save-interval",
+		"How often the graph is saved to disk, if it changed (minutes)",
+		node.DEFAULT_SAVE_INTERVAL); err != nil {
+
+		log.Fatalln("error registering option circular-save-interval:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-save-aliases",
+		"Whether circular should save the aliases of the nodes, in a file separate from the graph",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-save-aliases:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-max-state-size",
+		"How much disk the graph, its previous version and the aliases can take together (MB, 0 for no limit)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-max-state-size:", err)
+	}
+
+	if err := p.RegisterNewOption("circular-favorite-peers",
+		"A comma separated list of node ids whose routes from our peers are searched in advance, for the rebalances towards them",
+		""); err != nil {
+
+		log.Fatalln("error registering option circular-favorite-peers:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-favorites-refresh",
+		"How often the routes to the favorite peers are searched again (minutes)",
+		node.DEFAULT_FAVORITES_REFRESH); err != nil {
+
+		log.Fatalln("error registering option circular-favorites-refresh:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-warm-up",
+		"Whether circular should run a throwaway route search at startup, so that the first real one is fast",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-warm-up:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-max-channels",
+		"The maximum number of channels kept in the graph, the smallest and stalest are dropped (0 for unlimited)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-max-channels:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-strict-private",
+		"Whether private channels are forbidden in routes, including our own first and last hops",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-strict-private:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-min-capacity",
+		"The minimum capacity of the channels used as intermediate hops (sats, 0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-min-capacity:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-max-capacity",
+		"The maximum capacity of the channels used as intermediate hops (sats, 0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-max-capacity:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-strict-capacity",
+		"Whether the capacity range also applies to our own first and last hops",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-strict-capacity:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-max-hop-delay",
+		"The maximum delay advertised by the channels used as intermediate hops (blocks, 0 to disable)",
+		graph.DEFAULT_MAX_HOP_DELAY); err != nil {
+
+		log.Fatalln("error registering option circular-max-hop-delay:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-allowlist",
+		"Whether only the nodes in the allowlist can be intermediate hops",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-allowlist:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-avoid-local-channels",
+		"Whether our own channels are forbidden as intermediate hops, besides the first and last hops",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-avoid-local-channels:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-prefilter",
+		"Whether channels that can't carry the amount are filtered out of the graph before looking for a route",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-prefilter:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-search-workers",
+		"The number of goroutines evaluating the channels of the biggest nodes during a route search (0 or 1 to keep it serial)",
+		1); err != nil {
+
+		log.Fatalln("error registering option circular-search-workers:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-route-cache",
+		"Whether routes are cached until the graph changes",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-route-cache:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-amount-granularity",
+		"The granularity with which amounts are grouped by the route cache (msat)",
+		graph.DEFAULT_AMOUNT_GRANULARITY); err != nil {
+
+		log.Fatalln("error registering option circular-amount-granularity:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-min-hop-cost",
+		"The minimum cost of a hop when ranking routes, to avoid always using the same zero-fee channels (msat)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-min-hop-cost:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-reliability-weight",
+		"The extra cost of routing through a node that always fails, in proportion to its failure rate (ppm, 0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-reliability-weight:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-fee-volatility-weight",
+		"How much to avoid the channels whose advertised fee changes wildly between graph refreshes (ppm, 0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-fee-volatility-weight:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-base-fee-weight",
+		"How much the base fees weigh while ranking routes (percent)",
+		graph.DEFAULT_FEE_WEIGHT); err != nil {
+
+		log.Fatalln("error registering option circular-base-fee-weight:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-proportional-fee-weight",
+		"How much the proportional fees weigh while ranking routes (percent)",
+		graph.DEFAULT_FEE_WEIGHT); err != nil {
+
+		log.Fatalln("error registering option circular-proportional-fee-weight:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-min-liquidity-percentile",
+		"Skip the channels in this lowest percentile of the graph by believed liquidity ratio (0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-min-liquidity-percentile:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-require-evidence",
+		"Whether the liquidity of the channels is only trusted when it was learned, not estimated by aging",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-require-evidence:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-unevidenced-liquidity",
+		"The part of the capacity of a channel trusted without evidence with circular-require-evidence (percent)",
+		graph.DEFAULT_UNEVIDENCED_LIQUIDITY); err != nil {
+
+		log.Fatalln("error registering option circular-unevidenced-liquidity:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-missing-fees-penalty",
+		"The cost of routing through a channel without a fee policy (ppm, 0 to skip those channels)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-missing-fees-penalty:", err)
+	}
+
+	if err := p.RegisterNewOption("circular-preferred-nodes",
+		"A comma separated list of node ids that routes should go through when possible",
+		""); err != nil {
+
+		log.Fatalln("error registering option circular-preferred-nodes:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-preferred-bias",
+		"How much cheaper the channels of the preferred nodes look when choosing a route (ppm)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-preferred-bias:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-max-route-length",
+		"The maximum number of hops of a route, including our own channels",
+		graph.MAX_ROUTE_LENGTH); err != nil {
+
+		log.Fatalln("error registering option circular-max-route-length:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-delay-padding",
+		"The number of blocks added to the delay of every hop of a route, on top of the advertised delta",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-delay-padding:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-stability-check",
+		"Whether circular should search each route twice and only use it if both searches agree",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-stability-check:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-stability-delay",
+		"The delay between the two searches of the stability check (seconds)",
+		graph.DEFAULT_STABILITY_DELAY); err != nil {
+
+		log.Fatalln("error registering option circular-stability-delay:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-min-amount",
+		"The minimum amount of a rebalance, below which base fees make it too expensive (sats)",
+		node.DEFAULT_MIN_AMOUNT); err != nil {
+
+		log.Fatalln("error registering option circular-min-amount:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-max-alternate-outs",
+		"How many alternate outgoing channels to try when the first hop of a rebalance fails",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-max-alternate-outs:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-exclude-tightest-hop",
+		"Whether to retry without the tightest hop of a route that failed without telling which hop failed",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-exclude-tightest-hop:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-timeout-retry",
+		"Whether to retry on another route a payment that timed out, once listsendpays confirms it didn't complete",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-timeout-retry:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-route-memory",
+		"How many of the next rebalances of a pair of channels avoid the routes it used last (0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-route-memory:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-route-memory-penalty",
+		"The extra cost of the channels of a route just used by the same pair of channels (ppm of the amount)",
+		100); err != nil {
+
+		log.Fatalln("error registering option circular-route-memory-penalty:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-route-memory-decay",
+		"How long it takes for a route used by a pair of channels to be forgotten (minutes)",
+		60); err != nil {
+
+		log.Fatalln("error registering option circular-route-memory-decay:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-rebalance-deadline",
+		"How long a rebalance command can run, retries included, before returning what it did so far (seconds, 0 for no deadline)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-rebalance-deadline:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-explore-rate",
+		"Percentage of the rebalances that prefer the channels whose liquidity is not backed by evidence, to learn about them (0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-explore-rate:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-explore-bias",
+		"How much cheaper the channels without evidence look to the rebalances that explore (ppm of the amount)",
+		node.DEFAULT_EXPLORE_BIAS); err != nil {
+
+		log.Fatalln("error registering option circular-explore-bias:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-retry-delay",
+		"How long to wait before retrying after a temporary failure, doubled at every attempt (milliseconds, 0 to retry right away)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-retry-delay:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-retry-jitter",
+		"How much the retry delay is moved at random, so that rebalances that failed together don't retry together (percent)",
+		node.DEFAULT_RETRY_JITTER); err != nil {
+
+		log.Fatalln("error registering option circular-retry-jitter:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-chunk-max-overlap",
+		"How many of the hops of a chunk of circular-pull and circular-push can go through the channels used by the previous chunks (percent)",
+		node.DEFAULT_CHUNK_MAX_OVERLAP); err != nil {
+
+		log.Fatalln("error registering option circular-chunk-max-overlap:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-channel-cooldown",
+		"How long a local channel used by a rebalance can't be rebalanced again (minutes, 0 to disable)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-channel-cooldown:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-daily-fee-cap",
+		"The most circular can spend in fees over the last 24 hours, new rebalances are refused above it (sats, 0 for unlimited)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-daily-fee-cap:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-queue-concurrency",
+		"The number of queued rebalances that can run at the same time",
+		1); err != nil {
+
+		log.Fatalln("error registering option circular-queue-concurrency:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-queue-wait-for-self",
+		"Whether the queued rebalances wait for the graph to know a channel of ours before starting",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-queue-wait-for-self:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-inbound-fees",
+		"Whether the inbound fees advertised by the nodes are added to the fees of the routes",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-inbound-fees:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-aggregate-parallel",
+		"Whether parallel channels between two nodes can be used together when none of them can forward the amount alone",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-aggregate-parallel:", err)
+	}
+
+	if err := p.RegisterNewOption("circular-tie-break",
+		"The criteria used to choose between routes with the same cost, in order of preference (comma separated, from: hops, liquidity, scid)",
+		graph.DEFAULT_TIE_BREAK); err != nil {
+
+		log.Fatalln("error registering option circular-tie-break:", err)
+	}
+
+	if err := p.RegisterNewOption("circular-route-objective",
+		"What the route search minimizes, or a comma separated list tried in turn by the attempts (from: cheapest, shortest)",
+		graph.DEFAULT_OBJECTIVES); err != nil {
+
+		log.Fatalln("error registering option circular-route-objective:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-exclude-dead-nodes",
+		"Whether to avoid the nodes that look offline, according to the gossip and to our peers",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-exclude-dead-nodes:", err)
+	}
+
+	if err := p.RegisterNewOption("circular-local-balance",
+		"Which balance of our channels is believed to be available (to-us, to-us-minus-reserve, spendable)",
+		node.DEFAULT_LOCAL_BALANCE); err != nil {
+
+		log.Fatalln("error registering option circular-local-balance:", err)
+	}
+
+	if err := p.RegisterNewOption("circular-duplicates",
+		"What to do with a rebalance identical to one already in flight (reject, coalesce, allow)",
+		node.DEFAULT_DUPLICATES); err != nil {
+
+		log.Fatalln("error registering option circular-duplicates:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-min-probability",
+		"The minimum estimated probability of success of a route, in percent (0 accepts any route)",
+		0); err != nil {
+
+		log.Fatalln("error registering option circular-min-probability:", err)
+	}
+
 	if err := p.RegisterNewBoolOption("circular-save-stats",
 		"Whether circular should save stats in the database",
 		true); err != nil {
 
 		log.Fatalln("error registering option circular-liquidity-reset:", err)
 	}
+
+	if err := p.RegisterNewBoolOption("circular-reduce-to-outbound",
+		"Whether to lower the amount of a rebalance to the local balance of the outgoing channel, instead of failing",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-reduce-to-outbound:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-graph-reuse-channels",
+		"Whether to keep the channels whose gossip didn't change at every graph refresh, instead of building them again",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-graph-reuse-channels:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-check-graph",
+		"Whether to check that the channels and the adjacency list of the graph agree after every graph refresh",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-check-graph:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-strict-graph-load",
+		"Whether to refuse to start when the saved graph can't be read or has malformed channels, instead of starting with a new graph",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-strict-graph-load:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-save-preimages",
+		"Whether to save the preimages of successful rebalances as proof of payment (sensitive)",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-save-preimages:", err)
+	}
+
+	if err := p.RegisterNewBoolOption("circular-fee-history",
+		"Whether to record the fees advertised by the channels at every graph refresh, for analytics",
+		false); err != nil {
+
+		log.Fatalln("error registering option circular-fee-history:", err)
+	}
+
+	if err := p.RegisterNewIntOption("circular-fee-history-retention",
+		"How many days of fee history are kept, with circular-fee-history",
+		node.DEFAULT_FEE_HISTORY_RETENTION); err != nil {
+
+		log.Fatalln("error registering option circular-fee-history-retention:", err)
+	}
 }
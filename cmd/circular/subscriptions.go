@@ -14,6 +14,7 @@ func registerSubscriptions(p *glightning.Plugin) {
 	p.SubscribeSendPaySuccess(OnSendPaySuccess)
 	p.SubscribeConnect(OnConnect)
 	p.SubscribeDisconnect(OnDisconnect)
+	p.SubscribeForwardings(OnForward)
 }
 
 func OnSendPayFailure(sf *glightning.SendPayFailure) {
@@ -31,3 +32,7 @@ func OnConnect(c *glightning.ConnectEvent) {
 func OnDisconnect(d *glightning.DisconnectEvent) {
 	node.GetNode().OnDisconnect(d)
 }
+
+func OnForward(f *glightning.Forwarding) {
+	node.GetNode().OnForward(f)
+}
@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAgingPreview(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	fresh := newTestChannel(a, b, "1x1x1", 1000000, 0, 0, 40)
+	old := newTestChannel(b, a, "1x1x1", 1000000, 0, 0, 40)
+	g := newTestGraph(fresh, old)
+
+	now := time.Now()
+	fresh.Liquidity, fresh.Timestamp = 0, now.Unix()
+	old.Liquidity, old.Timestamp = 0, now.Add(-2*time.Hour).Unix()
+
+	// only the old belief goes back to half the capacity, a drift of 500000ppm over 2 channels
+	resets, drift := g.AgingPreview(time.Hour, now)
+	assert.Equal(t, 1, resets)
+	assert.Equal(t, uint64(250000), drift)
+	assert.Equal(t, uint64(500000000), old.AgedLiquidity(time.Hour, now.Unix()))
+	assert.Equal(t, uint64(0), fresh.AgedLiquidity(time.Hour, now.Unix()))
+
+	// the preview doesn't change the graph
+	assert.Equal(t, uint64(0), old.Liquidity)
+
+	// with a longer refresh nothing is reset yet
+	resets, drift = g.AgingPreview(3*time.Hour, now)
+	assert.Equal(t, 0, resets)
+	assert.Equal(t, uint64(0), drift)
+}
+
+func TestAgingZeroThreshold(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	old := newTestChannel(a, b, "1x1x1", 1000000, 0, 0, 40)
+	g := newTestGraph(old)
+	old.Liquidity, old.Timestamp = 42, time.Now().Add(-time.Minute).Unix()
+
+	// a threshold of 0 resets every belief at each refresh, it doesn't disable aging
+	assert.True(t, old.IsAged(0, time.Now().Unix()))
+	assert.Equal(t, 1, g.RefreshLiquidity(0))
+	assert.Equal(t, uint64(500000000), old.Liquidity)
+}
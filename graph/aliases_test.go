@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRoutingDoesNotDependOnAliases(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	channels := func() []*Channel {
+		return []*Channel{
+			newTestChannel(a, b, "1x1x1", 1000000, 0, 10, 40),
+			newTestChannel(b, c, "2x2x2", 1000000, 0, 10, 40),
+			newTestChannel(c, a, "3x3x3", 1000000, 0, 10, 40),
+		}
+	}
+
+	withoutAliases := newTestGraph(channels()...)
+	withAliases := newTestGraph(channels()...)
+	withAliases.SetAliases(map[string]string{a: "alice", b: "bob"})
+	withAliases.RefreshAliases([]*glightning.Node{{Id: c, Alias: "carol"}})
+	assert.Equal(t, "bob", withAliases.GetAlias(b))
+	assert.Equal(t, b, withoutAliases.GetAlias(b))
+	// only the refresh counts as a change to save, the loaded aliases are already on disk
+	assert.Equal(t, uint64(1), withAliases.AliasesVersion())
+
+	expected, err := withoutAliases.GetRoute(a, c, 100000000, nil, 10, nil)
+	assert.NoError(t, err)
+	route, err := withAliases.GetRoute(a, c, 100000000, nil, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, len(expected.Hops), len(route.Hops))
+	for i := range route.Hops {
+		assert.Equal(t, expected.Hops[i].ShortChannelId, route.Hops[i].ShortChannelId)
+	}
+	assert.Equal(t, expected.Fee(), route.Fee())
+}
+
+func TestUpdateAlias(t *testing.T) {
+	a, b, unknown := testNodeId(1), testNodeId(2), testNodeId(9)
+	g := newTestGraph(newTestChannel(a, b, "1x1x1", 1000000, 0, 10, 40))
+	g.RefreshAliases([]*glightning.Node{{Id: a, Alias: "alice"}, {Id: b, Alias: "bob"}})
+	version := g.AliasesVersion()
+
+	assert.True(t, g.UpdateAlias(b, "bobby"))
+	assert.Equal(t, "bobby", g.GetAlias(b))
+	assert.Equal(t, "alice", g.GetAlias(a))
+	assert.Equal(t, version+1, g.AliasesVersion())
+
+	// the same alias and empty aliases are not changes
+	assert.False(t, g.UpdateAlias(b, "bobby"))
+	assert.False(t, g.UpdateAlias(b, ""))
+	assert.Equal(t, "bobby", g.GetAlias(b))
+	assert.Equal(t, version+1, g.AliasesVersion())
+
+	// a node that has no channels in the graph yet keeps its alias for when it gets one
+	assert.True(t, g.UpdateAlias(unknown, "newcomer"))
+	assert.Equal(t, "newcomer", g.GetAlias(unknown))
+}
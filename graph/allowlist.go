@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"sort"
+	"sync"
+)
+
+// allowlist contains the only nodes that can be intermediate hops when RouteOptions.Allowlist is set
+type allowlist struct {
+	lock  *sync.RWMutex
+	nodes map[string]bool
+}
+
+func newAllowlist() *allowlist {
+	return &allowlist{
+		lock:  &sync.RWMutex{},
+		nodes: make(map[string]bool),
+	}
+}
+
+// SetAllowlist replaces the nodes of the allowlist
+func (g *Graph) SetAllowlist(nodes []string) {
+	allowed := make(map[string]bool, len(nodes))
+	for _, id := range nodes {
+		allowed[id] = true
+	}
+
+	g.allowlist.lock.Lock()
+	g.allowlist.nodes = allowed
+	g.allowlist.lock.Unlock()
+
+	// the cached routes might go through nodes that are not allowed anymore
+	g.channelsLock.Lock()
+	g.version++
+	g.channelsLock.Unlock()
+}
+
+// GetAllowlist returns the nodes of the allowlist, sorted
+func (g *Graph) GetAllowlist() []string {
+	g.allowlist.lock.RLock()
+	defer g.allowlist.lock.RUnlock()
+
+	nodes := make([]string, 0, len(g.allowlist.nodes))
+	for id := range g.allowlist.nodes {
+		nodes = append(nodes, id)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// allowlistDisconnects tells whether src and dst are disconnected by the allowlist alone,
+// no matter the amount, the exclusions or the number of hops
+func (g *Graph) allowlistDisconnects(src, dst string) bool {
+	g.channelsLock.RLock()
+	g.adjacencyListLock.RLock()
+	g.allowlist.lock.RLock()
+	defer g.channelsLock.RUnlock()
+	defer g.adjacencyListLock.RUnlock()
+	defer g.allowlist.lock.RUnlock()
+
+	return !g.reaches(src, dst, func(c *Channel) bool {
+		return c.IsPublic && (c.Source == src || g.allowlist.nodes[c.Source])
+	}, -1)
+}
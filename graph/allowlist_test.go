@@ -0,0 +1,43 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPathfinderAllowlist(t *testing.T) {
+	src, a, b, dst := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	g := newTestGraph(
+		newTestChannel(src, a, "1x1x1", 1000000, 0, 0, 10),
+		newTestChannel(a, dst, "2x1x1", 1000000, 0, 100, 10),
+		newTestChannel(src, b, "3x1x1", 1000000, 0, 0, 10),
+		newTestChannel(b, dst, "4x1x1", 1000000, 0, 200, 10),
+		newTestChannel(dst, src, "5x1x1", 1000000, 0, 0, 10),
+	)
+	amount := uint64(100000000)
+	options := NewRouteOptions()
+	options.Allowlist = true
+
+	// only b is trusted, the cheaper route through a is not allowed
+	g.SetAllowlist([]string{b})
+	route, err := g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "3x1x1", route.Hops[0].ShortChannelId)
+
+	// the allowlist alone disconnects src from dst
+	g.SetAllowlist([]string{})
+	_, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.Equal(t, util.ErrAllowlistDisconnected, err)
+
+	// a route that is missing for other reasons is not blamed on the allowlist
+	g.SetAllowlist([]string{a, b})
+	_, err = g.GetRoute(src, dst, amount, map[string]bool{a: true, b: true}, 4, options)
+	assert.Equal(t, util.ErrNoRoute, err)
+
+	options.Allowlist = false
+	g.SetAllowlist([]string{})
+	route, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "1x1x1", route.Hops[0].ShortChannelId)
+}
@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"time"
+)
+
+const (
+	BELIEFS_VERSION = 1
+
+	// CONFIDENCE_ESTIMATE is the confidence of the 50/50 estimate of a channel that was never learned or has aged
+	CONFIDENCE_ESTIMATE = 0.0
+	// CONFIDENCE_PAYMENT is the confidence of a liquidity inferred from the outcome of one of our payments
+	CONFIDENCE_PAYMENT = 0.7
+	// CONFIDENCE_EXACT is the confidence of a liquidity known exactly, like the one of our channels
+	CONFIDENCE_EXACT = 1.0
+)
+
+// LiquidityBelief is what we believe about the liquidity of a channel direction, and when we learned it
+type LiquidityBelief struct {
+	ChannelId string `json:"channel_id"`
+	Liquidity uint64 `json:"liquidity_msat"`
+	Timestamp int64  `json:"timestamp"`
+	// Evidence tells whether the liquidity was learned or only estimated, see Channel.Evidence
+	Evidence bool `json:"evidence,omitempty"`
+	// Confidence is how much the liquidity can be trusted, see Channel.Confidence
+	Confidence float64 `json:"confidence"`
+}
+
+// Beliefs is the portable format used to share the liquidity beliefs between nodes.
+// It doesn't contain the topology, which the importing node gets from its own gossip.
+type Beliefs struct {
+	Version  int               `json:"version"`
+	NodeId   string            `json:"node_id"`
+	Exported int64             `json:"exported"`
+	Beliefs  []LiquidityBelief `json:"beliefs"`
+}
+
+// ExportBeliefs returns the beliefs that have been learned, i.e. not the 50/50 estimates, new or aged.
+// The channels of the node are left out, the importing node can't know them better than from its peer.
+func (g *Graph) ExportBeliefs(id string) *Beliefs {
+	g.channelsLock.RLock()
+	defer g.channelsLock.RUnlock()
+
+	beliefs := make([]LiquidityBelief, 0)
+	for channelId, c := range g.Channels {
+		if !c.Evidence || c.Source == id || c.Destination == id {
+			continue
+		}
+		beliefs = append(beliefs, LiquidityBelief{
+			ChannelId:  channelId,
+			Liquidity:  c.Liquidity,
+			Timestamp:  c.Timestamp,
+			Evidence:   c.Evidence,
+			Confidence: c.Confidence,
+		})
+	}
+	return &Beliefs{
+		Version:  BELIEFS_VERSION,
+		NodeId:   id,
+		Exported: time.Now().Unix(),
+		Beliefs:  beliefs,
+	}
+}
+
+// ImportBeliefs merges the beliefs into the graph. A belief is only imported if the channel is known,
+// if it is newer than ours and if the channel is not one of ours, whose liquidity we know exactly.
+// What another node learned is never known exactly by us, so the confidence is capped at CONFIDENCE_PAYMENT.
+// It returns the number of imported and skipped beliefs.
+func (g *Graph) ImportBeliefs(beliefs *Beliefs, id string) (int, int) {
+	g.channelsLock.Lock()
+	defer g.channelsLock.Unlock()
+	g.version++
+
+	imported := 0
+	for _, belief := range beliefs.Beliefs {
+		c, ok := g.Channels[belief.ChannelId]
+		if !ok || !belief.Evidence || c.Source == id || c.Destination == id || belief.Timestamp <= c.Timestamp {
+			continue
+		}
+		c.Liquidity = belief.Liquidity
+		if c.Liquidity > c.Satoshis*1000 {
+			c.Liquidity = c.Satoshis * 1000
+		}
+		c.Timestamp = belief.Timestamp
+		c.Evidence = belief.Evidence
+		c.Confidence = belief.Confidence
+		// the files exported before the confidence only tell whether the belief was learned
+		if belief.Confidence == 0 || belief.Confidence > CONFIDENCE_PAYMENT {
+			c.Confidence = CONFIDENCE_PAYMENT
+		}
+		imported++
+	}
+	return imported, len(beliefs.Beliefs) - imported
+}
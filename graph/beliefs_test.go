@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestImportBeliefs(t *testing.T) {
+	self, a, b := testNodeId(0), testNodeId(1), testNodeId(2)
+	local := newTestChannel(self, a, "1x1x1", 1000000, 0, 1, 40)
+	old := newTestChannel(a, b, "2x2x2", 1000000, 0, 1, 40)
+	recent := newTestChannel(b, a, "2x2x2", 1000000, 0, 1, 40)
+	aged := newTestChannel(a, self, "3x3x3", 1000000, 0, 1, 40)
+	g := newTestGraph(local, old, recent, aged)
+
+	now := time.Now().Unix()
+	old.Timestamp = now - 3600
+	recent.Timestamp = now
+	localId, oldId, recentId := "1x1x1/"+util.GetDirection(self, a), "2x2x2/"+util.GetDirection(a, b), "2x2x2/"+util.GetDirection(b, a)
+
+	imported, skipped := g.ImportBeliefs(&Beliefs{Beliefs: []LiquidityBelief{
+		{ChannelId: localId, Liquidity: 1000, Timestamp: now},
+		{ChannelId: oldId, Liquidity: 2000, Timestamp: now - 60, Evidence: true, Confidence: CONFIDENCE_EXACT},
+		{ChannelId: recentId, Liquidity: 3000, Timestamp: now - 60},
+		{ChannelId: "9x9x9/0", Liquidity: 4000, Timestamp: now},
+	}}, self)
+
+	assert.Equal(t, 1, imported)
+	assert.Equal(t, 3, skipped)
+	// only the belief newer than ours on a channel that isn't ours wins, and isn't exact for us
+	assert.Equal(t, uint64(2000), old.Liquidity)
+	assert.Equal(t, now-60, old.Timestamp)
+	assert.Equal(t, CONFIDENCE_PAYMENT, old.Confidence)
+	assert.NotEqual(t, uint64(1000), local.Liquidity)
+	assert.NotEqual(t, uint64(3000), recent.Liquidity)
+
+	// neither the estimates, new or aged, nor our own channels are exported
+	g.SetLiquidity(localId, 500000000)
+	aged.Timestamp = now
+	beliefs := g.ExportBeliefs(self)
+	assert.Equal(t, 1, len(beliefs.Beliefs))
+	assert.Equal(t, oldId, beliefs.Beliefs[0].ChannelId)
+	assert.Equal(t, CONFIDENCE_PAYMENT, beliefs.Beliefs[0].Confidence)
+
+	// an estimate is not imported
+	_, skipped = g.ImportBeliefs(&Beliefs{Beliefs: []LiquidityBelief{{ChannelId: recentId, Liquidity: 3000, Timestamp: now + 60}}}, self)
+	assert.Equal(t, 1, skipped)
+
+	// a payment outcome is less certain, and an older file without the confidence is read as one
+	g.UpdateChannel(recentId, oldId, 5000)
+	assert.Equal(t, CONFIDENCE_PAYMENT, recent.Confidence)
+	old.Timestamp = 0
+	g.ImportBeliefs(&Beliefs{Beliefs: []LiquidityBelief{{ChannelId: oldId, Liquidity: 2000, Timestamp: now, Evidence: true}}}, self)
+	assert.Equal(t, CONFIDENCE_PAYMENT, old.Confidence)
+}
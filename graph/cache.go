@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	MAX_CACHED_ROUTES          = 1024
+	DEFAULT_AMOUNT_GRANULARITY = 1000 // msat
+)
+
+type routeCacheKey struct {
+	src     string
+	dst     string
+	amount  uint64
+	maxHops int
+	exclude string
+}
+
+// cachedHops are the hops found by a search with options, a copy of the ones of the search
+type cachedHops struct {
+	options *RouteOptions
+	hops    []RouteHop
+}
+
+// routeCache remembers the hops found by dijkstra for a bucketed amount.
+// It is emptied every time the graph changes.
+type routeCache struct {
+	lock    *sync.Mutex
+	version uint64
+	hops    map[routeCacheKey]cachedHops
+}
+
+func newRouteCache() *routeCache {
+	return &routeCache{
+		lock: &sync.Mutex{},
+		hops: make(map[routeCacheKey]cachedHops),
+	}
+}
+
+func newRouteCacheKey(src, dst string, amount uint64, exclude map[string]bool, maxHops int) routeCacheKey {
+	excluded := make([]string, 0, len(exclude))
+	for id, ok := range exclude {
+		if ok {
+			excluded = append(excluded, id)
+		}
+	}
+	sort.Strings(excluded)
+	return routeCacheKey{
+		src:     src,
+		dst:     dst,
+		amount:  amount,
+		maxHops: maxHops,
+		exclude: strings.Join(excluded, ","),
+	}
+}
+
+// get returns the hops cached for key, if they were found by a search with the same options, see sameSearch
+func (c *routeCache) get(key routeCacheKey, version uint64, options *RouteOptions) ([]RouteHop, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.version != version {
+		c.hops = make(map[routeCacheKey]cachedHops)
+		c.version = version
+		return nil, false
+	}
+	cached, ok := c.hops[key]
+	if !ok || !sameSearch(cached.options, options) {
+		return nil, false
+	}
+	return cached.hops, true
+}
+
+func (c *routeCache) put(key routeCacheKey, version uint64, options *RouteOptions, hops []RouteHop) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// the graph changed while we were looking for the route
+	if c.version != version {
+		return
+	}
+	if len(c.hops) >= MAX_CACHED_ROUTES {
+		c.hops = make(map[routeCacheKey]cachedHops)
+	}
+	c.hops[key] = cachedHops{options: options.clone(), hops: hops}
+}
+
+// BucketAmount rounds the amount up to a multiple of the amount granularity, so that amounts
+// that are close to each other share the same cached route, found for at least their amount
+func (o *RouteOptions) BucketAmount(amount uint64) uint64 {
+	if o.AmountGranularity <= 1 {
+		return amount
+	}
+	bucket := (amount + o.AmountGranularity - 1) / o.AmountGranularity * o.AmountGranularity
+	if bucket == 0 {
+		return o.AmountGranularity
+	}
+	return bucket
+}
+
+// costHops returns a copy of the hops, with amounts and delays computed for amount, or false if one of
+// them can't forward what it would carry. Like in dijkstra, each hop carries the fee of its own channel,
+// and the inbound fee charged by its destination is part of what it carries, if inbound is true.
+func costHops(hops []RouteHop, amount uint64, inbound bool) ([]RouteHop, bool) {
+	result := make([]RouteHop, len(hops))
+	var delay uint = 0
+	carried := amount
+	for i := len(hops) - 1; i >= 0; i-- {
+		// the destination of the hop forwards what the next hop carries, for its fees
+		if i < len(hops)-1 {
+			carried += forwardingFee(hops[i].Channel, hops[i+1].Channel, carried, inbound)
+		}
+		if !hops[i].CanForward(carried) {
+			return nil, false
+		}
+		delay += hops[i].Channel.Delay
+		result[i] = RouteHop{
+			Channel:      hops[i].Channel,
+			MilliSatoshi: carried + hops[i].ComputeFee(carried),
+			Delay:        delay,
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,114 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBucketAmount(t *testing.T) {
+	options := &RouteOptions{AmountGranularity: 1000}
+	assert.Equal(t, uint64(1000000), options.BucketAmount(1000000))
+	// rounded up, so that the cached route was found for at least the amount
+	assert.Equal(t, uint64(1000000), options.BucketAmount(999001))
+	assert.Equal(t, uint64(1001000), options.BucketAmount(1000001))
+	assert.Equal(t, uint64(1000), options.BucketAmount(1))
+
+	options.AmountGranularity = 0
+	assert.Equal(t, uint64(1000001), options.BucketAmount(1000001))
+}
+
+func TestRouteCacheHitAndInvalidation(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	graph := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 1000, 100, 40),
+		newTestChannel(b, c, "2x2x2", 1000000, 1000, 100, 40),
+		newTestChannel(c, a, "3x3x3", 1000000, 1000, 100, 40),
+	)
+	options := NewRouteOptions()
+	options.CacheRoutes = true
+
+	route, err := graph.GetRoute(a, c, 1000000, nil, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(graph.cache.hops))
+
+	// a close amount shares the bucket, but the route is costed for its own amount
+	cached, err := graph.GetRoute(a, c, 999999, nil, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(graph.cache.hops))
+	assert.Equal(t, route.Hops[0].ShortChannelId, cached.Hops[0].ShortChannelId)
+	assert.Equal(t, uint64(999999), cached.Amount)
+	fresh, err := graph.GetRoute(a, c, 999999, nil, 4, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, fresh.Hops, cached.Hops)
+
+	// a change in the graph invalidates the cache
+	graph.UpdateChannel("2x2x2/"+util.GetDirection(b, c), "2x2x2/"+util.GetDirection(c, b), 0)
+	_, err = graph.GetRoute(a, c, 1000000, nil, 4, options)
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(graph.cache.hops))
+}
+
+func TestRouteCacheKeysOnTheOptionValues(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	graph := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 1000, 100, 40),
+		newTestChannel(b, c, "2x2x2", 1000000, 1000, 100, 40),
+		newTestChannel(c, a, "3x3x3", 1000000, 1000, 100, 40),
+	)
+	options := NewRouteOptions()
+	options.CacheRoutes = true
+	version := graph.Version()
+
+	_, err := graph.GetRoute(a, c, 1000000, nil, 4, options)
+	assert.NoError(t, err)
+	key := newRouteCacheKey(a, c, 1000000, nil, 4)
+
+	// a copy of the options, like the cached router makes, finds the cached hops
+	copied := *options
+	_, ok := graph.cache.get(key, version, &copied)
+	assert.True(t, ok)
+
+	// options changed in place don't
+	options.MinHopCost = 5000
+	_, ok = graph.cache.get(key, version, options)
+	assert.False(t, ok)
+}
+
+func TestCachedRouteIsCheckedForTheAmount(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	first := newTestChannel(a, b, "1x1x1", 1000000, 1000, 100, 40)
+	second := newTestChannel(b, c, "2x2x2", 1000000, 1000, 100, 40)
+	graph := newTestGraph(first, second, newTestChannel(c, a, "3x3x3", 1000000, 1000, 100, 40))
+	options := NewRouteOptions()
+	options.CacheRoutes = true
+	options.AmountGranularity = 1000000
+
+	_, err := graph.GetRoute(a, c, 1000000, nil, 4, options)
+	assert.NoError(t, err)
+
+	// the cached hops can't carry less than the htlc minimum: the amount is searched on its own
+	second.minHtlcMsat = 600000
+	_, err = graph.GetRoute(a, c, 500000, nil, 4, options)
+	assert.Equal(t, util.ErrNoRoute, err)
+	_, ok := costHops([]RouteHop{{Channel: first}, {Channel: second}}, 500000, false)
+	assert.False(t, ok)
+
+	// the inbound fees are priced like in the search
+	second.minHtlcMsat = 1000
+	first.Inbound = &InboundFee{BaseMsat: 500}
+	withInbound, ok := costHops([]RouteHop{{Channel: first}, {Channel: second}}, 500000, true)
+	assert.True(t, ok)
+	without, _ := costHops([]RouteHop{{Channel: first}, {Channel: second}}, 500000, false)
+	assert.Equal(t, without[1].MilliSatoshi, withInbound[1].MilliSatoshi)
+	// the first hop carries the inbound fee of b, and its own fee on it
+	assert.GreaterOrEqual(t, withInbound[0].MilliSatoshi, without[0].MilliSatoshi+500)
+	assert.LessOrEqual(t, withInbound[0].MilliSatoshi, without[0].MilliSatoshi+501)
+}
+
+func TestRecordOutcomeInvalidatesTheCache(t *testing.T) {
+	graph := NewGraph()
+	version := graph.Version()
+	graph.RecordOutcome(testNodeId(1), false)
+	assert.Greater(t, graph.Version(), version)
+}
@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"github.com/elementsproject/glightning/glightning"
+	"sort"
+)
+
+// CapChannels deletes the least valuable channels until at most max are left, and returns them.
+// The smallest channels go first and, among channels of the same capacity, the ones with the oldest update.
+// The channels of node id are never deleted, because they are the first and last hops of our routes.
+// A max of 0 means no cap.
+func (g *Graph) CapChannels(max int, id string) []*Channel {
+	if max <= 0 {
+		return nil
+	}
+
+	g.channelsLock.Lock()
+	g.adjacencyListLock.Lock()
+	defer g.channelsLock.Unlock()
+	defer g.adjacencyListLock.Unlock()
+
+	if len(g.Channels) <= max {
+		return nil
+	}
+
+	candidates := make([]*Channel, 0, len(g.Channels))
+	for _, c := range g.Channels {
+		if c.Source == id || c.Destination == id {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return lessValuable(candidates[i].Channel, candidates[j].Channel)
+	})
+
+	excess := len(g.Channels) - max
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+	dropped := candidates[:excess]
+	for _, c := range dropped {
+		g.DeleteChannel(c)
+	}
+	if len(dropped) > 0 {
+		g.version++
+	}
+	return dropped
+}
+
+// CapChannelList keeps at most max channels of channelList, dropping them in the order of CapChannels,
+// so that the channels over the cap are never built by a refresh. It returns the channels kept and
+// the number dropped. A max of 0 means no cap.
+func CapChannelList(channelList []*glightning.Channel, max int, id string) ([]*glightning.Channel, int) {
+	if max <= 0 || len(channelList) <= max {
+		return channelList, 0
+	}
+
+	candidates := make([]*glightning.Channel, 0, len(channelList))
+	for _, c := range channelList {
+		if c.Source == id || c.Destination == id {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return lessValuable(candidates[i], candidates[j])
+	})
+
+	excess := len(channelList) - max
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+	dropped := make(map[*glightning.Channel]bool, excess)
+	for _, c := range candidates[:excess] {
+		dropped[c] = true
+	}
+	kept := make([]*glightning.Channel, 0, len(channelList)-excess)
+	for _, c := range channelList {
+		if !dropped[c] {
+			kept = append(kept, c)
+		}
+	}
+	return kept, excess
+}
+
+// lessValuable tells whether a goes before b when capping: the smaller first and, among channels
+// of the same capacity, the ones with the oldest update
+func lessValuable(a, b *glightning.Channel) bool {
+	if a.Satoshis != b.Satoshis {
+		return a.Satoshis < b.Satoshis
+	}
+	if a.LastUpdate != b.LastUpdate {
+		return a.LastUpdate < b.LastUpdate
+	}
+	if a.ShortChannelId != b.ShortChannelId {
+		return a.ShortChannelId < b.ShortChannelId
+	}
+	return a.Source < b.Source
+}
@@ -0,0 +1,58 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGraphCapChannels(t *testing.T) {
+	self, a, b, c := testNodeId(0), testNodeId(1), testNodeId(2), testNodeId(3)
+	ours := newTestChannel(self, a, "1x1x1", 100000, 0, 0, 10)
+	small := newTestChannel(a, b, "2x1x1", 200000, 0, 0, 10)
+	stale := newTestChannel(b, c, "3x1x1", 500000, 0, 0, 10)
+	stale.LastUpdate -= 3600
+	fresh := newTestChannel(c, a, "4x1x1", 500000, 0, 0, 10)
+	big := newTestChannel(a, c, "5x1x1", 1000000, 0, 0, 10)
+	g := newTestGraph(ours, small, stale, fresh, big)
+
+	assert.Empty(t, g.CapChannels(0, self))
+	assert.Empty(t, g.CapChannels(5, self))
+
+	// our channel is the smallest, but it must be kept
+	dropped := g.CapChannels(3, self)
+	assert.Equal(t, []*Channel{small, stale}, dropped)
+	assert.Equal(t, 3, len(g.Channels))
+	assert.Contains(t, g.Channels, "1x1x1/"+util.GetDirection(self, a))
+	assert.Empty(t, g.Inbound[b][a])
+	assert.Empty(t, g.Inbound[c][b])
+
+	dropped = g.CapChannels(1, self)
+	assert.Equal(t, []*Channel{fresh, big}, dropped)
+	assert.Equal(t, 1, len(g.Channels))
+}
+
+func TestCapChannelList(t *testing.T) {
+	self, a, b, c := testNodeId(0), testNodeId(1), testNodeId(2), testNodeId(3)
+	ours := newTestChannel(self, a, "1x1x1", 100000, 0, 0, 10).Channel
+	small := newTestChannel(a, b, "2x1x1", 200000, 0, 0, 10).Channel
+	stale := newTestChannel(b, c, "3x1x1", 500000, 0, 0, 10).Channel
+	stale.LastUpdate -= 3600
+	fresh := newTestChannel(c, a, "4x1x1", 500000, 0, 0, 10).Channel
+	channelList := []*glightning.Channel{ours, small, stale, fresh}
+
+	kept, dropped := CapChannelList(channelList, 0, self)
+	assert.Equal(t, channelList, kept)
+	assert.Equal(t, 0, dropped)
+
+	// same order as CapChannels, keeping the order of the list
+	kept, dropped = CapChannelList(channelList, 2, self)
+	assert.Equal(t, []*glightning.Channel{ours, fresh}, kept)
+	assert.Equal(t, 2, dropped)
+
+	// our channel is never dropped
+	kept, dropped = CapChannelList(channelList, 1, self)
+	assert.Equal(t, []*glightning.Channel{ours}, kept)
+	assert.Equal(t, 3, dropped)
+}
@@ -0,0 +1,28 @@
+package graph
+
+// IsOutsideCapacityRange tells whether the capacity of c is outside the range set by MinCapacity and MaxCapacity
+func (o *RouteOptions) IsOutsideCapacityRange(c *Channel) bool {
+	return (o.MinCapacity > 0 && c.Satoshis < o.MinCapacity) ||
+		(o.MaxCapacity > 0 && c.Satoshis > o.MaxCapacity)
+}
+
+// CountOutsideCapacityRange is the number of public channels that the capacity range removes from the searches
+func (g *Graph) CountOutsideCapacityRange(options *RouteOptions) int {
+	g.channelsLock.RLock()
+	defer g.channelsLock.RUnlock()
+	return g.countOutsideCapacityRange(options)
+}
+
+// countOutsideCapacityRange is the same as CountOutsideCapacityRange, the caller must hold channelsLock
+func (g *Graph) countOutsideCapacityRange(options *RouteOptions) int {
+	if options.MinCapacity == 0 && options.MaxCapacity == 0 {
+		return 0
+	}
+	count := 0
+	for _, c := range g.Channels {
+		if c.IsPublic && options.IsOutsideCapacityRange(c) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// newCapacityTestGraph has two paths from a to d: a cheap one through b over a channel of
+// smallCapacity and a whale of whaleCapacity, and an expensive one through c
+func newCapacityTestGraph(smallCapacity, whaleCapacity uint64) *Graph {
+	a, b, c, d := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	return newTestGraph(
+		newTestChannel(a, b, "1x1x1", smallCapacity, 0, 1, 40),
+		newTestChannel(b, d, "2x2x2", whaleCapacity, 0, 1, 40),
+		newTestChannel(a, c, "3x3x3", 1000000, 0, 500, 40),
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 500, 40),
+		newTestChannel(d, a, "5x5x5", 1000000, 0, 1, 40),
+	)
+}
+
+func TestMinCapacitySkipsSmallChannels(t *testing.T) {
+	a, c, d := testNodeId(1), testNodeId(3), testNodeId(4)
+	graph := newCapacityTestGraph(200000, 1000000)
+	options := NewRouteOptions()
+
+	route, err := graph.GetRoute(a, d, 10000000, map[string]bool{}, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "1x1x1", route.Hops[0].ShortChannelId)
+
+	options.MinCapacity = 500000
+	route, err = graph.GetRoute(a, d, 10000000, map[string]bool{}, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, c, route.Hops[0].Destination)
+	assert.Equal(t, 1, graph.CountOutsideCapacityRange(options))
+
+	small := graph.Channels["1x1x1/"+util.GetDirection(a, testNodeId(2))]
+	assert.Equal(t, SKIP_CAPACITY, graph.skipReason(small, 10000000, map[string]bool{}, options))
+}
+
+func TestMaxCapacitySkipsWhales(t *testing.T) {
+	a, c, d := testNodeId(1), testNodeId(3), testNodeId(4)
+	graph := newCapacityTestGraph(1000000, 50000000)
+	options := NewRouteOptions()
+
+	options.MaxCapacity = 10000000
+	route, err := graph.GetRoute(a, d, 10000000, map[string]bool{}, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, c, route.Hops[0].Destination)
+	assert.Equal(t, 1, graph.CountOutsideCapacityRange(options))
+
+	options.MaxCapacity = 0
+	assert.Equal(t, 0, graph.CountOutsideCapacityRange(options))
+	route, err = graph.GetRoute(a, d, 10000000, map[string]bool{}, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "2x2x2", route.Hops[1].ShortChannelId)
+}
@@ -11,8 +11,20 @@ type Channel struct {
 	*glightning.Channel `json:"channel"`
 	Liquidity           uint64 `json:"liquidity"`
 	Timestamp           int64  `json:"timestamp"`
-	maxHtlcMsat         uint64 `json:"-"`
-	minHtlcMsat         uint64 `json:"-"`
+	// Evidence is true when the liquidity was learned from a payment or a forward, and false when
+	// it is only the 50/50 estimate of a new channel or of an aged belief, see RouteOptions.RequireEvidence
+	Evidence bool `json:"evidence,omitempty"`
+	// Confidence is how much the liquidity can be trusted, from CONFIDENCE_ESTIMATE to CONFIDENCE_EXACT
+	Confidence float64 `json:"confidence,omitempty"`
+	// Inbound is the inbound fee charged by the destination, if it advertises one
+	Inbound     *InboundFee `json:"inbound,omitempty"`
+	maxHtlcMsat uint64      `json:"-"`
+	minHtlcMsat uint64      `json:"-"`
+	missingFees bool        `json:"-"`
+	// feeHistory are the fees advertised at the last refreshes, oldest first, see FeeVolatility
+	feeHistory []uint64 `json:"-"`
+	// parallel are the channels aggregated into this one by the pathfinding, see aggregateParallel
+	parallel []*Channel `json:"-"`
 }
 
 func NewChannel(channel *glightning.Channel, liquidity uint64, timestamp int64) *Channel {
@@ -24,19 +36,25 @@ func NewChannel(channel *glightning.Channel, liquidity uint64, timestamp int64)
 		Timestamp:   timestamp,
 		maxHtlcMsat: maxHtlcMsat,
 		minHtlcMsat: minHtlcMsat,
+		missingFees: channel.LastUpdate == 0,
 	}
 }
 
+// ComputeFee is the fee of forwarding amount through the channel. It saturates instead of overflowing,
+// a fee that large is over MAX_HOP_FEE anyway.
 func (c *Channel) ComputeFee(amount uint64) uint64 {
-	result := c.BaseFeeMillisatoshi
+	return addFee(c.BaseFeeMillisatoshi, c.computeProportionalFee(amount))
+}
+
+// computeProportionalFee is the part of the fee of amount that depends on the amount
+func (c *Channel) computeProportionalFee(amount uint64) uint64 {
 	// get the ceiling of the integer division
-	numerator := (amount / 1000) * c.FeePerMillionth
+	numerator := mulFee(amount/1000, c.FeePerMillionth)
 	var proportionalFee uint64 = 0
 	if numerator > 0 {
 		proportionalFee = ((numerator - 1) / 1000) + 1
 	}
-	result += proportionalFee
-	return result
+	return proportionalFee
 }
 
 func (c *Channel) ComputeFeePPM(amount uint64) uint64 {
@@ -60,6 +78,26 @@ func (c *Channel) GetDirection() uint8 {
 	return 1
 }
 
+// HasFeePolicy tells whether a channel_update was received for the channel: without it
+// the fees of the channel are unknown and read as zero
+func (c *Channel) HasFeePolicy() bool {
+	return !c.missingFees
+}
+
+// IsWithinHtlcBounds checks the amount against the advertised htlc minimum and maximum
+func (c *Channel) IsWithinHtlcBounds(amount uint64) bool {
+	return c.maxHtlcMsat >= amount &&
+		c.minHtlcMsat <= amount
+}
+
+// MightForward is a relaxed version of CanForward that ignores the htlc minimum:
+// if it returns false, the channel can't forward amount nor any bigger amount
+func (c *Channel) MightForward(amount uint64) bool {
+	return c.IsActive &&
+		c.Liquidity >= amount &&
+		c.maxHtlcMsat >= amount
+}
+
 func (c *Channel) CanForward(amount uint64) bool {
 	return c.IsActive &&
 		c.Liquidity >= amount &&
@@ -67,7 +105,22 @@ func (c *Channel) CanForward(amount uint64) bool {
 		c.minHtlcMsat <= amount
 }
 
+// IsAged tells whether the liquidity belief is older than refreshThreshold at the time now (unix seconds)
+func (c *Channel) IsAged(refreshThreshold time.Duration, now int64) bool {
+	return c.Timestamp+int64(refreshThreshold.Seconds()) < now
+}
+
+// AgedLiquidity is the liquidity that the channel will be believed to have at the time now (unix seconds)
+func (c *Channel) AgedLiquidity(refreshThreshold time.Duration, now int64) uint64 {
+	if c.IsAged(refreshThreshold, now) {
+		return uint64(0.5 * float64(c.Satoshis*1000))
+	}
+	return c.Liquidity
+}
+
 func (c *Channel) ResetLiquidity() {
 	c.Liquidity = uint64(0.5 * float64(c.Satoshis*1000))
 	c.Timestamp = time.Now().Unix()
+	c.Evidence = false
+	c.Confidence = CONFIDENCE_ESTIMATE
 }
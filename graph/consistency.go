@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"circular/util"
+	"fmt"
+)
+
+// CheckConsistency verifies that the adjacency list and the channels describe the same graph:
+// no scid appears twice in an edge, every scid of an edge has a channel in the right direction,
+// and every channel is in its edge. It walks the whole graph.
+func (g *Graph) CheckConsistency() error {
+	g.channelsLock.RLock()
+	g.adjacencyListLock.RLock()
+	defer g.channelsLock.RUnlock()
+	defer g.adjacencyListLock.RUnlock()
+
+	duplicates, missingChannels, missingEdges := 0, 0, 0
+	for dst, edges := range g.Inbound {
+		for src, edge := range edges {
+			seen := make(map[string]bool, len(edge))
+			for _, scid := range edge {
+				if seen[scid] {
+					duplicates++
+					continue
+				}
+				seen[scid] = true
+				c, ok := g.Channels[scid+"/"+util.GetDirection(src, dst)]
+				if !ok || c.Source != src || c.Destination != dst {
+					missingChannels++
+				}
+			}
+		}
+	}
+	for _, c := range g.Channels {
+		if !g.Inbound[c.Destination][c.Source].contains(c.ShortChannelId) {
+			missingEdges++
+		}
+	}
+	if duplicates > 0 || missingChannels > 0 || missingEdges > 0 {
+		return fmt.Errorf("%w: %d duplicate scids, %d scids without a channel, %d channels without an edge",
+			util.ErrInconsistentGraph, duplicates, missingChannels, missingEdges)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAddChannelTwice(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	ab := newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40)
+	graph := newTestGraph(ab, newTestChannel(b, a, "1x1x1", 1000000, 0, 1, 40))
+	assert.NoError(t, graph.CheckConsistency())
+
+	graph.AddChannel(ab)
+	assert.Equal(t, Edge{"1x1x1"}, graph.Inbound[b][a])
+	assert.NoError(t, graph.CheckConsistency())
+
+	// an edge broken by hand is caught, and deleting the channel removes every copy
+	graph.Inbound[b][a] = append(graph.Inbound[b][a], "1x1x1", "2x2x2")
+	assert.ErrorIs(t, graph.CheckConsistency(), util.ErrInconsistentGraph)
+	graph.DeleteChannel(ab)
+	assert.Equal(t, Edge{"2x2x2"}, graph.Inbound[b][a])
+	graph.Inbound[b][a] = Edge{}
+	assert.NoError(t, graph.CheckConsistency())
+}
@@ -0,0 +1,38 @@
+package graph
+
+import "circular/util"
+
+// DeadNodes returns the nodes that look offline from the gossip: all their peers disabled the
+// channels towards them, which is what nodes do when a peer disconnects. The channels of the dead
+// node itself might still look enabled, because it's not around to disable them.
+// Nodes that we, id, have channels with are never dead here, because we know better.
+func (g *Graph) DeadNodes(id string) map[string]bool {
+	g.channelsLock.RLock()
+	g.adjacencyListLock.RLock()
+	defer g.channelsLock.RUnlock()
+	defer g.adjacencyListLock.RUnlock()
+
+	dead := make(map[string]bool)
+	for u, edges := range g.Inbound {
+		if _, ok := edges[id]; ok || u == id {
+			continue
+		}
+		alive := false
+		for v, edge := range edges {
+			for _, scid := range edge {
+				channel, ok := g.Channels[scid+"/"+util.GetDirection(v, u)]
+				if ok && channel.IsActive {
+					alive = true
+					break
+				}
+			}
+			if alive {
+				break
+			}
+		}
+		if !alive {
+			dead[u] = true
+		}
+	}
+	return dead
+}
@@ -0,0 +1,31 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDeadNodes(t *testing.T) {
+	us, a, b, c := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	down := func(channel *Channel) *Channel {
+		channel.IsActive = false
+		return channel
+	}
+	g := newTestGraph(
+		newTestChannel(us, a, "1x1x1", 1000000, 0, 0, 10),
+		newTestChannel(a, b, "2x1x1", 1000000, 0, 0, 10),
+		// b is offline: c disabled its side of their channel, but b's side still looks enabled
+		down(newTestChannel(c, b, "3x1x1", 1000000, 0, 0, 10)),
+		newTestChannel(b, c, "3x1x1", 1000000, 0, 0, 10),
+		newTestChannel(a, c, "4x1x1", 1000000, 0, 0, 10),
+	)
+	assert.Equal(t, map[string]bool{}, g.DeadNodes(us))
+
+	down(g.Channels["2x1x1/"+util.GetDirection(a, b)])
+	assert.Equal(t, map[string]bool{b: true}, g.DeadNodes(us))
+
+	// our peers are judged by their connection to us, not by the gossip
+	down(g.Channels["1x1x1/"+util.GetDirection(us, a)])
+	assert.Equal(t, map[string]bool{b: true}, g.DeadNodes(us))
+}
@@ -0,0 +1,32 @@
+package graph
+
+const (
+	// DEFAULT_MAX_HOP_DELAY (blocks) only skips the channels with absurd delays, a week of blocks
+	DEFAULT_MAX_HOP_DELAY = 1008
+)
+
+// IsOverMaxHopDelay tells whether the delay advertised by c is more than MaxHopDelay
+func (o *RouteOptions) IsOverMaxHopDelay(c *Channel) bool {
+	return o.MaxHopDelay > 0 && c.Delay > o.MaxHopDelay
+}
+
+// CountOverMaxHopDelay is the number of public channels that MaxHopDelay removes from the searches
+func (g *Graph) CountOverMaxHopDelay(options *RouteOptions) int {
+	g.channelsLock.RLock()
+	defer g.channelsLock.RUnlock()
+	return g.countOverMaxHopDelay(options)
+}
+
+// countOverMaxHopDelay is the same as CountOverMaxHopDelay, the caller must hold channelsLock
+func (g *Graph) countOverMaxHopDelay(options *RouteOptions) int {
+	if options.MaxHopDelay == 0 {
+		return 0
+	}
+	count := 0
+	for _, c := range g.Channels {
+		if c.IsPublic && options.IsOverMaxHopDelay(c) {
+			count++
+		}
+	}
+	return count
+}
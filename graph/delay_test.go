@@ -0,0 +1,40 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMaxHopDelaySkipsGriefyChannels(t *testing.T) {
+	a, b, c, d := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	// the cheap path through b has a channel with a huge delay
+	graph := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40),
+		newTestChannel(b, d, "2x2x2", 1000000, 0, 1, 500),
+		newTestChannel(a, c, "3x3x3", 1000000, 0, 500, 40),
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 500, 144),
+		newTestChannel(d, a, "5x5x5", 1000000, 0, 1, 40),
+	)
+	options := NewRouteOptions()
+
+	// the default only skips absurd delays
+	route, err := graph.GetRoute(a, d, 10000000, map[string]bool{}, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, b, route.Hops[0].Destination)
+	assert.Equal(t, 0, graph.CountOverMaxHopDelay(options))
+
+	options.MaxHopDelay = 144
+	route, err = graph.GetRoute(a, d, 10000000, map[string]bool{}, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, c, route.Hops[0].Destination)
+	assert.Equal(t, 1, graph.CountOverMaxHopDelay(options))
+
+	griefy := graph.Channels["2x2x2/"+util.GetDirection(b, d)]
+	assert.Equal(t, SKIP_DELAY, graph.skipReason(griefy, 10000000, map[string]bool{}, options))
+	explanation := graph.Explain(a, d, 10000000, map[string]bool{}, 8, options)
+	assert.Equal(t, 1, explanation.DelayFiltered)
+
+	options.MaxHopDelay = 0
+	assert.Equal(t, 0, graph.CountOverMaxHopDelay(options))
+}
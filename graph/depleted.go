@@ -0,0 +1,58 @@
+package graph
+
+import "sort"
+
+// LiquidityRatio is the believed fraction of the capacity of the channel available in its direction
+func (c *Channel) LiquidityRatio() float64 {
+	if c.Satoshis == 0 {
+		return 0
+	}
+	return float64(c.Liquidity) / float64(c.Satoshis*1000)
+}
+
+// RefreshLiquidityCutoff computes the liquidity ratio under which there are percentile percent of the
+// public channels, and makes the searches with MinLiquidityPercentile skip the channels below it.
+// A percentile of 0 disables the cutoff.
+func (g *Graph) RefreshLiquidityCutoff(percentile int) float64 {
+	g.channelsLock.Lock()
+	defer g.channelsLock.Unlock()
+
+	cutoff := 0.0
+	if percentile > 0 {
+		ratios := make([]float64, 0, len(g.Channels))
+		for _, c := range g.Channels {
+			if c.IsPublic {
+				ratios = append(ratios, c.LiquidityRatio())
+			}
+		}
+		cutoff = liquidityCutoff(ratios, percentile)
+	}
+	if cutoff != g.liquidityCutoff {
+		g.liquidityCutoff = cutoff
+		g.version++
+	}
+	return cutoff
+}
+
+// liquidityCutoff returns the ratio under which there are percentile percent of ratios: it is the smallest
+// ratio such that skipping the ratios strictly below it skips at most percentile percent of them
+func liquidityCutoff(ratios []float64, percentile int) float64 {
+	if len(ratios) == 0 || percentile <= 0 {
+		return 0
+	}
+	if percentile > 100 {
+		percentile = 100
+	}
+	sort.Float64s(ratios)
+	i := percentile * len(ratios) / 100
+	if i >= len(ratios) {
+		// nothing is left, like skipping everything
+		return ratios[len(ratios)-1] + 1
+	}
+	return ratios[i]
+}
+
+// isDepleted tells whether c is under the liquidity cutoff. The caller must hold channelsLock.
+func (g *Graph) isDepleted(c *Channel, options *RouteOptions) bool {
+	return options.MinLiquidityPercentile > 0 && c.LiquidityRatio() < g.liquidityCutoff
+}
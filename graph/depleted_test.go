@@ -0,0 +1,52 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLiquidityCutoff(t *testing.T) {
+	ratios := []float64{0.9, 0.1, 0.5, 0.3, 0.7, 0.2, 0.4, 0.6, 0.8, 0.0}
+	assert.Equal(t, 0.0, liquidityCutoff(ratios, 0))
+	assert.Equal(t, 0.1, liquidityCutoff(ratios, 10))
+	assert.Equal(t, 0.5, liquidityCutoff(ratios, 50))
+	assert.Greater(t, liquidityCutoff(ratios, 100), 0.9)
+	assert.Equal(t, 0.0, liquidityCutoff(nil, 50))
+}
+
+func TestPathfinderSkipsDepletedChannels(t *testing.T) {
+	src, a, b, dst := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	g := newTestGraph(
+		// through a is cheaper, but a -> dst is believed to be almost empty
+		newTestChannel(src, a, "1x1x1", 1000000, 0, 0, 10),
+		newTestChannel(a, dst, "2x1x1", 10000000, 0, 100, 10),
+		newTestChannel(src, b, "3x1x1", 1000000, 0, 0, 10),
+		newTestChannel(b, dst, "4x1x1", 1000000, 0, 200, 10),
+		newTestChannel(dst, src, "5x1x1", 1000000, 0, 0, 10),
+	)
+	assert.True(t, g.SetLiquidity("2x1x1/"+util.GetDirection(a, dst), 200000000))
+	amount := uint64(100000000)
+
+	options := NewRouteOptions()
+	route, err := g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, a, route.Hops[0].Destination)
+
+	// the cutoff is only used by the searches that ask for it
+	assert.Equal(t, 0.5, g.RefreshLiquidityCutoff(20))
+	route, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, a, route.Hops[0].Destination)
+
+	options.MinLiquidityPercentile = 20
+	route, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, b, route.Hops[0].Destination)
+
+	// refreshing it with a percentile of 0 removes it
+	assert.Equal(t, 0.0, g.RefreshLiquidityCutoff(0))
+	route, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, a, route.Hops[0].Destination)
+}
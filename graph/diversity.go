@@ -0,0 +1,16 @@
+package graph
+
+// Overlap is the fraction of the hops of r that go through the channels in used (short channel ids),
+// 0 for a route without hops
+func (r *Route) Overlap(used map[string]bool) float64 {
+	if len(r.Hops) == 0 {
+		return 0
+	}
+	shared := 0
+	for _, hop := range r.Hops {
+		if used[hop.ShortChannelId] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(r.Hops))
+}
@@ -0,0 +1,44 @@
+package graph
+
+const (
+	// DEFAULT_UNEVIDENCED_LIQUIDITY is the percent of the capacity trusted without evidence with RequireEvidence
+	DEFAULT_UNEVIDENCED_LIQUIDITY = 10
+)
+
+// LacksEvidence tells whether c can carry amount only thanks to the estimate of its liquidity.
+// It is always false without RequireEvidence.
+func (o *RouteOptions) LacksEvidence(c *Channel, amount uint64) bool {
+	if !o.RequireEvidence || c.Evidence {
+		return false
+	}
+	return amount > c.Satoshis*1000*o.UnevidencedLiquidity/100
+}
+
+// EvidenceCoverage returns how many public channels have a liquidity backed by evidence, out of all of them
+func (g *Graph) EvidenceCoverage() (int, int) {
+	g.channelsLock.RLock()
+	defer g.channelsLock.RUnlock()
+
+	evidenced, public := 0, 0
+	for _, c := range g.Channels {
+		if !c.IsPublic {
+			continue
+		}
+		public++
+		if c.Evidence {
+			evidenced++
+		}
+	}
+	return evidenced, public
+}
+
+// CountUnevidenced is the number of hops of the route whose liquidity is not backed by evidence
+func (r *Route) CountUnevidenced() int {
+	count := 0
+	for _, hop := range r.Hops {
+		if !hop.Evidence {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRequireEvidenceRejectsAgedBeliefs(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	graph := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40),
+		newTestChannel(b, c, "2x2x2", 1000000, 0, 1, 40),
+		newTestChannel(c, a, "3x3x3", 1000000, 0, 1, 40),
+	)
+	options := NewRouteOptions()
+	// 400k sats only fit in the channels because aging believes them to be 50/50
+	amount := uint64(400000000)
+
+	route, err := graph.GetRoute(a, c, amount, map[string]bool{}, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(route.Hops))
+
+	options.RequireEvidence = true
+	_, err = graph.GetRoute(a, c, amount, map[string]bool{}, 8, options)
+	assert.Equal(t, util.ErrNoRoute, err)
+	bc := graph.Channels["2x2x2/"+util.GetDirection(b, c)]
+	assert.Equal(t, SKIP_NO_EVIDENCE, graph.skipReason(bc, amount, map[string]bool{}, options))
+
+	// small amounts are still trusted without evidence
+	_, err = graph.GetRoute(a, c, 50000000, map[string]bool{}, 8, options)
+	assert.NoError(t, err)
+
+	// a payment that went through a-b-c is evidence
+	graph.UpdateChannel("1x1x1/"+util.GetDirection(a, b), "1x1x1/"+util.GetDirection(b, a), 600000000)
+	graph.UpdateChannel("2x2x2/"+util.GetDirection(b, c), "2x2x2/"+util.GetDirection(c, b), 600000000)
+	assert.True(t, bc.Evidence)
+	route, err = graph.GetRoute(a, c, amount, map[string]bool{}, 8, options)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, len(route.Hops))
+	}
+
+	// once the belief ages, the evidence is gone
+	bc.ResetLiquidity()
+	assert.False(t, bc.Evidence)
+	_, err = graph.GetRoute(a, c, amount, map[string]bool{}, 8, options)
+	assert.Equal(t, util.ErrNoRoute, err)
+}
@@ -0,0 +1,197 @@
+package graph
+
+import (
+	"circular/util"
+	"sort"
+)
+
+const (
+	EXPLAIN_SAMPLE_SIZE = 10 // skipped channels reported next to the source, and as many next to the destination
+
+	SKIP_NONE        = "usable"
+	SKIP_EXCLUDED    = "excluded"
+	SKIP_PRIVATE     = "private"
+	SKIP_CAPACITY    = "capacity"
+	SKIP_DELAY       = "delay"
+	SKIP_LOCAL       = "local"
+	SKIP_NO_FEES     = "no-fee-policy"
+	SKIP_DISABLED    = "disabled"
+	SKIP_HTLC_BOUNDS = "htlc-bounds"
+	SKIP_LIQUIDITY   = "liquidity"
+	SKIP_DEPLETED    = "depleted"
+	SKIP_UNLIKELY    = "probability"
+	SKIP_NO_EVIDENCE = "no-evidence"
+
+	VERDICT_DISCONNECTED   = "disconnected"
+	VERDICT_EXCLUDED       = "excluded"
+	VERDICT_TOO_MANY_HOPS  = "too-many-hops"
+	VERDICT_AMOUNT_TOO_BIG = "amount-too-big"
+	VERDICT_INCONCLUSIVE   = "inconclusive"
+)
+
+type SkippedChannel struct {
+	Id     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// Explanation tells why no route was found between two nodes
+type Explanation struct {
+	Verdict string `json:"verdict"`
+	Message string `json:"message"`
+	// SourceChannels and DestinationChannels count the channels leaving the source and
+	// reaching the destination by the reason they can't be used, SKIP_NONE if they can
+	SourceChannels      map[string]int    `json:"source_channels"`
+	DestinationChannels map[string]int    `json:"destination_channels"`
+	Skipped             []*SkippedChannel `json:"skipped"`
+	// CapacityFiltered is the number of public channels of the graph outside the capacity range
+	CapacityFiltered int `json:"capacity_filtered,omitempty"`
+	// DelayFiltered is the number of public channels of the graph with a delay over the maximum
+	DelayFiltered int `json:"delay_filtered,omitempty"`
+}
+
+// Explain tells why GetRoute, called with the same parameters, didn't find a route.
+// It checks the channels next to src and dst the same way dijkstra does, and then finds which
+// of the constraints disconnects src from dst: the exclusions, maxHops or the amount.
+// It walks the whole graph a few times, so it's meant for troubleshooting, not for every search.
+func (g *Graph) Explain(src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) *Explanation {
+	if options == nil {
+		options = NewRouteOptions()
+	}
+	g.channelsLock.RLock()
+	g.adjacencyListLock.RLock()
+	defer g.channelsLock.RUnlock()
+	defer g.adjacencyListLock.RUnlock()
+
+	explanation := &Explanation{
+		SourceChannels:      make(map[string]int),
+		DestinationChannels: make(map[string]int),
+		Skipped:             make([]*SkippedChannel, 0),
+		CapacityFiltered:    g.countOutsideCapacityRange(options),
+		DelayFiltered:       g.countOverMaxHopDelay(options),
+	}
+
+	channelIds := make([]string, 0)
+	for channelId, c := range g.Channels {
+		if c.Source == src || c.Destination == dst {
+			channelIds = append(channelIds, channelId)
+		}
+	}
+	sort.Strings(channelIds)
+	sampledSrc, sampledDst := 0, 0
+	for _, channelId := range channelIds {
+		c := g.Channels[channelId]
+		reason := g.skipReason(c, amount, exclude, options)
+		sampled := false
+		if c.Source == src {
+			explanation.SourceChannels[reason]++
+			if reason != SKIP_NONE && sampledSrc < EXPLAIN_SAMPLE_SIZE {
+				sampledSrc++
+				sampled = true
+			}
+		}
+		if c.Destination == dst {
+			explanation.DestinationChannels[reason]++
+			if reason != SKIP_NONE && sampledDst < EXPLAIN_SAMPLE_SIZE {
+				sampledDst++
+				sampled = true
+			}
+		}
+		if sampled {
+			explanation.Skipped = append(explanation.Skipped, &SkippedChannel{Id: channelId, Reason: reason})
+		}
+	}
+
+	// relax one constraint at a time, the first one that disconnects src from dst is the culprit
+	usable := func(c *Channel) bool {
+		return c.IsPublic && c.IsActive
+	}
+	notExcluded := func(c *Channel) bool {
+		return usable(c) && !exclude[c.Source]
+	}
+	canForward := func(c *Channel) bool {
+		return g.skipReason(c, amount, exclude, options) == SKIP_NONE
+	}
+	limit := maxHops - 2 // like GetRoute, src and dst are not counted
+	switch {
+	case !g.reaches(src, dst, usable, -1):
+		explanation.Verdict = VERDICT_DISCONNECTED
+		explanation.Message = "there is no path of public and enabled channels between the two nodes"
+	case !g.reaches(src, dst, notExcluded, -1):
+		explanation.Verdict = VERDICT_EXCLUDED
+		explanation.Message = "every path between the two nodes goes through an excluded node"
+	case !g.reaches(src, dst, notExcluded, limit):
+		explanation.Verdict = VERDICT_TOO_MANY_HOPS
+		explanation.Message = "every path between the two nodes is longer than the maximum number of hops"
+	case !g.reaches(src, dst, canForward, limit):
+		explanation.Verdict = VERDICT_AMOUNT_TOO_BIG
+		explanation.Message = "no path short enough can carry the amount, try a smaller one"
+	default:
+		explanation.Verdict = VERDICT_INCONCLUSIVE
+		explanation.Message = "a path can carry the amount, but not the fees that add up along it"
+	}
+	return explanation
+}
+
+// skipReason is why dijkstra would skip c when it has to carry amount, SKIP_NONE if it wouldn't.
+// The caller must hold channelsLock.
+func (g *Graph) skipReason(c *Channel, amount uint64, exclude map[string]bool, options *RouteOptions) string {
+	switch {
+	case exclude[c.Source] || options.ExcludedChannels[c.ShortChannelId]:
+		return SKIP_EXCLUDED
+	case !c.IsPublic:
+		return SKIP_PRIVATE
+	case options.IsOutsideCapacityRange(c):
+		return SKIP_CAPACITY
+	case options.IsOverMaxHopDelay(c):
+		return SKIP_DELAY
+	case !c.HasFeePolicy() && options.MissingFeesPenalty == 0:
+		return SKIP_NO_FEES
+	case options.AvoidLocalChannels && (c.Source == options.LocalNode || c.Destination == options.LocalNode):
+		return SKIP_LOCAL
+	case !c.IsActive:
+		return SKIP_DISABLED
+	case !c.IsWithinHtlcBounds(amount):
+		return SKIP_HTLC_BOUNDS
+	case c.Liquidity < amount:
+		return SKIP_LIQUIDITY
+	case g.isDepleted(c, options):
+		return SKIP_DEPLETED
+	case options.MinProbability > 0 && c.SuccessProbability(amount) < options.MinProbability:
+		return SKIP_UNLIKELY
+	case options.LacksEvidence(c, amount):
+		return SKIP_NO_EVIDENCE
+	}
+	return SKIP_NONE
+}
+
+// reaches tells whether src can reach dst through the channels accepted by usable, with at most
+// limit hops (no limit if negative). Like dijkstra, it walks backwards from dst.
+// The caller must hold the locks of the graph.
+func (g *Graph) reaches(src, dst string, usable func(*Channel) bool, limit int) bool {
+	depth := map[string]int{dst: 0}
+	queue := []string{dst}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		if u == src {
+			return true
+		}
+		if limit >= 0 && depth[u] >= limit {
+			continue
+		}
+		for v, edge := range g.Inbound[u] {
+			if _, ok := depth[v]; ok {
+				continue
+			}
+			for _, scid := range edge {
+				channel, ok := g.Channels[scid+"/"+util.GetDirection(v, u)]
+				if ok && usable(channel) {
+					depth[v] = depth[u] + 1
+					queue = append(queue, v)
+					break
+				}
+			}
+		}
+	}
+	return false
+}
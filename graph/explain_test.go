@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	src, a, b, dst, lonely := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4), testNodeId(5)
+	g := newTestGraph(
+		newTestChannel(src, a, "1x1x1", 1000000, 0, 0, 10),
+		newTestChannel(a, b, "2x1x1", 1000000, 0, 0, 10),
+		newTestChannel(b, dst, "3x1x1", 100000, 0, 0, 10),
+		newTestChannel(lonely, src, "4x1x1", 1000000, 0, 0, 10),
+	)
+	amount := uint64(100000000)
+	exclude := map[string]bool{}
+
+	// b->dst only has 50000 sats of liquidity
+	_, err := g.GetRoute(src, dst, amount, exclude, 5, nil)
+	assert.Error(t, err)
+	explanation := g.Explain(src, dst, amount, exclude, 5, nil)
+	assert.Equal(t, VERDICT_AMOUNT_TOO_BIG, explanation.Verdict)
+	assert.Equal(t, map[string]int{SKIP_NONE: 1}, explanation.SourceChannels)
+	assert.Equal(t, map[string]int{SKIP_LIQUIDITY: 1}, explanation.DestinationChannels)
+	assert.Equal(t, []*SkippedChannel{{Id: "3x1x1/" + util.GetDirection(b, dst), Reason: SKIP_LIQUIDITY}}, explanation.Skipped)
+
+	assert.Equal(t, VERDICT_TOO_MANY_HOPS, g.Explain(src, dst, amount/10, exclude, 4, nil).Verdict)
+	assert.Equal(t, VERDICT_EXCLUDED, g.Explain(src, dst, amount/10, map[string]bool{a: true}, 5, nil).Verdict)
+	assert.Equal(t, VERDICT_DISCONNECTED, g.Explain(dst, src, amount/10, exclude, 5, nil).Verdict)
+}
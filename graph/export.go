@@ -0,0 +1,114 @@
+package graph
+
+import (
+	"encoding/csv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LIQUIDITY_CSV_HEADER is the stable set of columns of the liquidity export.
+// New columns must only be appended, so that existing scripts keep working.
+var LIQUIDITY_CSV_HEADER = []string{
+	"scid",
+	"direction",
+	"source",
+	"source_alias",
+	"destination",
+	"destination_alias",
+	"capacity_sat",
+	"local_msat",
+	"remote_msat",
+}
+
+// LiquidityRow is the believed liquidity of a channel, seen from its source
+type LiquidityRow struct {
+	ShortChannelId   string `json:"scid"`
+	Direction        uint8  `json:"direction"`
+	Source           string `json:"source"`
+	SourceAlias      string `json:"source_alias"`
+	Destination      string `json:"destination"`
+	DestinationAlias string `json:"destination_alias"`
+	Capacity         uint64 `json:"capacity_sat"`
+	Local            uint64 `json:"local_msat"`
+	Remote           uint64 `json:"remote_msat"`
+}
+
+func (r *LiquidityRow) toRecord() []string {
+	return []string{
+		r.ShortChannelId,
+		strconv.Itoa(int(r.Direction)),
+		r.Source,
+		r.SourceAlias,
+		r.Destination,
+		r.DestinationAlias,
+		strconv.FormatUint(r.Capacity, 10),
+		strconv.FormatUint(r.Local, 10),
+		strconv.FormatUint(r.Remote, 10),
+	}
+}
+
+// ExportLiquidity returns the believed liquidity of the channels of id, sorted by scid.
+// If neighbors is true, the channels of its peers are included as well.
+func (g *Graph) ExportLiquidity(id string, neighbors bool) []LiquidityRow {
+	g.channelsLock.RLock()
+	peers := make(map[string]bool)
+	for _, c := range g.Channels {
+		if c.Source == id {
+			peers[c.Destination] = true
+		}
+	}
+
+	rows := make([]LiquidityRow, 0)
+	for _, c := range g.Channels {
+		if c.Source != id && !(neighbors && peers[c.Source]) {
+			continue
+		}
+		local := c.Liquidity
+		if local > c.Satoshis*1000 {
+			local = c.Satoshis * 1000
+		}
+		rows = append(rows, LiquidityRow{
+			ShortChannelId: c.ShortChannelId,
+			Direction:      c.GetDirection(),
+			Source:         c.Source,
+			Destination:    c.Destination,
+			Capacity:       c.Satoshis,
+			Local:          local,
+			Remote:         c.Satoshis*1000 - local,
+		})
+	}
+	g.channelsLock.RUnlock()
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].ShortChannelId != rows[j].ShortChannelId {
+			return rows[i].ShortChannelId < rows[j].ShortChannelId
+		}
+		return rows[i].Direction < rows[j].Direction
+	})
+
+	for i := range rows {
+		rows[i].SourceAlias = g.GetAlias(rows[i].Source)
+		rows[i].DestinationAlias = g.GetAlias(rows[i].Destination)
+	}
+	return rows
+}
+
+// LiquidityToCSV formats the rows as CSV, header included
+func LiquidityToCSV(rows []LiquidityRow) (string, error) {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	if err := w.Write(LIQUIDITY_CSV_HEADER); err != nil {
+		return "", err
+	}
+	for _, row := range rows {
+		if err := w.Write(row.toRecord()); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
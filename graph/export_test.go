@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestExportLiquidity(t *testing.T) {
+	self, a, b := testNodeId(0), testNodeId(1), testNodeId(2)
+	graph := newTestGraph(
+		newTestChannel(self, a, "1x1x1", 1000000, 0, 1, 40),
+		newTestChannel(a, self, "1x1x1", 1000000, 0, 1, 40),
+		newTestChannel(a, b, "2x2x2", 2000000, 0, 1, 40),
+		newTestChannel(b, a, "2x2x2", 2000000, 0, 1, 40),
+	)
+	graph.Aliases[a] = "alice"
+
+	rows := graph.ExportLiquidity(self, false)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "1x1x1", rows[0].ShortChannelId)
+	assert.Equal(t, "alice", rows[0].DestinationAlias)
+	assert.Equal(t, rows[0].Capacity*1000, rows[0].Local+rows[0].Remote)
+
+	// one hop out includes the channels of the peers
+	rows = graph.ExportLiquidity(self, true)
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, "2x2x2", rows[2].ShortChannelId)
+	assert.Equal(t, a, rows[2].Source)
+
+	csv, err := LiquidityToCSV(rows)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(csv), "\n")
+	assert.Equal(t, 4, len(lines))
+	assert.Equal(t, strings.Join(LIQUIDITY_CSV_HEADER, ","), lines[0])
+}
@@ -0,0 +1,156 @@
+package graph
+
+import (
+	"reflect"
+	"sync"
+)
+
+type favoriteKey struct {
+	src string
+	dst string
+}
+
+// favoriteRoutes are the cheapest routes found in advance towards the favorite peers, see WarmFavoriteRoutes.
+// Unlike the route cache they survive the changes of liquidity, which are checked when a route is used,
+// so they are only valid until ClearFavoriteRoutes, when the graph is refreshed.
+type favoriteRoutes struct {
+	lock    *sync.RWMutex
+	options *RouteOptions
+	scids   map[favoriteKey][]string
+}
+
+func newFavoriteRoutes() *favoriteRoutes {
+	return &favoriteRoutes{
+		lock:  &sync.RWMutex{},
+		scids: make(map[favoriteKey][]string),
+	}
+}
+
+// WarmFavoriteRoutes searches the cheapest route for amount from every source to every favorite and keeps
+// the ones found, replacing the previous ones. They are only used for searches with the same options.
+// It returns the number of routes kept.
+func (g *Graph) WarmFavoriteRoutes(sources, favorites []string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) int {
+	searchOptions := options.clone()
+	searchOptions.CacheRoutes = false
+	scids := make(map[favoriteKey][]string)
+	for _, dst := range favorites {
+		for _, src := range sources {
+			if src == dst {
+				continue
+			}
+			route, err := g.GetRoute(src, dst, amount, exclude, maxHops, searchOptions)
+			if err != nil {
+				continue
+			}
+			scids[favoriteKey{src, dst}] = route.Scids()
+		}
+	}
+
+	g.favorites.lock.Lock()
+	defer g.favorites.lock.Unlock()
+	g.favorites.options = searchOptions
+	g.favorites.scids = scids
+	return len(scids)
+}
+
+// ClearFavoriteRoutes drops the routes found by WarmFavoriteRoutes
+func (g *Graph) ClearFavoriteRoutes() {
+	g.favorites.lock.Lock()
+	defer g.favorites.lock.Unlock()
+	g.favorites.scids = make(map[favoriteKey][]string)
+}
+
+// FavoriteRoutes is the number of routes found by WarmFavoriteRoutes that are still valid
+func (g *Graph) FavoriteRoutes() int {
+	g.favorites.lock.RLock()
+	defer g.favorites.lock.RUnlock()
+	return len(g.favorites.scids)
+}
+
+// GetFavoriteRoute returns the route kept from src to dst for amount, if there is one that was found with
+// the same options, is within maxHops, and whose channels dijkstra would still use for amount.
+// Otherwise a fresh search is needed.
+func (g *Graph) GetFavoriteRoute(src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) (*Route, bool) {
+	g.favorites.lock.RLock()
+	scids, ok := g.favorites.scids[favoriteKey{src, dst}]
+	ok = ok && sameSearch(g.favorites.options, options)
+	g.favorites.lock.RUnlock()
+	// -2 because the source and the destination are already known, like in getRoute
+	if !ok || len(scids) > maxHops-2 {
+		return nil, false
+	}
+
+	hops := make([]RouteHop, 0, len(scids))
+	from := src
+	for _, scid := range scids {
+		channel := g.getChannelFrom(scid, from)
+		if channel == nil || (channel.Destination != dst && exclude[channel.Destination]) {
+			return nil, false
+		}
+		hops = append(hops, RouteHop{Channel: channel})
+		from = channel.Destination
+	}
+	if from != dst {
+		return nil, false
+	}
+
+	g.channelsLock.RLock()
+	costed, ok := costHops(hops, amount, options.InboundFees)
+	g.channelsLock.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	route := NewRoute(src, dst, amount, costed, g)
+	if !g.usableHops(route.Hops, src, exclude, options) {
+		return nil, false
+	}
+	route.InboundFees = options.InboundFees
+	route.Probability = route.computeProbability()
+	if route.Probability < options.MinProbability {
+		return nil, false
+	}
+	return route, true
+}
+
+// usableHops tells whether dijkstra would still use every channel of hops for the amount it forwards,
+// see evaluateEdge and skipReason
+func (g *Graph) usableHops(hops []RouteHop, src string, exclude map[string]bool, options *RouteOptions) bool {
+	g.channelsLock.RLock()
+	defer g.channelsLock.RUnlock()
+	if options.Allowlist {
+		g.allowlist.lock.RLock()
+		defer g.allowlist.lock.RUnlock()
+	}
+
+	for _, hop := range hops {
+		if g.skipReason(hop.Channel, hop.MilliSatoshi, exclude, options) != SKIP_NONE {
+			return false
+		}
+		if options.Allowlist && hop.Source != src && !g.allowlist.nodes[hop.Source] {
+			return false
+		}
+		if hop.ComputeFee(hop.MilliSatoshi) > MAX_HOP_FEE {
+			return false
+		}
+	}
+	return true
+}
+
+// sameSearch tells whether a search with options a finds the same routes as one with options b:
+// the options that only change how the search runs, and the penalty of the recent channels
+// when it's disabled, are left out
+func sameSearch(a, b *RouteOptions) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	normalize := func(o RouteOptions) RouteOptions {
+		o.CacheRoutes = false
+		o.AmountGranularity = 0
+		o.SearchWorkers = 0
+		if o.RecentRoutePenalty == 0 {
+			o.RecentChannels = nil
+		}
+		return o
+	}
+	return reflect.DeepEqual(normalize(*a), normalize(*b))
+}
@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFavoriteRouteHitAndInvalidation(t *testing.T) {
+	peer, cheap, expensive, favorite := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	g := newTestGraph(
+		newTestChannel(peer, cheap, "1x1x1", 1000000, 0, 10, 40),
+		newTestChannel(cheap, favorite, "2x2x2", 1000000, 0, 10, 40),
+		newTestChannel(peer, expensive, "3x3x3", 1000000, 0, 500, 40),
+		newTestChannel(expensive, favorite, "4x4x4", 1000000, 0, 500, 40),
+		newTestChannel(favorite, peer, "5x5x5", 1000000, 0, 10, 40),
+	)
+	options := NewRouteOptions()
+	amount := uint64(100000000)
+
+	assert.Equal(t, 1, g.WarmFavoriteRoutes([]string{peer}, []string{favorite}, amount, nil, MAX_ROUTE_LENGTH, options))
+
+	// a hit is the route that a fresh search would find, costed for the amount asked
+	route, ok := g.GetFavoriteRoute(peer, favorite, 2*amount, nil, 8, options)
+	assert.True(t, ok)
+	fresh, err := g.GetRoute(peer, favorite, 2*amount, nil, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, fresh.Scids(), route.Scids())
+	assert.Equal(t, fresh.Fee(), route.Fee())
+	assert.Equal(t, 2*amount, route.Amount)
+
+	// a route that doesn't fit the search is a miss
+	_, ok = g.GetFavoriteRoute(cheap, favorite, amount, nil, 8, options)
+	assert.False(t, ok, "not a favorite route")
+	_, ok = g.GetFavoriteRoute(peer, favorite, amount, nil, 3, options)
+	assert.False(t, ok, "too long")
+	_, ok = g.GetFavoriteRoute(peer, favorite, amount, map[string]bool{cheap: true}, 8, options)
+	assert.False(t, ok, "excluded node")
+	other := NewRouteOptions()
+	other.MinCapacity = 2000000
+	_, ok = g.GetFavoriteRoute(peer, favorite, amount, nil, 8, other)
+	assert.False(t, ok, "other options")
+	excluded := NewRouteOptions()
+	excluded.ExcludedChannels = map[string]bool{"2x2x2": true}
+	_, ok = g.GetFavoriteRoute(peer, favorite, amount, nil, 8, excluded)
+	assert.False(t, ok, "excluded channel")
+	g.Channels["2x2x2/"+util.GetDirection(cheap, favorite)].Liquidity = 0
+	_, ok = g.GetFavoriteRoute(peer, favorite, amount, nil, 8, options)
+	assert.False(t, ok, "depleted channel")
+	g.Channels["2x2x2/"+util.GetDirection(cheap, favorite)].Liquidity = 500000000
+
+	// the options are compared by value, and the same filters as a search apply to the channels
+	copied := *options
+	copied.CacheRoutes = true
+	_, ok = g.GetFavoriteRoute(peer, favorite, amount, nil, 8, &copied)
+	assert.True(t, ok, "same options")
+	g.SetAllowlist([]string{expensive})
+	copied.Allowlist = true
+	assert.Equal(t, 1, g.WarmFavoriteRoutes([]string{peer}, []string{favorite}, amount, nil, MAX_ROUTE_LENGTH, &copied))
+	g.SetAllowlist([]string{})
+	_, ok = g.GetFavoriteRoute(peer, favorite, amount, nil, 8, &copied)
+	assert.False(t, ok, "node out of the allowlist")
+
+	// the graph refresh drops them
+	g.ClearFavoriteRoutes()
+	assert.Equal(t, 0, g.FavoriteRoutes())
+	_, ok = g.GetFavoriteRoute(peer, favorite, amount, nil, 8, options)
+	assert.False(t, ok)
+}
@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"circular/util"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	// MAX_HOP_FEE (msat) is the highest fee of a single hop that lightningd can represent: its fees are 32 bits wide
+	MAX_HOP_FEE = math.MaxUint32
+)
+
+// mulFee multiplies an amount by a fee rate, saturating at math.MaxUint64 instead of wrapping around
+func mulFee(amount, rate uint64) uint64 {
+	hi, lo := bits.Mul64(amount, rate)
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	return lo
+}
+
+// addFee adds two fees, saturating at math.MaxUint64 instead of wrapping around
+func addFee(a, b uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return math.MaxUint64
+	}
+	return sum
+}
+
+// addCost is the distance of a node reached from a node at distance through a channel costing cost,
+// with an inbound fee that can be negative. It saturates at math.MaxInt64, the distance of the unreachable nodes.
+func addCost(distance int64, cost uint64, inboundFee int64) int64 {
+	if cost > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	sum := distance + inboundFee
+	if sum > math.MaxInt64-int64(cost) {
+		return math.MaxInt64
+	}
+	return sum + int64(cost)
+}
+
+// CheckFeeBounds returns an error if the fee charged by a node of the route, local legs included,
+// is more than lightningd can represent, see MAX_HOP_FEE
+func (r *Route) CheckFeeBounds() error {
+	for i := 0; i < len(r.Hops)-1; i++ {
+		carried, forwarded := r.Hops[i].MilliSatoshi, r.Hops[i+1].MilliSatoshi
+		if carried < forwarded || carried-forwarded > MAX_HOP_FEE {
+			return fmt.Errorf("%w: %s charges more than %d msat to forward %d msat through %s", util.ErrFeeTooLarge,
+				r.Graph.GetAlias(r.Hops[i].Destination), uint64(MAX_HOP_FEE), forwarded, r.Hops[i+1].ShortChannelId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestFeeArithmeticSaturates(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	c := newTestChannel(a, b, "1x1x1", 1000000, math.MaxUint32, math.MaxUint32, 40)
+	assert.Greater(t, c.ComputeFee(math.MaxUint64), uint64(MAX_HOP_FEE))
+	assert.Equal(t, uint64(math.MaxUint64), addFee(math.MaxUint64, 1))
+	assert.Equal(t, int64(math.MaxInt64), addCost(math.MaxInt64-1, 2, 0))
+	assert.Equal(t, int64(math.MaxInt64), addCost(0, math.MaxUint64, 0))
+	assert.Equal(t, int64(5), addCost(10, 5, -10))
+}
+
+func TestRouteFeesAroundTheBoundary(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	// 2.5e12 msat at 1000ppm is 2.5e9 msat per hop: more than 2^31 msat in total, less than MAX_HOP_FEE per hop
+	amount := uint64(2500000000000)
+	g := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 10000000000, 0, 1000, 40),
+		newTestChannel(b, c, "2x2x2", 10000000000, 0, 1000, 40),
+		newTestChannel(c, a, "3x3x3", 10000000000, 0, 1000, 40),
+	)
+	options := NewRouteOptions()
+	options.CacheRoutes = false
+	route, err := g.GetRoute(a, c, amount, nil, 4, options)
+	assert.NoError(t, err)
+	assert.Greater(t, route.Fee(), uint64(math.MaxInt32))
+	assert.NoError(t, route.CheckFeeBounds())
+
+	// at 2000ppm a single hop charges 5e9 msat, which lightningd can't represent
+	g.Channels["2x2x2/"+util.GetDirection(b, c)].FeePerMillionth = 2000
+	_, err = g.GetRoute(a, c, amount, nil, 4, options)
+	assert.Equal(t, util.ErrNoRoute, err)
+
+	// the local legs are not searched, they are checked on the route
+	route.Hops[0].MilliSatoshi = route.Hops[1].MilliSatoshi + MAX_HOP_FEE + 1
+	assert.ErrorIs(t, route.CheckFeeBounds(), util.ErrFeeTooLarge)
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	FEE_STATS_CACHE_TTL = time.Minute
+)
+
+type Percentiles struct {
+	P10 uint64 `json:"p10"`
+	P50 uint64 `json:"p50"`
+	P90 uint64 `json:"p90"`
+}
+
+// FeeStats describes the fees of the public channels of the graph for an amount (msat).
+// Channels without a fee policy are left out, their fees are unknown.
+type FeeStats struct {
+	Amount      uint64      `json:"amount_msat"`
+	Channels    int         `json:"channels"`
+	FeePPM      Percentiles `json:"fee_ppm"`
+	BaseFee     Percentiles `json:"base_fee_msat"`
+	ZeroBaseFee int         `json:"zero_base_fee_channels"`
+	// the usable channels are the ones believed to be able to forward the amount right now
+	UsableChannels int         `json:"usable_channels"`
+	UsableFeePPM   Percentiles `json:"usable_fee_ppm"`
+	ComputedAt     int64       `json:"computed_at"`
+}
+
+// feeStatsCache keeps the fee stats by amount for FEE_STATS_CACHE_TTL
+type feeStatsCache struct {
+	lock  *sync.Mutex
+	stats map[uint64]*FeeStats
+}
+
+func newFeeStatsCache() *feeStatsCache {
+	return &feeStatsCache{
+		lock:  &sync.Mutex{},
+		stats: make(map[uint64]*FeeStats),
+	}
+}
+
+func (c *feeStatsCache) get(amount uint64, now time.Time) (*FeeStats, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats, ok := c.stats[amount]
+	if !ok || now.Sub(time.Unix(stats.ComputedAt, 0)) >= FEE_STATS_CACHE_TTL {
+		return nil, false
+	}
+	return stats, true
+}
+
+func (c *feeStatsCache) put(stats *FeeStats, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for amount, s := range c.stats {
+		if now.Sub(time.Unix(s.ComputedAt, 0)) >= FEE_STATS_CACHE_TTL {
+			delete(c.stats, amount)
+		}
+	}
+	c.stats[stats.Amount] = stats
+}
+
+// percentiles sorts values and returns their 10th, 50th and 90th percentiles (nearest rank)
+func percentiles(values []uint64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i] < values[j]
+	})
+	rank := func(p int) uint64 {
+		i := (p*len(values)+99)/100 - 1
+		if i < 0 {
+			i = 0
+		}
+		return values[i]
+	}
+	return Percentiles{P10: rank(10), P50: rank(50), P90: rank(90)}
+}
+
+// FeeStats computes the fee percentiles of the graph for amount (msat), with the same fees used by routing.
+// The result is cached for FEE_STATS_CACHE_TTL.
+func (g *Graph) FeeStats(amount uint64) *FeeStats {
+	now := time.Now()
+	if stats, ok := g.feeStats.get(amount, now); ok {
+		return stats
+	}
+
+	// take a snapshot of the fees so that sorting doesn't hold the lock
+	g.channelsLock.RLock()
+	feePPMs := make([]uint64, 0, len(g.Channels))
+	baseFees := make([]uint64, 0, len(g.Channels))
+	usableFeePPMs := make([]uint64, 0)
+	for _, c := range g.Channels {
+		if !c.IsPublic || !c.HasFeePolicy() {
+			continue
+		}
+		feePPM := c.ComputeFeePPM(amount)
+		feePPMs = append(feePPMs, feePPM)
+		baseFees = append(baseFees, c.BaseFeeMillisatoshi)
+		if c.CanForward(amount) {
+			usableFeePPMs = append(usableFeePPMs, feePPM)
+		}
+	}
+	g.channelsLock.RUnlock()
+
+	stats := &FeeStats{
+		Amount:         amount,
+		Channels:       len(feePPMs),
+		FeePPM:         percentiles(feePPMs),
+		BaseFee:        percentiles(baseFees),
+		UsableChannels: len(usableFeePPMs),
+		UsableFeePPM:   percentiles(usableFeePPMs),
+		ComputedAt:     now.Unix(),
+	}
+	for _, baseFee := range baseFees {
+		if baseFee == 0 {
+			stats.ZeroBaseFee++
+		}
+	}
+	g.feeStats.put(stats, now)
+	return stats
+}
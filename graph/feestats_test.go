@@ -0,0 +1,39 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPercentiles(t *testing.T) {
+	assert.Equal(t, Percentiles{}, percentiles(nil))
+	assert.Equal(t, Percentiles{P10: 7, P50: 7, P90: 7}, percentiles([]uint64{7}))
+	values := []uint64{100, 90, 80, 70, 60, 50, 40, 30, 20, 10}
+	assert.Equal(t, Percentiles{P10: 10, P50: 50, P90: 90}, percentiles(values))
+}
+
+func TestGraphFeeStats(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	unknown := newTestChannel(c, a, "4x1x1", 1000000, 0, 0, 10)
+	unknown.LastUpdate = 0
+	g := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 1000, 100, 10),
+		newTestChannel(b, c, "2x1x1", 1000000, 0, 500, 10),
+		// too small for the amount
+		newTestChannel(c, b, "3x1x1", 100000, 1, 2000, 10),
+		unknown,
+	)
+	amount := uint64(100000000)
+
+	stats := g.FeeStats(amount)
+	assert.Equal(t, 3, stats.Channels)
+	assert.Equal(t, 2, stats.UsableChannels)
+	// the ppm includes the base fee, like ComputeFee
+	assert.Equal(t, Percentiles{P10: 110, P50: 500, P90: 2000}, stats.FeePPM)
+	assert.Equal(t, Percentiles{P10: 110, P50: 110, P90: 500}, stats.UsableFeePPM)
+	assert.Equal(t, Percentiles{P10: 0, P50: 1, P90: 1000}, stats.BaseFee)
+	assert.Equal(t, 1, stats.ZeroBaseFee)
+
+	// cached until FEE_STATS_CACHE_TTL
+	assert.Same(t, stats, g.FeeStats(amount))
+}
@@ -0,0 +1,18 @@
+package graph
+
+const (
+	// DEFAULT_FEE_WEIGHT ranks routes by the fees they pay
+	DEFAULT_FEE_WEIGHT = 100
+)
+
+// feeCost is the cost of the fee of channel for amount while ranking routes: its base part weighs
+// BaseFeeWeight percent and its proportional part ProportionalFeeWeight percent. With both weights at 0,
+// which would make every route free, the fee is used as it is.
+func (o *RouteOptions) feeCost(channel *Channel, amount uint64) uint64 {
+	if o.BaseFeeWeight == DEFAULT_FEE_WEIGHT && o.ProportionalFeeWeight == DEFAULT_FEE_WEIGHT ||
+		o.BaseFeeWeight == 0 && o.ProportionalFeeWeight == 0 {
+		return channel.ComputeFee(amount)
+	}
+	return (channel.BaseFeeMillisatoshi*o.BaseFeeWeight + channel.computeProportionalFee(amount)*o.ProportionalFeeWeight) /
+		DEFAULT_FEE_WEIGHT
+}
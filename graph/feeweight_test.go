@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFeeWeightsFlipRoute(t *testing.T) {
+	a, b, c, d, e := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4), testNodeId(5)
+	// a -> b -> e pays a base fee of 3000 msat, a -> c -> d -> e pays 2 * 2000 msat of proportional fees
+	g := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 0, 40),
+		newTestChannel(b, e, "2x2x2", 1000000, 3000, 0, 40),
+		newTestChannel(a, c, "3x3x3", 1000000, 0, 0, 40),
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 200, 40),
+		newTestChannel(d, e, "5x5x5", 1000000, 0, 200, 40),
+		newTestChannel(e, a, "6x6x6", 1000000, 0, 0, 40),
+	)
+	amount := uint64(10000000)
+
+	// by default the fees are compared as they are, the base fee is cheaper
+	hops, err := g.dijkstra(a, e, amount, nil, 10, NewRouteOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, b, hops[0].Destination)
+
+	// weighing base fees twice makes the longer route cheaper
+	options := NewRouteOptions()
+	options.BaseFeeWeight = 200
+	hops, err = g.dijkstra(a, e, amount, nil, 10, options)
+	assert.NoError(t, err)
+	assert.Equal(t, c, hops[0].Destination)
+
+	// and so does weighing proportional fees half
+	options = NewRouteOptions()
+	options.ProportionalFeeWeight = 50
+	hops, err = g.dijkstra(a, e, amount, nil, 10, options)
+	assert.NoError(t, err)
+	assert.Equal(t, c, hops[0].Destination)
+}
+
+func TestFeeCost(t *testing.T) {
+	channel := newTestChannel(testNodeId(1), testNodeId(2), "1x1x1", 1000000, 1000, 100, 40)
+	amount := uint64(10000000)
+
+	options := NewRouteOptions()
+	assert.Equal(t, channel.ComputeFee(amount), options.feeCost(channel, amount))
+
+	options.BaseFeeWeight = 0
+	assert.Equal(t, uint64(1000), options.feeCost(channel, amount))
+
+	options.BaseFeeWeight, options.ProportionalFeeWeight = 150, 0
+	assert.Equal(t, uint64(1500), options.feeCost(channel, amount))
+
+	// both weights at 0 don't make the channel free
+	options.BaseFeeWeight = 0
+	assert.Equal(t, channel.ComputeFee(amount), options.feeCost(channel, amount))
+}
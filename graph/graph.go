@@ -12,6 +12,7 @@ import (
 const (
 	FILE                                = "graph.json"
 	DEFAULT_GRAPH_REFRESH_INTERVAL      = 10      // minutes
+	DEFAULT_ALIAS_REFRESH_INTERVAL      = 10      // minutes
 	PRUNING_INTERVAL               uint = 1209600 // 14 days
 )
 
@@ -31,6 +32,23 @@ type Graph struct {
 	adjacencyListLock *sync.RWMutex
 	channelsLock      *sync.RWMutex
 	aliasesLock       *sync.RWMutex
+	// version is incremented every time channels change, it is protected by channelsLock
+	version     uint64
+	cache       *routeCache
+	reliability *reliabilityScores
+	allowlist   *allowlist
+	feeStats    *feeStatsCache
+	favorites   *favoriteRoutes
+	// liquidityCutoff is the liquidity ratio under which channels are skipped, see RefreshLiquidityCutoff.
+	// It is protected by channelsLock.
+	liquidityCutoff float64
+	// aliasesVersion is incremented every time aliases change, it is protected by aliasesLock
+	aliasesVersion uint64
+	// ReuseChannels keeps the channels whose gossip didn't change at every refresh, see RefreshChannels
+	ReuseChannels bool
+	// bans are the channels kept out of the graph until the time they map to, see BanChannel.
+	// They are protected by channelsLock.
+	bans map[string]time.Time
 }
 
 func NewGraph() *Graph {
@@ -41,9 +59,22 @@ func NewGraph() *Graph {
 		adjacencyListLock: &sync.RWMutex{},
 		channelsLock:      &sync.RWMutex{},
 		aliasesLock:       &sync.RWMutex{},
+		cache:             newRouteCache(),
+		reliability:       newReliabilityScores(),
+		allowlist:         newAllowlist(),
+		feeStats:          newFeeStatsCache(),
+		favorites:         newFavoriteRoutes(),
+		bans:              make(map[string]time.Time),
 	}
 }
 
+// Version is incremented every time the channels change, liquidity included
+func (g *Graph) Version() uint64 {
+	g.channelsLock.RLock()
+	defer g.channelsLock.RUnlock()
+	return g.version
+}
+
 func (g *Graph) Lock() {
 	g.channelsLock.Lock()
 	g.adjacencyListLock.Lock()
@@ -75,7 +106,10 @@ func allocate(links *map[string]map[string]Edge, from, to string) {
 
 func (g *Graph) AddChannel(c *Channel) {
 	allocate(&g.Inbound, c.Destination, c.Source)
-	g.Inbound[c.Destination][c.Source] = append(g.Inbound[c.Destination][c.Source], c.ShortChannelId)
+	// a channel added twice would be relaxed twice by dijkstra
+	if !g.Inbound[c.Destination][c.Source].contains(c.ShortChannelId) {
+		g.Inbound[c.Destination][c.Source] = append(g.Inbound[c.Destination][c.Source], c.ShortChannelId)
+	}
 
 	if c.maxHtlcMsat == 0 {
 		maxHtlcMsat, _ := strconv.ParseUint(strings.TrimSuffix(c.HtlcMaximumMilliSatoshis, "msat"), 10, 64)
@@ -85,27 +119,75 @@ func (g *Graph) AddChannel(c *Channel) {
 		minHtlcMsat, _ := strconv.ParseUint(strings.TrimSuffix(c.HtlcMinimumMilliSatoshis, "msat"), 10, 64)
 		c.minHtlcMsat = minHtlcMsat
 	}
+	c.missingFees = c.LastUpdate == 0
 }
 
-func (g *Graph) RefreshChannels(channelList []*glightning.Channel) {
+// RefreshChannels updates the graph with the channels received via gossip and returns how many were new.
+// The channels are built before locking the graph, so that route searches are only blocked while
+// they are swapped in. What was learned about the existing channels is carried over during the swap,
+// so that the liquidity updates that happen while the channels are built are not lost.
+// With ReuseChannels, the channels whose gossip didn't change are kept as they are instead of being built again.
+func (g *Graph) RefreshChannels(channelList []*glightning.Channel) int {
+	ids := make([]string, len(channelList))
+	for i, c := range channelList {
+		ids[i] = c.ShortChannelId + "/" + util.GetDirection(c.Source, c.Destination)
+	}
+	reused := make([]*Channel, len(channelList))
+	if g.ReuseChannels {
+		g.channelsLock.RLock()
+		for i, c := range channelList {
+			if old, ok := g.Channels[ids[i]]; ok && *old.Channel == *c {
+				reused[i] = old
+			}
+		}
+		g.channelsLock.RUnlock()
+	}
+
+	// we need to do NewChannel and not only update the liquidity because of gossip updates
+	channels := make([]*Channel, len(channelList))
+	for i, c := range channelList {
+		if reused[i] == nil {
+			// if the channel did not exist prior to this refresh estimate its initial liquidity to be 50/50
+			channels[i] = NewChannel(c, uint64(0.5*float64(c.Satoshis*1000)), 0)
+		}
+	}
+
 	g.channelsLock.Lock()
 	g.adjacencyListLock.Lock()
 	defer g.channelsLock.Unlock()
 	defer g.adjacencyListLock.Unlock()
+	g.version++
 
-	// we need to do NewChannel and not only update the liquidity because of gossip updates
-	for _, c := range channelList {
-		var channel *Channel
-		channelId := c.ShortChannelId + "/" + util.GetDirection(c.Source, c.Destination)
-		// if the channel did not exist prior to this refresh estimate its initial liquidity to be 50/50
-		if _, ok := g.Channels[channelId]; !ok {
-			channel = NewChannel(c, uint64(0.5*float64(c.Satoshis*1000)), 0)
-			g.AddChannel(channel)
+	added := 0
+	now := time.Now()
+	for i, channel := range channels {
+		if g.isBanned(channelList[i].ShortChannelId, now) {
+			continue
+		}
+		old, ok := g.Channels[ids[i]]
+		if reused[i] != nil {
+			if old == reused[i] {
+				old.pushFee()
+				continue
+			}
+			// the channel changed while it wasn't locked, it has to be built after all
+			channel = NewChannel(channelList[i], uint64(0.5*float64(channelList[i].Satoshis*1000)), 0)
+		}
+		if ok {
+			channel.Liquidity = old.Liquidity
+			channel.Timestamp = old.Timestamp
+			channel.Evidence = old.Evidence
+			channel.Confidence = old.Confidence
+			channel.Inbound = old.Inbound
+			channel.feeHistory = channel.recordFee(old.feeHistory)
 		} else {
-			channel = NewChannel(c, g.Channels[channelId].Liquidity, g.Channels[channelId].Timestamp)
+			g.AddChannel(channel)
+			channel.feeHistory = channel.recordFee(nil)
+			added++
 		}
-		g.Channels[channelId] = channel
+		g.Channels[ids[i]] = channel
 	}
+	return added
 }
 
 func (g *Graph) RefreshAliases(nodes []*glightning.Node) {
@@ -113,37 +195,106 @@ func (g *Graph) RefreshAliases(nodes []*glightning.Node) {
 	defer g.aliasesLock.Unlock()
 
 	for _, n := range nodes {
-		g.Aliases[n.Id] = n.Alias
+		if alias, ok := g.Aliases[n.Id]; !ok || alias != n.Alias {
+			g.Aliases[n.Id] = n.Alias
+			g.aliasesVersion++
+		}
 	}
 }
 
-func (g *Graph) PruneChannels() {
+// UpdateAlias sets the alias of the node with id, whether the node has channels in the graph or not,
+// and returns whether it changed. Empty aliases, of nodes that didn't announce themselves, are ignored.
+func (g *Graph) UpdateAlias(id, alias string) bool {
+	if alias == "" {
+		return false
+	}
+	g.aliasesLock.Lock()
+	defer g.aliasesLock.Unlock()
+
+	if current, ok := g.Aliases[id]; ok && current == alias {
+		return false
+	}
+	g.Aliases[id] = alias
+	g.aliasesVersion++
+	return true
+}
+
+// AliasesVersion is incremented every time the aliases change
+func (g *Graph) AliasesVersion() uint64 {
+	g.aliasesLock.RLock()
+	defer g.aliasesLock.RUnlock()
+	return g.aliasesVersion
+}
+
+// GetAliases returns a copy of the aliases of the nodes, by node id
+func (g *Graph) GetAliases() map[string]string {
+	g.aliasesLock.RLock()
+	defer g.aliasesLock.RUnlock()
+	aliases := make(map[string]string, len(g.Aliases))
+	for id, alias := range g.Aliases {
+		aliases[id] = alias
+	}
+	return aliases
+}
+
+// SetAliases adds aliases, for example the ones saved to file, without overwriting the ones already known
+func (g *Graph) SetAliases(aliases map[string]string) {
+	g.aliasesLock.Lock()
+	defer g.aliasesLock.Unlock()
+	for id, alias := range aliases {
+		if _, ok := g.Aliases[id]; !ok {
+			g.Aliases[id] = alias
+		}
+	}
+}
+
+// PruneChannels deletes the channels that haven't been updated for PRUNING_INTERVAL and returns how many
+func (g *Graph) PruneChannels() int {
 	g.channelsLock.Lock()
 	defer g.channelsLock.Unlock()
+	g.version++
 
 	// get current time in seconds
 	now := uint(time.Now().Unix())
 
 	// prune channels that are older than PRUNING_INTERVAL
 	// TODO: remove closed channels, but might be worth waiting for glightning to implement channel_state_changed
+	pruned := 0
 	for _, c := range g.Channels {
 		if c.LastUpdate+PRUNING_INTERVAL < now {
 			g.DeleteChannel(c)
+			pruned++
 		}
 	}
+	return pruned
 }
 
 func (g *Graph) DeleteChannel(c *Channel) {
 	// delete from channel map
 	delete(g.Channels, c.ShortChannelId+"/"+util.GetDirection(c.Source, c.Destination))
 
-	// delete from adjacency list
-	for i, edge := range g.Inbound[c.Destination][c.Source] {
-		if edge == c.ShortChannelId {
-			g.Inbound[c.Destination][c.Source] = remove(g.Inbound[c.Destination][c.Source], i)
-			break
+	// delete from adjacency list, every copy of the scid in case it was added twice
+	edge := g.Inbound[c.Destination][c.Source]
+	removed := false
+	for i := len(edge) - 1; i >= 0; i-- {
+		if edge[i] == c.ShortChannelId {
+			edge = remove(edge, i)
+			removed = true
+		}
+	}
+	if removed {
+		g.Inbound[c.Destination][c.Source] = edge
+	}
+}
+
+// contains tells whether scid is one of the channels of the edge
+func (e Edge) contains(scid string) bool {
+	for _, s := range e {
+		if s == scid {
+			return true
 		}
 	}
+	return false
 }
 
 // assumes valid input
@@ -152,6 +303,22 @@ func remove(s []string, i int) []string {
 	return s[:len(s)-1]
 }
 
+// HasNode tells whether the graph knows a channel of id, towards it or from it
+func (g *Graph) HasNode(id string) bool {
+	g.adjacencyListLock.RLock()
+	defer g.adjacencyListLock.RUnlock()
+	if len(g.Inbound[id]) > 0 {
+		return true
+	}
+	// the adjacency list only goes backwards, the channels from id are in the edges of their destinations
+	for _, edges := range g.Inbound {
+		if _, ok := edges[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *Graph) GetAlias(id string) string {
 	g.aliasesLock.RLock()
 	defer g.aliasesLock.RUnlock()
@@ -165,19 +332,69 @@ func (g *Graph) GetAlias(id string) string {
 func (g *Graph) UpdateChannel(channelId, oppositeChannelId string, amount uint64) {
 	g.channelsLock.Lock()
 	defer g.channelsLock.Unlock()
+	g.version++
 
 	now := time.Now().Unix()
 
 	if _, ok := g.Channels[channelId]; ok {
 		g.Channels[channelId].Liquidity = amount
 		g.Channels[channelId].Timestamp = now
+		g.Channels[channelId].Evidence = true
+		g.Channels[channelId].Confidence = CONFIDENCE_PAYMENT
 	}
 
 	if _, ok := g.Channels[oppositeChannelId]; ok {
 		g.Channels[oppositeChannelId].Liquidity =
 			g.Channels[oppositeChannelId].Satoshis*1000 - amount
 		g.Channels[oppositeChannelId].Timestamp = now
+		g.Channels[oppositeChannelId].Evidence = true
+		g.Channels[oppositeChannelId].Confidence = CONFIDENCE_PAYMENT
+	}
+}
+
+// SetLiquidity sets the liquidity of a channel that we know for sure, like one of ours.
+// It returns true if the liquidity changed.
+func (g *Graph) SetLiquidity(channelId string, amount uint64) bool {
+	g.channelsLock.Lock()
+	defer g.channelsLock.Unlock()
+
+	c, ok := g.Channels[channelId]
+	if !ok {
+		return false
+	}
+	c.Timestamp = time.Now().Unix()
+	c.Evidence = true
+	c.Confidence = CONFIDENCE_EXACT
+	if c.Liquidity == amount {
+		return false
 	}
+	c.Liquidity = amount
+	g.version++
+	return true
+}
+
+// ConsumeLiquidity lowers the liquidity of a channel after amount went through it: the channel had at
+// least amount before, so what's left is the belief, raised to amount if it was lower, minus amount.
+// A liquidity known exactly is left alone, it's set again by SetLiquidity. It returns true if the liquidity changed.
+func (g *Graph) ConsumeLiquidity(channelId string, amount uint64) bool {
+	g.channelsLock.Lock()
+	defer g.channelsLock.Unlock()
+
+	c, ok := g.Channels[channelId]
+	if !ok || c.Confidence == CONFIDENCE_EXACT {
+		return false
+	}
+	left := uint64(0)
+	if c.Liquidity > amount {
+		left = c.Liquidity - amount
+	}
+	if c.Liquidity == left {
+		return false
+	}
+	c.Liquidity = left
+	c.Timestamp = time.Now().Unix()
+	g.version++
+	return true
 }
 
 func (g *Graph) GetChannel(id string) (*Channel, error) {
@@ -193,13 +410,14 @@ func (g *Graph) GetChannel(id string) (*Channel, error) {
 func (g *Graph) RefreshLiquidity(refreshThreshold time.Duration) int {
 	g.channelsLock.Lock()
 	defer g.channelsLock.Unlock()
+	g.version++
 
 	// get current time in seconds
 	now := time.Now().Unix()
 	hits := 0
 
 	for _, c := range g.Channels {
-		if c.Timestamp+int64(refreshThreshold.Seconds()) < now {
+		if c.IsAged(refreshThreshold, now) {
 			c.ResetLiquidity()
 			hits++
 		}
@@ -207,3 +425,29 @@ func (g *Graph) RefreshLiquidity(refreshThreshold time.Duration) int {
 
 	return hits
 }
+
+// AgingPreview tells what RefreshLiquidity(refreshThreshold) would do at the time at, without changing the graph:
+// how many beliefs it would reset, and how far the beliefs would move on average over all the channels (ppm of the capacity)
+func (g *Graph) AgingPreview(refreshThreshold time.Duration, at time.Time) (int, uint64) {
+	g.channelsLock.RLock()
+	defer g.channelsLock.RUnlock()
+
+	resets := 0
+	var drift uint64
+	for _, c := range g.Channels {
+		if c.Satoshis == 0 || !c.IsAged(refreshThreshold, at.Unix()) {
+			continue
+		}
+		resets++
+		aged := c.AgedLiquidity(refreshThreshold, at.Unix())
+		if aged > c.Liquidity {
+			drift += (aged - c.Liquidity) * 1000 / c.Satoshis
+		} else {
+			drift += (c.Liquidity - aged) * 1000 / c.Satoshis
+		}
+	}
+	if len(g.Channels) == 0 {
+		return resets, 0
+	}
+	return resets, drift / uint64(len(g.Channels))
+}
@@ -0,0 +1,58 @@
+package graph
+
+import (
+	"circular/util"
+	"fmt"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testNodeId returns a well-formed node id that is unique for each i
+func testNodeId(i int) string {
+	return fmt.Sprintf("02%064x", i)
+}
+
+func newTestChannel(src, dst, scid string, satoshis, baseFee, feeRate uint64, delay uint) *Channel {
+	var channelFlags uint = 0
+	if util.GetDirection(src, dst) == "1" {
+		channelFlags = 1
+	}
+	return NewChannel(&glightning.Channel{
+		Source:                   src,
+		Destination:              dst,
+		ShortChannelId:           scid,
+		IsPublic:                 true,
+		Satoshis:                 satoshis,
+		AmountMsat:               strconv.FormatUint(satoshis*1000, 10) + "msat",
+		ChannelFlags:             channelFlags,
+		IsActive:                 true,
+		LastUpdate:               uint(time.Now().Unix()),
+		BaseFeeMillisatoshi:      baseFee,
+		FeePerMillionth:          feeRate,
+		Delay:                    delay,
+		HtlcMinimumMilliSatoshis: "1000msat",
+		HtlcMaximumMilliSatoshis: strconv.FormatUint(satoshis*1000, 10) + "msat",
+	}, satoshis*1000/2, 0)
+}
+
+func newTestGraph(channels ...*Channel) *Graph {
+	g := NewGraph()
+	for _, c := range channels {
+		g.Channels[c.ShortChannelId+"/"+util.GetDirection(c.Source, c.Destination)] = c
+		g.AddChannel(c)
+	}
+	return g
+}
+
+func TestHasNode(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	g := newTestGraph(newTestChannel(a, b, "1x1x1", 1000000, 0, 0, 40))
+
+	// a node is known by its channels in either direction
+	assert.True(t, g.HasNode(a), "outbound only")
+	assert.True(t, g.HasNode(b), "inbound only")
+	assert.False(t, g.HasNode(c))
+}
@@ -0,0 +1,57 @@
+// Package graphtest builds the channels and graphs used by the tests of the packages that search routes.
+// The tests of the graph package itself can't import it, they have their own copy in graph_test.go.
+package graphtest
+
+import (
+	"circular/graph"
+	"circular/util"
+	"fmt"
+	"github.com/elementsproject/glightning/glightning"
+	"strconv"
+	"time"
+)
+
+// NodeId is a valid node id, different for every i
+func NodeId(i int) string {
+	return fmt.Sprintf("02%064x", i)
+}
+
+// NewChannel is a public and active channel from src to dst, updated now, believed to have half its capacity
+func NewChannel(src, dst, scid string, satoshis, baseFee, feeRate uint64, delay uint) *graph.Channel {
+	var channelFlags uint = 0
+	if util.GetDirection(src, dst) == "1" {
+		channelFlags = 1
+	}
+	return graph.NewChannel(&glightning.Channel{
+		Source:                   src,
+		Destination:              dst,
+		ShortChannelId:           scid,
+		IsPublic:                 true,
+		Satoshis:                 satoshis,
+		AmountMsat:               strconv.FormatUint(satoshis*1000, 10) + "msat",
+		ChannelFlags:             channelFlags,
+		IsActive:                 true,
+		LastUpdate:               uint(time.Now().Unix()),
+		BaseFeeMillisatoshi:      baseFee,
+		FeePerMillionth:          feeRate,
+		Delay:                    delay,
+		HtlcMinimumMilliSatoshis: "1000msat",
+		HtlcMaximumMilliSatoshis: strconv.FormatUint(satoshis*1000, 10) + "msat",
+	}, satoshis*1000/2, 0)
+}
+
+// AddChannel adds c to g and returns it
+func AddChannel(g *graph.Graph, c *graph.Channel) *graph.Channel {
+	g.Channels[c.ShortChannelId+"/"+util.GetDirection(c.Source, c.Destination)] = c
+	g.AddChannel(c)
+	return c
+}
+
+// NewGraph is a graph with the given channels
+func NewGraph(channels ...*graph.Channel) *graph.Graph {
+	g := graph.NewGraph()
+	for _, c := range channels {
+		AddChannel(g, c)
+	}
+	return g
+}
@@ -0,0 +1,57 @@
+package graph
+
+// InboundFee is charged by a node on the htlcs that reach it through a channel, on top of the
+// fee of the channel they leave through. It can be negative, a discount, but the total fee of
+// the forward can't go below zero.
+type InboundFee struct {
+	BaseMsat        int64 `json:"base_msat"`
+	FeePerMillionth int64 `json:"fee_per_millionth"`
+}
+
+func (f *InboundFee) compute(amount uint64) int64 {
+	// like ComputeFee, the proportional part is rounded towards the bigger fee
+	proportional := int64(amount/1000) * f.FeePerMillionth
+	if proportional > 0 {
+		proportional = (proportional-1)/1000 + 1
+	} else {
+		proportional /= 1000
+	}
+	return f.BaseMsat + proportional
+}
+
+// forwardingFee is the fee charged by the node between in and out to forward amount over out.
+// The inbound fee of in is considered only if inbound is true.
+func forwardingFee(in, out *Channel, amount uint64, inbound bool) uint64 {
+	fee := out.ComputeFee(amount)
+	if !inbound || in == nil || in.Inbound == nil {
+		return fee
+	}
+	return addInboundFee(fee, in.Inbound.compute(amount+fee))
+}
+
+// addInboundFee adds an inbound fee to the outbound fee of a forward, without going below zero
+func addInboundFee(fee uint64, inboundFee int64) uint64 {
+	if inboundFee < 0 && uint64(-inboundFee) > fee {
+		return 0
+	}
+	return uint64(int64(fee) + inboundFee)
+}
+
+// SetInboundFees sets the inbound fees of the channels, by channel id (scid/direction).
+// The inbound fee of a channel is the one charged by its destination. Channels that are not in
+// the graph are skipped, channels not in fees have their inbound fee removed.
+// It returns the number of channels with an inbound fee.
+func (g *Graph) SetInboundFees(fees map[string]*InboundFee) int {
+	g.channelsLock.Lock()
+	defer g.channelsLock.Unlock()
+	g.version++
+
+	set := 0
+	for channelId, c := range g.Channels {
+		c.Inbound = fees[channelId]
+		if c.Inbound != nil {
+			set++
+		}
+	}
+	return set
+}
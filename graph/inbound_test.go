@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRouteInboundFees(t *testing.T) {
+	amount := uint64(100000000)
+	route := newTestRoute(amount)
+	fee := route.Fee()
+	in := route.Hops[2].Channel
+
+	// b charges an inbound fee on the htlcs coming from a, but it's ignored unless enabled
+	route.Hops[1].Channel.Inbound = &InboundFee{BaseMsat: 1000, FeePerMillionth: 100}
+	route.recomputeFeeAndDelay()
+	assert.Equal(t, fee, route.Fee())
+
+	route.InboundFees = true
+	route.recomputeFeeAndDelay()
+	// computed on the amount forwarded by b plus its outbound fee
+	assert.Equal(t, uint64(52000), in.ComputeFee(amount))
+	assert.Equal(t, amount+52000+11006, route.Hops[1].MilliSatoshi)
+	assert.Greater(t, route.Fee(), fee+11006)
+	estimate, _, err := route.EstimateFee(amount)
+	assert.NoError(t, err)
+	assert.Equal(t, route.Fee(), estimate)
+
+	// a discount bigger than the outbound fee of b makes b forward for free, not pay us
+	route.Hops[1].Channel.Inbound = &InboundFee{FeePerMillionth: -1000}
+	route.recomputeFeeAndDelay()
+	assert.Equal(t, amount, route.Hops[1].MilliSatoshi)
+	assert.Less(t, route.Fee(), fee)
+}
+
+func TestPathfinderInboundFees(t *testing.T) {
+	src, a, b, dst := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	g := newTestGraph(
+		newTestChannel(src, a, "1x1x1", 1000000, 0, 0, 10),
+		newTestChannel(a, dst, "2x1x1", 1000000, 0, 100, 10),
+		newTestChannel(src, b, "3x1x1", 1000000, 0, 0, 10),
+		newTestChannel(b, dst, "4x1x1", 1000000, 0, 200, 10),
+		newTestChannel(dst, src, "5x1x1", 1000000, 0, 0, 10),
+	)
+	amount := uint64(100000000)
+
+	options := NewRouteOptions()
+	route, err := g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "1x1x1", route.Hops[0].ShortChannelId)
+
+	// b discounts the htlcs coming from src, making its route cheaper
+	assert.Equal(t, 1, g.SetInboundFees(map[string]*InboundFee{
+		"3x1x1/" + util.GetDirection(src, b): {FeePerMillionth: -150},
+	}))
+	route, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "1x1x1", route.Hops[0].ShortChannelId, "inbound fees are ignored unless enabled")
+
+	options.InboundFees = true
+	route, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "3x1x1", route.Hops[0].ShortChannelId)
+}
@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"circular/util"
+	"strings"
+)
+
+const (
+	// OBJECTIVE_CHEAPEST searches the route with the lowest fees
+	OBJECTIVE_CHEAPEST = "cheapest"
+	// OBJECTIVE_SHORTEST searches the route with the fewest hops, the one with the lowest cost among them.
+	// A rebalance searches it within its fee cap instead, see rebalance.getRoute
+	OBJECTIVE_SHORTEST = "shortest"
+
+	DEFAULT_OBJECTIVES = OBJECTIVE_CHEAPEST
+
+	// SHORTEST_HOP_COST (msat) is added to the cost of every channel with OBJECTIVE_SHORTEST.
+	// It's more than the fees of any route, so that a hop less always wins over lower fees.
+	SHORTEST_HOP_COST = 1 << 40
+)
+
+// ParseObjectives parses a comma separated list of route objectives, in the order they are tried
+func ParseObjectives(s string) ([]string, error) {
+	objectives := make([]string, 0, 2)
+	seen := make(map[string]bool)
+	for _, objective := range strings.Split(s, ",") {
+		objective = strings.TrimSpace(objective)
+		if objective == "" {
+			continue
+		}
+		switch objective {
+		case OBJECTIVE_CHEAPEST, OBJECTIVE_SHORTEST:
+		default:
+			return nil, util.ErrInvalidObjective
+		}
+		if seen[objective] {
+			continue
+		}
+		seen[objective] = true
+		objectives = append(objectives, objective)
+	}
+	if len(objectives) == 0 {
+		return nil, util.ErrInvalidObjective
+	}
+	return objectives, nil
+}
+
+// hopCost is the cost of a hop on top of the cost of its channel
+func (o *RouteOptions) hopCost() uint64 {
+	if o.Objective == OBJECTIVE_SHORTEST {
+		return SHORTEST_HOP_COST
+	}
+	return 0
+}
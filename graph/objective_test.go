@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseObjectives(t *testing.T) {
+	objectives, err := ParseObjectives(DEFAULT_OBJECTIVES)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{OBJECTIVE_CHEAPEST}, objectives)
+
+	objectives, err = ParseObjectives(" shortest, cheapest,shortest")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{OBJECTIVE_SHORTEST, OBJECTIVE_CHEAPEST}, objectives)
+
+	_, err = ParseObjectives("fastest")
+	assert.Equal(t, util.ErrInvalidObjective, err)
+	_, err = ParseObjectives("")
+	assert.Equal(t, util.ErrInvalidObjective, err)
+}
+
+func TestShortestAndCheapestRoutesDiffer(t *testing.T) {
+	a, b, c, d := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	// a -> d pays 500 ppm in one hop, a -> b -> c -> d pays 2 * 100 ppm in three
+	g := newTestGraph(
+		newTestChannel(a, d, "1x1x1", 1000000, 0, 500, 40),
+		newTestChannel(a, b, "2x2x2", 1000000, 0, 0, 40),
+		newTestChannel(b, c, "3x3x3", 1000000, 0, 100, 40),
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 100, 40),
+		newTestChannel(d, a, "9x9x9", 1000000, 0, 0, 40),
+	)
+	amount := uint64(10000000)
+
+	options := NewRouteOptions()
+	options.CacheRoutes = false
+	cheapest, err := g.GetRoute(a, d, amount, map[string]bool{}, 10, options)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2x2x2", "3x3x3", "4x4x4"}, cheapest.Scids())
+
+	options.Objective = OBJECTIVE_SHORTEST
+	shortest, err := g.GetRoute(a, d, amount, map[string]bool{}, 10, options)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1x1x1"}, shortest.Scids())
+	assert.Greater(t, shortest.Fee(), cheapest.Fee())
+
+	// among the routes with the fewest hops the cheapest one wins
+	g = newTestGraph(
+		newTestChannel(a, b, "2x2x2", 1000000, 0, 300, 40),
+		newTestChannel(b, d, "5x5x5", 1000000, 0, 300, 40),
+		newTestChannel(a, c, "6x6x6", 1000000, 0, 100, 40),
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 100, 40),
+		newTestChannel(d, a, "9x9x9", 1000000, 0, 0, 40),
+	)
+	shortest, err = g.GetRoute(a, d, amount, map[string]bool{}, 10, options)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"6x6x6", "4x4x4"}, shortest.Scids())
+}
@@ -0,0 +1,154 @@
+package graph
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_STABILITY_DELAY = 2 // seconds
+)
+
+// RouteOptions contains the settings that influence how a route is searched
+type RouteOptions struct {
+	// StrictPrivate forbids private channels anywhere in the route, local legs included.
+	// Private channels are never used as intermediate hops regardless of this setting.
+	StrictPrivate bool `json:"strict_private"`
+	// AvoidLocalChannels forbids the channels of LocalNode as intermediate hops, whether LocalNode is excluded or not
+	AvoidLocalChannels bool   `json:"avoid_local_channels"`
+	LocalNode          string `json:"-"`
+	// Allowlist only allows the nodes in the allowlist of the graph as intermediate hops.
+	// The ends of the search, the peers of our channels, are always allowed.
+	Allowlist bool `json:"allowlist"`
+	// MinCapacity and MaxCapacity (sats) skip the intermediate channels smaller or bigger than them, 0 disables
+	// the bound. With StrictCapacity they also apply to the local legs, which is enforced by the callers.
+	MinCapacity    uint64 `json:"min_capacity"`
+	MaxCapacity    uint64 `json:"max_capacity"`
+	StrictCapacity bool   `json:"strict_capacity"`
+	// MaxHopDelay (blocks) skips the intermediate channels that advertise a longer delay, 0 disables it.
+	// It's independent of the limit on the total delay of the route.
+	MaxHopDelay uint `json:"max_hop_delay"`
+	// PreFilter removes the channels that can't carry the amount before running dijkstra, unless AggregateParallel is set
+	PreFilter bool `json:"prefilter"`
+	// SearchWorkers is the number of goroutines evaluating the channels of the hubs during a search,
+	// see evaluateEdgesParallel. 0 and 1 keep the search serial. It doesn't change the routes found.
+	SearchWorkers int `json:"search_workers"`
+	// CacheRoutes remembers the routes found until the graph changes
+	CacheRoutes bool `json:"cache_routes"`
+	// AmountGranularity (msat) is the size of the buckets in which amounts are grouped by the route cache
+	AmountGranularity uint64 `json:"amount_granularity"`
+	// MinHopCost (msat) is the minimum cost of a hop while ranking routes, so that zero-fee channels
+	// are not always preferred. It doesn't change the fees that are actually paid.
+	MinHopCost uint64 `json:"min_hop_cost"`
+	// ReliabilityWeight (ppm of the amount) is the extra cost of going through a node that always fails.
+	// Nodes are penalized in proportion to their failure rate, 0 disables the penalty.
+	ReliabilityWeight uint64 `json:"reliability_weight"`
+	// FeeVolatilityWeight (ppm of the amount) is the extra cost of going through a channel whose advertised
+	// fee changes at every refresh, see Channel.FeeVolatility. 0 disables the penalty.
+	FeeVolatilityWeight uint64 `json:"fee_volatility_weight"`
+	// BaseFeeWeight and ProportionalFeeWeight (percent) weigh the base and the proportional part of the fee
+	// of a channel while ranking routes, see feeCost. They don't change the fees that are actually paid.
+	BaseFeeWeight         uint64 `json:"base_fee_weight"`
+	ProportionalFeeWeight uint64 `json:"proportional_fee_weight"`
+	// MinLiquidityPercentile skips the channels in the lowest percentile by liquidity ratio. The cutoff is
+	// computed by the graph in RefreshLiquidityCutoff, not by each search. 0 disables it.
+	MinLiquidityPercentile int `json:"min_liquidity_percentile"`
+	// RequireEvidence only trusts the liquidity of the channels that is backed by evidence: a channel whose
+	// liquidity is only estimated, see Channel.Evidence, is used for up to UnevidencedLiquidity (percent
+	// of its capacity) instead of the half of its capacity assumed by the estimate
+	RequireEvidence      bool   `json:"require_evidence"`
+	UnevidencedLiquidity uint64 `json:"unevidenced_liquidity"`
+	// ExploreBias (ppm of the amount) lowers the cost of the channels without evidence, without going
+	// below zero, so that the rebalances that explore learn about them. It's only set for those rebalances.
+	ExploreBias uint64 `json:"explore_bias"`
+	// MissingFeesPenalty (ppm of the amount) is the cost of going through a channel without a fee policy.
+	// Such channels are skipped when it is 0. It doesn't change the fees that are actually paid.
+	MissingFeesPenalty uint64 `json:"missing_fees_penalty"`
+	// PreferredNodes are nudged into the routes: the cost of the channels they forward through
+	// is lowered by PreferredBias (ppm of the amount), without going below zero
+	PreferredNodes map[string]bool `json:"preferred_nodes"`
+	PreferredBias  uint64          `json:"preferred_bias"`
+	// RecentChannels are the channels of the routes used last by the same pair of channels, weighted
+	// between 0 and 1 by how recently. Their cost is raised by RecentRoutePenalty (ppm of the amount) times the weight.
+	RecentChannels     map[string]float64 `json:"-"`
+	RecentRoutePenalty uint64             `json:"recent_route_penalty"`
+	// ExcludedChannels are the short channel ids that can't be used as intermediate hops, in either direction
+	ExcludedChannels map[string]bool `json:"-"`
+	// AggregateParallel lets the pathfinding use the parallel channels between two nodes together when
+	// none of them can forward the amount alone. The routes going through them must be split, see Route.Split.
+	AggregateParallel bool `json:"aggregate_parallel"`
+	// MaxRouteLength is the maximum number of hops of the final route, local legs included.
+	// It is enforced by the callers after assembling the route.
+	MaxRouteLength int `json:"max_route_length"`
+	// DelayPadding (blocks) is added to the delay of every hop when assembling the route, on top of
+	// the delta advertised by the channel. It is applied by the callers, see Route.DelayPadding.
+	DelayPadding uint `json:"delay_padding"`
+	// StabilityCheck searches the route twice, StabilityDelay apart, and only accepts it
+	// if both searches agree. It is enforced by the callers, not by GetRoute itself.
+	StabilityCheck bool          `json:"stability_check"`
+	StabilityDelay time.Duration `json:"stability_delay"`
+	// InboundFees adds the inbound fees advertised by the nodes to the fees of the routes
+	InboundFees bool `json:"inbound_fees"`
+	// MinProbability is the minimum estimated probability of success of a route, 0 accepts any route
+	MinProbability float64 `json:"min_probability"`
+	// TieBreak is the order of the criteria used to choose between paths with the same cost,
+	// see ParseTieBreak. Without criteria the first path found is kept.
+	TieBreak []string `json:"tie_break"`
+	// Objective is what the search minimizes, see OBJECTIVE_CHEAPEST and OBJECTIVE_SHORTEST.
+	// It's the cheapest route when empty.
+	Objective string `json:"objective"`
+}
+
+func NewRouteOptions() *RouteOptions {
+	return &RouteOptions{
+		AmountGranularity:     DEFAULT_AMOUNT_GRANULARITY,
+		MaxRouteLength:        MAX_ROUTE_LENGTH,
+		StabilityDelay:        DEFAULT_STABILITY_DELAY * time.Second,
+		TieBreak:              []string{TIE_BREAK_HOPS, TIE_BREAK_LIQUIDITY, TIE_BREAK_SCID},
+		BaseFeeWeight:         DEFAULT_FEE_WEIGHT,
+		ProportionalFeeWeight: DEFAULT_FEE_WEIGHT,
+		MaxHopDelay:           DEFAULT_MAX_HOP_DELAY,
+		UnevidencedLiquidity:  DEFAULT_UNEVIDENCED_LIQUIDITY,
+		Objective:             OBJECTIVE_CHEAPEST,
+	}
+}
+
+// ParsePreferredNodes parses a comma separated list of node ids
+func ParsePreferredNodes(s string) map[string]bool {
+	preferred := make(map[string]bool)
+	for _, id := range strings.Split(s, ",") {
+		id = strings.TrimSpace(id)
+		if id != "" {
+			preferred[id] = true
+		}
+	}
+	return preferred
+}
+
+// clone returns a copy of the options that doesn't share their maps and slices,
+// so that it stays the same when the options are changed in place
+func (o *RouteOptions) clone() *RouteOptions {
+	c := *o
+	if o.PreferredNodes != nil {
+		c.PreferredNodes = make(map[string]bool, len(o.PreferredNodes))
+		for id, preferred := range o.PreferredNodes {
+			c.PreferredNodes[id] = preferred
+		}
+	}
+	if o.RecentChannels != nil {
+		c.RecentChannels = make(map[string]float64, len(o.RecentChannels))
+		for id, weight := range o.RecentChannels {
+			c.RecentChannels[id] = weight
+		}
+	}
+	if o.ExcludedChannels != nil {
+		c.ExcludedChannels = make(map[string]bool, len(o.ExcludedChannels))
+		for scid, excluded := range o.ExcludedChannels {
+			c.ExcludedChannels[scid] = excluded
+		}
+	}
+	if o.TieBreak != nil {
+		c.TieBreak = append([]string(nil), o.TieBreak...)
+	}
+	return &c
+}
@@ -0,0 +1,219 @@
+package graph
+
+import (
+	"circular/util"
+	"sort"
+)
+
+// The parallel channels between two nodes can be aggregated by the pathfinding when none of them
+// can forward the amount alone, see RouteOptions.AggregateParallel. The aggregated hop is a channel
+// standing for all of them: it can't be sent as it is, Route.Split turns the route into one route
+// for each of the channels, each carrying its share of the amount.
+
+// splitParallel shares amount among channels, the ones with the most liquidity first, without going
+// over their liquidity and htlc maximum. No share is below minShare: when a channel can't take all
+// that is left, it takes less so that the rest is at least minShare. It returns the channels used
+// and their shares, or false if together they can't carry amount.
+func splitParallel(channels []*Channel, amount, minShare uint64) ([]*Channel, []uint64, bool) {
+	sorted := make([]*Channel, len(channels))
+	copy(sorted, channels)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Liquidity != sorted[j].Liquidity {
+			return sorted[i].Liquidity > sorted[j].Liquidity
+		}
+		return sorted[i].ShortChannelId < sorted[j].ShortChannelId
+	})
+
+	used := make([]*Channel, 0, len(sorted))
+	shares := make([]uint64, 0, len(sorted))
+	remaining := amount
+	for _, channel := range sorted {
+		if remaining == 0 {
+			break
+		}
+		floor := util.Max(channel.minHtlcMsat, minShare)
+		share := util.Min(util.Min(channel.Liquidity, channel.maxHtlcMsat), remaining)
+		if share < remaining && remaining-share < minShare {
+			// the rest would be dust for the route, snap it up to minShare
+			if remaining < minShare+floor {
+				continue
+			}
+			share = remaining - minShare
+		}
+		if share == 0 || share < floor {
+			continue
+		}
+		used = append(used, channel)
+		shares = append(shares, share)
+		remaining -= share
+	}
+	return used, shares, remaining == 0
+}
+
+// aggregateParallel returns the channel standing for the parallel channels of edge from v to u that
+// together can forward amount, and the fee it costs to send the shares through them.
+// It returns nil if fewer than two channels are needed or if they can't carry amount even together.
+func (g *Graph) aggregateParallel(v, u string, edge Edge, amount uint64, options *RouteOptions) (*Channel, uint64) {
+	candidates := make([]*Channel, 0, len(edge))
+	for _, scid := range edge {
+		channel, ok := g.Channels[scid+"/"+util.GetDirection(v, u)]
+		if !ok || !channel.IsPublic || !channel.HasFeePolicy() || !channel.IsActive {
+			continue
+		}
+		if options.AvoidLocalChannels && (channel.Source == options.LocalNode || channel.Destination == options.LocalNode) {
+			continue
+		}
+		if options.IsOutsideCapacityRange(channel) || options.IsOverMaxHopDelay(channel) || g.isDepleted(channel, options) {
+			continue
+		}
+		// the shares are not known yet, only the channels with evidence are trusted with one
+		if options.RequireEvidence && !channel.Evidence {
+			continue
+		}
+		candidates = append(candidates, channel)
+	}
+
+	// the rest of the route is not known yet, its htlc minima are checked by Route.Split
+	used, shares, ok := splitParallel(candidates, amount, 0)
+	if !ok || len(used) < 2 {
+		return nil, 0
+	}
+	var fee uint64
+	for i, channel := range used {
+		fee += channel.ComputeFee(shares[i])
+	}
+	return newAggregateChannel(used), fee
+}
+
+// newAggregateChannel builds the channel standing for parallel. Its fees and delay are the highest
+// among them, so that the aggregated route is never cheaper than any of its parts.
+func newAggregateChannel(parallel []*Channel) *Channel {
+	base := *parallel[0].Channel
+	aggregate := &Channel{
+		Channel:     &base,
+		Timestamp:   parallel[0].Timestamp,
+		minHtlcMsat: parallel[0].minHtlcMsat,
+		parallel:    parallel,
+	}
+	aggregate.Satoshis = 0
+	for _, channel := range parallel {
+		aggregate.Satoshis += channel.Satoshis
+		aggregate.Liquidity += channel.Liquidity
+		aggregate.maxHtlcMsat += channel.maxHtlcMsat
+		aggregate.minHtlcMsat = util.Min(aggregate.minHtlcMsat, channel.minHtlcMsat)
+		aggregate.BaseFeeMillisatoshi = util.Max(aggregate.BaseFeeMillisatoshi, channel.BaseFeeMillisatoshi)
+		aggregate.FeePerMillionth = util.Max(aggregate.FeePerMillionth, channel.FeePerMillionth)
+		if channel.Delay > aggregate.Delay {
+			aggregate.Delay = channel.Delay
+		}
+	}
+	return aggregate
+}
+
+// IsAggregate tells whether the channel stands for parallel channels aggregated by the pathfinding
+func (c *Channel) IsAggregate() bool {
+	return c.parallel != nil
+}
+
+// Split returns the routes to send in place of r: r itself, or one route for each of the parallel
+// channels of an aggregated hop. Each route but the last carries through its channel the share
+// planned for it, the last one delivers the rest of the amount. No route delivers less than the
+// highest htlc minimum of the other hops, which would refuse it: the shares are sized for it,
+// and a part that ends up too small anyway is carried by another one.
+func (r *Route) Split() []*Route {
+	for i, hop := range r.Hops {
+		if !hop.IsAggregate() {
+			continue
+		}
+		floor := r.minPartAmount(i)
+		used, shares, ok := splitParallel(hop.parallel, hop.MilliSatoshi, r.minShare(i, floor))
+		if !ok {
+			// the liquidity changed since the route was found, send all of it through the fullest channel
+			used, shares = hop.parallel[:1], []uint64{hop.MilliSatoshi}
+		}
+
+		channels := make([]*Channel, 0, len(used))
+		amounts := make([]uint64, 0, len(used))
+		remaining := r.Amount
+		for k, channel := range used {
+			if k == len(used)-1 {
+				channels = append(channels, channel)
+				amounts = append(amounts, remaining)
+				break
+			}
+			amount := uint64(float64(r.Amount) * float64(shares[k]) / float64(hop.MilliSatoshi))
+			part := r.withChannel(i, channel, amount)
+			// every part pays the base fees of all the hops, lower it until it fits in its share
+			for part.Hops[i].MilliSatoshi > shares[k] && amount > 0 {
+				amount -= util.Min(part.Hops[i].MilliSatoshi-shares[k], amount)
+				part = r.withChannel(i, channel, amount)
+			}
+			// what a dust part would carry is left to the next ones
+			if amount == 0 || amount < floor {
+				continue
+			}
+			remaining -= amount
+			channels = append(channels, channel)
+			amounts = append(amounts, amount)
+		}
+		// the fees can leave the last part just below the floor, the previous one carries it
+		if n := len(amounts); n > 1 && amounts[n-1] < floor {
+			amounts[n-2] += amounts[n-1]
+			channels, amounts = channels[:n-1], amounts[:n-1]
+		}
+
+		routes := make([]*Route, 0, len(channels))
+		for k, channel := range channels {
+			routes = append(routes, r.withChannel(i, channel, amounts[k]).Split()...)
+		}
+		return routes
+	}
+	return []*Route{r}
+}
+
+// minPartAmount is the least amount that a part of r can deliver: the highest htlc minimum among the hops
+// but the one at index i, which every part carries at least. The channels of the hop at index i are checked
+// by splitParallel instead.
+func (r *Route) minPartAmount(i int) uint64 {
+	var floor uint64
+	for j, hop := range r.Hops {
+		if j != i {
+			floor = util.Max(floor, hop.minHtlcMsat)
+		}
+	}
+	return floor
+}
+
+// minShare is the least amount that the hop at index i must carry for a part to deliver amount,
+// through the most expensive of its parallel channels
+func (r *Route) minShare(i int, amount uint64) uint64 {
+	if amount == 0 {
+		return 0
+	}
+	var share uint64
+	for _, channel := range r.Hops[i].parallel {
+		share = util.Max(share, r.withChannel(i, channel, amount).Hops[i].MilliSatoshi)
+	}
+	return share
+}
+
+// SplitFee is the total fee of the routes that r is split into
+func (r *Route) SplitFee() uint64 {
+	var fee uint64
+	for _, route := range r.Split() {
+		fee += route.Fee()
+	}
+	return fee
+}
+
+// withChannel returns a copy of r delivering amount, with channel in place of the channel of the hop at index i
+func (r *Route) withChannel(i int, channel *Channel, amount uint64) *Route {
+	route := *r
+	route.Amount = amount
+	route.Hops = make([]RouteHop, len(r.Hops))
+	copy(route.Hops, r.Hops)
+	route.Hops[i].Channel = channel
+	route.Hops[len(route.Hops)-1].MilliSatoshi = amount
+	route.recomputeFeeAndDelay()
+	return &route
+}
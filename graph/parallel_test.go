@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPathfinderAggregatesParallelChannels(t *testing.T) {
+	self, a, b := testNodeId(0), testNodeId(1), testNodeId(2)
+	out := newTestChannel(self, a, "1x1x1", 2000000, 1000, 100, 40)
+	in := newTestChannel(b, self, "4x4x4", 2000000, 1000, 100, 40)
+	// a -> b has two channels with 400k sats of liquidity each: 600k sats only fit in both
+	first := newTestChannel(a, b, "2x2x2", 800000, 1000, 100, 40)
+	second := newTestChannel(a, b, "3x3x3", 800000, 1000, 100, 40)
+	graph := newTestGraph(out, first, second, in)
+	amount := uint64(600000000)
+
+	_, err := graph.GetRoute(a, b, amount, nil, 10, nil)
+	assert.Equal(t, util.ErrNoRoute, err)
+
+	options := NewRouteOptions()
+	options.AggregateParallel = true
+	route, err := graph.GetRoute(a, b, amount, nil, 10, options)
+	assert.NoError(t, err)
+	route.Prepend(out)
+	route.Append(in)
+	assert.Equal(t, 3, len(route.Hops))
+	assert.True(t, route.Hops[1].IsAggregate())
+
+	// the route is sent as one part for each channel, each within its liquidity
+	parts := route.Split()
+	assert.Equal(t, 2, len(parts))
+	scids := make(map[string]bool)
+	var delivered uint64
+	for _, part := range parts {
+		assert.False(t, part.Hops[1].IsAggregate())
+		assert.LessOrEqual(t, part.Hops[1].MilliSatoshi, part.Hops[1].Liquidity)
+		assert.NoError(t, part.CheckHtlcBounds())
+		assert.Equal(t, out.ShortChannelId, part.Hops[0].ShortChannelId)
+		assert.Equal(t, in.ShortChannelId, part.Hops[2].ShortChannelId)
+		scids[part.Hops[1].ShortChannelId] = true
+		delivered += part.Amount
+	}
+	assert.Equal(t, map[string]bool{"2x2x2": true, "3x3x3": true}, scids)
+	assert.Equal(t, amount, delivered)
+	// every part pays the base fees of its hops
+	assert.Equal(t, parts[0].Fee()+parts[1].Fee(), route.SplitFee())
+	assert.Equal(t, route.Fee()+first.BaseFeeMillisatoshi+in.BaseFeeMillisatoshi, route.SplitFee())
+
+	// the pre-filter doesn't drop the channels that are only usable together
+	options.PreFilter = true
+	route, err = graph.GetRoute(a, b, amount, nil, 10, options)
+	assert.NoError(t, err)
+	assert.True(t, route.Hops[0].IsAggregate())
+	options.PreFilter = false
+
+	// a single channel that can carry the amount is used as it is
+	route, err = graph.GetRoute(a, b, 300000000, nil, 10, options)
+	assert.NoError(t, err)
+	assert.False(t, route.Hops[0].IsAggregate())
+	assert.Equal(t, 1, len(route.Split()))
+}
+
+func TestSplitSnapsPartsToTheHtlcMinimumOfTheRoute(t *testing.T) {
+	self, a, b := testNodeId(0), testNodeId(1), testNodeId(2)
+	out := newTestChannel(self, a, "1x1x1", 2000000, 1000, 100, 40)
+	in := newTestChannel(b, self, "4x4x4", 2000000, 1000, 100, 40)
+	// the incoming channel refuses anything under 150k sats
+	in.minHtlcMsat = 150000000
+	first := newTestChannel(a, b, "2x2x2", 800000, 1000, 100, 40)
+	second := newTestChannel(a, b, "3x3x3", 800000, 1000, 100, 40)
+	graph := newTestGraph(out, first, second, in)
+	amount := uint64(500000000)
+
+	options := NewRouteOptions()
+	options.AggregateParallel = true
+	route, err := graph.GetRoute(a, b, amount, nil, 10, options)
+	assert.NoError(t, err)
+	route.Prepend(out)
+	route.Append(in)
+
+	// filling the fullest channel first would leave 100k sats to the other one: it takes 150k sats instead
+	parts := route.Split()
+	assert.Equal(t, 2, len(parts))
+	var delivered uint64
+	for _, part := range parts {
+		assert.GreaterOrEqual(t, part.Amount, in.minHtlcMsat)
+		assert.NoError(t, part.CheckHtlcBounds())
+		assert.LessOrEqual(t, part.Hops[1].MilliSatoshi, part.Hops[1].Liquidity)
+		delivered += part.Amount
+	}
+	assert.Equal(t, amount, delivered)
+
+	// when no split leaves both parts above the minimum, a single part carries all of it
+	in.minHtlcMsat = 300000000
+	parts = route.Split()
+	assert.Equal(t, 1, len(parts))
+	assert.Equal(t, amount, parts[0].Amount)
+}
@@ -4,22 +4,90 @@ import (
 	"circular/util"
 	"container/heap"
 	"log"
+	"math"
 	"strings"
+	"time"
 )
 
-func (g *Graph) GetRoute(src, dst string, amount uint64, exclude map[string]bool, maxHops int) (*Route, error) {
-	hops, err := g.dijkstra(src, dst, amount, exclude, maxHops-2) // -2 because we already know the source and destination
+func (g *Graph) GetRoute(src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) (*Route, error) {
+	if src == dst {
+		return nil, util.ErrSameSourceAndDestination
+	}
+	if options == nil {
+		options = NewRouteOptions()
+	}
+	var (
+		route *Route
+		err   error
+	)
+	if options.MinProbability > 0 {
+		route, err = g.getLikelyRoute(src, dst, amount, exclude, maxHops, options)
+	} else {
+		route, err = g.getRoute(src, dst, amount, exclude, maxHops, options)
+	}
+	if err == util.ErrNoRoute && options.Allowlist && g.allowlistDisconnects(src, dst) {
+		return nil, util.ErrAllowlistDisconnected
+	}
+	return route, err
+}
+
+func (g *Graph) getRoute(src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) (*Route, error) {
+	// the shares of aggregated hops depend on the exact amount, they can't be cached by bucket
+	if options.CacheRoutes && !options.AggregateParallel {
+		return g.getCachedRoute(src, dst, amount, exclude, maxHops, options)
+	}
+
+	hops, err := g.dijkstra(src, dst, amount, exclude, maxHops-2, options) // -2 because we already know the source and destination
 	if err != nil {
 		return nil, err
 	}
 
 	route := NewRoute(src, dst, amount, hops, g)
+	route.InboundFees = options.InboundFees
+	route.Probability = route.computeProbability()
+	return route, nil
+}
+
+// getCachedRoute looks for a route for the bucketed amount, reusing the cached one if the graph didn't change.
+// The route might be slightly suboptimal for amount, since it was searched for the bucketed amount.
+// If one of its hops can't forward amount after all, for example below its htlc minimum, the route is searched for amount.
+func (g *Graph) getCachedRoute(src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) (*Route, error) {
+	bucket := options.BucketAmount(amount)
+	key := newRouteCacheKey(src, dst, bucket, exclude, maxHops)
+	version := g.Version()
+
+	hops, ok := g.cache.get(key, version, options)
+	if !ok {
+		var err error
+		hops, err = g.dijkstra(src, dst, bucket, exclude, maxHops-2, options)
+		if err != nil {
+			return nil, err
+		}
+		g.cache.put(key, version, options, hops)
+	}
+
+	g.channelsLock.RLock()
+	costed, ok := costHops(hops, amount, options.InboundFees)
+	g.channelsLock.RUnlock()
+	if !ok {
+		var err error
+		if costed, err = g.dijkstra(src, dst, amount, exclude, maxHops-2, options); err != nil {
+			return nil, err
+		}
+	}
+
+	route := NewRoute(src, dst, amount, costed, g)
+	route.InboundFees = options.InboundFees
+	route.Probability = route.computeProbability()
 	return route, nil
 }
 
-func (g *Graph) dijkstra(src, dst string, amount uint64, exclude map[string]bool, maxHops int) ([]RouteHop, error) {
-	// start from the destination and find the source so that we can compute fees
-	// TODO: consider that 32bits fees can be a problem but the api does it in that way
+func (g *Graph) dijkstra(src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) ([]RouteHop, error) {
+	// start from the destination and find the source so that we can compute fees.
+	// Distances are int64 whatever the platform, the fees of the hops are bounded by MAX_HOP_FEE
+	if options == nil {
+		options = NewRouteOptions()
+	}
 	g.channelsLock.RLock()
 	g.adjacencyListLock.RLock()
 	defer g.channelsLock.RUnlock()
@@ -32,42 +100,73 @@ func (g *Graph) dijkstra(src, dst string, amount uint64, exclude map[string]bool
 		return nil, util.ErrNoSuchNode
 	}
 
+	inbound := g.Inbound
+	// the pre-filter would drop the channels too small alone, which aggregateParallel combines
+	if options.PreFilter && !options.AggregateParallel {
+		inbound = g.filterInbound(amount)
+	}
+
+	if options.Allowlist {
+		g.allowlist.lock.RLock()
+		defer g.allowlist.lock.RUnlock()
+	}
+
+	now := time.Now()
+	if options.ReliabilityWeight > 0 {
+		g.reliability.lock.RLock()
+		defer g.reliability.lock.RUnlock()
+	}
+
 	// initialize data structures
-	distance := make(map[string]int)
-	maxDistance := 1 << 31
-	for u := range g.Inbound {
+	distance := make(map[string]int64)
+	var maxDistance int64 = math.MaxInt64
+	for u := range inbound {
 		distance[u] = maxDistance
 	}
 	distance[dst] = 0
 	hop := make(map[string]RouteHop)
+	// best is the queue item describing the best path found so far from each node
+	best := make(map[string]*PqItem)
+	settled := make(map[string]bool)
 
 	// initialize priority queue, put destination in
-	pq := make(PriorityQueue, 1, 16)
-	pq[0] = &Item{value: &PqItem{
-		Node:   dst,
-		Amount: amount,
-		Delay:  0,
-		Hops:   0,
-	}, priority: 0}
-	heap.Init(&pq)
+	pq := NewPriorityQueue(options)
+	best[dst] = &PqItem{
+		Node:         dst,
+		Amount:       amount,
+		Delay:        0,
+		Hops:         0,
+		MinLiquidity: math.MaxUint64,
+	}
+	heap.Push(pq, &Item{value: best[dst], priority: 0})
 
 	// main loop
 	for pq.Len() > 0 {
 		// get the node with the lowest distance from the priority queue
-		pqItem := heap.Pop(&pq).(*Item)
+		pqItem := heap.Pop(pq).(*Item)
 		u := pqItem.value.Node
 		amount := pqItem.value.Amount
 		delay := pqItem.value.Delay
 		hops := pqItem.value.Hops
-		priority := pqItem.priority
-		// if we already visited this node with a lower distance, ignore it
-		if priority > distance[u] {
+		minLiquidity := pqItem.value.MinLiquidity
+		// if we already visited this node or found a better path from it, ignore it
+		if settled[u] || pqItem.value != best[u] {
 			continue
 		}
 
-		// if we reached the source, we are done
+		// if we reached the source, we are done. With inbound fees, a discount might still
+		// lower its distance: the source is never settled and the search goes on
 		if u == src {
-			break
+			if !options.InboundFees {
+				break
+			}
+			continue
+		}
+		settled[u] = true
+
+		// a discount can at most cancel the fee charged by u, nothing cheaper than the source can come from here
+		if options.InboundFees && pqItem.priority-int64(pqItem.value.Fee) >= distance[src] {
+			continue
 		}
 
 		// if we reached the maximum number of hops, discard this node
@@ -75,55 +174,87 @@ func (g *Graph) dijkstra(src, dst string, amount uint64, exclude map[string]bool
 			continue
 		}
 
-		// check all the neighbors of the current node
-		for v, edge := range g.Inbound[u] {
-			if exclude[v] {
-				continue
+		// relax updates the priority queue if going from v to u through channel is a better way to reach v
+		relax := func(v, scid string, channel *Channel, carried, channelFee, channelCost uint64, inboundFee int64) {
+			if channelCost < options.MinHopCost {
+				channelCost = options.MinHopCost
+			}
+			if options.ReliabilityWeight > 0 {
+				// v is the node that has to forward the payment through this channel
+				failureRate := 1 - g.getScore(v, now)
+				channelCost += uint64(failureRate * float64(amount) * float64(options.ReliabilityWeight) / 1000000)
+			}
+			if options.FeeVolatilityWeight > 0 {
+				// the fee might be higher by the time the payment gets there
+				channelCost += uint64(channel.FeeVolatility() * float64(amount) * float64(options.FeeVolatilityWeight) / 1000000)
+			}
+			if options.RecentRoutePenalty > 0 && options.RecentChannels[scid] > 0 {
+				// the route was just used, it's probably depleted in this direction
+				channelCost += uint64(options.RecentChannels[scid] * float64(amount) * float64(options.RecentRoutePenalty) / 1000000)
+			}
+			if options.PreferredBias > 0 && options.PreferredNodes[v] {
+				// costs can't be negative, or settled nodes could get cheaper
+				bias := amount * options.PreferredBias / 1000000
+				if bias > channelCost {
+					bias = channelCost
+				}
+				channelCost -= bias
+			}
+			if options.ExploreBias > 0 && !channel.Evidence {
+				// going through the channel is worth it to learn its liquidity
+				bias := amount * options.ExploreBias / 1000000
+				if bias > channelCost {
+					bias = channelCost
+				}
+				channelCost -= bias
+			}
+			newDistance := addCost(distance[u], channelCost+options.hopCost(), inboundFee)
+			if newDistance > distance[v] || settled[v] {
+				return
 			}
 
-			// for each channel in the edge between two nodes (there may be multiple channels between two nodes)
-			for _, scid := range edge {
-
-				// some optimization for concatenating strings
-				var sb strings.Builder
-				sb.WriteString(scid)
-				sb.WriteString("/")
-				sb.WriteString(util.GetDirection(v, u))
-				channelId := sb.String()
+			candidate := &PqItem{
+				Node:         v,
+				Amount:       carried + channelFee,
+				Delay:        delay + channel.Delay,
+				Hops:         hops + 1,
+				MinLiquidity: minLiquidity,
+				Scid:         scid,
+				Fee:          channelFee,
+			}
+			if channel.Liquidity < minLiquidity {
+				candidate.MinLiquidity = channel.Liquidity
+			}
+			// on equal cost, the tie-break decides
+			if newDistance == distance[v] && !options.prefers(candidate, best[v]) {
+				return
+			}
 
-				//channelId := scid + "/" + util.GetDirection(v, u)
-				if _, ok := g.Channels[channelId]; !ok {
-					log.Println("channel not found:", channelId)
-					continue
-				}
-				channel := g.Channels[channelId]
+			// now v is reachable from u with a lower distance
+			distance[v] = newDistance
+			best[v] = candidate
 
-				// check if the channel is usable
-				if !channel.CanForward(amount) {
-					continue
-				}
+			// add v to the priority queue while computing fees, delay and hops
+			hop[v] = RouteHop{
+				channel,
+				candidate.Amount,
+				candidate.Delay,
+			}
+			heap.Push(pq, &Item{value: candidate, priority: newDistance})
+		}
 
-				// compute fees and update the priority queue if we found a better way to reach v
-				channelFee := channel.ComputeFee(amount)
-				newDistance := distance[u] + int(channelFee)
-				if newDistance < distance[v] {
-
-					// now v is reachable from u with a lower distance
-					distance[v] = newDistance
-
-					// add v to the priority queue while computing fees, delay and hops
-					hop[v] = RouteHop{
-						channel,
-						amount + channelFee,
-						delay + channel.Delay,
-					}
-					heap.Push(&pq, &Item{value: &PqItem{
-						Node:   v,
-						Amount: amount + channelFee,
-						Delay:  delay + channel.Delay,
-						Hops:   hops + 1,
-					}, priority: newDistance})
-				}
+		emit := func(c relaxCandidate) {
+			relax(c.v, c.scid, c.channel, c.carried, c.channelFee, c.channelCost, c.inboundFee)
+		}
+		// check all the neighbors of the current node. The channels of the hubs can be evaluated
+		// concurrently, the relaxations stay serial so that the result is the same
+		if options.SearchWorkers > 1 && len(inbound[u]) >= PARALLEL_MIN_NEIGHBORS {
+			for _, c := range g.evaluateEdgesParallel(u, src, dst, inbound[u], amount, pqItem.value.Fee, exclude, options) {
+				emit(c)
+			}
+		} else {
+			for v, edge := range inbound[u] {
+				g.evaluateEdge(u, v, src, dst, edge, amount, pqItem.value.Fee, exclude, options, emit)
 			}
 		}
 	}
@@ -139,3 +270,136 @@ func (g *Graph) dijkstra(src, dst string, amount uint64, exclude map[string]bool
 	}
 	return hops, nil
 }
+
+// relaxCandidate is a way to reach v from the node being settled, as found by evaluateEdge
+type relaxCandidate struct {
+	v           string
+	scid        string
+	channel     *Channel
+	carried     uint64
+	channelFee  uint64
+	channelCost uint64
+	inboundFee  int64
+}
+
+// evaluateEdge checks the channels going from v to u, the node being settled, and emits the usable ones.
+// amount is what u forwards and fee is the fee that u charges for it. It only reads the graph, so that
+// the edges of a node can be evaluated concurrently, while the caller holds the locks of the search.
+func (g *Graph) evaluateEdge(u, v, src, dst string, edge Edge, amount, fee uint64, exclude map[string]bool, options *RouteOptions, emit func(relaxCandidate)) {
+	if exclude[v] {
+		return
+	}
+	// u is allowed already, since it was reached
+	if options.Allowlist && v != src && !g.allowlist.nodes[v] {
+		return
+	}
+
+	// forwardable is true if one of the channels of the edge can forward the amount alone
+	forwardable := false
+	// for each channel in the edge between two nodes (there may be multiple channels between two nodes)
+	for _, scid := range edge {
+		if options.ExcludedChannels[scid] {
+			continue
+		}
+
+		// some optimization for concatenating strings
+		var sb strings.Builder
+		sb.WriteString(scid)
+		sb.WriteString("/")
+		sb.WriteString(util.GetDirection(v, u))
+		channelId := sb.String()
+
+		//channelId := scid + "/" + util.GetDirection(v, u)
+		if _, ok := g.Channels[channelId]; !ok {
+			log.Println("channel not found:", channelId)
+			continue
+		}
+		channel := g.Channels[channelId]
+
+		// private channels can't be used as intermediate hops
+		if !channel.IsPublic {
+			continue
+		}
+		if options.IsOutsideCapacityRange(channel) || options.IsOverMaxHopDelay(channel) {
+			continue
+		}
+		// the fees of channels without a policy are unknown, they are only used with a penalty
+		if !channel.HasFeePolicy() && options.MissingFeesPenalty == 0 {
+			continue
+		}
+		// our channels are only allowed as the local legs, which are not part of the search
+		if options.AvoidLocalChannels && (channel.Source == options.LocalNode || channel.Destination == options.LocalNode) {
+			continue
+		}
+
+		var inboundFee int64 = 0
+		if options.InboundFees && channel.Inbound != nil && u != dst {
+			// u charges the inbound fee of the channel on top of the fee of the channel it forwards through,
+			// which is already part of the distance of u: a discount can lower it, but not below zero
+			inboundFee = channel.Inbound.compute(amount)
+			if inboundFee < -int64(fee) {
+				inboundFee = -int64(fee)
+			}
+		}
+
+		// check if the channel is usable. The channel has to deliver to u what u forwards plus
+		// all the fees of u: amount already contains the fee of the channel that u forwards through
+		carried := uint64(int64(amount) + inboundFee)
+		if !channel.CanForward(carried) {
+			continue
+		}
+		forwardable = true
+		// the most depleted channels of the graph are probably unable to forward anything
+		if g.isDepleted(channel, options) {
+			continue
+		}
+		// the channel would only be usable because its liquidity is assumed, not known
+		if options.LacksEvidence(channel, carried) {
+			continue
+		}
+		// the probability of the route can't be higher than the one of any of its hops
+		if options.MinProbability > 0 && channel.SuccessProbability(carried) < options.MinProbability {
+			continue
+		}
+
+		// compute fees and update the priority queue if we found a better way to reach v
+		channelFee := channel.ComputeFee(carried)
+		// lightningd would refuse the route
+		if channelFee > MAX_HOP_FEE {
+			continue
+		}
+		channelCost := options.feeCost(channel, carried)
+		if !channel.HasFeePolicy() {
+			channelCost = carried * options.MissingFeesPenalty / 1000000
+		}
+		emit(relaxCandidate{v, scid, channel, carried, channelFee, channelCost, inboundFee})
+	}
+
+	// none of the channels can forward the amount alone, maybe all of them together can
+	if !forwardable && options.AggregateParallel && len(edge) > 1 {
+		if channel, channelFee := g.aggregateParallel(v, u, edge, amount, options); channel != nil && channelFee <= MAX_HOP_FEE {
+			emit(relaxCandidate{v, channel.ShortChannelId, channel, amount, channelFee, channelFee, 0})
+		}
+	}
+}
+
+// filterInbound returns a view of the adjacency list containing only the channels
+// that might be able to forward amount. The amount only grows while moving towards
+// the source, so the channels that are left out would have been skipped anyway.
+// Every node is kept, so that the view can be used in place of g.Inbound.
+func (g *Graph) filterInbound(amount uint64) map[string]map[string]Edge {
+	filtered := make(map[string]map[string]Edge, len(g.Inbound))
+	for u, edges := range g.Inbound {
+		filtered[u] = make(map[string]Edge)
+		for v, edge := range edges {
+			for _, scid := range edge {
+				channel, ok := g.Channels[scid+"/"+util.GetDirection(v, u)]
+				if !ok || !channel.MightForward(amount) {
+					continue
+				}
+				filtered[u][v] = append(filtered[u][v], scid)
+			}
+		}
+	}
+	return filtered
+}
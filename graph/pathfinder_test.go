@@ -4,6 +4,7 @@ import (
 	"circular/util"
 	"encoding/json"
 	"fmt"
+	"github.com/elementsproject/glightning/glightning"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"os"
@@ -47,7 +48,7 @@ func TestPathfinderBasic(t *testing.T) {
 	}
 	maxHops := 10
 
-	hops, err := graph.dijkstra(src, dst, uint64(amount), exclude, maxHops)
+	hops, err := graph.dijkstra(src, dst, uint64(amount), exclude, maxHops, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -90,8 +91,381 @@ func BenchmarkGraph_GetRoute(b *testing.B) {
 				src := ids[rand.Intn(len(ids))]
 				dst := ids[rand.Intn(len(ids))]
 				amount := uint64(rand.Intn(1000000000))
-				graph.GetRoute(src, dst, amount, nil, h)
+				graph.GetRoute(src, dst, amount, nil, h, nil)
 			}
 		})
 	}
 }
+
+func BenchmarkGraph_GetRoutePreFilter(b *testing.B) {
+	graph, err := LoadGraphFromFile("testdata", "mainnet_graph.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	rand.Seed(69)
+
+	ids := make([]string, 0, len(graph.Inbound))
+	for k := range graph.Inbound {
+		ids = append(ids, k)
+	}
+
+	// large amounts are the ones where pre-filtering pays off
+	amount := uint64(2000000000)
+	for _, options := range []*RouteOptions{{PreFilter: false}, {PreFilter: true}} {
+		b.Run(fmt.Sprintf("dijkstra_prefilter_%t", options.PreFilter), func(b *testing.B) {
+			b.N = 200
+			for i := 0; i < b.N; i++ {
+				src := ids[rand.Intn(len(ids))]
+				dst := ids[rand.Intn(len(ids))]
+				graph.GetRoute(src, dst, amount, nil, 8, options)
+			}
+		})
+	}
+}
+
+func TestPathfinderSkipsDisabledDirection(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	disabled := newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40)
+	// lightningd reports the direction whose channel_update has the disable bit as not active
+	disabled.IsActive = false
+	graph := newTestGraph(disabled, newTestChannel(b, a, "1x1x1", 1000000, 0, 1, 40))
+
+	_, err := graph.dijkstra(a, b, 100000000, nil, 10, nil)
+	assert.Equal(t, util.ErrNoRoute, err)
+
+	hops, err := graph.dijkstra(b, a, 100000000, nil, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(hops))
+	assert.Equal(t, b, hops[0].Source)
+}
+
+func TestPathfinderPicksBestParallelChannel(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	expensive := newTestChannel(a, b, "1x1x1", 1000000, 0, 500, 40)
+	cheap := newTestChannel(a, b, "2x2x2", 1000000, 0, 1, 40)
+	graph := newTestGraph(expensive, cheap, newTestChannel(b, a, "3x3x3", 1000000, 0, 1, 40))
+
+	hops, err := graph.dijkstra(a, b, 100000000, nil, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(hops))
+	assert.Equal(t, "2x2x2", hops[0].ShortChannelId)
+
+	// the cheap channel can't carry the amount, the other one can
+	cheap.Liquidity = 50000000
+	hops, err = graph.dijkstra(a, b, 100000000, nil, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "1x1x1", hops[0].ShortChannelId)
+}
+
+func TestPathfinderSkipsPrivateIntermediateChannels(t *testing.T) {
+	a, b, c, d := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	// a -> b -> d is the cheapest path but b -> d is private
+	private := newTestChannel(b, d, "2x2x2", 1000000, 0, 1, 40)
+	private.IsPublic = false
+	graph := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40),
+		private,
+		newTestChannel(a, c, "3x3x3", 1000000, 0, 100, 40),
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 100, 40),
+		newTestChannel(d, a, "5x5x5", 1000000, 0, 1, 40),
+	)
+
+	hops, err := graph.dijkstra(a, d, 100000000, nil, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(hops))
+	for _, hop := range hops {
+		assert.True(t, hop.IsPublic)
+	}
+	assert.Equal(t, c, hops[0].Destination)
+}
+
+func TestPathfinderPreFilterEqualsUnfiltered(t *testing.T) {
+	graph, err := LoadGraphFromFile("testdata", "graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := "02d41224b71a5346a656f8949c66d11495e39dac55ab8772f55c26ca515db910ea"
+	dst := "03c731efa9935d869d87e57d4496de2b3badfb9ec7dbbd40051fb19351027336c5"
+	prefilter := &RouteOptions{PreFilter: true}
+
+	for _, amount := range []uint64{1000000, 200000000, 2000000000, 20000000000} {
+		hops, err := graph.dijkstra(src, dst, amount, nil, 10, nil)
+		filteredHops, filteredErr := graph.dijkstra(src, dst, amount, nil, 10, prefilter)
+		assert.Equal(t, err, filteredErr)
+		if err != nil {
+			continue
+		}
+		// equal cost routes might differ, but the cost must be the same
+		assert.Equal(t, hops[0].MilliSatoshi, filteredHops[0].MilliSatoshi)
+		assert.Equal(t, len(hops), len(filteredHops))
+	}
+}
+
+func TestGetRouteSameSourceAndDestination(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	graph := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40),
+		newTestChannel(b, a, "1x1x1", 1000000, 0, 1, 40),
+	)
+
+	_, err := graph.GetRoute(a, a, 100000000, nil, 10, nil)
+	assert.Equal(t, util.ErrSameSourceAndDestination, err)
+}
+
+func TestPathfinderMinHopCostSpreadsUsage(t *testing.T) {
+	a, b, c, d, e := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4), testNodeId(5)
+	// a -> b -> c -> e is a zero-fee corridor, a -> d -> e charges a tiny fee
+	graph := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 0, 40),
+		newTestChannel(b, c, "2x2x2", 1000000, 0, 0, 40),
+		newTestChannel(c, e, "3x3x3", 1000000, 0, 0, 40),
+		newTestChannel(a, d, "4x4x4", 1000000, 0, 1, 40),
+		newTestChannel(d, e, "5x5x5", 1000000, 0, 1, 40),
+		newTestChannel(e, a, "6x6x6", 1000000, 0, 1, 40),
+	)
+	amount := uint64(100000000)
+
+	hops, err := graph.dijkstra(a, e, amount, nil, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(hops))
+	assert.Equal(t, amount, hops[0].MilliSatoshi)
+
+	// with a minimum cost per hop the shorter, cheap route is preferred
+	hops, err = graph.dijkstra(a, e, amount, nil, 10, &RouteOptions{MinHopCost: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(hops))
+	assert.Equal(t, d, hops[0].Destination)
+
+	// the fees are the real ones, the synthetic cost only affects the ranking
+	assert.Equal(t, amount+hops[1].ComputeFee(amount)+hops[0].ComputeFee(amount+hops[1].ComputeFee(amount)), hops[0].MilliSatoshi)
+}
+
+func TestPathfinderTieBreak(t *testing.T) {
+	src, a, b, c, dst := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4), testNodeId(5)
+	newGraph := func() *Graph {
+		// three zero-fee paths from src to dst: two of 2 hops, through a and b, and one of 3 hops through c and a
+		return newTestGraph(
+			newTestChannel(src, a, "5x1x1", 1500000, 0, 0, 10),
+			newTestChannel(a, dst, "6x1x1", 2000000, 0, 0, 10),
+			newTestChannel(src, b, "1x1x1", 1000000, 0, 0, 10),
+			newTestChannel(b, dst, "2x1x1", 1000000, 0, 0, 10),
+			newTestChannel(src, c, "3x1x1", 5000000, 0, 0, 10),
+			newTestChannel(c, a, "4x1x1", 5000000, 0, 0, 10),
+			newTestChannel(dst, src, "7x1x1", 1000000, 0, 0, 10),
+		)
+	}
+	amount := uint64(100000000)
+
+	cases := []struct {
+		tieBreak string
+		scids    []string
+	}{
+		{DEFAULT_TIE_BREAK, []string{"5x1x1", "6x1x1"}},
+		{"scid", []string{"1x1x1", "2x1x1"}},
+		{"liquidity,hops", []string{"3x1x1", "4x1x1", "6x1x1"}},
+	}
+	for _, tc := range cases {
+		options := NewRouteOptions()
+		var err error
+		options.TieBreak, err = ParseTieBreak(tc.tieBreak)
+		assert.NoError(t, err)
+
+		// the choice must not depend on map iteration order
+		for i := 0; i < 20; i++ {
+			hops, err := newGraph().dijkstra(src, dst, amount, map[string]bool{}, 5, options)
+			assert.NoError(t, err)
+			scids := make([]string, 0, len(hops))
+			for _, hop := range hops {
+				scids = append(scids, hop.ShortChannelId)
+			}
+			assert.Equal(t, tc.scids, scids, tc.tieBreak)
+		}
+	}
+
+	_, err := ParseTieBreak("hops,fees")
+	assert.Equal(t, util.ErrInvalidTieBreak, err)
+}
+
+func TestPathfinderChecksAmountWithFees(t *testing.T) {
+	src, a, b, dst := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	amount := uint64(100000000)
+	srcToA := newTestChannel(src, a, "1x1x1", 1000000, 0, 0, 10)
+	aToDst := newTestChannel(a, dst, "2x1x1", 1000000, 1000, 0, 10)
+	g := newTestGraph(
+		srcToA,
+		aToDst,
+		newTestChannel(src, b, "3x1x1", 1000000, 0, 0, 10),
+		newTestChannel(b, dst, "4x1x1", 1000000, 2000, 0, 10),
+		newTestChannel(dst, src, "5x1x1", 1000000, 0, 0, 10),
+	)
+	// src -> a can carry the amount, but not the amount plus the fee of a
+	srcToA.maxHtlcMsat = amount + 999
+
+	options := NewRouteOptions()
+	route, err := g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "3x1x1", route.Hops[0].ShortChannelId)
+
+	// it can carry the fee of a, but not the inbound fee that a charges on top of it
+	srcToA.maxHtlcMsat = amount + 1000
+	route, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "1x1x1", route.Hops[0].ShortChannelId)
+
+	srcToA.Inbound = &InboundFee{BaseMsat: 1}
+	options.InboundFees = true
+	route, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "3x1x1", route.Hops[0].ShortChannelId)
+}
+
+func TestPathfinderAvoidLocalChannels(t *testing.T) {
+	us, a, b, c, dst := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4), testNodeId(5)
+	g := newTestGraph(
+		// through us for free
+		newTestChannel(a, us, "1x1x1", 1000000, 0, 0, 10),
+		newTestChannel(us, dst, "2x1x1", 1000000, 0, 0, 10),
+		// around us, with a fee
+		newTestChannel(a, b, "3x1x1", 1000000, 0, 100, 10),
+		newTestChannel(b, dst, "4x1x1", 1000000, 0, 100, 10),
+		newTestChannel(dst, c, "5x1x1", 1000000, 0, 0, 10),
+		newTestChannel(c, a, "6x1x1", 1000000, 0, 0, 10),
+	)
+	amount := uint64(100000000)
+
+	// without excluding ourselves, our channels are the cheapest intermediate hops
+	options := NewRouteOptions()
+	options.LocalNode = us
+	route, err := g.GetRoute(a, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "1x1x1", route.Hops[0].ShortChannelId)
+
+	options.AvoidLocalChannels = true
+	route, err = g.GetRoute(a, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "3x1x1", route.Hops[0].ShortChannelId)
+}
+
+func TestPathfinderPreferredNodes(t *testing.T) {
+	src, a, b, dst := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	newGraph := func() *Graph {
+		return newTestGraph(
+			// through a is slightly cheaper than through b
+			newTestChannel(src, a, "1x1x1", 1000000, 0, 0, 10),
+			newTestChannel(a, dst, "2x1x1", 1000000, 0, 100, 10),
+			newTestChannel(src, b, "3x1x1", 1000000, 0, 0, 10),
+			newTestChannel(b, dst, "4x1x1", 1000000, 0, 150, 10),
+			newTestChannel(dst, src, "5x1x1", 1000000, 0, 0, 10),
+		)
+	}
+	amount := uint64(100000000)
+
+	options := NewRouteOptions()
+	route, err := newGraph().GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, a, route.Hops[0].Destination)
+
+	// a bias smaller than the difference in fees doesn't change the route
+	options.PreferredNodes = ParsePreferredNodes(" " + b + ",")
+	options.PreferredBias = 25
+	route, err = newGraph().GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, a, route.Hops[0].Destination)
+
+	// a bigger one steers the route through b, and the cost of its channel stays at zero
+	options.PreferredBias = 1000
+	route, err = newGraph().GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, b, route.Hops[0].Destination)
+	// the fees paid are the real ones
+	assert.Equal(t, amount+route.Hops[1].ComputeFee(amount), route.Hops[0].MilliSatoshi)
+}
+
+func TestPathfinderMissingFeePolicy(t *testing.T) {
+	src, a, b, dst := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	// a channel seen without a channel_update: no fees, no last_update
+	var unknown glightning.Channel
+	err := json.Unmarshal([]byte(`{"source": "`+a+`", "destination": "`+dst+`", "short_channel_id": "2x1x1",
+		"public": true, "satoshis": 1000000, "amount_msat": "1000000000msat", "active": true, "delay": 10,
+		"htlc_minimum_msat": "1000msat", "htlc_maximum_msat": "1000000000msat"}`), &unknown)
+	assert.NoError(t, err)
+	newGraph := func() *Graph {
+		return newTestGraph(
+			newTestChannel(src, a, "1x1x1", 1000000, 0, 0, 10),
+			NewChannel(&unknown, 500000000, 0),
+			newTestChannel(src, b, "3x1x1", 1000000, 0, 0, 10),
+			newTestChannel(b, dst, "4x1x1", 1000000, 0, 500, 10),
+			newTestChannel(dst, src, "5x1x1", 1000000, 0, 0, 10),
+		)
+	}
+	amount := uint64(100000000)
+
+	g := newGraph()
+	assert.False(t, g.Channels["2x1x1/"+util.GetDirection(a, dst)].HasFeePolicy())
+	assert.Equal(t, 1, g.GetStats().NoFeesChannels)
+
+	// by default the channel is skipped, even if it looks free
+	options := NewRouteOptions()
+	route, err := g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, b, route.Hops[0].Destination)
+
+	// with a small penalty it is used
+	options.MissingFeesPenalty = 100
+	route, err = newGraph().GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, a, route.Hops[0].Destination)
+
+	// with a penalty above the fees of the alternative it isn't
+	options.MissingFeesPenalty = 1000
+	route, err = newGraph().GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, b, route.Hops[0].Destination)
+}
+
+// BenchmarkGraph_GetRouteDuringRefresh measures the route searches while the graph is refreshed
+// over and over, which only blocks them while the new channels are swapped in
+func BenchmarkGraph_GetRouteDuringRefresh(b *testing.B) {
+	graph, err := LoadGraphFromFile("testdata", "mainnet_graph.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	rand.Seed(69)
+
+	ids := make([]string, 0, len(graph.Inbound))
+	for k := range graph.Inbound {
+		ids = append(ids, k)
+	}
+	channelList := make([]*glightning.Channel, 0, len(graph.Channels))
+	for _, c := range graph.Channels {
+		channelList = append(channelList, c.Channel)
+	}
+
+	for _, refresh := range []bool{false, true} {
+		b.Run(fmt.Sprintf("dijkstra_refreshing_%t", refresh), func(b *testing.B) {
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for refresh {
+					select {
+					case <-stop:
+						return
+					default:
+						graph.RefreshChannels(channelList)
+					}
+				}
+			}()
+
+			b.N = 200
+			for i := 0; i < b.N; i++ {
+				src := ids[rand.Intn(len(ids))]
+				dst := ids[rand.Intn(len(ids))]
+				amount := uint64(rand.Intn(1000000000))
+				graph.GetRoute(src, dst, amount, nil, 5, nil)
+			}
+			close(stop)
+			<-done
+		})
+	}
+}
@@ -9,51 +9,82 @@ type PqItem struct {
 	Amount uint64
 	Delay  uint
 	Hops   int
+	// MinLiquidity is the liquidity of the least liquid channel between Node and the destination
+	MinLiquidity uint64
+	// Scid is the channel used to leave Node
+	Scid string
+	// Fee is the fee of the channel used to leave Node
+	Fee uint64
 }
 
 // Priority queue implementation from https://pkg.go.dev/container/heap#example__priorityQueue
 type Item struct {
 	value    *PqItem // The id of the value.
-	priority int     // The priority of the value in the queue.
+	priority int64   // The priority of the value in the queue.
 	// The index is needed by update and is maintained by the heap.Interface methods.
 	index int // The index of the item in the heap.
 }
 
 // A PriorityQueue implements heap.Interface and holds Items.
-type PriorityQueue []*Item
+// Items with the same priority are ordered by the tie-break of options, then by hops and node id,
+// so that the order doesn't depend on map iteration.
+type PriorityQueue struct {
+	items   []*Item
+	options *RouteOptions
+}
+
+func NewPriorityQueue(options *RouteOptions) *PriorityQueue {
+	return &PriorityQueue{
+		items:   make([]*Item, 0, 16),
+		options: options,
+	}
+}
 
-func (pq PriorityQueue) Len() int { return len(pq) }
+func (pq *PriorityQueue) Len() int { return len(pq.items) }
 
-func (pq PriorityQueue) Less(i, j int) bool {
+func (pq *PriorityQueue) Less(i, j int) bool {
 	// We want Pop to give us the lowest priority (lowest fee)
-	return pq[i].priority < pq[j].priority
+	a, b := pq.items[i], pq.items[j]
+	if a.priority != b.priority {
+		return a.priority < b.priority
+	}
+	if pq.options.prefers(a.value, b.value) {
+		return true
+	}
+	if pq.options.prefers(b.value, a.value) {
+		return false
+	}
+	if a.value.Hops != b.value.Hops {
+		return a.value.Hops < b.value.Hops
+	}
+	return a.value.Node < b.value.Node
 }
 
-func (pq PriorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
-	pq[i].index = i
-	pq[j].index = j
+func (pq *PriorityQueue) Swap(i, j int) {
+	pq.items[i], pq.items[j] = pq.items[j], pq.items[i]
+	pq.items[i].index = i
+	pq.items[j].index = j
 }
 
 func (pq *PriorityQueue) Push(x any) {
-	n := len(*pq)
+	n := len(pq.items)
 	item := x.(*Item)
 	item.index = n
-	*pq = append(*pq, item)
+	pq.items = append(pq.items, item)
 }
 
 func (pq *PriorityQueue) Pop() any {
-	old := *pq
+	old := pq.items
 	n := len(old)
 	item := old[n-1]
 	old[n-1] = nil  // avoid memory leak
 	item.index = -1 // for safety
-	*pq = old[0 : n-1]
+	pq.items = old[0 : n-1]
 	return item
 }
 
 // update modifies the priority and value of an Item in the queue.
-func (pq *PriorityQueue) update(item *Item, value *PqItem, priority int) {
+func (pq *PriorityQueue) update(item *Item, value *PqItem, priority int64) {
 	item.value = value
 	item.priority = priority
 	heap.Fix(pq, item.index)
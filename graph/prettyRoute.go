@@ -1,8 +1,22 @@
 package graph
 
 import (
+	"circular/util"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ROUTE_FORMAT_JSON     = "json"
+	ROUTE_FORMAT_SIMPLE   = "simple"
+	ROUTE_FORMAT_DETAILED = "detailed"
+	ROUTE_FORMAT_ALIASES  = "aliases"
+	// ROUTE_FORMAT_SENDPAY is the route parameter of the sendpay command of lightningd, see SendPayRoute
+	ROUTE_FORMAT_SENDPAY = "sendpay"
+
+	DEFAULT_ROUTE_FORMAT = ROUTE_FORMAT_JSON
 )
 
 type PrettyRouteHop struct {
@@ -24,7 +38,16 @@ type PrettyRoute struct {
 	Amount           uint64           `json:"amount_sat"`
 	Fee              uint64           `json:"fee_msat"`
 	FeePPM           uint64           `json:"ppm"`
+	BaseFee          uint64           `json:"base_fee_msat"`
+	Probability      float64          `json:"probability"`
 	Hops             []PrettyRouteHop `json:"hops"`
+	// GraphAge is the time since the last successful refresh of the graph the route was found on,
+	// StaleGraph tells that it's over the stale threshold, so that fees and liquidity might be outdated
+	GraphAge   int64 `json:"graph_age_seconds"`
+	StaleGraph bool  `json:"stale_graph,omitempty"`
+	// lastAlias and lastId are the alias and the id of the node the route ends at, which is not the source of any hop
+	lastAlias string
+	lastId    string
 }
 
 func NewPrettyRoute(route *Route, paymentHash string) *PrettyRoute {
@@ -67,16 +90,39 @@ func NewPrettyRoute(route *Route, paymentHash string) *PrettyRoute {
 		Amount:           route.Amount / 1000,
 		Fee:              route.Fee(),
 		FeePPM:           route.FeePPM(),
+		BaseFee:          route.BaseFees(),
+		Probability:      route.Probability,
 		Hops:             hops,
+		lastAlias:        route.Graph.GetAlias(route.Hops[len(route.Hops)-1].Destination),
+		lastId:           route.Hops[len(route.Hops)-1].Destination,
+	}
+}
+
+// SetGraphAge records the age of the graph the route was found on
+func (r *PrettyRoute) SetGraphAge(age time.Duration, stale bool) {
+	r.GraphAge = int64(age.Seconds())
+	r.StaleGraph = stale
+}
+
+// graphAgeWarning warns that the route was found on a stale graph, it's empty if it wasn't
+func (r *PrettyRoute) graphAgeWarning() string {
+	if !r.StaleGraph {
+		return ""
 	}
+	return "WARNING: the graph is " + (time.Duration(r.GraphAge) * time.Second).String() +
+		" old, fees and liquidity might be outdated, refresh it before sending large amounts"
 }
 
 func (r *PrettyRoute) String() string {
 	var result string
 	result += "Route from: " + r.SourceAlias + " to: " + r.DestinationAlias + "\n"
 	result += "Amount: " + strconv.FormatUint(r.Amount, 10) + "\n"
-	result += "Fee: " + strconv.FormatUint(r.Fee, 10) + "msat\n"
-	result += "Fee PPM: " + strconv.FormatUint(r.FeePPM, 10) + "\n"
+	result += "Fee: " + formatFee(r.Fee, r.FeePPM) + "\n"
+	result += fmt.Sprintf("Probability: %.2f%%\n", r.Probability*100)
+	result += "Graph age: " + (time.Duration(r.GraphAge) * time.Second).String() + "\n"
+	if warning := r.graphAgeWarning(); warning != "" {
+		result += warning + "\n"
+	}
 	result += "Hops: " + strconv.Itoa(len(r.Hops)) + "\n"
 
 	for i := 0; i < len(r.Hops); i++ {
@@ -86,9 +132,9 @@ func (r *PrettyRoute) String() string {
 		delay := r.Hops[i].Delay
 		shortChannelId := r.Hops[i].ShortChannelId
 
-		result += fmt.Sprintf("Hop %2d: %40s, fee: %8.3f, ppm: %5d, scid: %s, delay: %d\n",
+		result += fmt.Sprintf("Hop %2d: %40s, fee: %22s, scid: %s, delay: %d\n",
 			i+1, alias,
-			float64(fee)/1000, feePPM,
+			formatFee(fee, feePPM),
 			shortChannelId, delay)
 	}
 	return result
@@ -97,13 +143,84 @@ func (r *PrettyRoute) String() string {
 func (r *PrettyRoute) Simple() string {
 	var result string
 	result += "Sending " + strconv.FormatUint(r.Amount, 10) + " sats from [" + r.SourceAlias + "] to [" + r.DestinationAlias
-	result += "] over " + strconv.Itoa(len(r.Hops)) + " hops, costing " + strconv.FormatUint(r.Fee, 10) + "msat ("
-	result += strconv.FormatUint(r.FeePPM, 10) + "PPM)"
+	result += "] over " + strconv.Itoa(len(r.Hops)) + " hops, costing " + formatFee(r.Fee, r.FeePPM)
 	result += " via "
 	for i := 0; i < len(r.Hops); i++ {
 		alias := r.Hops[i].Alias
-		feePPM := r.Hops[i].FeePPM
-		result += "- " + alias + " (" + strconv.FormatUint(feePPM, 10) + "PPM) "
+		result += "- " + alias + ": " + formatFee(r.Hops[i].Fee, r.Hops[i].FeePPM) + " "
+	}
+	if warning := r.graphAgeWarning(); warning != "" {
+		result += "- " + warning
 	}
 	return result
 }
+
+// formatFee renders a fee both in msat and in ppm, since for small amounts the ppm alone
+// makes a tiny fee look huge, and for large amounts the msat alone hide how expensive it is
+func formatFee(fee, feePPM uint64) string {
+	return strconv.FormatUint(fee, 10) + "msat (" + strconv.FormatUint(feePPM, 10) + "ppm)"
+}
+
+// Aliases is the chain of the nodes of the route, with the channels between them
+func (r *PrettyRoute) Aliases() string {
+	var sb strings.Builder
+	for _, hop := range r.Hops {
+		sb.WriteString(hop.Alias)
+		sb.WriteString(" -[")
+		sb.WriteString(hop.ShortChannelId)
+		sb.WriteString("]-> ")
+	}
+	sb.WriteString(r.lastAlias)
+	return sb.String()
+}
+
+// Format renders the route as text in one of the route formats. ROUTE_FORMAT_JSON has no text form,
+// the route itself is the JSON, so it renders as Simple like any unknown format.
+func (r *PrettyRoute) Format(format string) string {
+	switch format {
+	case ROUTE_FORMAT_DETAILED:
+		return r.String()
+	case ROUTE_FORMAT_ALIASES:
+		return r.Aliases()
+	}
+	return r.Simple()
+}
+
+func ValidateRouteFormat(format string) error {
+	switch format {
+	case "", ROUTE_FORMAT_JSON, ROUTE_FORMAT_SIMPLE, ROUTE_FORMAT_DETAILED, ROUTE_FORMAT_ALIASES, ROUTE_FORMAT_SENDPAY:
+		return nil
+	}
+	return util.ErrInvalidRouteFormat
+}
+
+// SendPayHop is a hop of the route parameter of the sendpay command of lightningd
+type SendPayHop struct {
+	Id         string `json:"id"`
+	Channel    string `json:"channel"`
+	Delay      uint   `json:"delay"`
+	AmountMsat uint64 `json:"amount_msat"`
+}
+
+// SendPayRoute is the route in the format expected by sendpay, with the same amounts and delays that
+// Route.ToLightningRoute gives to SendPay: each hop names the node it delivers to, not the one it starts from.
+// It's empty for the routes read back from the database, which don't know the node they end at.
+func (r *PrettyRoute) SendPayRoute() []SendPayHop {
+	if r.lastId == "" {
+		return nil
+	}
+	hops := make([]SendPayHop, len(r.Hops))
+	for i, hop := range r.Hops {
+		hops[i] = SendPayHop{
+			Channel:    hop.ShortChannelId,
+			Delay:      hop.Delay,
+			AmountMsat: hop.MilliSatoshi,
+		}
+		if i+1 < len(r.Hops) {
+			hops[i].Id = r.Hops[i+1].Id
+		} else {
+			hops[i].Id = r.lastId
+		}
+	}
+	return hops
+}
@@ -0,0 +1,74 @@
+package graph
+
+import "circular/util"
+
+const (
+	// MAX_PROBABILITY_ATTEMPTS is how many routes are searched before giving up on finding one
+	// that is likely enough to succeed
+	MAX_PROBABILITY_ATTEMPTS = 5
+)
+
+// SuccessProbability estimates the probability that the channel can forward amount, assuming that
+// every liquidity between 0 and the capacity is equally likely
+func (c *Channel) SuccessProbability(amount uint64) float64 {
+	capacity := c.Satoshis * 1000
+	if amount > capacity {
+		return 0
+	}
+	return float64(capacity+1-amount) / float64(capacity+1)
+}
+
+// computeProbability is the product of the success probabilities of the hops. It's computed before
+// the local legs are added to the route, because their balances are known.
+func (r *Route) computeProbability() float64 {
+	probability := 1.0
+	for _, hop := range r.Hops {
+		probability *= hop.SuccessProbability(hop.MilliSatoshi)
+	}
+	return probability
+}
+
+// leastLikelyNode returns the node that forwards through the hop least likely to succeed,
+// or the node receiving from it when the forwarding node is src
+func (r *Route) leastLikelyNode() string {
+	worst := 0
+	for i, hop := range r.Hops {
+		if hop.SuccessProbability(hop.MilliSatoshi) < r.Hops[worst].SuccessProbability(r.Hops[worst].MilliSatoshi) {
+			worst = i
+		}
+	}
+	if r.Hops[worst].Source != r.Source {
+		return r.Hops[worst].Source
+	}
+	return r.Hops[worst].Destination
+}
+
+// getLikelyRoute looks for a route whose probability of success is at least options.MinProbability.
+// Every time the route found is not likely enough, the node of its least likely hop is excluded
+// and the search is done again, so the result might be more expensive than the cheapest route.
+func (g *Graph) getLikelyRoute(src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) (*Route, error) {
+	excluded := make(map[string]bool, len(exclude))
+	for id, ok := range exclude {
+		excluded[id] = ok
+	}
+
+	for i := 0; i < MAX_PROBABILITY_ATTEMPTS; i++ {
+		route, err := g.getRoute(src, dst, amount, excluded, maxHops, options)
+		if err != nil {
+			if i > 0 {
+				// there were routes, but we excluded them for being unlikely
+				return nil, util.ErrRouteTooUnlikely
+			}
+			return nil, err
+		}
+		if route.Probability >= options.MinProbability {
+			return route, nil
+		}
+		node := route.leastLikelyNode()
+		if node == dst {
+			break
+		}
+		excluded[node] = true
+	}
+	return nil, util.ErrRouteTooUnlikely
+}
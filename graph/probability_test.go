@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPathfinderMinProbability(t *testing.T) {
+	src, a, b, dst := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	g := newTestGraph(
+		newTestChannel(src, a, "1x1x1", 10000000, 0, 0, 10),
+		// cheap, but barely big enough for the amount
+		newTestChannel(a, dst, "2x1x1", 250000, 0, 100, 10),
+		newTestChannel(src, b, "3x1x1", 10000000, 0, 0, 10),
+		newTestChannel(b, dst, "4x1x1", 10000000, 0, 200, 10),
+		newTestChannel(dst, src, "5x1x1", 10000000, 0, 0, 10),
+	)
+	amount := uint64(100000000)
+
+	options := NewRouteOptions()
+	route, err := g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "1x1x1", route.Hops[0].ShortChannelId)
+	assert.Less(t, route.Probability, 0.6)
+
+	options.MinProbability = 0.9
+	route, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "3x1x1", route.Hops[0].ShortChannelId)
+	assert.GreaterOrEqual(t, route.Probability, 0.9)
+
+	options.MinProbability = 0.985
+	_, err = g.GetRoute(src, dst, amount, map[string]bool{}, 4, options)
+	assert.ErrorIs(t, err, util.ErrRouteTooUnlikely)
+}
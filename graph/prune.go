@@ -0,0 +1,58 @@
+package graph
+
+import (
+	"time"
+)
+
+// PruneChannel deletes both directions of the channel scid from the graph right away and returns how
+// many were deleted. The next refresh adds the channel back if lightningd still has it, unless it's banned.
+func (g *Graph) PruneChannel(scid string) int {
+	g.channelsLock.Lock()
+	g.adjacencyListLock.Lock()
+	defer g.channelsLock.Unlock()
+	defer g.adjacencyListLock.Unlock()
+
+	return g.pruneChannel(scid)
+}
+
+// pruneChannel is the same as PruneChannel, the caller must hold channelsLock and adjacencyListLock
+func (g *Graph) pruneChannel(scid string) int {
+	pruned := 0
+	for _, direction := range []string{"0", "1"} {
+		if c, ok := g.Channels[scid+"/"+direction]; ok {
+			g.DeleteChannel(c)
+			pruned++
+		}
+	}
+	if pruned > 0 {
+		g.version++
+	}
+	return pruned
+}
+
+// BanChannel prunes the channel scid and keeps it out of the graph until the time until,
+// whatever the refreshes say. It returns how many directions were deleted.
+// Bans are not saved with the graph, they are lost on restart.
+func (g *Graph) BanChannel(scid string, until time.Time) int {
+	g.channelsLock.Lock()
+	g.adjacencyListLock.Lock()
+	defer g.channelsLock.Unlock()
+	defer g.adjacencyListLock.Unlock()
+
+	g.bans[scid] = until
+	return g.pruneChannel(scid)
+}
+
+// isBanned tells whether the channel scid is banned at the time now, forgetting the expired bans.
+// The caller must hold channelsLock for writing.
+func (g *Graph) isBanned(scid string, now time.Time) bool {
+	until, ok := g.bans[scid]
+	if !ok {
+		return false
+	}
+	if now.After(until) {
+		delete(g.bans, scid)
+		return false
+	}
+	return true
+}
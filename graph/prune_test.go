@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPruneChannel(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	ab := newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40)
+	ba := newTestChannel(b, a, "1x1x1", 1000000, 0, 1, 40)
+	bc := newTestChannel(b, c, "2x2x2", 1000000, 0, 1, 40)
+	graph := newTestGraph(ab, ba, bc)
+	version := graph.Version()
+
+	assert.Equal(t, 2, graph.PruneChannel("1x1x1"))
+	assert.Greater(t, graph.Version(), version)
+	assert.NotContains(t, graph.Channels, "1x1x1/"+util.GetDirection(a, b))
+	assert.NotContains(t, graph.Channels, "1x1x1/"+util.GetDirection(b, a))
+	assert.NotContains(t, graph.Inbound[b][a], "1x1x1")
+	assert.NotContains(t, graph.Inbound[a][b], "1x1x1")
+	// the other channels are untouched
+	assert.Contains(t, graph.Channels, "2x2x2/"+util.GetDirection(b, c))
+	assert.Contains(t, graph.Inbound[c][b], "2x2x2")
+
+	assert.Equal(t, 0, graph.PruneChannel("1x1x1"))
+
+	// the next refresh adds the channel back
+	graph.RefreshChannels([]*glightning.Channel{ab.Channel, ba.Channel, bc.Channel})
+	assert.Contains(t, graph.Channels, "1x1x1/"+util.GetDirection(a, b))
+	assert.Contains(t, graph.Inbound[b][a], "1x1x1")
+}
+
+func TestBanChannel(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	ab := newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40)
+	ba := newTestChannel(b, a, "1x1x1", 1000000, 0, 1, 40)
+	graph := newTestGraph(ab, ba)
+
+	assert.Equal(t, 2, graph.BanChannel("1x1x1", time.Now().Add(time.Hour)))
+	graph.RefreshChannels([]*glightning.Channel{ab.Channel, ba.Channel})
+	assert.Empty(t, graph.Channels)
+	assert.Empty(t, graph.Inbound[b][a])
+
+	// once the ban expires, the refresh adds the channel back
+	graph.BanChannel("1x1x1", time.Now().Add(-time.Second))
+	graph.RefreshChannels([]*glightning.Channel{ab.Channel, ba.Channel})
+	assert.Len(t, graph.Channels, 2)
+	assert.Contains(t, graph.Inbound[b][a], "1x1x1")
+	assert.Empty(t, graph.bans)
+}
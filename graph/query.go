@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"circular/util"
+	"sort"
+)
+
+const (
+	SORT_BY_PPM        = "ppm"
+	SORT_BY_LIQUIDITY  = "liquidity"
+	SORT_BY_CAPACITY   = "capacity"
+	SORT_BY_VOLATILITY = "volatility"
+)
+
+type ChannelInfo struct {
+	ChannelId        string  `json:"channel_id"`
+	Source           string  `json:"source"`
+	Destination      string  `json:"destination"`
+	SourceAlias      string  `json:"source_alias"`
+	DestinationAlias string  `json:"destination_alias"`
+	Capacity         uint64  `json:"capacity_sat"`
+	BaseFee          uint64  `json:"base_fee_msat"`
+	FeePPM           uint64  `json:"ppm"`
+	Liquidity        uint64  `json:"liquidity_msat"`
+	LiquidityRatio   float64 `json:"liquidity_ratio"`
+	// FeeVolatility is how wildly the advertised fee changed over the last refreshes, see Channel.FeeVolatility
+	FeeVolatility float64 `json:"fee_volatility"`
+	VolatileFee   bool    `json:"volatile_fee,omitempty"`
+}
+
+// ChannelQuery selects, sorts and paginates the channels of the graph
+type ChannelQuery struct {
+	SortBy            string
+	Descending        bool
+	MinPPM            uint64
+	MaxPPM            uint64
+	MinLiquidityRatio float64
+	MaxLiquidityRatio float64
+	Limit             int
+	Offset            int
+}
+
+func (q *ChannelQuery) matches(info *ChannelInfo) bool {
+	if info.FeePPM < q.MinPPM {
+		return false
+	}
+	if q.MaxPPM > 0 && info.FeePPM > q.MaxPPM {
+		return false
+	}
+	if info.LiquidityRatio < q.MinLiquidityRatio {
+		return false
+	}
+	if q.MaxLiquidityRatio > 0 && info.LiquidityRatio > q.MaxLiquidityRatio {
+		return false
+	}
+	return true
+}
+
+func (q *ChannelQuery) less(a, b *ChannelInfo) bool {
+	switch q.SortBy {
+	case SORT_BY_LIQUIDITY:
+		return a.LiquidityRatio < b.LiquidityRatio
+	case SORT_BY_CAPACITY:
+		return a.Capacity < b.Capacity
+	case SORT_BY_VOLATILITY:
+		return a.FeeVolatility < b.FeeVolatility
+	default:
+		return a.FeePPM < b.FeePPM
+	}
+}
+
+// QueryChannels returns the channels matching the query and the total number of matches before pagination
+func (g *Graph) QueryChannels(q *ChannelQuery) ([]ChannelInfo, int, error) {
+	if q.SortBy == "" {
+		q.SortBy = SORT_BY_PPM
+	}
+	if q.SortBy != SORT_BY_PPM && q.SortBy != SORT_BY_LIQUIDITY && q.SortBy != SORT_BY_CAPACITY &&
+		q.SortBy != SORT_BY_VOLATILITY {
+		return nil, 0, util.ErrInvalidSortField
+	}
+
+	// take a snapshot so that sorting doesn't hold the lock
+	g.channelsLock.RLock()
+	result := make([]ChannelInfo, 0)
+	for id, c := range g.Channels {
+		info := ChannelInfo{
+			ChannelId:     id,
+			Source:        c.Source,
+			Destination:   c.Destination,
+			Capacity:      c.Satoshis,
+			BaseFee:       c.BaseFeeMillisatoshi,
+			FeePPM:        c.FeePerMillionth,
+			Liquidity:     c.Liquidity,
+			FeeVolatility: c.FeeVolatility(),
+		}
+		info.VolatileFee = info.FeeVolatility > VOLATILE_FEE_THRESHOLD
+		if c.Satoshis > 0 {
+			info.LiquidityRatio = float64(c.Liquidity) / float64(c.Satoshis*1000)
+		}
+		if q.matches(&info) {
+			result = append(result, info)
+		}
+	}
+	g.channelsLock.RUnlock()
+
+	// the ties are broken by channel id, so that the pages don't overlap or skip channels
+	sort.Slice(result, func(i, j int) bool {
+		a, b := &result[i], &result[j]
+		if q.less(a, b) {
+			return !q.Descending
+		}
+		if q.less(b, a) {
+			return q.Descending
+		}
+		return a.ChannelId < b.ChannelId
+	})
+
+	total := len(result)
+	if q.Offset >= total {
+		return []ChannelInfo{}, total, nil
+	}
+	result = result[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(result) {
+		result = result[:q.Limit]
+	}
+
+	for i := range result {
+		result[i].SourceAlias = g.GetAlias(result[i].Source)
+		result[i].DestinationAlias = g.GetAlias(result[i].Destination)
+	}
+	return result, total, nil
+}
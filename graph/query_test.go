@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQueryChannelsPagination(t *testing.T) {
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	// all the channels have the same ppm but one, the ties must not move between pages
+	g := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 100, 40),
+		newTestChannel(b, a, "1x1x1", 1000000, 0, 100, 40),
+		newTestChannel(b, c, "2x2x2", 1000000, 0, 100, 40),
+		newTestChannel(c, b, "2x2x2", 1000000, 0, 100, 40),
+		newTestChannel(c, a, "3x3x3", 1000000, 0, 500, 40),
+	)
+
+	for _, descending := range []bool{false, true} {
+		all, total, err := g.QueryChannels(&ChannelQuery{Descending: descending})
+		assert.NoError(t, err)
+		assert.Equal(t, 5, total)
+
+		for i := 0; i < 10; i++ {
+			paged := make([]ChannelInfo, 0)
+			for offset := 0; offset < total; offset += 2 {
+				page, _, err := g.QueryChannels(&ChannelQuery{Descending: descending, Limit: 2, Offset: offset})
+				assert.NoError(t, err)
+				paged = append(paged, page...)
+			}
+			assert.Equal(t, all, paged)
+		}
+
+		// the ties are by channel id whatever the order
+		if descending {
+			assert.Equal(t, uint64(500), all[0].FeePPM)
+			all = all[1:]
+		}
+		for i := 1; i < 4; i++ {
+			assert.Less(t, all[i-1].ChannelId, all[i].ChannelId)
+		}
+	}
+
+	page, total, err := g.QueryChannels(&ChannelQuery{Offset: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, total)
+	assert.Empty(t, page)
+}
@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRecentRouteIsDeprioritized(t *testing.T) {
+	a, b, c, d := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	// a -> b -> d is slightly cheaper than a -> c -> d
+	graph := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 10, 40),
+		newTestChannel(b, d, "2x2x2", 1000000, 0, 10, 40),
+		newTestChannel(a, c, "3x3x3", 1000000, 0, 20, 40),
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 20, 40),
+		newTestChannel(d, a, "5x5x5", 1000000, 0, 10, 40),
+	)
+	amount := uint64(100000000)
+	options := NewRouteOptions()
+	options.RecentRoutePenalty = 100
+
+	route, err := graph.GetRoute(a, d, amount, nil, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, b, route.Hops[0].Destination)
+
+	// the route was just used: the other one is preferred
+	options.RecentChannels = map[string]float64{"1x1x1": 1, "2x2x2": 1}
+	route, err = graph.GetRoute(a, d, amount, nil, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, c, route.Hops[0].Destination)
+
+	// as the memory fades, the cheaper route is back
+	options.RecentChannels = map[string]float64{"1x1x1": 0.05, "2x2x2": 0.05}
+	route, err = graph.GetRoute(a, d, amount, nil, 8, options)
+	assert.NoError(t, err)
+	assert.Equal(t, b, route.Hops[0].Destination)
+}
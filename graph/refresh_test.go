@@ -0,0 +1,62 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// testGossip returns the gossip of a ring of n nodes, as freshly decoded from listchannels
+func testGossip(n int) []*glightning.Channel {
+	gossip := make([]*glightning.Channel, n)
+	for i := 0; i < n; i++ {
+		gossip[i] = newTestChannel(testNodeId(i), testNodeId((i+1)%n), strconv.Itoa(i+1)+"x1x1", 1000000, 1000, 100, 40).Channel
+	}
+	return gossip
+}
+
+func gossipId(c *glightning.Channel) string {
+	return c.ShortChannelId + "/" + util.GetDirection(c.Source, c.Destination)
+}
+
+// refreshAllocations refreshes g with gossip and returns how many bytes the refresh allocated
+func refreshAllocations(g *Graph, gossip []*glightning.Channel) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	g.RefreshChannels(gossip)
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestRefreshChannelsReusesUnchanged(t *testing.T) {
+	channels := 10000
+	allocated := make(map[bool]uint64)
+	for _, reuse := range []bool{false, true} {
+		g := NewGraph()
+		g.ReuseChannels = reuse
+		g.RefreshChannels(testGossip(channels))
+
+		// the fee of the first channel changed, all the others are the same
+		gossip := testGossip(channels)
+		gossip[0].FeePerMillionth = 200
+		changed, unchanged := gossipId(gossip[0]), gossipId(gossip[1])
+		g.Channels[changed].Liquidity = 42
+		before := g.Channels[unchanged]
+
+		allocated[reuse] = refreshAllocations(g, gossip)
+		t.Log("reuse: ", reuse, ", allocated during refresh: ", allocated[reuse]/1024, " KiB")
+
+		// either way the refresh has the same outcome
+		assert.Equal(t, uint64(200), g.Channels[changed].FeePerMillionth)
+		assert.Equal(t, uint64(42), g.Channels[changed].Liquidity)
+		assert.Equal(t, []uint64{101, 201}, g.Channels[changed].feeHistory)
+		assert.Equal(t, []uint64{101, 101}, g.Channels[unchanged].feeHistory)
+		assert.Equal(t, reuse, before == g.Channels[unchanged])
+	}
+	// only the changed channel was built again
+	assert.Less(t, allocated[true]*4, allocated[false])
+}
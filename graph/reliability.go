@@ -0,0 +1,114 @@
+package graph
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// RELIABILITY_HALF_LIFE is the time after which the outcomes recorded for a node count half
+	RELIABILITY_HALF_LIFE = 24 * time.Hour
+)
+
+// NodeReliability counts the attempts that went through a node and how many of them it forwarded
+type NodeReliability struct {
+	Attempts   float64   `json:"attempts"`
+	Successes  float64   `json:"successes"`
+	LastUpdate time.Time `json:"-"`
+}
+
+// decay makes older outcomes count less, so that a node can recover from a bad period
+func (r *NodeReliability) decay(now time.Time) {
+	factor := math.Pow(0.5, float64(now.Sub(r.LastUpdate))/float64(RELIABILITY_HALF_LIFE))
+	r.Attempts *= factor
+	r.Successes *= factor
+	r.LastUpdate = now
+}
+
+// Score is the fraction of attempts that succeeded, smoothed so that a node
+// with no history scores 0.5 and a single outcome doesn't dominate
+func (r *NodeReliability) Score() float64 {
+	return (r.Successes + 1) / (r.Attempts + 2)
+}
+
+type reliabilityScores struct {
+	lock  *sync.RWMutex
+	nodes map[string]*NodeReliability
+}
+
+func newReliabilityScores() *reliabilityScores {
+	return &reliabilityScores{
+		lock:  &sync.RWMutex{},
+		nodes: make(map[string]*NodeReliability),
+	}
+}
+
+// RecordOutcome records whether id forwarded one of our payments
+func (g *Graph) RecordOutcome(id string, success bool) {
+	g.reliability.lock.Lock()
+	now := time.Now()
+	r, ok := g.reliability.nodes[id]
+	if !ok {
+		r = &NodeReliability{LastUpdate: now}
+		g.reliability.nodes[id] = r
+	}
+	r.decay(now)
+	r.Attempts++
+	if success {
+		r.Successes++
+	}
+	g.reliability.lock.Unlock()
+
+	// the cached routes were ranked with the previous score, see ReliabilityWeight.
+	// The searches take the channels lock first, so it's taken once the reliability lock is released.
+	g.channelsLock.Lock()
+	g.version++
+	g.channelsLock.Unlock()
+}
+
+// getScore must be called while holding the reliability lock
+func (g *Graph) getScore(id string, now time.Time) float64 {
+	r, ok := g.reliability.nodes[id]
+	if !ok {
+		return (&NodeReliability{}).Score()
+	}
+	decayed := *r
+	decayed.decay(now)
+	return decayed.Score()
+}
+
+type NodeScore struct {
+	Id        string  `json:"id"`
+	Alias     string  `json:"alias"`
+	Score     float64 `json:"score"`
+	Attempts  float64 `json:"attempts"`
+	Successes float64 `json:"successes"`
+}
+
+// GetReliability returns the nodes we have tried to route through, least reliable first
+func (g *Graph) GetReliability() []NodeScore {
+	g.reliability.lock.RLock()
+	now := time.Now()
+	scores := make([]NodeScore, 0, len(g.reliability.nodes))
+	for id, r := range g.reliability.nodes {
+		decayed := *r
+		decayed.decay(now)
+		scores = append(scores, NodeScore{
+			Id:        id,
+			Score:     decayed.Score(),
+			Attempts:  decayed.Attempts,
+			Successes: decayed.Successes,
+		})
+	}
+	g.reliability.lock.RUnlock()
+
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Score < scores[j].Score
+	})
+	for i := range scores {
+		scores[i].Alias = g.GetAlias(scores[i].Id)
+	}
+	return scores
+}
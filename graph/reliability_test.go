@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNodeReliabilityDecay(t *testing.T) {
+	now := time.Now()
+	r := &NodeReliability{Attempts: 10, Successes: 0, LastUpdate: now.Add(-RELIABILITY_HALF_LIFE)}
+	assert.InDelta(t, 1.0/12, r.Score(), 0.0001)
+
+	r.decay(now)
+	assert.InDelta(t, 5, r.Attempts, 0.0001)
+	assert.Greater(t, r.Score(), 1.0/12)
+	assert.Equal(t, 0.5, (&NodeReliability{}).Score())
+}
+
+func TestPathfinderAvoidsUnreliableNodes(t *testing.T) {
+	a, b, c, d := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	// a -> b -> d and a -> c -> d cost the same, b is slightly cheaper
+	g := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40),
+		newTestChannel(b, d, "2x2x2", 1000000, 0, 9, 40),
+		newTestChannel(a, c, "3x3x3", 1000000, 0, 1, 40),
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 10, 40),
+		newTestChannel(d, a, "5x5x5", 1000000, 0, 1, 40),
+	)
+	amount := uint64(100000000)
+
+	hops, err := g.dijkstra(a, d, amount, nil, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, b, hops[0].Destination)
+
+	for i := 0; i < 5; i++ {
+		g.RecordOutcome(b, false)
+		g.RecordOutcome(c, true)
+	}
+
+	// the scores don't matter unless they have a weight
+	hops, err = g.dijkstra(a, d, amount, nil, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, b, hops[0].Destination)
+
+	hops, err = g.dijkstra(a, d, amount, nil, 10, &RouteOptions{ReliabilityWeight: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, c, hops[0].Destination)
+	// the fees paid are the real ones
+	assert.Equal(t, amount+1000+hops[0].ComputeFee(amount+1000), hops[0].MilliSatoshi)
+
+	scores := g.GetReliability()
+	assert.Equal(t, b, scores[0].Id)
+	assert.Equal(t, c, scores[1].Id)
+}
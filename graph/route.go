@@ -1,11 +1,20 @@
 package graph
 
 import (
+	"circular/util"
+	"fmt"
 	"github.com/elementsproject/glightning/glightning"
 )
 
 const (
 	INITIAL_DELAY = 144
+	// MIN_FINAL_CLTV is the default cltv-final of lightningd, the minimum delta it accepts on the last hop.
+	// The one configured is read at startup, this is only used if it can't be.
+	MIN_FINAL_CLTV = 18
+	// MAX_ROUTE_LENGTH is the maximum number of hops that fit in an onion
+	MAX_ROUTE_LENGTH = 20
+	// MAX_ROUTE_DELAY is the default max-locktime-blocks of lightningd, the longest total delay it accepts
+	MAX_ROUTE_DELAY = 2016
 )
 
 type RouteHop struct {
@@ -20,6 +29,14 @@ type Route struct {
 	Amount      uint64
 	Hops        []RouteHop
 	Graph       *Graph
+	// FinalCltv is the delay of the last hop, the one paying ourselves
+	FinalCltv uint
+	// InboundFees adds the inbound fees to the fees of the hops
+	InboundFees bool
+	// DelayPadding (blocks) is added to the delta of every hop, without going over MAX_ROUTE_DELAY
+	DelayPadding uint
+	// Probability is the estimated probability of success of the hops found by the pathfinding
+	Probability float64
 }
 
 func NewRoute(src, dst string, amount uint64, hops []RouteHop, graph *Graph) *Route {
@@ -29,6 +46,8 @@ func NewRoute(src, dst string, amount uint64, hops []RouteHop, graph *Graph) *Ro
 		Amount:      amount,
 		Hops:        hops,
 		Graph:       graph,
+		FinalCltv:   INITIAL_DELAY,
+		Probability: 1,
 	}
 }
 
@@ -40,6 +59,152 @@ func (r *Route) FeePPM() uint64 {
 	return (r.Fee() * 1000000) / r.Amount
 }
 
+// Scids are the short channel ids of the hops of the route, in order
+func (r *Route) Scids() []string {
+	scids := make([]string, len(r.Hops))
+	for i, hop := range r.Hops {
+		scids[i] = hop.ShortChannelId
+	}
+	return scids
+}
+
+// NewRouteFromScids builds the route going through the channels in scids, in order, starting from src.
+// Every channel must start where the previous one ends and must be able to forward amount.
+func (g *Graph) NewRouteFromScids(src string, scids []string, amount uint64, finalCltv uint) (*Route, error) {
+	if len(scids) == 0 {
+		return nil, util.ErrNoRequiredParameter
+	}
+	if amount == 0 {
+		return nil, util.ErrZeroAmount
+	}
+
+	channels := make([]*Channel, 0, len(scids))
+	from := src
+	for _, scid := range scids {
+		channel := g.getChannelFrom(scid, from)
+		if channel == nil {
+			return nil, fmt.Errorf("%w: %s does not start from %s", util.ErrDiscontinuousRoute, scid, g.GetAlias(from))
+		}
+		channels = append(channels, channel)
+		from = channel.Destination
+	}
+
+	route := NewRoute(src, from, amount, []RouteHop{}, g)
+	route.FinalCltv = finalCltv
+	for _, channel := range channels {
+		route.Append(channel)
+	}
+
+	// check the amount that each hop has to carry, fees included
+	for _, hop := range route.Hops {
+		if !hop.CanForward(hop.MilliSatoshi) {
+			return nil, fmt.Errorf("%w: %s", util.ErrUnusableHop, hop.ShortChannelId)
+		}
+	}
+	return route, nil
+}
+
+// getChannelFrom returns the direction of the channel scid that starts from id, if any
+func (g *Graph) getChannelFrom(scid, id string) *Channel {
+	g.channelsLock.RLock()
+	defer g.channelsLock.RUnlock()
+
+	for _, direction := range []string{"0", "1"} {
+		if channel, ok := g.Channels[scid+"/"+direction]; ok && channel.Source == id {
+			return channel
+		}
+	}
+	return nil
+}
+
+// TightestHop returns the index of the intermediate hop with the least believed liquidity left after
+// forwarding its amount, the one most likely to have failed. The local legs are skipped, since their
+// balance is known. It returns -1 if there are no intermediate hops.
+func (r *Route) TightestHop() int {
+	tightest := -1
+	var tightestMargin int64
+	for i := 1; i < len(r.Hops)-1; i++ {
+		margin := int64(r.Hops[i].Liquidity) - int64(r.Hops[i].MilliSatoshi)
+		if tightest == -1 || margin < tightestMargin {
+			tightest, tightestMargin = i, margin
+		}
+	}
+	return tightest
+}
+
+// BaseFees is the part of the fee of the route that is due to base fees
+func (r *Route) BaseFees() uint64 {
+	var baseFees uint64
+	// the first hop is our own channel, we don't pay fees to ourselves
+	for i := 1; i < len(r.Hops); i++ {
+		baseFees += r.Hops[i].BaseFeeMillisatoshi
+	}
+	return baseFees
+}
+
+// CheckLength returns an error if the route, local legs included, has more than maxLength hops
+func (r *Route) CheckLength(maxLength int) error {
+	if len(r.Hops) > maxLength {
+		return util.NewRouteTooLongError(len(r.Hops), maxLength)
+	}
+	return nil
+}
+
+// CheckHtlcBounds returns an error if a hop, local legs included, can't carry its amount, fees included,
+// within the htlc bounds advertised for the channel
+func (r *Route) CheckHtlcBounds() error {
+	for _, hop := range r.Hops {
+		if !hop.IsWithinHtlcBounds(hop.MilliSatoshi) {
+			return fmt.Errorf("%w: %s", util.ErrHopCannotCarryAmount, hop.ShortChannelId)
+		}
+	}
+	return nil
+}
+
+// IsEquivalent returns true if the two routes use the same channels or cost the same fee
+func (r *Route) IsEquivalent(other *Route) bool {
+	if other == nil || r.Amount != other.Amount {
+		return false
+	}
+	if len(r.Hops) == len(other.Hops) {
+		same := true
+		for i := range r.Hops {
+			if r.Hops[i].ShortChannelId != other.Hops[i].ShortChannelId ||
+				r.Hops[i].GetDirection() != other.Hops[i].GetDirection() {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	if len(r.Hops) == 0 || len(other.Hops) == 0 {
+		return false
+	}
+	return r.Fee() == other.Fee()
+}
+
+// EstimateFee computes the fee (msat and ppm) that sending amount over the channels
+// of the route would cost, without modifying the route
+func (r *Route) EstimateFee(amount uint64) (uint64, uint64, error) {
+	if amount == 0 {
+		return 0, 0, util.ErrZeroAmount
+	}
+	toForward := amount
+	for i := len(r.Hops) - 1; i >= 0; i-- {
+		if !r.Hops[i].IsWithinHtlcBounds(toForward) {
+			return 0, 0, util.ErrHopCannotCarryAmount
+		}
+		// the first hop is our own channel, we don't pay fees to ourselves
+		if i > 0 {
+			toForward += forwardingFee(r.Hops[i-1].Channel, r.Hops[i].Channel, toForward, r.InboundFees)
+		}
+	}
+	fee := toForward - amount
+	return fee, fee * 1000000 / amount, nil
+}
+
 func (r *Route) Prepend(channel *Channel) {
 	firstHop := r.Hops[0]
 	newFirstHop := RouteHop{
@@ -50,14 +215,34 @@ func (r *Route) Prepend(channel *Channel) {
 	r.Hops = append([]RouteHop{newFirstHop}, r.Hops...)
 }
 
+// delayPadding is the padding of each hop, lowered so that the total delay stays within MAX_ROUTE_DELAY
+func (r *Route) delayPadding() uint {
+	if r.DelayPadding == 0 || len(r.Hops) < 2 {
+		return 0
+	}
+	delay := r.Hops[len(r.Hops)-1].Delay
+	for _, hop := range r.Hops[1:] {
+		delay += hop.Channel.Delay
+	}
+	if delay >= MAX_ROUTE_DELAY {
+		return 0
+	}
+	padded := uint(len(r.Hops) - 1)
+	if maxPadding := (MAX_ROUTE_DELAY - delay) / padded; r.DelayPadding > maxPadding {
+		return maxPadding
+	}
+	return r.DelayPadding
+}
+
 func (r *Route) recomputeFeeAndDelay() {
+	padding := r.delayPadding()
 	for i := len(r.Hops) - 2; i >= 0; i-- {
 		hop := r.Hops[i+1]
 		amountToForward := hop.MilliSatoshi
-		r.Hops[i].MilliSatoshi = amountToForward + hop.ComputeFee(amountToForward)
+		r.Hops[i].MilliSatoshi = amountToForward + forwardingFee(r.Hops[i].Channel, hop.Channel, amountToForward, r.InboundFees)
 
 		delay := hop.Delay
-		r.Hops[i].Delay = delay + hop.Channel.Delay
+		r.Hops[i].Delay = delay + hop.Channel.Delay + padding
 	}
 }
 
@@ -65,7 +250,7 @@ func (r *Route) Append(channel *Channel) {
 	newLastHop := RouteHop{
 		Channel:      channel,
 		MilliSatoshi: r.Amount,
-		Delay:        INITIAL_DELAY,
+		Delay:        r.FinalCltv,
 	}
 	r.Hops = append(r.Hops, newLastHop)
 	r.recomputeFeeAndDelay()
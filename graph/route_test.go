@@ -0,0 +1,261 @@
+package graph
+
+import (
+	"circular/util"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestRoute builds the route self -> a -> b -> self, the way a rebalance would
+func newTestRoute(amount uint64) *Route {
+	self, a, b := testNodeId(0), testNodeId(1), testNodeId(2)
+	out := newTestChannel(self, a, "1x1x1", 1000000, 1000, 100, 40)
+	middle := newTestChannel(a, b, "2x2x2", 1000000, 1000, 200, 40)
+	in := newTestChannel(b, self, "3x3x3", 1000000, 2000, 500, 40)
+	graph := newTestGraph(out, middle, in)
+
+	route := NewRoute(a, b, amount, []RouteHop{{middle, amount, INITIAL_DELAY}}, graph)
+	route.Prepend(out)
+	route.Append(in)
+	return route
+}
+
+func TestRouteEstimateFee(t *testing.T) {
+	amount := uint64(100000000)
+	route := newTestRoute(amount)
+
+	fee, ppm, err := route.EstimateFee(amount)
+	assert.NoError(t, err)
+	assert.Equal(t, route.Fee(), fee)
+	assert.Equal(t, route.FeePPM(), ppm)
+
+	// estimating must not modify the route
+	hops := make([]RouteHop, len(route.Hops))
+	copy(hops, route.Hops)
+	fee, _, err = route.EstimateFee(2 * amount)
+	assert.NoError(t, err)
+	assert.Greater(t, fee, route.Fee())
+	assert.Equal(t, hops, route.Hops)
+	assert.Equal(t, amount, route.Amount)
+}
+
+func TestRouteEstimateFeeHtlcMaxExceeded(t *testing.T) {
+	amount := uint64(100000000)
+	route := newTestRoute(amount)
+	// the middle hop can carry exactly what it needs to carry for amount, fees included
+	route.Hops[1].maxHtlcMsat = route.Hops[1].MilliSatoshi
+
+	_, _, err := route.EstimateFee(amount)
+	assert.NoError(t, err)
+
+	_, _, err = route.EstimateFee(amount + 1000)
+	assert.Equal(t, util.ErrHopCannotCarryAmount, err)
+
+	_, _, err = route.EstimateFee(0)
+	assert.Equal(t, util.ErrZeroAmount, err)
+}
+
+func TestRouteWithoutIntermediateHops(t *testing.T) {
+	self, a := testNodeId(0), testNodeId(1)
+	out := newTestChannel(self, a, "1x1x1", 1000000, 1000, 100, 40)
+	in := newTestChannel(a, self, "2x2x2", 1000000, 2000, 500, 30)
+	graph := newTestGraph(out, in)
+
+	amount := uint64(100000000)
+	route := NewRoute(a, a, amount, []RouteHop{}, graph)
+	route.Append(out)
+	route.Append(in)
+
+	assert.Equal(t, 2, len(route.Hops))
+	assert.Equal(t, amount, route.Hops[1].MilliSatoshi)
+	assert.Equal(t, uint(INITIAL_DELAY), route.Hops[1].Delay)
+	// the only fee paid is the one of the peer forwarding back to us
+	assert.Equal(t, in.ComputeFee(amount), route.Fee())
+	assert.Equal(t, uint(INITIAL_DELAY)+in.Delay, route.Hops[0].Delay)
+}
+
+func TestRouteIsEquivalent(t *testing.T) {
+	amount := uint64(100000000)
+	a, b, c := testNodeId(1), testNodeId(2), testNodeId(3)
+	ab := newTestChannel(a, b, "1x1x1", 1000000, 1000, 100, 40)
+	ac := newTestChannel(a, c, "2x2x2", 1000000, 1000, 100, 40)
+	cb := newTestChannel(c, b, "3x3x3", 1000000, 0, 0, 40)
+	expensive := newTestChannel(a, b, "4x4x4", 1000000, 1000, 500, 40)
+	graph := newTestGraph(ab, ac, cb, expensive)
+
+	route := NewRoute(a, b, amount, []RouteHop{{ab, amount, INITIAL_DELAY}}, graph)
+	assert.True(t, route.IsEquivalent(NewRoute(a, b, amount, []RouteHop{{ab, amount, INITIAL_DELAY}}, graph)))
+
+	// a different path with the same cost is fine
+	other := NewRoute(c, b, amount, []RouteHop{{cb, amount, INITIAL_DELAY}}, graph)
+	other.Prepend(ac)
+	assert.Equal(t, route.Fee(), other.Fee())
+	assert.True(t, route.IsEquivalent(other))
+
+	// a more expensive one isn't
+	other = NewRoute(a, b, amount, []RouteHop{{expensive, amount + expensive.ComputeFee(amount), INITIAL_DELAY}}, graph)
+	assert.False(t, route.IsEquivalent(other))
+
+	assert.False(t, route.IsEquivalent(NewRoute(a, b, 2*amount, []RouteHop{{ab, 2 * amount, INITIAL_DELAY}}, graph)))
+	assert.False(t, route.IsEquivalent(nil))
+}
+
+func TestRouteFinalCltv(t *testing.T) {
+	amount := uint64(100000000)
+	self, a := testNodeId(0), testNodeId(1)
+	out := newTestChannel(self, a, "1x1x1", 1000000, 1000, 100, 40)
+	in := newTestChannel(a, self, "2x2x2", 1000000, 1000, 100, 80)
+
+	route := NewRoute(a, a, amount, []RouteHop{}, newTestGraph(out, in))
+	route.FinalCltv = MIN_FINAL_CLTV
+	route.Append(out)
+	route.Append(in)
+
+	assert.Equal(t, uint(MIN_FINAL_CLTV), route.Hops[1].Delay)
+	assert.Equal(t, MIN_FINAL_CLTV+in.Delay, route.Hops[0].Delay)
+}
+
+func TestRouteCheckLength(t *testing.T) {
+	amount := uint64(100000000)
+	length := MAX_ROUTE_LENGTH + 1
+	channels := make([]*Channel, length)
+	for i := 0; i < length; i++ {
+		channels[i] = newTestChannel(testNodeId(i), testNodeId(i+1), fmt.Sprintf("%dx1x1", i+1), 1000000, 1000, 100, 40)
+	}
+	g := newTestGraph(channels...)
+
+	route := NewRoute(testNodeId(1), testNodeId(length-1), amount, []RouteHop{}, g)
+	for _, channel := range channels {
+		route.Append(channel)
+	}
+	assert.Equal(t, length, len(route.Hops))
+	assert.Equal(t, util.NewRouteTooLongError(length, MAX_ROUTE_LENGTH), route.CheckLength(MAX_ROUTE_LENGTH))
+
+	route.Hops = route.Hops[:MAX_ROUTE_LENGTH]
+	assert.NoError(t, route.CheckLength(MAX_ROUTE_LENGTH))
+}
+
+func TestNewRouteFromScids(t *testing.T) {
+	amount := uint64(100000000)
+	self, a, b := testNodeId(0), testNodeId(1), testNodeId(2)
+	g := newTestGraph(
+		newTestChannel(self, a, "1x1x1", 1000000, 1000, 100, 40),
+		newTestChannel(a, self, "1x1x1", 1000000, 1000, 100, 40),
+		newTestChannel(a, b, "2x2x2", 1000000, 1000, 200, 40),
+		newTestChannel(b, a, "2x2x2", 1000000, 1000, 200, 40),
+		newTestChannel(b, self, "3x3x3", 1000000, 2000, 500, 40),
+		newTestChannel(self, b, "3x3x3", 1000000, 2000, 500, 40),
+	)
+
+	route, err := g.NewRouteFromScids(self, []string{"1x1x1", "2x2x2", "3x3x3"}, amount, INITIAL_DELAY)
+	assert.NoError(t, err)
+	assert.Equal(t, self, route.Destination)
+	assert.Equal(t, newTestRoute(amount).Fee(), route.Fee())
+	assert.Equal(t, a, route.Hops[0].Destination)
+	assert.Equal(t, b, route.Hops[1].Destination)
+
+	// 2x2x2 doesn't start from self
+	_, err = g.NewRouteFromScids(self, []string{"2x2x2", "3x3x3"}, amount, INITIAL_DELAY)
+	assert.ErrorIs(t, err, util.ErrDiscontinuousRoute)
+
+	// the amount doesn't fit the channels
+	_, err = g.NewRouteFromScids(self, []string{"1x1x1", "2x2x2"}, 2*amount*1000, INITIAL_DELAY)
+	assert.ErrorIs(t, err, util.ErrUnusableHop)
+}
+
+func TestRouteCheckHtlcBounds(t *testing.T) {
+	amount := uint64(100000000)
+	route := newTestRoute(amount)
+	assert.NoError(t, route.CheckHtlcBounds())
+
+	// our outgoing channel carries the amount plus all the fees of the route
+	route.Hops[0].maxHtlcMsat = amount
+	err := route.CheckHtlcBounds()
+	assert.ErrorIs(t, err, util.ErrHopCannotCarryAmount)
+	assert.Contains(t, err.Error(), "1x1x1")
+}
+
+func TestPrettyRouteFormats(t *testing.T) {
+	route := newTestRoute(100000000)
+	route.Graph.Aliases[testNodeId(0)] = "self"
+	route.Graph.Aliases[testNodeId(1)] = "alice"
+	route.Graph.Aliases[testNodeId(2)] = "bob"
+	pretty := NewPrettyRoute(route, "")
+
+	assert.Equal(t, "self -[1x1x1]-> alice -[2x2x2]-> bob -[3x3x3]-> self", pretty.Format(ROUTE_FORMAT_ALIASES))
+	assert.Equal(t, pretty.Simple(), pretty.Format(ROUTE_FORMAT_SIMPLE))
+	assert.Equal(t, pretty.String(), pretty.Format(ROUTE_FORMAT_DETAILED))
+
+	assert.NoError(t, ValidateRouteFormat(""))
+	assert.NoError(t, ValidateRouteFormat(ROUTE_FORMAT_JSON))
+	assert.Error(t, ValidateRouteFormat("yaml"))
+}
+
+func TestPrettyRouteGraphAge(t *testing.T) {
+	pretty := NewPrettyRoute(newTestRoute(100000000), "")
+	pretty.SetGraphAge(10*time.Minute, false)
+	assert.Equal(t, int64(600), pretty.GraphAge)
+	assert.False(t, pretty.StaleGraph)
+	assert.Contains(t, pretty.String(), "Graph age: 10m0s")
+	assert.NotContains(t, pretty.String(), "WARNING")
+	assert.NotContains(t, pretty.Simple(), "WARNING")
+
+	pretty.SetGraphAge(2*time.Hour, true)
+	assert.True(t, pretty.StaleGraph)
+	assert.Contains(t, pretty.String(), "Graph age: 2h0m0s")
+	assert.Contains(t, pretty.String(), "WARNING: the graph is 2h0m0s old")
+	assert.Contains(t, pretty.Simple(), "WARNING")
+}
+
+func TestRouteDelayPadding(t *testing.T) {
+	self, a, b := testNodeId(0), testNodeId(1), testNodeId(2)
+	out := newTestChannel(self, a, "1x1x1", 1000000, 1000, 100, 40)
+	middle := newTestChannel(a, b, "2x2x2", 1000000, 1000, 200, 40)
+	in := newTestChannel(b, self, "3x3x3", 1000000, 2000, 500, 40)
+	graph := newTestGraph(out, middle, in)
+
+	build := func(padding uint) *Route {
+		route := NewRoute(a, b, 1000000, []RouteHop{{middle, 1000000, INITIAL_DELAY}}, graph)
+		route.DelayPadding = padding
+		route.Prepend(out)
+		route.Append(in)
+		return route
+	}
+
+	// every hop forwarding the payment gets the padding on top of the advertised delta
+	route := build(6)
+	assert.Equal(t, uint(INITIAL_DELAY), route.Hops[2].Delay)
+	assert.Equal(t, uint(INITIAL_DELAY+40+6), route.Hops[1].Delay)
+	assert.Equal(t, uint(INITIAL_DELAY+80+12), route.Hops[0].Delay)
+	// the advertised deltas are untouched
+	assert.Equal(t, uint(40), in.Delay)
+	assert.Equal(t, uint(40), middle.Delay)
+
+	// the padding is lowered so that the total delay stays within the limit
+	route = build(2000)
+	assert.Equal(t, uint(MAX_ROUTE_DELAY), route.Hops[0].Delay)
+	assert.Equal(t, uint(INITIAL_DELAY+40+896), route.Hops[1].Delay)
+}
+
+func TestPrettyRouteFeesInMsatAndPPM(t *testing.T) {
+	route := newTestRoute(100000000)
+	route.Graph.Aliases[testNodeId(1)] = "alice"
+	route.Graph.Aliases[testNodeId(2)] = "bob"
+	pretty := NewPrettyRoute(route, "")
+
+	total := strconv.FormatUint(route.Fee(), 10) + "msat (" + strconv.FormatUint(route.FeePPM(), 10) + "ppm)"
+	assert.Contains(t, pretty.String(), "Fee: "+total+"\n")
+	assert.Contains(t, pretty.Simple(), "costing "+total+" via")
+
+	for i, hop := range pretty.Hops[1:] {
+		fee := route.Hops[i].MilliSatoshi - route.Hops[i+1].MilliSatoshi
+		assert.Equal(t, fee, hop.Fee)
+		assert.Equal(t, fee*1000000/route.Hops[i+1].MilliSatoshi, hop.FeePPM)
+		perHop := strconv.FormatUint(hop.Fee, 10) + "msat (" + strconv.FormatUint(hop.FeePPM, 10) + "ppm)"
+		assert.Contains(t, pretty.String(), perHop+", scid: "+hop.ShortChannelId)
+		assert.Contains(t, pretty.Simple(), hop.Alias+": "+perHop)
+	}
+}
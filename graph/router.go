@@ -0,0 +1,103 @@
+package graph
+
+import (
+	"circular/util"
+	"sort"
+)
+
+const (
+	ROUTER_DIJKSTRA    = "dijkstra"
+	ROUTER_PROBABILITY = "probability"
+	ROUTER_CACHED      = "cached"
+	// DEFAULT_ROUTER_PROBABILITY is the minimum probability of ROUTER_PROBABILITY when the options don't set one
+	DEFAULT_ROUTER_PROBABILITY = 0.5
+)
+
+// Router is a way of searching a route, see Routers
+type Router func(g *Graph, src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) (*Route, error)
+
+// Routers are the ways of searching a route by name: the cheapest route, the cheapest route likely enough
+// to succeed, and the cheapest route for the bucket of the amount, reusing the cached one. Each of them
+// searches with a copy of options that only enables what it needs.
+var Routers = map[string]Router{
+	ROUTER_DIJKSTRA: func(g *Graph, src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) (*Route, error) {
+		plain := *options
+		plain.CacheRoutes, plain.MinProbability = false, 0
+		return g.GetRoute(src, dst, amount, exclude, maxHops, &plain)
+	},
+	ROUTER_PROBABILITY: func(g *Graph, src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) (*Route, error) {
+		likely := *options
+		likely.CacheRoutes = false
+		if likely.MinProbability == 0 {
+			likely.MinProbability = DEFAULT_ROUTER_PROBABILITY
+		}
+		return g.GetRoute(src, dst, amount, exclude, maxHops, &likely)
+	},
+	ROUTER_CACHED: func(g *Graph, src, dst string, amount uint64, exclude map[string]bool, maxHops int, options *RouteOptions) (*Route, error) {
+		cached := *options
+		cached.CacheRoutes, cached.MinProbability, cached.AggregateParallel = true, 0, false
+		return g.GetRoute(src, dst, amount, exclude, maxHops, &cached)
+	},
+}
+
+// GetRouter returns the router with name
+func GetRouter(name string) (Router, error) {
+	router, ok := Routers[name]
+	if !ok {
+		return nil, util.ErrUnknownRouter
+	}
+	return router, nil
+}
+
+// RouterNames are the names of the routers, sorted
+func RouterNames() []string {
+	names := make([]string, 0, len(Routers))
+	for name := range Routers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RouteDiff compares the route A with the route B: the channels both go through, the ones
+// only one of them goes through, and how much more B costs and how many more hops it has
+type RouteDiff struct {
+	SharedHops    []string `json:"shared_hops"`
+	OnlyA         []string `json:"only_a"`
+	OnlyB         []string `json:"only_b"`
+	FeeDelta      int64    `json:"fee_delta_msat"`
+	HopCountDelta int      `json:"hop_count_delta"`
+}
+
+// DiffRoutes compares the channels, in order, and the fees of a and b
+func DiffRoutes(a, b *Route) *RouteDiff {
+	inA := make(map[string]bool, len(a.Hops))
+	for _, hop := range a.Hops {
+		inA[hop.ShortChannelId] = true
+	}
+	inB := make(map[string]bool, len(b.Hops))
+	for _, hop := range b.Hops {
+		inB[hop.ShortChannelId] = true
+	}
+
+	diff := &RouteDiff{
+		SharedHops:    make([]string, 0),
+		OnlyA:         make([]string, 0),
+		OnlyB:         make([]string, 0),
+		FeeDelta:      int64(b.Fee()) - int64(a.Fee()),
+		HopCountDelta: len(b.Hops) - len(a.Hops),
+	}
+	for _, hop := range a.Hops {
+		if inB[hop.ShortChannelId] {
+			diff.SharedHops = append(diff.SharedHops, hop.ShortChannelId)
+		} else {
+			diff.OnlyA = append(diff.OnlyA, hop.ShortChannelId)
+		}
+	}
+	for _, hop := range b.Hops {
+		if !inA[hop.ShortChannelId] {
+			diff.OnlyB = append(diff.OnlyB, hop.ShortChannelId)
+		}
+	}
+	return diff
+}
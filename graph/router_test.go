@@ -0,0 +1,47 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	a, b, c, d, e := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4), testNodeId(5)
+	// a -> b -> e is cheap but its second hop is small, a -> c -> d -> e is expensive but likely
+	g := newTestGraph(
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 10, 40),
+		newTestChannel(b, e, "2x2x2", 150000, 0, 10, 40),
+		newTestChannel(a, c, "3x3x3", 1000000, 0, 10, 40),
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 100, 40),
+		newTestChannel(d, e, "5x5x5", 1000000, 0, 100, 40),
+		newTestChannel(e, a, "6x6x6", 1000000, 0, 10, 40),
+	)
+	g.Channels["2x2x2/"+util.GetDirection(b, e)].Liquidity = 150000000
+	amount := uint64(100000000)
+
+	dijkstra, err := GetRouter(ROUTER_DIJKSTRA)
+	assert.NoError(t, err)
+	cheapest, err := dijkstra(g, a, e, amount, nil, 10, NewRouteOptions())
+	assert.NoError(t, err)
+	probability, err := GetRouter(ROUTER_PROBABILITY)
+	assert.NoError(t, err)
+	likely, err := probability(g, a, e, amount, nil, 10, NewRouteOptions())
+	assert.NoError(t, err)
+
+	diff := DiffRoutes(cheapest, likely)
+	assert.Equal(t, []string{"1x1x1", "2x2x2"}, diff.OnlyA)
+	assert.Equal(t, []string{"3x3x3", "4x4x4", "5x5x5"}, diff.OnlyB)
+	assert.Empty(t, diff.SharedHops)
+	assert.Equal(t, 1, diff.HopCountDelta)
+	assert.Greater(t, diff.FeeDelta, int64(0))
+
+	same := DiffRoutes(cheapest, cheapest)
+	assert.Equal(t, []string{"1x1x1", "2x2x2"}, same.SharedHops)
+	assert.Empty(t, same.OnlyA)
+	assert.Equal(t, int64(0), same.FeeDelta)
+
+	_, err = GetRouter("astar")
+	assert.Equal(t, util.ErrUnknownRouter, err)
+	assert.Equal(t, []string{ROUTER_CACHED, ROUTER_DIJKSTRA, ROUTER_PROBABILITY}, RouterNames())
+}
@@ -11,6 +11,7 @@ type Stats struct {
 	ActiveChannels  int `json:"active_channels"`
 	LiquidChannels  int `json:"liquid_channels"`
 	MaxHtlcChannels int `json:"max_htlc_channels"`
+	NoFeesChannels  int `json:"no_fee_policy_channels"`
 }
 
 func (g *Graph) GetStats() *Stats {
@@ -22,10 +23,14 @@ func (g *Graph) GetStats() *Stats {
 	activeChannels := 0
 	atLeast200kLiquidity := 0
 	atLeast200kMaxHtlc := 0
+	noFeePolicy := 0
 	for _, c := range g.Channels {
 		if c.IsActive {
 			activeChannels++
 		}
+		if !c.HasFeePolicy() {
+			noFeePolicy++
+		}
 		if c.Liquidity >= 200000000 {
 			atLeast200kLiquidity++
 		}
@@ -41,6 +46,7 @@ func (g *Graph) GetStats() *Stats {
 		ActiveChannels:  activeChannels,
 		LiquidChannels:  atLeast200kLiquidity,
 		MaxHtlcChannels: atLeast200kMaxHtlc,
+		NoFeesChannels:  noFeePolicy,
 	}
 }
 
@@ -51,6 +57,7 @@ func (s *Stats) String() string {
 	result += "graph has " + strconv.Itoa(s.Channels) + " channels\n"
 	result += "graph has " + strconv.Itoa(s.ActiveChannels) + " active channels\n"
 	result += "graph has " + strconv.Itoa(s.LiquidChannels) + " channels believed to have at least 200k liquidity\n"
-	result += "graph has " + strconv.Itoa(s.MaxHtlcChannels) + " channels with at least 200k max htlc"
+	result += "graph has " + strconv.Itoa(s.MaxHtlcChannels) + " channels with at least 200k max htlc\n"
+	result += "graph has " + strconv.Itoa(s.NoFeesChannels) + " channels without a fee policy"
 	return result
 }
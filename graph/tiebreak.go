@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"circular/util"
+	"strings"
+)
+
+const (
+	// TIE_BREAK_HOPS prefers the path with fewer hops
+	TIE_BREAK_HOPS = "hops"
+	// TIE_BREAK_LIQUIDITY prefers the path whose least liquid channel has the most liquidity
+	TIE_BREAK_LIQUIDITY = "liquidity"
+	// TIE_BREAK_SCID prefers the path whose first channel has the lexicographically smallest scid
+	TIE_BREAK_SCID = "scid"
+
+	DEFAULT_TIE_BREAK = TIE_BREAK_HOPS + "," + TIE_BREAK_LIQUIDITY + "," + TIE_BREAK_SCID
+)
+
+// ParseTieBreak parses a comma separated list of tie-break criteria, in order of preference
+func ParseTieBreak(s string) ([]string, error) {
+	tieBreak := make([]string, 0, 3)
+	seen := make(map[string]bool)
+	for _, criterion := range strings.Split(s, ",") {
+		criterion = strings.TrimSpace(criterion)
+		if criterion == "" {
+			continue
+		}
+		switch criterion {
+		case TIE_BREAK_HOPS, TIE_BREAK_LIQUIDITY, TIE_BREAK_SCID:
+		default:
+			return nil, util.ErrInvalidTieBreak
+		}
+		if seen[criterion] {
+			continue
+		}
+		seen[criterion] = true
+		tieBreak = append(tieBreak, criterion)
+	}
+	return tieBreak, nil
+}
+
+// prefers tells whether the path described by a should be chosen over the one described by b
+// when they have the same cost. The criteria are applied in the order of TieBreak.
+func (o *RouteOptions) prefers(a, b *PqItem) bool {
+	if b == nil {
+		return true
+	}
+	for _, criterion := range o.TieBreak {
+		switch criterion {
+		case TIE_BREAK_HOPS:
+			if a.Hops != b.Hops {
+				return a.Hops < b.Hops
+			}
+		case TIE_BREAK_LIQUIDITY:
+			if a.MinLiquidity != b.MinLiquidity {
+				return a.MinLiquidity > b.MinLiquidity
+			}
+		case TIE_BREAK_SCID:
+			if a.Scid != b.Scid {
+				return a.Scid < b.Scid
+			}
+		}
+	}
+	return false
+}
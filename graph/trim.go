@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// MarshalWithin encodes the graph as it's saved to file, leaving out the channels with the oldest liquidity
+// beliefs until it fits in limit bytes, starting from the ones never learned. The channels left out come back
+// with the next refresh, believed 50/50. It returns how many channels were left out.
+func (g *Graph) MarshalWithin(limit int64) ([]byte, int, error) {
+	g.channelsLock.RLock()
+	defer g.channelsLock.RUnlock()
+
+	type entry struct {
+		id      string
+		channel *Channel
+		size    int64
+	}
+	entries := make([]entry, 0, len(g.Channels))
+	for id, c := range g.Channels {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return nil, 0, err
+		}
+		// "id":{...},
+		entries = append(entries, entry{id: id, channel: c, size: int64(len(id) + len(data) + 4)})
+	}
+	// newest beliefs first
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].channel.Timestamp != entries[j].channel.Timestamp {
+			return entries[i].channel.Timestamp > entries[j].channel.Timestamp
+		}
+		return entries[i].id < entries[j].id
+	})
+
+	kept := make(map[string]*Channel)
+	size := int64(len(`{"channels":{}}`))
+	for _, e := range entries {
+		if size+e.size > limit {
+			break
+		}
+		kept[e.id] = e.channel
+		size += e.size
+	}
+	data, err := json.Marshal(&Graph{Channels: kept})
+	return data, len(g.Channels) - len(kept), err
+}
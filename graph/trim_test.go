@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"circular/util"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestMarshalWithinDropsOldestBeliefs(t *testing.T) {
+	channels := make([]*Channel, 0)
+	for i := 1; i <= 10; i++ {
+		c := newTestChannel(testNodeId(i), testNodeId(i+1), strconv.Itoa(i)+"x1x1", 1000000, 0, 1, 40)
+		// the first two were never learned, the others from the oldest to the newest
+		if i > 2 {
+			c.Timestamp = int64(1000 + i)
+		}
+		channels = append(channels, c)
+	}
+	g := newTestGraph(channels...)
+
+	full, dropped, err := g.MarshalWithin(1 << 30)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	whole, err := json.Marshal(g)
+	assert.NoError(t, err)
+	assert.Equal(t, len(whole), len(full))
+
+	limit := int64(len(full) / 2)
+	data, dropped, err := g.MarshalWithin(limit)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, int64(len(data)), limit)
+	assert.Greater(t, dropped, 2)
+
+	saved := NewGraph()
+	assert.NoError(t, json.Unmarshal(data, saved))
+	assert.Equal(t, 10-dropped, len(saved.Channels))
+	// the channels kept are the ones learned the most recently
+	for _, c := range channels {
+		_, kept := saved.Channels[c.ShortChannelId+"/"+util.GetDirection(c.Source, c.Destination)]
+		assert.Equal(t, c.Timestamp > int64(1000+dropped), kept, c.ShortChannelId)
+	}
+}
@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"circular/util"
+	"fmt"
+	"github.com/elementsproject/glightning/glightning"
+)
+
+// ValidateChannel tells why c can't be added to the graph: its nodes must be public keys, and different.
+// Junk ids would end up as nodes of the adjacency list and give the channel a meaningless direction.
+func ValidateChannel(c *glightning.Channel) error {
+	if c == nil {
+		return fmt.Errorf("%w: no gossip", util.ErrMalformedChannel)
+	}
+	if c.ShortChannelId == "" {
+		return fmt.Errorf("%w: no short channel id", util.ErrMalformedChannel)
+	}
+	if !util.IsNodeId(c.Source) {
+		return fmt.Errorf("%w %s: invalid source %q", util.ErrMalformedChannel, c.ShortChannelId, c.Source)
+	}
+	if !util.IsNodeId(c.Destination) {
+		return fmt.Errorf("%w %s: invalid destination %q", util.ErrMalformedChannel, c.ShortChannelId, c.Destination)
+	}
+	if c.Source == c.Destination {
+		return fmt.Errorf("%w %s: source and destination are both %s", util.ErrMalformedChannel, c.ShortChannelId, c.Source)
+	}
+	return nil
+}
+
+// DropMalformed returns the channels of channelList that can be added to the graph, and why the others can't
+func DropMalformed(channelList []*glightning.Channel) ([]*glightning.Channel, []error) {
+	valid := make([]*glightning.Channel, 0, len(channelList))
+	var malformed []error
+	for _, c := range channelList {
+		if err := ValidateChannel(c); err != nil {
+			malformed = append(malformed, err)
+			continue
+		}
+		valid = append(valid, c)
+	}
+	return valid, malformed
+}
+
+// RemoveMalformed removes from the channels of a graph that was just decoded, before they are added to the
+// adjacency list, the ones that are malformed or that are saved under an id that isn't theirs, and tells why
+func (g *Graph) RemoveMalformed() []error {
+	var malformed []error
+	for id, c := range g.Channels {
+		var err error
+		if c == nil {
+			err = fmt.Errorf("%w %s: no channel", util.ErrMalformedChannel, id)
+		} else if err = ValidateChannel(c.Channel); err == nil {
+			if channelId := c.ShortChannelId + "/" + util.GetDirection(c.Source, c.Destination); id != channelId {
+				err = fmt.Errorf("%w %s: saved as %s", util.ErrMalformedChannel, channelId, id)
+			}
+		}
+		if err != nil {
+			malformed = append(malformed, err)
+			delete(g.Channels, id)
+		}
+	}
+	return malformed
+}
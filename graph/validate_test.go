@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"circular/util"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateChannel(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	assert.NoError(t, ValidateChannel(&glightning.Channel{Source: a, Destination: b, ShortChannelId: "1x1x1"}))
+
+	for _, c := range []*glightning.Channel{
+		nil,
+		{Source: a, Destination: b},
+		{Source: "", Destination: b, ShortChannelId: "1x1x1"},
+		{Source: a, Destination: "02aa", ShortChannelId: "1x1x1"},
+		{Source: a, Destination: "04" + b[2:], ShortChannelId: "1x1x1"},
+		{Source: a, Destination: b[:64] + "zz", ShortChannelId: "1x1x1"},
+		{Source: a, Destination: a, ShortChannelId: "1x1x1"},
+	} {
+		assert.True(t, errors.Is(ValidateChannel(c), util.ErrMalformedChannel), c)
+	}
+}
+
+func TestMalformedChannelsAreNotAdded(t *testing.T) {
+	a, b := testNodeId(1), testNodeId(2)
+	valid, malformed := DropMalformed([]*glightning.Channel{
+		{Source: a, Destination: b, ShortChannelId: "1x1x1"},
+		{Source: a, Destination: "", ShortChannelId: "2x2x2"},
+	})
+	assert.Len(t, valid, 1)
+	assert.Len(t, malformed, 1)
+	assert.Contains(t, malformed[0].Error(), "2x2x2")
+
+	g := newTestGraph(newTestChannel(a, b, "1x1x1", 1000000, 0, 1, 40))
+	junk := newTestChannel(a, b, "2x2x2", 1000000, 0, 1, 40)
+	junk.Destination = "not a node"
+	g.Channels["2x2x2/0"] = junk
+	// a valid channel saved under the id of another one
+	g.Channels["3x3x3/0"] = newTestChannel(b, a, "4x4x4", 1000000, 0, 1, 40)
+
+	assert.Len(t, g.RemoveMalformed(), 2)
+	assert.Len(t, g.Channels, 1)
+	assert.NoError(t, g.CheckConsistency())
+}
@@ -0,0 +1,59 @@
+package graph
+
+const (
+	// FEE_HISTORY_LENGTH is the number of graph refreshes whose advertised fee is kept for each channel
+	FEE_HISTORY_LENGTH = 8
+	// FEE_REFERENCE_AMOUNT (msat) is the amount at which the advertised fees are compared, so that
+	// a change of the base fee and one of the proportional fee can be measured together
+	FEE_REFERENCE_AMOUNT = 1000000000
+	// VOLATILE_FEE_THRESHOLD is the volatility above which the fees of a channel are flagged as volatile
+	VOLATILE_FEE_THRESHOLD = 0.25
+)
+
+// recordFee appends the fee that c advertises now to history, the fees advertised at the previous
+// refreshes, and returns the most recent FEE_HISTORY_LENGTH of them
+func (c *Channel) recordFee(history []uint64) []uint64 {
+	fee := c.ComputeFeePPM(FEE_REFERENCE_AMOUNT)
+	if len(history) == FEE_HISTORY_LENGTH {
+		history = history[1:]
+	}
+	return append(append(make([]uint64, 0, FEE_HISTORY_LENGTH), history...), fee)
+}
+
+// pushFee records the current fee of c in its own history, in place. The graph must be locked.
+func (c *Channel) pushFee() {
+	fee := c.ComputeFeePPM(FEE_REFERENCE_AMOUNT)
+	if len(c.feeHistory) < FEE_HISTORY_LENGTH {
+		c.feeHistory = append(c.feeHistory, fee)
+		return
+	}
+	copy(c.feeHistory, c.feeHistory[1:])
+	c.feeHistory[len(c.feeHistory)-1] = fee
+}
+
+// FeeVolatility is how wildly the advertised fee of c changed over the last refreshes: the average of
+// the relative changes between consecutive refreshes, from 0 for a fee that never changed to 1 for a
+// fee going back and forth between zero and something else at every refresh
+func (c *Channel) FeeVolatility() float64 {
+	if len(c.feeHistory) < 2 {
+		return 0
+	}
+	var total float64
+	for i := 1; i < len(c.feeHistory); i++ {
+		previous, current := c.feeHistory[i-1], c.feeHistory[i]
+		if previous == current {
+			continue
+		}
+		high, low := previous, current
+		if low > high {
+			high, low = low, high
+		}
+		total += float64(high-low) / float64(high)
+	}
+	return total / float64(len(c.feeHistory)-1)
+}
+
+// IsFeeVolatile tells whether the fees of c change too often to budget against them
+func (c *Channel) IsFeeVolatile() bool {
+	return c.FeeVolatility() > VOLATILE_FEE_THRESHOLD
+}
@@ -0,0 +1,62 @@
+package graph
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// refreshWithFee refreshes g with the channels of gossip, the fee rate of the one with scid replaced by feeRate
+func refreshWithFee(g *Graph, gossip []*glightning.Channel, scid string, feeRate uint64) {
+	refreshed := make([]*glightning.Channel, len(gossip))
+	for i, c := range gossip {
+		copied := *c
+		if copied.ShortChannelId == scid {
+			copied.FeePerMillionth = feeRate
+		}
+		refreshed[i] = &copied
+	}
+	g.RefreshChannels(refreshed)
+}
+
+func TestFeeVolatility(t *testing.T) {
+	a, b, c, d := testNodeId(1), testNodeId(2), testNodeId(3), testNodeId(4)
+	// a -> b -> d is the cheapest path, but the fee of b -> d spikes at every other refresh
+	gossip := []*glightning.Channel{
+		newTestChannel(a, b, "1x1x1", 1000000, 0, 10, 40).Channel,
+		newTestChannel(b, d, "2x2x2", 1000000, 0, 10, 40).Channel,
+		newTestChannel(a, c, "3x3x3", 1000000, 0, 50, 40).Channel,
+		newTestChannel(c, d, "4x4x4", 1000000, 0, 50, 40).Channel,
+		newTestChannel(d, a, "5x5x5", 1000000, 0, 10, 40).Channel,
+	}
+	g := NewGraph()
+	for _, feeRate := range []uint64{10, 2000, 10, 2000, 10, 2000, 10, 2000, 10, 2000, 10} {
+		refreshWithFee(g, gossip, "2x2x2", feeRate)
+	}
+	volatile := g.Channels["2x2x2/"+util.GetDirection(b, d)]
+	stable := g.Channels["4x4x4/"+util.GetDirection(c, d)]
+
+	assert.Len(t, volatile.feeHistory, FEE_HISTORY_LENGTH)
+	assert.InDelta(t, 0.995, volatile.FeeVolatility(), 0.001)
+	assert.True(t, volatile.IsFeeVolatile())
+	assert.Equal(t, 0.0, stable.FeeVolatility())
+	assert.False(t, stable.IsFeeVolatile())
+
+	// without the penalty the volatile channel is used, since its fee is low right now
+	amount := uint64(100000000)
+	hops, err := g.dijkstra(a, d, amount, nil, 10, NewRouteOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, b, hops[0].Destination)
+
+	options := NewRouteOptions()
+	options.FeeVolatilityWeight = 1000
+	hops, err = g.dijkstra(a, d, amount, nil, 10, options)
+	assert.NoError(t, err)
+	assert.Equal(t, c, hops[0].Destination)
+
+	channels, _, err := g.QueryChannels(&ChannelQuery{SortBy: SORT_BY_VOLATILITY, Descending: true, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "2x2x2/"+util.GetDirection(b, d), channels[0].ChannelId)
+	assert.True(t, channels[0].VolatileFee)
+}
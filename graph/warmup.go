@@ -0,0 +1,43 @@
+package graph
+
+import (
+	"sort"
+)
+
+const (
+	// WARM_UP_AMOUNT (msat) is the amount of the throwaway search, small enough to go through most channels
+	WARM_UP_AMOUNT = 1000000
+)
+
+// WarmUp runs a throwaway search to dst from a node picked among all the nodes of the graph, so that
+// the first real search doesn't pay for touching the adjacency lists and the channels for the first time.
+// The search settles the nodes closer to dst than the source, which is a large part of the graph on average.
+// The route cache is not used, and nothing in the graph is changed. It returns the number of hops of
+// the route found, 0 if there is none.
+func (g *Graph) WarmUp(dst string, options *RouteOptions) int {
+	g.adjacencyListLock.RLock()
+	sources := make([]string, 0, len(g.Inbound))
+	for v := range g.Inbound {
+		if v != dst {
+			sources = append(sources, v)
+		}
+	}
+	g.adjacencyListLock.RUnlock()
+	if len(sources) == 0 {
+		return 0
+	}
+	// the same source on every start, which one doesn't matter
+	sort.Strings(sources)
+	src := sources[len(sources)/2]
+
+	warmUpOptions := NewRouteOptions()
+	if options != nil {
+		*warmUpOptions = *options
+	}
+	warmUpOptions.CacheRoutes = false
+	route, err := g.GetRoute(src, dst, WARM_UP_AMOUNT, nil, MAX_ROUTE_LENGTH, warmUpOptions)
+	if err != nil {
+		return 0
+	}
+	return len(route.Hops)
+}
@@ -0,0 +1,26 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWarmUpLeavesTheGraphUnchanged(t *testing.T) {
+	self, a, b := testNodeId(0), testNodeId(1), testNodeId(2)
+	graph := newTestGraph(
+		newTestChannel(self, a, "1x1x1", 1000000, 0, 1, 40),
+		newTestChannel(a, b, "2x2x2", 1000000, 0, 1, 40),
+		newTestChannel(b, self, "3x3x3", 1000000, 0, 1, 40),
+	)
+	version := graph.Version()
+	options := NewRouteOptions()
+	options.CacheRoutes = true
+
+	// the search goes from b, the second of a and b
+	assert.Equal(t, 1, graph.WarmUp(self, options))
+	assert.Equal(t, version, graph.Version())
+	assert.Len(t, graph.cache.hops, 0)
+	assert.True(t, options.CacheRoutes)
+
+	assert.Equal(t, 0, graph.WarmUp(testNodeId(9), options))
+}
@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"sync"
+)
+
+const (
+	// PARALLEL_MIN_NEIGHBORS is the number of neighbors that a node needs for its channels to be evaluated
+	// concurrently: for smaller nodes, starting the workers costs more than it saves
+	PARALLEL_MIN_NEIGHBORS = 128
+)
+
+// evaluateEdgesParallel evaluates the edges of u, the node being settled, across options.SearchWorkers goroutines.
+// The candidates are returned in the order of the edges, so that relaxing them one by one gives the same
+// result as the serial search. Relaxing is not parallel: distances and the priority queue are shared.
+func (g *Graph) evaluateEdgesParallel(u, src, dst string, edges map[string]Edge, amount, fee uint64, exclude map[string]bool, options *RouteOptions) []relaxCandidate {
+	neighbors := make([]string, 0, len(edges))
+	for v := range edges {
+		neighbors = append(neighbors, v)
+	}
+	workers := options.SearchWorkers
+	if workers > len(neighbors) {
+		workers = len(neighbors)
+	}
+
+	// each worker takes a contiguous range of neighbors and collects its candidates by neighbor
+	candidates := make([][]relaxCandidate, len(neighbors))
+	size := (len(neighbors) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(neighbors); start += size {
+		end := start + size
+		if end > len(neighbors) {
+			end = len(neighbors)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				v := neighbors[i]
+				g.evaluateEdge(u, v, src, dst, edges[v], amount, fee, exclude, options, func(c relaxCandidate) {
+					candidates[i] = append(candidates[i], c)
+				})
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	result := make([]relaxCandidate, 0, len(neighbors))
+	for _, c := range candidates {
+		result = append(result, c...)
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newHubGraph builds a graph of leaves connected to a few random hubs each, which are all connected together.
+// The hubs end up with hundreds of neighbors, like the big routing nodes of mainnet.
+func newHubGraph(r *rand.Rand, hubs, leaves int) *Graph {
+	channels := make([]*Channel, 0)
+	scid := 0
+	connect := func(a, b string) {
+		scid++
+		id := fmt.Sprintf("%dx%dx0", 700000+scid/1000, scid%1000)
+		sats := uint64(1000000 + r.Intn(10000000))
+		base, ppm := uint64(r.Intn(2000)), uint64(r.Intn(1000))
+		channels = append(channels,
+			newTestChannel(a, b, id, sats, base, ppm, 40),
+			newTestChannel(b, a, id, sats, uint64(r.Intn(2000)), uint64(r.Intn(1000)), 40))
+	}
+	for i := 0; i < hubs; i++ {
+		for j := i + 1; j < hubs; j++ {
+			connect(testNodeId(i), testNodeId(j))
+		}
+	}
+	for i := hubs; i < hubs+leaves; i++ {
+		for _, h := range r.Perm(hubs)[:3] {
+			connect(testNodeId(i), testNodeId(h))
+		}
+	}
+	return newTestGraph(channels...)
+}
+
+func TestParallelSearchEqualsSerial(t *testing.T) {
+	mainnet, err := LoadGraphFromFile("testdata", "mainnet_graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := LoadGraphFromFile("testdata", "graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(69))
+	graphs := map[string]*Graph{
+		"mainnet": mainnet,
+		"small":   small,
+		"hubs":    newHubGraph(r, 10, 1000),
+	}
+
+	for name, graph := range graphs {
+		ids := make([]string, 0, len(graph.Inbound))
+		for id := range graph.Inbound {
+			ids = append(ids, id)
+		}
+		serial := NewRouteOptions()
+		parallel := NewRouteOptions()
+		parallel.SearchWorkers = 4
+
+		for i := 0; i < 20; i++ {
+			src := ids[r.Intn(len(ids))]
+			dst := ids[r.Intn(len(ids))]
+			if src == dst {
+				continue
+			}
+			amount := uint64(r.Intn(1000000000))
+			hops, err := graph.dijkstra(src, dst, amount, nil, 8, serial)
+			parallelHops, parallelErr := graph.dijkstra(src, dst, amount, nil, 8, parallel)
+			assert.Equal(t, err, parallelErr, name)
+			if err != nil {
+				continue
+			}
+			// the tie-break ends with the scid, so even equal cost routes must be the same
+			assert.Equal(t, hops[0].MilliSatoshi, parallelHops[0].MilliSatoshi, name)
+			assert.Equal(t, len(hops), len(parallelHops), name)
+			for j := range hops {
+				assert.Equal(t, hops[j].ShortChannelId, parallelHops[j].ShortChannelId, name)
+			}
+		}
+	}
+}
+
+func BenchmarkGraph_GetRouteParallel(b *testing.B) {
+	graph := newHubGraph(rand.New(rand.NewSource(69)), 40, 20000)
+	rand.Seed(69)
+
+	ids := make([]string, 0, len(graph.Inbound))
+	for k := range graph.Inbound {
+		ids = append(ids, k)
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		options := NewRouteOptions()
+		options.SearchWorkers = workers
+		b.Run(fmt.Sprintf("dijkstra_%d_workers", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				src := ids[rand.Intn(len(ids))]
+				dst := ids[rand.Intn(len(ids))]
+				graph.dijkstra(src, dst, 100000000, nil, 6, options)
+			}
+		})
+	}
+}
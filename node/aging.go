@@ -0,0 +1,159 @@
+package node
+
+import (
+	"circular/util"
+	"encoding/json"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"os"
+	"time"
+)
+
+const (
+	AGING_FILE = "aging.json"
+)
+
+// AgingParameters are the settings of the aging of the liquidity beliefs: a belief older than
+// LiquidityRefresh goes back to half the capacity at the first check, every CheckInterval
+type AgingParameters struct {
+	LiquidityRefresh int `json:"liquidity_refresh"` // minutes
+	CheckInterval    int `json:"check_interval"`    // minutes
+	// Disabled is set when the beliefs never age, whatever LiquidityRefresh, see circular-disable-aging
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+func (n *Node) getLiquidityRefresh() time.Duration {
+	n.agingLock.RLock()
+	defer n.agingLock.RUnlock()
+	return n.liquidityRefresh
+}
+
+func (n *Node) setLiquidityRefresh(refresh time.Duration) {
+	n.agingLock.Lock()
+	defer n.agingLock.Unlock()
+	n.liquidityRefresh = refresh
+}
+
+// loadAging reads the aging parameters changed with circular-aging, which win over circular-liquidity-refresh.
+// A missing file leaves the options as they are.
+func (n *Node) loadAging(dir string) error {
+	data, err := os.ReadFile(dir + "/" + AGING_FILE)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var parameters AgingParameters
+	if err := json.Unmarshal(data, &parameters); err != nil {
+		return err
+	}
+	if parameters.LiquidityRefresh < 1 {
+		return util.ErrInvalidLiquidityRefresh
+	}
+	n.setLiquidityRefresh(time.Duration(parameters.LiquidityRefresh) * time.Minute)
+	n.Logln(glightning.Info, "loaded the aging parameters, liquidity refresh: ", parameters.LiquidityRefresh, " minutes")
+	return nil
+}
+
+func (n *Node) saveAging(dir string, parameters *AgingParameters) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dir+"/"+AGING_FILE, data, 0644)
+}
+
+// Aging shows the aging parameters and their effect at the next check, and changes them when LiquidityRefresh is set
+type Aging struct {
+	LiquidityRefresh int    `json:"liquidityrefresh,omitempty"` // minutes
+	Scid             string `json:"scid,omitempty"`
+}
+
+type AgingSample struct {
+	Id            string `json:"id"`
+	Capacity      uint64 `json:"capacity_msat"`
+	Liquidity     uint64 `json:"liquidity_msat"`
+	LastUpdate    int64  `json:"last_update"`
+	NextLiquidity uint64 `json:"next_liquidity_msat"`
+}
+
+type AgingResult struct {
+	AgingParameters
+	// NextResets is the number of beliefs that will be reset at the next check, at the latest
+	NextResets int `json:"next_resets"`
+	// AverageDrift is how far the beliefs will move at the next check, on average over all the channels
+	AverageDrift uint64         `json:"average_drift_ppm"`
+	Samples      []*AgingSample `json:"samples,omitempty"`
+}
+
+func (a *Aging) Name() string {
+	return "circular-aging"
+}
+
+func (a *Aging) New() interface{} {
+	return &Aging{}
+}
+
+func (a *Aging) Call() (jrpc2.Result, error) {
+	n := GetNode()
+	if a.LiquidityRefresh < 0 {
+		return nil, util.ErrInvalidLiquidityRefresh
+	}
+	if a.LiquidityRefresh > 0 {
+		n.setLiquidityRefresh(time.Duration(a.LiquidityRefresh) * time.Minute)
+		if err := n.saveAging(CIRCULAR_DIR, &AgingParameters{LiquidityRefresh: a.LiquidityRefresh}); err != nil {
+			n.Logln(glightning.Unusual, "unable to save the aging parameters: ", err)
+			return nil, err
+		}
+		n.Logln(glightning.Info, "liquidity refresh changed to ", a.LiquidityRefresh, " minutes")
+	}
+	return n.agingPreview(a.Scid, time.Now())
+}
+
+// agingPreview describes the aging at the check following now, and the beliefs of the two directions of scid if it is set
+func (n *Node) agingPreview(scid string, now time.Time) (*AgingResult, error) {
+	refresh := n.getLiquidityRefresh()
+	next := now.Add(LIQUIDITY_REFRESH_INTERVAL * time.Minute)
+	result := &AgingResult{
+		AgingParameters: AgingParameters{
+			LiquidityRefresh: int(n.getLiquidityRefresh().Minutes()),
+			CheckInterval:    LIQUIDITY_REFRESH_INTERVAL,
+			Disabled:         n.agingDisabled,
+		},
+	}
+	// with aging disabled nothing is reset, whatever the refresh
+	if !n.agingDisabled {
+		result.NextResets, result.AverageDrift = n.Graph.AgingPreview(refresh, next)
+	}
+
+	if scid == "" {
+		return result, nil
+	}
+	for _, direction := range []string{"0", "1"} {
+		channel, err := n.Graph.GetChannel(scid + "/" + direction)
+		if err != nil {
+			continue
+		}
+		nextLiquidity := channel.Liquidity
+		if !n.agingDisabled {
+			nextLiquidity = channel.AgedLiquidity(refresh, next.Unix())
+		}
+		result.Samples = append(result.Samples, &AgingSample{
+			Id:            scid + "/" + direction,
+			Capacity:      channel.Satoshis * 1000,
+			Liquidity:     channel.Liquidity,
+			LastUpdate:    channel.Timestamp,
+			NextLiquidity: nextLiquidity,
+		})
+	}
+	if len(result.Samples) == 0 {
+		return nil, util.ErrChannelNotFound
+	}
+	return result, nil
+}
@@ -0,0 +1,38 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAgingPreviewDisabled(t *testing.T) {
+	alice, bob := "02aa", "02bb"
+	g := graph.NewGraph()
+	c := &glightning.Channel{Source: alice, Destination: bob, ShortChannelId: "1x1x1", Satoshis: 1000000}
+	id := "1x1x1/" + util.GetDirection(alice, bob)
+	g.Channels[id] = graph.NewChannel(c, 42, 0)
+	g.AddChannel(g.Channels[id])
+	g.Channels[id].Timestamp = time.Now().Add(-30 * 24 * time.Hour).Unix()
+	n := &Node{Graph: g, liquidityRefresh: 0, agingLock: &sync.RWMutex{}}
+
+	// a refresh of 0 resets every belief at the next check
+	preview, err := n.agingPreview("1x1x1", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, preview.NextResets)
+	assert.Equal(t, uint64(500000000), preview.Samples[0].NextLiquidity)
+
+	// unless aging is disabled, whatever the refresh
+	n.agingDisabled = true
+	preview, err = n.agingPreview("1x1x1", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, preview.Disabled)
+	assert.Equal(t, 0, preview.NextResets)
+	assert.Equal(t, uint64(42), preview.Samples[0].NextLiquidity)
+	// the configured refresh is still reported, for when aging is enabled again
+	assert.Equal(t, 0, preview.LiquidityRefresh)
+}
@@ -0,0 +1,59 @@
+package node
+
+import (
+	"circular/util"
+	"encoding/json"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+	"os"
+	"time"
+)
+
+const (
+	ALIASES_FILE = "aliases.json"
+)
+
+// loadAliases reads the aliases saved next to the graph, a JSON object of aliases by node id.
+// Aliases are only used for display, so they are kept out of the graph file. It returns how many were loaded.
+func (n *Node) loadAliases(dir string) (int, error) {
+	defer util.TimeTrack(time.Now(), "node.loadAliases", n.Logf)
+	data, err := os.ReadFile(dir + "/" + ALIASES_FILE)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	aliases := make(map[string]string)
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return 0, err
+	}
+	n.Graph.SetAliases(aliases)
+	n.Logln(glightning.Info, "loaded ", len(aliases), " aliases")
+	return len(aliases), nil
+}
+
+// saveAliases saves the aliases to file if they changed since the last save.
+// The caller must hold saveLock.
+func (n *Node) saveAliases(dir string) error {
+	version := n.Graph.AliasesVersion()
+	if version == n.savedAliasesVersion {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(n.Graph.GetAliases())
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dir+"/"+ALIASES_FILE+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(dir+"/"+ALIASES_FILE+".tmp", dir+"/"+ALIASES_FILE); err != nil {
+		return err
+	}
+	n.savedAliasesVersion = version
+	return nil
+}
@@ -0,0 +1,98 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"os"
+)
+
+const (
+	ALLOWLIST_FILE = "allowlist.json"
+)
+
+// loadAllowlist reads the allowlist from the data dir, a JSON array of node ids.
+// A missing file is an empty allowlist.
+func (n *Node) loadAllowlist(dir string) error {
+	data, err := os.ReadFile(dir + "/" + ALLOWLIST_FILE)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var nodes []string
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return err
+	}
+	n.Graph.SetAllowlist(nodes)
+	n.Logln(glightning.Info, "loaded ", len(nodes), " nodes in the allowlist")
+	return nil
+}
+
+func (n *Node) saveAllowlist(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(n.Graph.GetAllowlist())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dir+"/"+ALLOWLIST_FILE, data, 0644)
+}
+
+// Allowlist adds and removes nodes from the allowlist, and returns it
+type Allowlist struct {
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+type AllowlistResult struct {
+	Enabled bool     `json:"enabled"`
+	Nodes   []string `json:"nodes"`
+}
+
+func (a *Allowlist) Name() string {
+	return "circular-allowlist"
+}
+
+func (a *Allowlist) New() interface{} {
+	return &Allowlist{}
+}
+
+func (a *Allowlist) Call() (jrpc2.Result, error) {
+	n := GetNode()
+	if len(a.Add) > 0 || len(a.Remove) > 0 {
+		nodes := updateAllowlist(n.Graph.GetAllowlist(), a.Add, a.Remove)
+		n.Graph.SetAllowlist(nodes)
+		if err := n.saveAllowlist(CIRCULAR_DIR); err != nil {
+			n.Logln(glightning.Unusual, "unable to save the allowlist: ", err)
+			return nil, err
+		}
+		n.Logln(glightning.Info, "allowlist updated, it has ", len(nodes), " nodes")
+	}
+	return &AllowlistResult{
+		Enabled: n.RouteOptions.Allowlist,
+		Nodes:   n.Graph.GetAllowlist(),
+	}, nil
+}
+
+func updateAllowlist(nodes, add, remove []string) []string {
+	allowed := make(map[string]bool, len(nodes)+len(add))
+	for _, id := range nodes {
+		allowed[id] = true
+	}
+	for _, id := range add {
+		allowed[id] = true
+	}
+	for _, id := range remove {
+		delete(allowed, id)
+	}
+	result := make([]string, 0, len(allowed))
+	for id := range allowed {
+		result = append(result, id)
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"strconv"
+	"strings"
+)
+
+const (
+	// LOCAL_BALANCE_TO_US is all our balance in the channel, what lightningd calls to_us_msat
+	LOCAL_BALANCE_TO_US = "to-us"
+	// LOCAL_BALANCE_TO_US_MINUS_RESERVE is our balance minus the reserve that our peer requires us to keep
+	LOCAL_BALANCE_TO_US_MINUS_RESERVE = "to-us-minus-reserve"
+	// LOCAL_BALANCE_SPENDABLE is what lightningd says that we can send right now, which also
+	// accounts for the htlcs in flight and for the fees of the commitment transaction
+	LOCAL_BALANCE_SPENDABLE = "spendable"
+
+	DEFAULT_LOCAL_BALANCE = LOCAL_BALANCE_TO_US_MINUS_RESERVE
+)
+
+func ParseLocalBalanceSource(source string) (string, error) {
+	switch source {
+	case LOCAL_BALANCE_TO_US, LOCAL_BALANCE_TO_US_MINUS_RESERVE, LOCAL_BALANCE_SPENDABLE:
+		return source, nil
+	}
+	return "", util.ErrInvalidLocalBalanceSource
+}
+
+// LocalBalance is what we believe we can send through the channel, according to the local balance source
+func (n *Node) LocalBalance(channel *glightning.PeerChannel) uint64 {
+	return localBalance(channel, n.localBalanceSource)
+}
+
+// RemoteBalance is what we believe we can receive through the channel, according to the local balance source
+func (n *Node) RemoteBalance(channel *glightning.PeerChannel) uint64 {
+	return remoteBalance(channel, n.localBalanceSource)
+}
+
+func localBalance(channel *glightning.PeerChannel, source string) uint64 {
+	toUs := msat(channel.MilliSatoshiToUs, channel.ToUsMsat)
+	switch source {
+	case LOCAL_BALANCE_SPENDABLE:
+		return msat(channel.SpendableMilliSatoshi, channel.SpendableMsat)
+	case LOCAL_BALANCE_TO_US_MINUS_RESERVE:
+		return subtractReserve(toUs, channel.OurChannelReserveSatoshi*1000, channel.OurReserveMsat)
+	}
+	return toUs
+}
+
+func remoteBalance(channel *glightning.PeerChannel, source string) uint64 {
+	toThem := subtractReserve(msat(channel.MilliSatoshiTotal, channel.TotalMsat), msat(channel.MilliSatoshiToUs, channel.ToUsMsat), "")
+	switch source {
+	case LOCAL_BALANCE_SPENDABLE:
+		return msat(channel.ReceivableMilliSatoshi, channel.ReceivableMsat)
+	case LOCAL_BALANCE_TO_US_MINUS_RESERVE:
+		return subtractReserve(toThem, channel.TheirChannelReserveSatoshi*1000, channel.TheirReserveMsat)
+	}
+	return toThem
+}
+
+// subtractReserve subtracts the reserve from the balance, without going below zero
+func subtractReserve(balance, reserve uint64, reserveMsat string) uint64 {
+	reserve = msat(reserve, reserveMsat)
+	if reserve > balance {
+		return 0
+	}
+	return balance - reserve
+}
+
+// msat returns value, or the amount in s ("1234msat") if value is not set.
+// Depending on its version, lightningd fills one or the other.
+func msat(value uint64, s string) uint64 {
+	if value != 0 || s == "" {
+		return value
+	}
+	parsed, _ := strconv.ParseUint(strings.TrimSuffix(s, "msat"), 10, 64)
+	return parsed
+}
+
+// seedLocalLiquidity sets the liquidity of both directions of our channels to the balances reported
+// by listpeers, so that the first and last hops are not believed to carry more than they can.
+// The caller must hold PeersLock.
+func (n *Node) seedLocalLiquidity() int {
+	seeded := 0
+	for _, peer := range n.Peers {
+		for _, channel := range peer.Channels {
+			if channel.ShortChannelId == "" {
+				continue
+			}
+			if n.Graph.SetLiquidity(channel.ShortChannelId+"/"+util.GetDirection(n.Id, peer.Id), n.LocalBalance(channel)) {
+				seeded++
+			}
+			if n.Graph.SetLiquidity(channel.ShortChannelId+"/"+util.GetDirection(peer.Id, n.Id), n.RemoteBalance(channel)) {
+				seeded++
+			}
+		}
+	}
+	return seeded
+}
@@ -0,0 +1,34 @@
+package node
+
+import (
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLocalBalanceWithReserve(t *testing.T) {
+	channel := &glightning.PeerChannel{
+		MilliSatoshiTotal:        1000000000,
+		MilliSatoshiToUs:         600000000,
+		OurChannelReserveSatoshi: 10000,
+		TheirReserveMsat:         "20000000msat",
+		SpendableMilliSatoshi:    585000000,
+		ReceivableMilliSatoshi:   375000000,
+	}
+
+	assert.Equal(t, uint64(600000000), localBalance(channel, LOCAL_BALANCE_TO_US))
+	assert.Equal(t, uint64(400000000), remoteBalance(channel, LOCAL_BALANCE_TO_US))
+
+	assert.Equal(t, uint64(590000000), localBalance(channel, LOCAL_BALANCE_TO_US_MINUS_RESERVE))
+	assert.Equal(t, uint64(380000000), remoteBalance(channel, LOCAL_BALANCE_TO_US_MINUS_RESERVE))
+
+	assert.Equal(t, uint64(585000000), localBalance(channel, LOCAL_BALANCE_SPENDABLE))
+	assert.Equal(t, uint64(375000000), remoteBalance(channel, LOCAL_BALANCE_SPENDABLE))
+
+	// a balance below the reserve can't be spent at all
+	channel.MilliSatoshiToUs = 5000000
+	assert.Equal(t, uint64(0), localBalance(channel, LOCAL_BALANCE_TO_US_MINUS_RESERVE))
+
+	_, err := ParseLocalBalanceSource("everything")
+	assert.Error(t, err)
+}
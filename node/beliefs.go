@@ -0,0 +1,104 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"encoding/json"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"os"
+	"time"
+)
+
+const (
+	BELIEFS_FILE = "beliefs.json"
+)
+
+type ExportBeliefs struct {
+	File string `json:"file,omitempty"`
+}
+
+type ExportBeliefsResult struct {
+	File     string `json:"file"`
+	Exported int    `json:"exported"`
+}
+
+func (e *ExportBeliefs) Name() string {
+	return "circular-export-beliefs"
+}
+
+func (e *ExportBeliefs) New() interface{} {
+	return &ExportBeliefs{}
+}
+
+func (e *ExportBeliefs) Call() (jrpc2.Result, error) {
+	n := GetNode()
+	defer util.TimeTrack(time.Now(), "node.ExportBeliefs", n.Logf)
+	if e.File == "" {
+		e.File = CIRCULAR_DIR + "/" + BELIEFS_FILE
+	}
+
+	beliefs := n.Graph.ExportBeliefs(n.Id)
+	file, err := os.Create(e.File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := json.NewEncoder(file).Encode(beliefs); err != nil {
+		return nil, err
+	}
+
+	n.Logln(glightning.Info, "exported ", len(beliefs.Beliefs), " liquidity beliefs to ", e.File)
+	return &ExportBeliefsResult{
+		File:     e.File,
+		Exported: len(beliefs.Beliefs),
+	}, nil
+}
+
+type ImportBeliefs struct {
+	File string `json:"file"`
+}
+
+type ImportBeliefsResult struct {
+	From     string `json:"from"`
+	Imported int    `json:"imported"`
+	Skipped  int    `json:"skipped"`
+}
+
+func (i *ImportBeliefs) Name() string {
+	return "circular-import-beliefs"
+}
+
+func (i *ImportBeliefs) New() interface{} {
+	return &ImportBeliefs{}
+}
+
+func (i *ImportBeliefs) Call() (jrpc2.Result, error) {
+	n := GetNode()
+	defer util.TimeTrack(time.Now(), "node.ImportBeliefs", n.Logf)
+	if i.File == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+
+	file, err := os.Open(i.File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	beliefs := &graph.Beliefs{}
+	if err := json.NewDecoder(file).Decode(beliefs); err != nil {
+		return nil, err
+	}
+	if beliefs.Version != graph.BELIEFS_VERSION {
+		return nil, util.ErrUnsupportedBeliefsVersion
+	}
+
+	imported, skipped := n.Graph.ImportBeliefs(beliefs, n.Id)
+	n.Logln(glightning.Info, "imported ", imported, " liquidity beliefs from ", n.Graph.GetAlias(beliefs.NodeId), ", skipped ", skipped)
+	return &ImportBeliefsResult{
+		From:     beliefs.NodeId,
+		Imported: imported,
+		Skipped:  skipped,
+	}, nil
+}
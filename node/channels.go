@@ -0,0 +1,67 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/jrpc2"
+	"time"
+)
+
+const (
+	DEFAULT_CHANNELS_LIMIT = 50
+)
+
+type ChannelInfo struct {
+	SortBy            string  `json:"sortby,omitempty"`
+	Descending        bool    `json:"desc,omitempty"`
+	MinPPM            uint64  `json:"minppm,omitempty"`
+	MaxPPM            uint64  `json:"maxppm,omitempty"`
+	MinLiquidityRatio float64 `json:"minliquidityratio,omitempty"`
+	MaxLiquidityRatio float64 `json:"maxliquidityratio,omitempty"`
+	Limit             int     `json:"limit,omitempty"`
+	Offset            int     `json:"offset,omitempty"`
+}
+
+type ChannelInfoResult struct {
+	Total    int                 `json:"total"`
+	Channels []graph.ChannelInfo `json:"channels"`
+}
+
+func (c *ChannelInfo) Name() string {
+	return "circular-channels"
+}
+
+func (c *ChannelInfo) New() interface{} {
+	return &ChannelInfo{}
+}
+
+func (c *ChannelInfo) Call() (jrpc2.Result, error) {
+	n := GetNode()
+	defer util.TimeTrack(time.Now(), "node.ChannelInfo", n.Logf)
+
+	if c.Limit <= 0 {
+		c.Limit = DEFAULT_CHANNELS_LIMIT
+	}
+	if c.Offset < 0 {
+		c.Offset = 0
+	}
+
+	channels, total, err := n.Graph.QueryChannels(&graph.ChannelQuery{
+		SortBy:            c.SortBy,
+		Descending:        c.Descending,
+		MinPPM:            c.MinPPM,
+		MaxPPM:            c.MaxPPM,
+		MinLiquidityRatio: c.MinLiquidityRatio,
+		MaxLiquidityRatio: c.MaxLiquidityRatio,
+		Limit:             c.Limit,
+		Offset:            c.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChannelInfoResult{
+		Total:    total,
+		Channels: channels,
+	}, nil
+}
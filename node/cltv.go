@@ -0,0 +1,53 @@
+package node
+
+import (
+	"circular/graph"
+	"github.com/elementsproject/glightning/glightning"
+)
+
+// CLTV_FINAL_CONFIG is the option of lightningd with the minimum delta it accepts on the last hop
+const CLTV_FINAL_CONFIG = "cltv-final"
+
+// loadMinFinalCltv reads cltv-final from lightningd, keeping graph.MIN_FINAL_CLTV if it can't be read
+func (n *Node) loadMinFinalCltv() {
+	configs, err := n.lightning.ListConfigs()
+	if err != nil {
+		n.Logln(glightning.Unusual, "unable to read ", CLTV_FINAL_CONFIG, ", using ", graph.MIN_FINAL_CLTV, ": ", err)
+		return
+	}
+	cltvFinal, ok := parseCltvFinal(configs)
+	if !ok {
+		n.Logln(glightning.Unusual, CLTV_FINAL_CONFIG, " not found in listconfigs, using ", graph.MIN_FINAL_CLTV)
+		return
+	}
+	n.minFinalCltv = cltvFinal
+	n.Logln(glightning.Debug, CLTV_FINAL_CONFIG, ": ", cltvFinal)
+}
+
+// parseCltvFinal finds cltv-final in the result of listconfigs, either at the top level
+// or, since lightningd 23.08, as the value_int of configs
+func parseCltvFinal(configs map[string]interface{}) (uint, bool) {
+	if value, ok := configs[CLTV_FINAL_CONFIG].(float64); ok && value > 0 {
+		return uint(value), true
+	}
+	nested, ok := configs["configs"].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	config, ok := nested[CLTV_FINAL_CONFIG].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	if value, ok := config["value_int"].(float64); ok && value > 0 {
+		return uint(value), true
+	}
+	return 0, false
+}
+
+// MinFinalCltv is the minimum delta that our node accepts on the last hop of a rebalance
+func (n *Node) MinFinalCltv() uint {
+	if n.minFinalCltv == 0 {
+		return graph.MIN_FINAL_CLTV
+	}
+	return n.minFinalCltv
+}
@@ -0,0 +1,34 @@
+package node
+
+import (
+	"circular/graph"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseCltvFinal(t *testing.T) {
+	parse := func(raw string) (uint, bool) {
+		var configs map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+			t.Fatal(err)
+		}
+		return parseCltvFinal(configs)
+	}
+
+	cltvFinal, ok := parse(`{"cltv-final": 40}`)
+	assert.True(t, ok)
+	assert.Equal(t, uint(40), cltvFinal)
+
+	cltvFinal, ok = parse(`{"configs": {"cltv-final": {"source": "default", "value_int": 18}}}`)
+	assert.True(t, ok)
+	assert.Equal(t, uint(18), cltvFinal)
+
+	_, ok = parse(`{"configs": {}}`)
+	assert.False(t, ok)
+}
+
+func TestMinFinalCltvDefault(t *testing.T) {
+	assert.Equal(t, uint(graph.MIN_FINAL_CLTV), (&Node{}).MinFinalCltv())
+	assert.Equal(t, uint(40), (&Node{minFinalCltv: 40}).MinFinalCltv())
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type CooldownStatus struct {
+	Scid         string `json:"scid"`
+	LastUsed     int64  `json:"last_used"`
+	RemainingSec int64  `json:"remaining_seconds"`
+}
+
+// channelCooldowns remembers when each local channel was last used by a successful rebalance
+type channelCooldowns struct {
+	lock     *sync.Mutex
+	lastUsed map[string]time.Time
+}
+
+func newChannelCooldowns() *channelCooldowns {
+	return &channelCooldowns{
+		lock:     &sync.Mutex{},
+		lastUsed: make(map[string]time.Time),
+	}
+}
+
+func (c *channelCooldowns) mark(now time.Time, scids ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, scid := range scids {
+		c.lastUsed[scid] = now
+	}
+}
+
+// remaining is how long scid still has to cool down, 0 if it can be used
+func (c *channelCooldowns) remaining(scid string, now time.Time, period time.Duration) time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	lastUsed, ok := c.lastUsed[scid]
+	if !ok || now.Sub(lastUsed) >= period {
+		return 0
+	}
+	return period - now.Sub(lastUsed)
+}
+
+// cooling returns the channels still cooling down, the ones with the longest wait first.
+// The channels that finished cooling down are forgotten.
+func (c *channelCooldowns) cooling(now time.Time, period time.Duration) []CooldownStatus {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result := make([]CooldownStatus, 0)
+	for scid, lastUsed := range c.lastUsed {
+		if now.Sub(lastUsed) >= period {
+			delete(c.lastUsed, scid)
+			continue
+		}
+		result = append(result, CooldownStatus{
+			Scid:         scid,
+			LastUsed:     lastUsed.Unix(),
+			RemainingSec: int64((period - now.Sub(lastUsed)).Seconds()),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].RemainingSec != result[j].RemainingSec {
+			return result[i].RemainingSec > result[j].RemainingSec
+		}
+		return result[i].Scid < result[j].Scid
+	})
+	return result
+}
+
+// MarkRebalanced starts the cooldown of the local channels used by a successful rebalance
+func (n *Node) MarkRebalanced(scids ...string) {
+	if n.ChannelCooldown <= 0 {
+		return
+	}
+	n.cooldowns.mark(time.Now(), scids...)
+}
+
+// CooldownRemaining is how long the local channel scid has to wait before being rebalanced again
+func (n *Node) CooldownRemaining(scid string) time.Duration {
+	if n.ChannelCooldown <= 0 {
+		return 0
+	}
+	return n.cooldowns.remaining(scid, time.Now(), n.ChannelCooldown)
+}
+
+func (n *Node) GetCooldowns() []CooldownStatus {
+	return n.cooldowns.cooling(time.Now(), n.ChannelCooldown)
+}
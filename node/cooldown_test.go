@@ -0,0 +1,33 @@
+package node
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannelCooldowns(t *testing.T) {
+	c := newChannelCooldowns()
+	now := time.Unix(1700000000, 0)
+	period := 30 * time.Minute
+
+	assert.Equal(t, time.Duration(0), c.remaining("1x1x1", now, period))
+
+	c.mark(now, "1x1x1", "2x2x2")
+	c.mark(now.Add(10*time.Minute), "3x3x3")
+	assert.Equal(t, 20*time.Minute, c.remaining("1x1x1", now.Add(10*time.Minute), period))
+	assert.Equal(t, time.Duration(0), c.remaining("1x1x1", now.Add(period), period))
+
+	cooling := c.cooling(now.Add(20*time.Minute), period)
+	assert.Equal(t, 3, len(cooling))
+	assert.Equal(t, "3x3x3", cooling[0].Scid)
+	assert.Equal(t, int64(20*60), cooling[0].RemainingSec)
+	assert.Equal(t, "1x1x1", cooling[1].Scid)
+	assert.Equal(t, int64(10*60), cooling[1].RemainingSec)
+
+	// the channels that cooled down are forgotten
+	cooling = c.cooling(now.Add(35*time.Minute), period)
+	assert.Equal(t, 1, len(cooling))
+	assert.Equal(t, "3x3x3", cooling[0].Scid)
+	assert.Equal(t, 1, len(c.lastUsed))
+}
@@ -1,6 +1,7 @@
 package node
 
 import (
+	"circular/graph"
 	"circular/util"
 	"github.com/elementsproject/glightning/glightning"
 	"github.com/robfig/cron/v3"
@@ -11,21 +12,59 @@ import (
 
 const (
 	LIQUIDITY_REFRESH_INTERVAL = 10 // minutes
+	GRAPH_AGE_CHECK_INTERVAL   = 1  // minutes
 )
 
 func (n *Node) setupCronJobs(options map[string]glightning.Option) {
 	c := cron.New()
 
 	// every 10 minutes by default, refresh the information gathered via gossip
-	addCronJob(c, strconv.Itoa(options["circular-graph-refresh"].GetValue().(int))+"m", func() {
-		n.refreshGraph()
+	graphRefresh := options["circular-graph-refresh"].GetValue().(int)
+	addCronJob(c, strconv.Itoa(graphRefresh)+"m", func() {
+		n.scheduledRefreshGraph(time.Duration(graphRefresh) * time.Minute)
+	})
+
+	// every 10 minutes by default, refresh the aliases of the nodes. ListNodes is expensive on big graphs
+	// and aliases are only used for display, so they can be refreshed less often than the channels
+	addCronJob(c, strconv.Itoa(options["circular-alias-refresh"].GetValue().(int))+"m", func() {
+		if _, err := n.tryRefreshAliases(false); err != nil {
+			n.Logln(glightning.Unusual, "aliases refresh failed: ", err)
+		}
+	})
+
+	// if enabled, force a refresh when the graph gets older than the max age
+	if n.graphMaxAge > 0 {
+		addCronJob(c, strconv.Itoa(GRAPH_AGE_CHECK_INTERVAL)+"m", func() {
+			n.checkGraphAge()
+		})
+	}
+
+	// every 10 minutes by default, save the graph if it changed
+	addCronJob(c, strconv.Itoa(int(n.saveInterval.Minutes()))+"m", func() {
+		n.saveGraph()
 	})
 
 	// every 30 seconds by default, refresh peers
 	addCronJob(c, strconv.Itoa(options["circular-peer-refresh"].GetValue().(int))+"s", func() {
-		n.refreshPeers()
+		if _, err := n.tryRefreshPeers(false); err != nil {
+			n.Logln(glightning.Unusual, "peers refresh failed: ", err)
+		}
 	})
 
+	// every 10 minutes by default, find again the routes to the favorite peers, dropped by the graph refresh
+	if len(n.favoritePeers) > 0 {
+		addCronJob(c, strconv.Itoa(int(n.favoritesRefresh.Minutes()))+"m", func() {
+			n.warmFavoriteRoutes()
+		})
+	}
+
+	// every hour, drop the fee history older than the retention
+	if n.feeHistory != nil {
+		addCronJob(c, strconv.Itoa(FEE_HISTORY_PRUNE_INTERVAL)+"m", func() {
+			n.pruneFeeHistory()
+		})
+	}
+
 	// every 10 minutes by default, check if there are channels that need to be reset
 	addCronJob(c, strconv.Itoa(LIQUIDITY_REFRESH_INTERVAL)+"m", func() {
 		n.refreshLiquidity()
@@ -41,62 +80,173 @@ func addCronJob(c *cron.Cron, interval string, f func()) {
 	}
 }
 
-func (n *Node) refreshGraph() error {
+// scheduledRefreshGraph refreshes the graph, unless it was refreshed (e.g. manually)
+// during the last half of the interval, in which case it is still fresh
+func (n *Node) scheduledRefreshGraph(interval time.Duration) {
+	if age := n.graphAge(); age < interval/2 {
+		n.Logln(glightning.Debug, "skipping scheduled graph refresh, the graph was refreshed ", age.Round(time.Second), " ago")
+		return
+	}
+	if _, err := n.tryRefreshGraph(false); err != nil {
+		n.Logln(glightning.Unusual, "graph refresh failed: ", err)
+	}
+}
+
+// checkGraphAge forces a graph refresh if the last successful one is older than the max age
+func (n *Node) checkGraphAge() {
+	age := n.graphAge()
+	if age <= n.graphMaxAge {
+		return
+	}
+	n.Logln(glightning.Info, "forcing graph refresh, last successful refresh was ", age.Round(time.Second), " ago")
+	if _, err := n.tryRefreshGraph(false); err != nil {
+		n.Logln(glightning.Unusual, "forced graph refresh failed: ", err)
+	}
+}
+
+func (n *Node) refreshGraph() (result *RefreshResult, err error) {
 	defer util.TimeTrack(time.Now(), "node.refreshGraph", n.Logf)
+	defer func() { n.updateGraphHealth(err) }()
 	n.Logln(glightning.Info, "refreshing graph")
-
-	channelList, err := n.lightning.ListChannels()
+	start := time.Now()
+
+	var (
+		channelList []*glightning.Channel
+		inboundFees map[string]*graph.InboundFee
+	)
+	if n.RouteOptions.InboundFees {
+		channelList, inboundFees, err = n.listChannelsWithInboundFees()
+	} else {
+		channelList, err = n.lightning.ListChannels()
+	}
 	if err != nil {
 		n.Logf(glightning.Unusual, "error listing channels: %+v", err)
-		return err
+		return nil, err
+	}
+
+	channelList, malformed := graph.DropMalformed(channelList)
+	for _, malformedErr := range malformed {
+		n.Logln(glightning.Debug, malformedErr, ", skipping it")
+	}
+	if len(malformed) > 0 {
+		n.Logln(glightning.Unusual, "skipped ", len(malformed), " malformed channels from listchannels")
+	}
+
+	// the channels over the cap are dropped before they are built
+	channelList, capped := graph.CapChannelList(channelList, n.maxChannels, n.Id)
+	if capped > 0 {
+		n.Logln(glightning.Info, "graph capped at ", n.maxChannels, " channels, skipped ", capped, " from listchannels")
 	}
 
 	n.Logln(glightning.Debug, "refreshing channels")
-	n.Graph.RefreshChannels(channelList)
+	added := n.Graph.RefreshChannels(channelList)
+	n.recordFeeHistory(channelList)
+	if n.RouteOptions.InboundFees {
+		n.Logln(glightning.Debug, "channels with inbound fees: ", n.Graph.SetInboundFees(inboundFees))
+	}
 
 	n.Logln(glightning.Debug, "pruning channels")
-	n.Graph.PruneChannels()
+	removed := n.Graph.PruneChannels()
+
+	// the graph might still have channels over the cap from the previous refreshes or the file
+	if dropped := n.Graph.CapChannels(n.maxChannels, n.Id); len(dropped) > 0 {
+		for _, c := range dropped {
+			n.Logln(glightning.Debug, "dropping channel ", c.ShortChannelId, "/", c.GetDirection(),
+				" of ", c.Satoshis, " sats, last updated at ", time.Unix(int64(c.LastUpdate), 0))
+		}
+		n.Logln(glightning.Info, "graph capped at ", n.maxChannels, " channels, dropped ", len(dropped))
+		removed += len(dropped)
+	}
+
+	if n.checkGraph {
+		if err := n.Graph.CheckConsistency(); err != nil {
+			n.Logln(glightning.Unusual, err)
+		}
+	}
+
+	n.refreshDeadNodes()
+
+	// the routes found in advance might go through channels that changed or left the graph
+	n.Graph.ClearFavoriteRoutes()
+
+	if n.RouteOptions.MinLiquidityPercentile > 0 {
+		cutoff := n.Graph.RefreshLiquidityCutoff(n.RouteOptions.MinLiquidityPercentile)
+		n.Logf(glightning.Debug, "skipping the channels with a liquidity ratio below %.3f", cutoff)
+	}
 
+	n.Logln(glightning.Info, "graph has been refreshed")
+	return newRefreshResult(start, added, removed, len(channelList)), nil
+}
+
+func (n *Node) refreshAliases() (*RefreshResult, error) {
+	defer util.TimeTrack(time.Now(), "node.refreshAliases", n.Logf)
 	n.Logln(glightning.Debug, "refreshing aliases")
+	start := time.Now()
+
 	nodes, err := n.lightning.ListNodes()
 	if err != nil {
 		n.Logf(glightning.Unusual, "error listing nodes: %+v", err)
-		return err
+		return nil, err
 	}
 	n.Graph.RefreshAliases(nodes)
+	return newRefreshResult(start, 0, 0, len(nodes)), nil
+}
 
-	n.Logln(glightning.Debug, "saving graph to file")
-	if err = n.SaveGraphToFile(CIRCULAR_DIR, "graph.json"); err != nil {
-		n.Logf(glightning.Unusual, "error saving graph to file: %+v", err)
-		return err
+// refreshAlias refreshes the alias of a single node, without waiting for the next refresh of all the aliases
+func (n *Node) refreshAlias(id string) {
+	node, err := n.lightning.GetNode(id)
+	if err != nil {
+		// the node might not have announced itself yet
+		n.Logln(glightning.Debug, "unable to refresh the alias of ", id, ": ", err)
+		return
+	}
+	if n.Graph.UpdateAlias(node.Id, node.Alias) {
+		n.Logln(glightning.Debug, "alias of ", node.Id, " updated to ", node.Alias)
 	}
-
-	n.Logln(glightning.Info, "graph has been refreshed")
-	return nil
 }
 
-func (n *Node) refreshPeers() error {
+func (n *Node) refreshPeers() (*RefreshResult, error) {
 	defer util.TimeTrack(time.Now(), "node.refreshPeers", n.Logf)
 	n.Logln(glightning.Debug, "refreshing peers")
+	start := time.Now()
 
 	peers, err := n.lightning.ListPeers()
 	if err != nil {
 		n.Logln(glightning.Unusual, err)
-		return err
+		return nil, err
 	}
 
 	n.PeersLock.Lock()
 	defer n.PeersLock.Unlock()
+	added := 0
+	listed := make(map[string]bool, len(peers))
 	for _, peer := range peers {
+		if _, ok := n.Peers[peer.Id]; !ok {
+			added++
+		}
 		n.Peers[peer.Id] = peer
+		listed[peer.Id] = true
 	}
-	return nil
+	// the peers that lightningd forgot, once their channels are closed and they disconnected
+	removed := 0
+	for id := range n.Peers {
+		if !listed[id] {
+			delete(n.Peers, id)
+			removed++
+		}
+	}
+	n.Logln(glightning.Debug, "seeded the liquidity of ", n.seedLocalLiquidity(), " local channels")
+	return newRefreshResult(start, added, removed, len(n.Peers)), nil
 }
 
 func (n *Node) refreshLiquidity() {
 	defer util.TimeTrack(time.Now(), "node.refreshLiquidity", n.Logf)
+	if n.agingDisabled {
+		n.Logln(glightning.Debug, "aging disabled, not refreshing liquidity")
+		return
+	}
 	n.Logln(glightning.Debug, "refreshing liquidity")
 
-	hits := n.Graph.RefreshLiquidity(n.liquidityRefresh)
+	hits := n.Graph.RefreshLiquidity(n.getLiquidityRefresh())
 	n.Logf(glightning.Info, "liquidity has been reset on %d channels", hits)
 }
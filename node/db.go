@@ -2,10 +2,12 @@ package node
 
 import (
 	"circular/graph"
+	"encoding/hex"
 	"encoding/json"
 	badger "github.com/dgraph-io/badger/v3"
 	"github.com/elementsproject/glightning/glightning"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -70,6 +72,34 @@ func (s *Store) Delete(key string) error {
 	return nil
 }
 
+// ListPaymentHashes returns the hashes of the payments that are still pending:
+// the ones whose preimage is stored and the ones that timed out
+func (s *Store) ListPaymentHashes() ([]string, error) {
+	result := make([]string, 0)
+	err := s.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		it := txn.NewIterator(options)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := string(it.Item().Key())
+			if strings.HasPrefix(key, TIMEOUT_PREFIX) {
+				result = append(result, strings.TrimPrefix(key, TIMEOUT_PREFIX))
+				continue
+			}
+			// preimages are stored with their hash as key
+			if _, err := hex.DecodeString(key); err == nil && len(key) == 64 {
+				result = append(result, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (s *Store) ListFailures() ([]glightning.SendPayFailure, error) {
 	result := make([]glightning.SendPayFailure, 0)
 	err := s.db.View(func(txn *badger.Txn) error {
@@ -0,0 +1,54 @@
+package node
+
+import (
+	"github.com/elementsproject/glightning/glightning"
+)
+
+// refreshDeadNodes remembers the nodes that the gossip shows as offline, see graph.DeadNodes.
+// It's done after every graph refresh, so that route searches don't have to walk the graph.
+func (n *Node) refreshDeadNodes() {
+	if !n.excludeDeadNodes {
+		return
+	}
+	dead := n.Graph.DeadNodes(n.Id)
+	n.deadNodesLock.Lock()
+	n.deadNodes = dead
+	n.deadNodesLock.Unlock()
+	n.Logln(glightning.Debug, "nodes that look offline: ", len(dead))
+}
+
+// ExcludeDeadNodes adds to exclude the nodes that look offline, if circular-exclude-dead-nodes is enabled:
+// the ones that the gossip shows as offline and our peers that are disconnected from us.
+// The nodes in keep, the ends of the route, are never excluded. It returns how many nodes were added.
+func (n *Node) ExcludeDeadNodes(exclude map[string]bool, keep ...string) int {
+	if !n.excludeDeadNodes {
+		return 0
+	}
+	kept := make(map[string]bool, len(keep))
+	for _, id := range keep {
+		kept[id] = true
+	}
+
+	added := 0
+	add := func(id string) {
+		if !kept[id] && !exclude[id] {
+			exclude[id] = true
+			added++
+		}
+	}
+
+	n.deadNodesLock.RLock()
+	for id := range n.deadNodes {
+		add(id)
+	}
+	n.deadNodesLock.RUnlock()
+
+	n.PeersLock.RLock()
+	for id, peer := range n.Peers {
+		if !peer.Connected {
+			add(id)
+		}
+	}
+	n.PeersLock.RUnlock()
+	return added
+}
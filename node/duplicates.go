@@ -0,0 +1,22 @@
+package node
+
+import "circular/util"
+
+const (
+	// DUPLICATES_REJECT fails a rebalance identical to one already in flight
+	DUPLICATES_REJECT = "reject"
+	// DUPLICATES_COALESCE waits for the identical rebalance in flight and returns its result
+	DUPLICATES_COALESCE = "coalesce"
+	// DUPLICATES_ALLOW runs identical rebalances side by side
+	DUPLICATES_ALLOW = "allow"
+
+	DEFAULT_DUPLICATES = DUPLICATES_REJECT
+)
+
+func ParseDuplicates(mode string) (string, error) {
+	switch mode {
+	case DUPLICATES_REJECT, DUPLICATES_COALESCE, DUPLICATES_ALLOW:
+		return mode, nil
+	}
+	return "", util.ErrInvalidDuplicates
+}
@@ -0,0 +1,59 @@
+package node
+
+import (
+	"bytes"
+	"circular/util"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/elementsproject/glightning/glightning"
+	"log"
+)
+
+const (
+	// ENTROPY_CHECK_SAMPLES is the number of preimages generated by the self-test at startup
+	ENTROPY_CHECK_SAMPLES = 16
+)
+
+// checkEntropy makes sure that the preimage generator is usable before any payment is sent.
+// A broken generator would let anyone who guesses the preimages claim the rebalances, so the plugin stops.
+func (n *Node) checkEntropy() {
+	if err := checkPreimageGenerator(n.PreimageGenerator, ENTROPY_CHECK_SAMPLES); err != nil {
+		n.Logln(glightning.Unusual, "CRITICAL: ", err, ", refusing to start")
+		log.Fatalln(err)
+	}
+	n.Logln(glightning.Info, "preimage generator self-test passed with ", ENTROPY_CHECK_SAMPLES, " samples")
+}
+
+// checkPreimageGenerator generates samples preimages and checks that they are well formed, that the hashes
+// derive from them and that none of them repeats. It can't prove that they are random, but it catches
+// the generators that fail, return constants or are deterministic by design.
+func checkPreimageGenerator(g PreimageGenerator, samples int) error {
+	if _, ok := g.(*DeterministicPreimageGenerator); ok {
+		return fmt.Errorf("%w: the generator is deterministic", util.ErrEntropyCheckFailed)
+	}
+	zero := make([]byte, 32)
+	seen := make(map[string]bool, samples)
+	for i := 0; i < samples; i++ {
+		pair, err := g.Generate()
+		if err != nil {
+			return fmt.Errorf("%w: %v", util.ErrEntropyCheckFailed, err)
+		}
+		preimage, err := hex.DecodeString(pair.Preimage)
+		if err != nil || len(preimage) != 32 {
+			return fmt.Errorf("%w: malformed preimage", util.ErrEntropyCheckFailed)
+		}
+		if bytes.Equal(preimage, zero) {
+			return fmt.Errorf("%w: empty preimage", util.ErrEntropyCheckFailed)
+		}
+		hash := sha256.Sum256(preimage)
+		if hex.EncodeToString(hash[:]) != pair.Hash {
+			return fmt.Errorf("%w: the hash doesn't derive from the preimage", util.ErrEntropyCheckFailed)
+		}
+		if seen[pair.Preimage] {
+			return fmt.Errorf("%w: repeated preimage after %d samples", util.ErrEntropyCheckFailed, i)
+		}
+		seen[pair.Preimage] = true
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package node
+
+import (
+	"circular/util"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// brokenPreimageGenerator simulates the ways an entropy source can go wrong
+type brokenPreimageGenerator struct {
+	pairs []PreimageHashPair
+	err   error
+	calls int
+}
+
+func (g *brokenPreimageGenerator) Generate() (PreimageHashPair, error) {
+	if g.err != nil {
+		return PreimageHashPair{}, g.err
+	}
+	pair := g.pairs[g.calls%len(g.pairs)]
+	g.calls++
+	return pair, nil
+}
+
+func TestCheckPreimageGenerator(t *testing.T) {
+	assert.NoError(t, checkPreimageGenerator(&LocalPreimageGenerator{}, ENTROPY_CHECK_SAMPLES))
+
+	valid, err := NewPreimageHashPair()
+	assert.NoError(t, err)
+	other, err := NewPreimageHashPair()
+	assert.NoError(t, err)
+	zero := strings.Repeat("00", 32)
+
+	broken := map[string]PreimageGenerator{
+		"failing":       &brokenPreimageGenerator{err: errors.New("no entropy")},
+		"constant":      &brokenPreimageGenerator{pairs: []PreimageHashPair{valid}},
+		"cycling":       &brokenPreimageGenerator{pairs: []PreimageHashPair{valid, other}},
+		"wrong hash":    &brokenPreimageGenerator{pairs: []PreimageHashPair{{Preimage: valid.Preimage, Hash: other.Hash}}},
+		"malformed":     &brokenPreimageGenerator{pairs: []PreimageHashPair{{Preimage: "not hex", Hash: valid.Hash}}},
+		"empty":         &brokenPreimageGenerator{pairs: []PreimageHashPair{{Preimage: zero, Hash: valid.Hash}}},
+		"deterministic": NewDeterministicPreimageGenerator([]byte("seed")),
+	}
+	for name, g := range broken {
+		err := checkPreimageGenerator(g, ENTROPY_CHECK_SAMPLES)
+		assert.ErrorIs(t, err, util.ErrEntropyCheckFailed, name)
+	}
+}
@@ -0,0 +1,113 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"math/rand"
+	"sync"
+)
+
+const (
+	// DEFAULT_EXPLORE_BIAS is the default of circular-explore-bias (ppm of the amount)
+	DEFAULT_EXPLORE_BIAS = 1000
+)
+
+type ExplorationStatus struct {
+	// Rate is the percentage of the rebalances that explore
+	Rate int `json:"rate"`
+	// Explorations is the number of rebalances that explored, ProbedChannels the number of channels
+	// without evidence that their routes went through, once per rebalance
+	Explorations   int `json:"explorations"`
+	ProbedChannels int `json:"probed_channels"`
+	// EvidencedChannels out of PublicChannels have a liquidity backed by evidence, StartEvidenced
+	// is how many had one before the first exploration
+	EvidencedChannels int `json:"evidenced_channels"`
+	PublicChannels    int `json:"public_channels"`
+	StartEvidenced    int `json:"evidenced_channels_at_start"`
+}
+
+// exploration decides which rebalances explore the channels without evidence, and counts what they did
+type exploration struct {
+	lock           *sync.Mutex
+	rand           *rand.Rand
+	explorations   int
+	probedChannels int
+	startEvidenced int
+}
+
+func newExploration(source rand.Source) *exploration {
+	return &exploration{
+		lock: &sync.Mutex{},
+		rand: rand.New(source),
+	}
+}
+
+// decide tells whether a rebalance explores, which happens for rate percent of them.
+// coverage is only called before the first exploration, to remember where it started from.
+func (e *exploration) decide(rate int, coverage func() int) bool {
+	if rate <= 0 {
+		return false
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.rand.Intn(100) >= rate {
+		return false
+	}
+	if e.explorations == 0 {
+		e.startEvidenced = coverage()
+	}
+	e.explorations++
+	return true
+}
+
+func (e *exploration) record(probed int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.probedChannels += probed
+}
+
+// ShouldExplore tells whether a rebalance should prefer the channels without evidence, see circular-explore-rate
+func (n *Node) ShouldExplore() bool {
+	return n.exploration.decide(n.ExploreRate, func() int {
+		evidenced, _ := n.Graph.EvidenceCoverage()
+		return evidenced
+	})
+}
+
+// ExploreOptions are the route options of an exploring rebalance, biased towards the channels without evidence
+func (n *Node) ExploreOptions(options *graph.RouteOptions) *graph.RouteOptions {
+	explore := *options
+	explore.CacheRoutes = false
+	explore.ExploreBias = n.ExploreBias
+	return &explore
+}
+
+// RecordExploration counts the hops without evidence of a route tried by an exploring rebalance.
+// probed are the channels already counted for the same rebalance, so that the attempts going through
+// the same channels count them once: the new ones are added to it.
+func (n *Node) RecordExploration(route *graph.Route, probed map[string]bool) {
+	count := 0
+	for _, hop := range route.Hops {
+		id := hop.ShortChannelId + "/" + util.GetDirection(hop.Source, hop.Destination)
+		if hop.Evidence || probed[id] {
+			continue
+		}
+		probed[id] = true
+		count++
+	}
+	n.exploration.record(count)
+}
+
+func (n *Node) GetExploration() ExplorationStatus {
+	n.exploration.lock.Lock()
+	defer n.exploration.lock.Unlock()
+	evidenced, public := n.Graph.EvidenceCoverage()
+	return ExplorationStatus{
+		Rate:              n.ExploreRate,
+		Explorations:      n.exploration.explorations,
+		ProbedChannels:    n.exploration.probedChannels,
+		EvidencedChannels: evidenced,
+		PublicChannels:    public,
+		StartEvidenced:    n.exploration.startEvidenced,
+	}
+}
@@ -0,0 +1,67 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/graph/graphtest"
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestExplorationPicksUnevidencedRoutesAtRate(t *testing.T) {
+	a, b, c, d := graphtest.NodeId(1), graphtest.NodeId(2), graphtest.NodeId(3), graphtest.NodeId(4)
+	// a-b-d is cheaper, a-c-d has never been used
+	g := graphtest.NewGraph(
+		graphtest.NewChannel(a, b, "1x1x1", 1000000, 0, 10, 40),
+		graphtest.NewChannel(b, d, "2x2x2", 1000000, 0, 10, 40),
+		graphtest.NewChannel(a, c, "3x3x3", 1000000, 0, 100, 40),
+		graphtest.NewChannel(c, d, "4x4x4", 1000000, 0, 100, 40),
+		graphtest.NewChannel(d, a, "5x5x5", 1000000, 0, 10, 40),
+	)
+	g.Channels["1x1x1/"+util.GetDirection(a, b)].Evidence = true
+	g.Channels["2x2x2/"+util.GetDirection(b, d)].Evidence = true
+	g.Channels["5x5x5/"+util.GetDirection(d, a)].Evidence = true
+
+	n := &Node{
+		Graph:        g,
+		RouteOptions: graph.NewRouteOptions(),
+		ExploreRate:  20,
+		ExploreBias:  DEFAULT_EXPLORE_BIAS,
+		exploration:  newExploration(rand.NewSource(1)),
+	}
+	n.RouteOptions.CacheRoutes = false
+
+	trials, explored := 1000, 0
+	for i := 0; i < trials; i++ {
+		options := n.RouteOptions
+		if n.ShouldExplore() {
+			options = n.ExploreOptions(options)
+		}
+		route, err := g.GetRoute(a, d, 100000000, map[string]bool{}, 8, options)
+		if !assert.NoError(t, err) {
+			return
+		}
+		if route.CountUnevidenced() > 0 {
+			explored++
+			// a rebalance that tries the same route twice probes its channels once
+			probed := make(map[string]bool)
+			n.RecordExploration(route, probed)
+			n.RecordExploration(route, probed)
+		}
+	}
+	status := n.GetExploration()
+	assert.Equal(t, explored, status.Explorations)
+	assert.Equal(t, 2*explored, status.ProbedChannels)
+	assert.InDelta(t, trials*n.ExploreRate/100, explored, 50)
+	assert.Equal(t, 3, status.StartEvidenced)
+	assert.Equal(t, 5, status.PublicChannels)
+}
+
+func TestExplorationDisabled(t *testing.T) {
+	e := newExploration(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		assert.False(t, e.decide(0, func() int { return 0 }))
+	}
+	assert.Equal(t, 0, e.explorations)
+}
@@ -0,0 +1,61 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/jrpc2"
+	"time"
+)
+
+const (
+	EXPORT_FORMAT_JSON = "json"
+	EXPORT_FORMAT_CSV  = "csv"
+)
+
+type ExportLiquidity struct {
+	Format    string `json:"format,omitempty"`
+	Neighbors bool   `json:"neighbors,omitempty"`
+}
+
+type ExportLiquidityResult struct {
+	Format   string               `json:"format"`
+	Channels []graph.LiquidityRow `json:"channels,omitempty"`
+	CSV      string               `json:"csv,omitempty"`
+}
+
+func (e *ExportLiquidity) Name() string {
+	return "circular-export-liquidity"
+}
+
+func (e *ExportLiquidity) New() interface{} {
+	return &ExportLiquidity{}
+}
+
+func (e *ExportLiquidity) Call() (jrpc2.Result, error) {
+	n := GetNode()
+	defer util.TimeTrack(time.Now(), "node.ExportLiquidity", n.Logf)
+
+	if e.Format == "" {
+		e.Format = EXPORT_FORMAT_JSON
+	}
+	if e.Format != EXPORT_FORMAT_JSON && e.Format != EXPORT_FORMAT_CSV {
+		return nil, util.ErrInvalidExportFormat
+	}
+
+	rows := n.Graph.ExportLiquidity(n.Id, e.Neighbors)
+	if e.Format == EXPORT_FORMAT_JSON {
+		return &ExportLiquidityResult{
+			Format:   e.Format,
+			Channels: rows,
+		}, nil
+	}
+
+	csv, err := graph.LiquidityToCSV(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportLiquidityResult{
+		Format: e.Format,
+		CSV:    csv,
+	}, nil
+}
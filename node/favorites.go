@@ -0,0 +1,50 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"strings"
+	"time"
+)
+
+const (
+	// FAVORITE_ROUTE_AMOUNT (msat) is the amount the routes to the favorite peers are searched for,
+	// the default split amount of circular-pull and circular-push
+	FAVORITE_ROUTE_AMOUNT     = 100000000
+	DEFAULT_FAVORITES_REFRESH = 10 // minutes
+)
+
+// parseFavoritePeers parses a comma separated list of node ids, returning apart the entries that are not node ids
+func parseFavoritePeers(s string) (favorites, invalid []string) {
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(s, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		if !util.IsNodeId(id) {
+			invalid = append(invalid, id)
+			continue
+		}
+		seen[id] = true
+		favorites = append(favorites, id)
+	}
+	return favorites, invalid
+}
+
+// warmFavoriteRoutes searches the cheapest routes from the peers of our channels to the favorite peers,
+// so that the rebalances towards them don't wait for a search, see circular-favorite-peers
+func (n *Node) warmFavoriteRoutes() {
+	defer util.TimeTrack(time.Now(), "node.warmFavoriteRoutes", n.Logf)
+	n.PeersLock.RLock()
+	sources := make([]string, 0, len(n.Peers))
+	for id := range n.Peers {
+		sources = append(sources, id)
+	}
+	n.PeersLock.RUnlock()
+
+	exclude := map[string]bool{n.Id: true}
+	routes := n.Graph.WarmFavoriteRoutes(sources, n.favoritePeers, FAVORITE_ROUTE_AMOUNT, exclude, graph.MAX_ROUTE_LENGTH, n.RouteOptions)
+	n.Logln(glightning.Debug, "found ", routes, " routes from ", len(sources), " peers to ", len(n.favoritePeers), " favorite peers")
+}
@@ -0,0 +1,19 @@
+package node
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseFavoritePeers(t *testing.T) {
+	a := "02" + strings.Repeat("a", 64)
+	b := "03" + strings.Repeat("b", 64)
+	favorites, invalid := parseFavoritePeers(a + ", " + b + ",," + a + ",nope")
+	assert.Equal(t, []string{a, b}, favorites)
+	assert.Equal(t, []string{"nope"}, invalid)
+
+	favorites, invalid = parseFavoritePeers("")
+	assert.Empty(t, favorites)
+	assert.Empty(t, invalid)
+}
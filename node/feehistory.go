@@ -0,0 +1,283 @@
+package node
+
+import (
+	"bufio"
+	"circular/util"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FEE_HISTORY_FILE = "fee_history.csv"
+
+	DEFAULT_FEE_HISTORY_RETENTION = 30 // days
+	FEE_HISTORY_PRUNE_INTERVAL    = 60 // minutes
+)
+
+// FeeEntry is the fee advertised by a direction of a channel from Timestamp, until the next entry of the same direction
+type FeeEntry struct {
+	Timestamp int64  `json:"timestamp"`
+	Direction string `json:"direction"`
+	BaseFee   uint64 `json:"base_fee_msat"`
+	FeeRate   uint64 `json:"fee_ppm"`
+}
+
+type advertisedFee struct {
+	baseFee uint64
+	feeRate uint64
+}
+
+// feeHistory appends the fees advertised by the channels of the graph to FEE_HISTORY_FILE, one line per entry:
+//
+//	<unix timestamp>,<scid>/<direction>,<base fee msat>,<fee ppm>
+//
+// A direction is written the first time it's seen and then only when its fees change, so the fee of a
+// direction at some time is the one of its last line before it. It's only for analytics, routing doesn't read it.
+type feeHistory struct {
+	lock      sync.Mutex
+	dir       string
+	retention time.Duration
+	// last are the fees written last, by scid/direction
+	last map[string]advertisedFee
+}
+
+func newFeeHistory(dir string, retention time.Duration) *feeHistory {
+	return &feeHistory{
+		dir:       dir,
+		retention: retention,
+		last:      make(map[string]advertisedFee),
+	}
+}
+
+// record appends the directions of channels whose fees changed since the last time, at now.
+// It returns the number of lines written.
+func (h *feeHistory) record(channels []*glightning.Channel, now time.Time) (int, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	changed := make(map[string]advertisedFee)
+	var lines strings.Builder
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	for _, c := range channels {
+		key := c.ShortChannelId + "/" + util.GetDirection(c.Source, c.Destination)
+		fee := advertisedFee{baseFee: c.BaseFeeMillisatoshi, feeRate: c.FeePerMillionth}
+		if last, ok := h.last[key]; ok && last == fee {
+			continue
+		}
+		changed[key] = fee
+		lines.WriteString(timestamp + "," + key + "," + strconv.FormatUint(fee.baseFee, 10) + "," +
+			strconv.FormatUint(fee.feeRate, 10) + "\n")
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	if err := os.MkdirAll(h.dir, 0700); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(h.dir+"/"+FEE_HISTORY_FILE, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(lines.String()); err != nil {
+		return 0, err
+	}
+	for key, fee := range changed {
+		h.last[key] = fee
+	}
+	return len(changed), nil
+}
+
+// prune drops the lines older than the retention at now, see pruneBefore
+func (h *feeHistory) prune(now time.Time) (int, error) {
+	return h.pruneBefore(now.Add(-h.retention).Unix())
+}
+
+// pruneBefore drops the lines older than cutoff (unix seconds). The last of them of every direction is still its fee
+// at the cutoff, so it's kept at the cutoff instead, and the fees written last are still the ones in the file.
+// It returns the number of lines dropped.
+func (h *feeHistory) pruneBefore(cutoff int64) (int, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	path := h.dir + "/" + FEE_HISTORY_FILE
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var (
+		kept    strings.Builder
+		expired int
+		dropped int
+		// lastExpired is the last expired line of every direction, in the order they were first seen
+		lastExpired = make(map[string]string)
+		directions  []string
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		entry, _, err := parseFeeEntry(line)
+		if err != nil {
+			dropped++
+			continue
+		}
+		if entry.Timestamp < cutoff {
+			expired++
+			_, rest, _ := strings.Cut(line, ",")
+			direction, _, _ := strings.Cut(rest, ",")
+			if _, ok := lastExpired[direction]; !ok {
+				directions = append(directions, direction)
+			}
+			lastExpired[direction] = rest
+			continue
+		}
+		kept.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	dropped += expired - len(directions)
+	if dropped == 0 {
+		return 0, nil
+	}
+
+	var pruned strings.Builder
+	timestamp := strconv.FormatInt(cutoff, 10)
+	for _, direction := range directions {
+		pruned.WriteString(timestamp + "," + lastExpired[direction] + "\n")
+	}
+	pruned.WriteString(kept.String())
+	if err := os.WriteFile(path+".tmp", []byte(pruned.String()), 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return 0, err
+	}
+	return dropped, nil
+}
+
+// series returns the entries of both directions of scid, oldest first
+func (h *feeHistory) series(scid string) ([]FeeEntry, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	entries := make([]FeeEntry, 0)
+	f, err := os.Open(h.dir + "/" + FEE_HISTORY_FILE)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry, entryScid, err := parseFeeEntry(scanner.Text())
+		if err != nil {
+			continue // a line cut by a crash
+		}
+		if entryScid == scid {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseFeeEntry parses a line of FEE_HISTORY_FILE, returning its entry and its scid
+func parseFeeEntry(line string) (FeeEntry, string, error) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 4 {
+		return FeeEntry{}, "", util.ErrMalformedFeeEntry
+	}
+	scid, direction, ok := strings.Cut(fields[1], "/")
+	if !ok {
+		return FeeEntry{}, "", util.ErrMalformedFeeEntry
+	}
+	timestamp, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return FeeEntry{}, "", util.ErrMalformedFeeEntry
+	}
+	baseFee, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return FeeEntry{}, "", util.ErrMalformedFeeEntry
+	}
+	feeRate, err := strconv.ParseUint(fields[3], 10, 64)
+	if err != nil {
+		return FeeEntry{}, "", util.ErrMalformedFeeEntry
+	}
+	return FeeEntry{Timestamp: timestamp, Direction: direction, BaseFee: baseFee, FeeRate: feeRate}, scid, nil
+}
+
+// recordFeeHistory records the fees of the channels listed by a refresh, if circular-fee-history is enabled.
+// It runs in its own goroutine, so that the refresh doesn't wait for the disk.
+func (n *Node) recordFeeHistory(channels []*glightning.Channel) {
+	if n.feeHistory == nil {
+		return
+	}
+	go func() {
+		written, err := n.feeHistory.record(channels, time.Now())
+		if err != nil {
+			n.Logln(glightning.Unusual, "unable to record the fee history: ", err)
+			return
+		}
+		n.Logln(glightning.Debug, "fee history: ", written, " fee changes recorded")
+	}()
+}
+
+// pruneFeeHistory drops the fee history older than circular-fee-history-retention
+func (n *Node) pruneFeeHistory() {
+	dropped, err := n.feeHistory.prune(time.Now())
+	if err != nil {
+		n.Logln(glightning.Unusual, "unable to prune the fee history: ", err)
+		return
+	}
+	if dropped > 0 {
+		n.Logln(glightning.Info, "fee history: dropped ", dropped, " entries older than ", n.feeHistory.retention)
+	}
+}
+
+type FeeHistoryResult struct {
+	ShortChannelId string     `json:"scid"`
+	Entries        []FeeEntry `json:"entries"`
+}
+
+// FeeHistory exports the fees advertised over time by a channel, see circular-fee-history
+type FeeHistory struct {
+	ShortChannelId string `json:"scid"`
+}
+
+func (f *FeeHistory) Name() string {
+	return "circular-fee-history"
+}
+
+func (f *FeeHistory) New() interface{} {
+	return &FeeHistory{}
+}
+
+func (f *FeeHistory) Call() (jrpc2.Result, error) {
+	if f.ShortChannelId == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+	n := GetNode()
+	if n.feeHistory == nil {
+		return nil, util.ErrFeeHistoryDisabled
+	}
+	entries, err := n.feeHistory.series(f.ShortChannelId)
+	if err != nil {
+		return nil, err
+	}
+	return &FeeHistoryResult{ShortChannelId: f.ShortChannelId, Entries: entries}, nil
+}
@@ -0,0 +1,97 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFeeHistoryRecordsChanges(t *testing.T) {
+	a, b := "02aa", "03bb"
+	channels := []*glightning.Channel{
+		{Source: a, Destination: b, ShortChannelId: "1x1x1", BaseFeeMillisatoshi: 1000, FeePerMillionth: 100},
+		{Source: b, Destination: a, ShortChannelId: "1x1x1", BaseFeeMillisatoshi: 0, FeePerMillionth: 50},
+		{Source: a, Destination: b, ShortChannelId: "2x2x2", BaseFeeMillisatoshi: 0, FeePerMillionth: 10},
+	}
+	h := newFeeHistory(t.TempDir()+"/circular", 24*time.Hour)
+	now := time.Unix(1700000000, 0)
+
+	entries, err := h.series("1x1x1")
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// every direction is written the first time, then only the ones that changed
+	written, err := h.record(channels, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, written)
+	written, err = h.record(channels, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, written)
+	channels[0].FeePerMillionth = 200
+	written, err = h.record(channels, now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, written)
+
+	entries, err = h.series("1x1x1")
+	assert.NoError(t, err)
+	direction := util.GetDirection(a, b)
+	assert.Equal(t, []FeeEntry{
+		{Timestamp: now.Unix(), Direction: direction, BaseFee: 1000, FeeRate: 100},
+		{Timestamp: now.Unix(), Direction: util.GetDirection(b, a), BaseFee: 0, FeeRate: 50},
+		{Timestamp: now.Add(2 * time.Hour).Unix(), Direction: direction, BaseFee: 1000, FeeRate: 200},
+	}, entries)
+
+	data, err := os.ReadFile(h.dir + "/" + FEE_HISTORY_FILE)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "1700000000,2x2x2/"+direction+",0,10\n")
+
+	// the last entry of every direction beyond the retention is still its fee: nothing is dropped
+	dropped, err := h.prune(now.Add(25 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	entries, err = h.series("1x1x1")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	written, err = h.record(channels, now.Add(25*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, written)
+
+	// the older entries are dropped, the last ones are moved to the cutoff, and nothing is written again
+	for hours := 27; hours <= 30; hours++ {
+		dropped, err = h.prune(now.Add(time.Duration(hours) * time.Hour))
+		assert.NoError(t, err)
+		if hours == 27 {
+			assert.Equal(t, 1, dropped)
+		} else {
+			assert.Equal(t, 0, dropped)
+		}
+		written, err = h.record(channels, now.Add(time.Duration(hours)*time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, written)
+	}
+	cutoff := now.Add(3 * time.Hour).Unix()
+	entries, err = h.series("1x1x1")
+	assert.NoError(t, err)
+	assert.Equal(t, []FeeEntry{
+		{Timestamp: cutoff, Direction: direction, BaseFee: 1000, FeeRate: 200},
+		{Timestamp: cutoff, Direction: util.GetDirection(b, a), BaseFee: 0, FeeRate: 50},
+	}, entries)
+	entries, err = h.series("2x2x2")
+	assert.NoError(t, err)
+	assert.Equal(t, []FeeEntry{{Timestamp: cutoff, Direction: direction, BaseFee: 0, FeeRate: 10}}, entries)
+}
+
+func TestParseFeeEntry(t *testing.T) {
+	entry, scid, err := parseFeeEntry("1700000000,812345x1234x0/1,1000,250")
+	assert.NoError(t, err)
+	assert.Equal(t, "812345x1234x0", scid)
+	assert.Equal(t, FeeEntry{Timestamp: 1700000000, Direction: "1", BaseFee: 1000, FeeRate: 250}, entry)
+
+	for _, line := range []string{"", "1700000000,812345x1234x0,1000,250", "1700000000,812345x1234x0/1,1000", "x,1x1x1/0,1,1"} {
+		_, _, err := parseFeeEntry(line)
+		assert.Equal(t, util.ErrMalformedFeeEntry, err, line)
+	}
+}
@@ -0,0 +1,33 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/jrpc2"
+	"time"
+)
+
+const (
+	DEFAULT_FEE_STATS_AMOUNT = 200000 // sats
+)
+
+type FeeStats struct {
+	Amount uint64 `json:"amount,omitempty"`
+}
+
+func (f *FeeStats) Name() string {
+	return "circular-fee-stats"
+}
+
+func (f *FeeStats) New() interface{} {
+	return &FeeStats{}
+}
+
+func (f *FeeStats) Call() (jrpc2.Result, error) {
+	n := GetNode()
+	defer util.TimeTrack(time.Now(), "node.FeeStats", n.Logf)
+
+	if f.Amount == 0 {
+		f.Amount = DEFAULT_FEE_STATS_AMOUNT
+	}
+	return n.Graph.FeeStats(f.Amount * 1000), nil
+}
@@ -4,37 +4,25 @@ import (
 	"circular/graph"
 	"circular/util"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/elementsproject/glightning/glightning"
 	"os"
 	"time"
 )
 
+// LoadGraphFromFile loads the graph from filename in dir or, if it's missing or, in lenient mode, unreadable,
+// from its previous version. In strict mode (circular-strict-graph-load) an unreadable file is an error.
 func (n *Node) LoadGraphFromFile(dir, filename string) error {
 	defer util.TimeTrack(time.Now(), "graph.LoadGraphFromFile", n.Logf)
-	file, err := os.Open(dir + "/" + filename)
-	if err != nil {
-		n.Logln(glightning.Debug, "unable to load graph data:", err, ", looking for an old file")
-		n.Logln(glightning.Debug, "trying to load an old version of the graph")
-		filename += ".old"
-		file, err = os.Open(dir + "/" + filename)
-		if err != nil {
-			n.Logln(glightning.Debug, "unable to load any old version of the graph: ", err, ", continuing with a new graph")
-			return util.ErrNoGraphToLoad
-		}
+	g, loaded, skipped, err := readGraphFile(dir, filename, n.strictGraphLoad)
+	for _, skippedErr := range skipped {
+		n.Logln(glightning.Unusual, skippedErr, ", skipping it")
 	}
-	defer file.Close()
-	n.Logln(glightning.Debug, "loading graph data from file:", dir+"/"+filename)
-
-	g := graph.NewGraph()
-
-	err = json.NewDecoder(file).Decode(g)
 	if err != nil {
 		return err
 	}
-
-	for _, c := range g.Channels {
-		g.AddChannel(c)
-	}
+	n.Logln(glightning.Debug, "loaded graph data from file:", loaded)
 
 	n.Graph = g
 
@@ -42,47 +30,85 @@ func (n *Node) LoadGraphFromFile(dir, filename string) error {
 	return nil
 }
 
-func (n *Node) SaveGraphToFile(dir, filename string) error {
-	defer util.TimeTrack(time.Now(), "graph.SaveGraphToFile", n.Logf)
-
-	// check if dir exists, otherwise create it
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		if err := os.Mkdir(dir, 0755); err != nil {
-			return err
+// readGraphFile reads the graph from filename in dir, or from its ".old" version. It returns the graph, the file it
+// was read from and, in lenient mode, the errors of the files that couldn't be read and were skipped, and of the
+// malformed channels that were left out of the graph.
+// In strict mode the first file that exists but can't be read, or that has a malformed channel, is an error.
+func readGraphFile(dir, filename string, strict bool) (*graph.Graph, string, []error, error) {
+	skipped := make([]error, 0)
+	for _, path := range []string{dir + "/" + filename, dir + "/" + filename + ".old"} {
+		g, malformed, err := decodeGraphFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil && strict && len(malformed) > 0 {
+			err = fmt.Errorf("%d malformed channels, the first is %w", len(malformed), malformed[0])
 		}
+		if err != nil {
+			err = fmt.Errorf("%w %s: %v", util.ErrUnreadableGraph, path, err)
+			if strict {
+				return nil, "", skipped, err
+			}
+			skipped = append(skipped, err)
+			continue
+		}
+		return g, path, append(skipped, malformed...), nil
 	}
+	return nil, "", skipped, util.ErrNoGraphToLoad
+}
 
-	filename = dir + "/" + filename
-	if err := n.serializeToFile(filename); err != nil {
-		return err
+// decodeGraphFile reads the graph saved at path, without the malformed channels, and tells why they are malformed
+func decodeGraphFile(path string) (*graph.Graph, []error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer file.Close()
 
-	// save old file
-	// check if filename exists
-	if _, err := os.Stat(filename); err == nil {
-		err = os.Rename(filename, filename+".old")
+	g := graph.NewGraph()
+	if err := json.NewDecoder(file).Decode(g); err != nil {
+		return nil, nil, err
 	}
-	// rename tmp to filename
-	if err := os.Rename(filename+".tmp", filename); err != nil {
-		return err
+	malformed := g.RemoveMalformed()
+	for _, c := range g.Channels {
+		g.AddChannel(c)
 	}
-
-	return nil
+	return g, malformed, nil
 }
 
-func (n *Node) serializeToFile(filename string) error {
-	// open temporary file
-	file, err := os.Create(filename + ".tmp")
+func (n *Node) SaveGraphToFile(dir, filename string) error {
+	defer util.TimeTrack(time.Now(), "graph.SaveGraphToFile", n.Logf)
+
+	data, err := n.marshalGraph()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	return replaceGraphFile(dir, filename, data, true)
+}
 
-	// write json
+// marshalGraph encodes the graph as it's saved to file
+func (n *Node) marshalGraph() ([]byte, error) {
 	n.Graph.Lock()
 	defer n.Graph.Unlock()
-	if err := json.NewEncoder(file).Encode(n.Graph); err != nil {
+	return json.Marshal(n.Graph)
+}
+
+// replaceGraphFile writes data as filename in dir, through a temporary file. The file it replaces is kept
+// as its ".old" version if keepPrevious is set, and removed otherwise.
+func replaceGraphFile(dir, filename string, data []byte, keepPrevious bool) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-	return nil
+	path := dir + "/" + filename
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if keepPrevious {
+		if _, err := os.Stat(path); err == nil {
+			if err := os.Rename(path, path+".old"); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(path+".tmp", path)
 }
@@ -0,0 +1,81 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeGraphFile(t *testing.T, path, content string) {
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestReadGraphFileMissing(t *testing.T) {
+	dir := t.TempDir()
+	for _, strict := range []bool{false, true} {
+		_, _, skipped, err := readGraphFile(dir, graph.FILE, strict)
+		assert.Equal(t, util.ErrNoGraphToLoad, err)
+		assert.Empty(t, skipped)
+	}
+}
+
+func TestReadGraphFileLenient(t *testing.T) {
+	dir := t.TempDir()
+	writeGraphFile(t, dir+"/"+graph.FILE, `{"channels": {`)
+
+	// the corrupt file is skipped and reported, and there is nothing else to load
+	_, _, skipped, err := readGraphFile(dir, graph.FILE, false)
+	assert.Equal(t, util.ErrNoGraphToLoad, err)
+	assert.Len(t, skipped, 1)
+	assert.True(t, errors.Is(skipped[0], util.ErrUnreadableGraph))
+	assert.Contains(t, skipped[0].Error(), dir+"/"+graph.FILE)
+
+	// the previous version is used instead
+	writeGraphFile(t, dir+"/"+graph.FILE+".old", `{}`)
+	g, loaded, skipped, err := readGraphFile(dir, graph.FILE, false)
+	assert.NoError(t, err)
+	assert.NotNil(t, g)
+	assert.Equal(t, dir+"/"+graph.FILE+".old", loaded)
+	assert.Len(t, skipped, 1)
+}
+
+func TestReadGraphFileStrict(t *testing.T) {
+	dir := t.TempDir()
+	writeGraphFile(t, dir+"/"+graph.FILE, `{"channels": {`)
+	writeGraphFile(t, dir+"/"+graph.FILE+".old", `{}`)
+
+	// the corrupt file is an error, even if the previous version could be loaded
+	_, _, _, err := readGraphFile(dir, graph.FILE, true)
+	assert.True(t, errors.Is(err, util.ErrUnreadableGraph))
+	assert.Contains(t, err.Error(), dir+"/"+graph.FILE)
+
+	writeGraphFile(t, dir+"/"+graph.FILE, `{}`)
+	_, loaded, _, err := readGraphFile(dir, graph.FILE, true)
+	assert.NoError(t, err)
+	assert.Equal(t, dir+"/"+graph.FILE, loaded)
+}
+
+func TestReadGraphFileMalformedChannel(t *testing.T) {
+	dir := t.TempDir()
+	a := "02" + strings.Repeat("aa", 32)
+	writeGraphFile(t, dir+"/"+graph.FILE, `{"channels": {
+		"1x1x1/1": {"channel": {"source": "`+a+`", "destination": "02bb", "short_channel_id": "1x1x1"}}
+	}}`)
+
+	// in lenient mode the channel is left out and reported
+	g, _, skipped, err := readGraphFile(dir, graph.FILE, false)
+	assert.NoError(t, err)
+	assert.Empty(t, g.Channels)
+	assert.Empty(t, g.Inbound)
+	assert.Len(t, skipped, 1)
+	assert.True(t, errors.Is(skipped[0], util.ErrMalformedChannel))
+
+	// in strict mode the file is refused
+	_, _, _, err = readGraphFile(dir, graph.FILE, true)
+	assert.True(t, errors.Is(err, util.ErrUnreadableGraph))
+	assert.Contains(t, err.Error(), "1x1x1")
+}
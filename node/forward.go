@@ -0,0 +1,40 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+)
+
+const (
+	FORWARD_SETTLED = "settled"
+)
+
+// OnForward learns from the forwards settled through our channels: the amount received left the incoming
+// channel in the direction towards us, and the amount sent left the outgoing channel in the direction from us
+func (n *Node) OnForward(f *glightning.Forwarding) {
+	if lowered := n.learnFromForward(f); lowered > 0 {
+		n.Logln(glightning.Debug, "forward from ", f.InChannel, " to ", f.OutChannel,
+			" lowered the liquidity of ", lowered, " channels")
+	}
+}
+
+// learnFromForward lowers the liquidity of the channels of a settled forward and returns how many changed
+func (n *Node) learnFromForward(f *glightning.Forwarding) int {
+	if f.Status != FORWARD_SETTLED {
+		return 0
+	}
+	lowered := 0
+	if peer, err := n.GetChannelPeerFromScid(f.InChannel); err == nil {
+		channelId := f.InChannel + "/" + util.GetDirection(peer.Id, n.Id)
+		if n.Graph.ConsumeLiquidity(channelId, msat(f.MilliSatoshiIn, f.InMsat)) {
+			lowered++
+		}
+	}
+	if peer, err := n.GetChannelPeerFromScid(f.OutChannel); err == nil {
+		channelId := f.OutChannel + "/" + util.GetDirection(n.Id, peer.Id)
+		if n.Graph.ConsumeLiquidity(channelId, msat(f.MilliSatoshiOut, f.OutMsat)) {
+			lowered++
+		}
+	}
+	return lowered
+}
@@ -0,0 +1,61 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestForwardLowersLiquidity(t *testing.T) {
+	us, alice, bob := "02aa", "02bb", "02cc"
+	g := graph.NewGraph()
+	for _, c := range []*glightning.Channel{
+		{Source: alice, Destination: us, ShortChannelId: "1x1x1", Satoshis: 1000000},
+		{Source: us, Destination: bob, ShortChannelId: "2x2x2", Satoshis: 1000000},
+	} {
+		channel := graph.NewChannel(c, 100000000, 0)
+		g.Channels[c.ShortChannelId+"/"+util.GetDirection(c.Source, c.Destination)] = channel
+		g.AddChannel(channel)
+	}
+	n := &Node{
+		Id:        us,
+		Graph:     g,
+		PeersLock: &sync.RWMutex{},
+		Peers: map[string]*glightning.Peer{
+			alice: {Id: alice, Channels: []*glightning.PeerChannel{{ShortChannelId: "1x1x1"}}},
+			bob:   {Id: bob, Channels: []*glightning.PeerChannel{{ShortChannelId: "2x2x2"}}},
+		},
+	}
+	inId := "1x1x1/" + util.GetDirection(alice, us)
+	outId := "2x2x2/" + util.GetDirection(us, bob)
+
+	forward := &glightning.Forwarding{
+		InChannel:  "1x1x1",
+		OutChannel: "2x2x2",
+		InMsat:     "30001000msat",
+		OutMsat:    "30000000msat",
+		Status:     "failed",
+	}
+	// only settled forwards moved the liquidity
+	assert.Equal(t, 0, n.learnFromForward(forward))
+
+	// the amounts left both directions, towards us and from us
+	forward.Status = FORWARD_SETTLED
+	assert.Equal(t, 2, n.learnFromForward(forward))
+	assert.Equal(t, uint64(69999000), g.Channels[inId].Liquidity)
+	assert.Equal(t, uint64(70000000), g.Channels[outId].Liquidity)
+
+	// a forward bigger than the belief proves it was at least that much: nothing is left
+	forward.InMsat, forward.OutMsat = "200001000msat", "200000000msat"
+	assert.Equal(t, 2, n.learnFromForward(forward))
+	assert.Equal(t, uint64(0), g.Channels[outId].Liquidity)
+
+	// a liquidity known exactly is left to the next refresh of the peers
+	g.SetLiquidity(inId, 500000000)
+	assert.Equal(t, 0, n.learnFromForward(forward))
+	assert.Equal(t, uint64(500000000), g.Channels[inId].Liquidity)
+	assert.Equal(t, graph.CONFIDENCE_EXACT, g.Channels[inId].Confidence)
+}
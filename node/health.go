@@ -0,0 +1,125 @@
+package node
+
+import (
+	"github.com/elementsproject/glightning/jrpc2"
+	"strconv"
+	"time"
+)
+
+const (
+	DEFAULT_GRAPH_STALE_THRESHOLD = 60 // minutes
+	HEALTH_OK                     = "ok"
+	HEALTH_STALE                  = "stale"
+	// HEALTH_NO_SELF is the status of a graph that is fresh but knows no channel of ours, see CheckSelfInGraph
+	HEALTH_NO_SELF = "no-self"
+)
+
+type GraphHealth struct {
+	Status string `json:"status"`
+	// LastRefresh and Age are left out until the first successful refresh
+	LastRefresh         int64  `json:"last_successful_refresh,omitempty"`
+	Age                 int64  `json:"age_seconds,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastError           string `json:"last_error,omitempty"`
+	// SelfInGraph tells whether the graph knows a channel of ours, without which no route can be found
+	SelfInGraph bool `json:"self_in_graph"`
+}
+
+func (h *GraphHealth) Name() string {
+	return "circular-health"
+}
+
+func (h *GraphHealth) New() interface{} {
+	return &GraphHealth{}
+}
+
+func (h *GraphHealth) Call() (jrpc2.Result, error) {
+	return GetNode().GetGraphHealth(), nil
+}
+
+// updateGraphHealth records the outcome of a graph refresh
+func (n *Node) updateGraphHealth(err error) {
+	n.healthLock.Lock()
+	defer n.healthLock.Unlock()
+
+	if err != nil {
+		n.refreshFailures++
+		n.lastRefreshError = err
+		return
+	}
+	n.refreshFailures = 0
+	n.lastRefreshError = nil
+	n.lastGraphRefresh = time.Now()
+}
+
+func (n *Node) GetGraphHealth() *GraphHealth {
+	n.healthLock.RLock()
+	defer n.healthLock.RUnlock()
+
+	health := &GraphHealth{
+		Status:              HEALTH_OK,
+		ConsecutiveFailures: n.refreshFailures,
+	}
+	if !n.lastGraphRefresh.IsZero() {
+		health.LastRefresh = n.lastGraphRefresh.Unix()
+		health.Age = int64(time.Since(n.lastGraphRefresh).Seconds())
+	}
+	if n.lastRefreshError != nil {
+		health.LastError = n.lastRefreshError.Error()
+	}
+	health.SelfInGraph = n.CheckSelfInGraph() == nil
+	if n.isGraphStale() {
+		health.Status = HEALTH_STALE
+	} else if !health.SelfInGraph {
+		health.Status = HEALTH_NO_SELF
+	}
+	return health
+}
+
+// graphAge is the time since the last successful graph refresh
+func (n *Node) graphAge() time.Duration {
+	n.healthLock.RLock()
+	defer n.healthLock.RUnlock()
+	return time.Since(n.lastGraphRefresh)
+}
+
+// GraphAge is the time since the last successful graph refresh, 0 before the first one,
+// and whether it makes the graph stale
+func (n *Node) GraphAge() (time.Duration, bool) {
+	n.healthLock.RLock()
+	defer n.healthLock.RUnlock()
+	if n.lastGraphRefresh.IsZero() {
+		return 0, false
+	}
+	return time.Since(n.lastGraphRefresh), n.isGraphStale()
+}
+
+// IsGraphStale returns true if the graph has not been refreshed successfully
+// for longer than the configured threshold. A graph never refreshed since the start,
+// e.g. loaded from file, is not stale: the first refresh is on its way.
+func (n *Node) IsGraphStale() bool {
+	n.healthLock.RLock()
+	defer n.healthLock.RUnlock()
+	return n.isGraphStale()
+}
+
+func (n *Node) isGraphStale() bool {
+	return !n.lastGraphRefresh.IsZero() && time.Since(n.lastGraphRefresh) > n.graphStaleThreshold
+}
+
+func (h *GraphHealth) String() string {
+	result := "Graph health: " + h.Status + "\n"
+	if h.LastRefresh == 0 {
+		result += "last successful refresh: never\n"
+	} else {
+		result += "last successful refresh: " + time.Unix(h.LastRefresh, 0).String() + "\n"
+	}
+	result += "consecutive failures: " + strconv.Itoa(h.ConsecutiveFailures)
+	if !h.SelfInGraph {
+		result += "\nno channel of ours in the graph yet"
+	}
+	if h.LastError != "" {
+		result += "\nlast error: " + h.LastError
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGraphWithoutSelf(t *testing.T) {
+	us, alice, bob := "02aa", "02bb", "02cc"
+	g := graph.NewGraph()
+	// the gossip knows other channels, but not ours yet
+	c := &glightning.Channel{Source: alice, Destination: bob, ShortChannelId: "2x2x2", Satoshis: 1000000}
+	g.Channels["2x2x2/"+util.GetDirection(alice, bob)] = graph.NewChannel(c, 500000000, 0)
+	g.AddChannel(g.Channels["2x2x2/"+util.GetDirection(alice, bob)])
+	n := &Node{
+		Id:                  us,
+		Graph:               g,
+		PeersLock:           &sync.RWMutex{},
+		Peers:               map[string]*glightning.Peer{alice: {Id: alice, Channels: []*glightning.PeerChannel{{ShortChannelId: "1x1x1"}}}},
+		healthLock:          &sync.RWMutex{},
+		lastGraphRefresh:    time.Now(),
+		graphStaleThreshold: time.Hour,
+	}
+
+	assert.Equal(t, util.ErrSelfNotInGraph, n.CheckSelfInGraph())
+	_, err := n.GetOutgoingChannelFromScid("1x1x1")
+	assert.Equal(t, util.ErrSelfNotInGraph, err)
+	_, err = n.GetIncomingChannelFromScid("1x1x1")
+	assert.Equal(t, util.ErrSelfNotInGraph, err)
+	health := n.GetGraphHealth()
+	assert.False(t, health.SelfInGraph)
+	assert.Equal(t, HEALTH_NO_SELF, health.Status)
+
+	// once one of our channels is known, a missing channel is just missing
+	c = &glightning.Channel{Source: alice, Destination: us, ShortChannelId: "3x3x3", Satoshis: 1000000}
+	g.AddChannel(graph.NewChannel(c, 500000000, 0))
+	assert.NoError(t, n.CheckSelfInGraph())
+	_, err = n.GetOutgoingChannelFromScid("1x1x1")
+	assert.Equal(t, util.ErrNoOutgoingChannel, err)
+	health = n.GetGraphHealth()
+	assert.True(t, health.SelfInGraph)
+	assert.Equal(t, HEALTH_OK, health.Status)
+}
+
+func TestGraphStaleness(t *testing.T) {
+	n := &Node{
+		Graph:               graph.NewGraph(),
+		PeersLock:           &sync.RWMutex{},
+		healthLock:          &sync.RWMutex{},
+		graphStaleThreshold: time.Hour,
+	}
+
+	// never refreshed, e.g. loaded from file: no refresh to report, and not stale
+	health := n.GetGraphHealth()
+	assert.False(t, n.IsGraphStale())
+	assert.NotEqual(t, HEALTH_STALE, health.Status)
+	assert.Equal(t, int64(0), health.LastRefresh)
+	assert.Equal(t, int64(0), health.Age)
+	assert.Contains(t, health.String(), "last successful refresh: never")
+	age, stale := n.GraphAge()
+	assert.Equal(t, time.Duration(0), age)
+	assert.False(t, stale)
+
+	n.lastGraphRefresh = time.Now().Add(-2 * time.Hour)
+	health = n.GetGraphHealth()
+	assert.True(t, n.IsGraphStale())
+	assert.Equal(t, HEALTH_STALE, health.Status)
+	assert.Equal(t, n.lastGraphRefresh.Unix(), health.LastRefresh)
+	assert.GreaterOrEqual(t, health.Age, int64(7200))
+
+	// a failed refresh doesn't make it fresh, a successful one does
+	n.updateGraphHealth(util.ErrNoGraphToLoad)
+	assert.True(t, n.IsGraphStale())
+	assert.Equal(t, 1, n.GetGraphHealth().ConsecutiveFailures)
+	n.updateGraphHealth(nil)
+	assert.False(t, n.IsGraphStale())
+	assert.Equal(t, 0, n.GetGraphHealth().ConsecutiveFailures)
+}
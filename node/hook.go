@@ -0,0 +1,25 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"fmt"
+)
+
+// RouteHook inspects a route right before it is sent, and rejects it by returning false with a reason.
+// It's called by the rebalances after the route has passed every check of circular: maxppm, the maximum
+// route length, the htlc bounds and, if enabled, the stability check. It only sees routes that would
+// otherwise be sent, and it must not modify them.
+// It can be called concurrently by parallel rebalances.
+type RouteHook func(route *graph.Route) (accept bool, reason string)
+
+// CheckRoute runs the RouteHook on route, if one is set
+func (n *Node) CheckRoute(route *graph.Route) error {
+	if n.RouteHook == nil {
+		return nil
+	}
+	if accept, reason := n.RouteHook(route); !accept {
+		return fmt.Errorf("%w: %s", util.ErrRouteRejected, reason)
+	}
+	return nil
+}
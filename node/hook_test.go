@@ -0,0 +1,24 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckRoute(t *testing.T) {
+	n := &Node{}
+	route := graph.NewRoute("a", "b", 1000000, []graph.RouteHop{}, graph.NewGraph())
+	assert.NoError(t, n.CheckRoute(route))
+
+	n.RouteHook = func(r *graph.Route) (bool, string) {
+		return r.Amount < 1000000, "amount above policy"
+	}
+	err := n.CheckRoute(route)
+	assert.ErrorIs(t, err, util.ErrRouteRejected)
+	assert.Contains(t, err.Error(), "amount above policy")
+
+	route.Amount = 999000
+	assert.NoError(t, n.CheckRoute(route))
+}
@@ -0,0 +1,44 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+)
+
+// gossipChannel is a channel of listchannels, with the inbound fee if lightningd reports it
+type gossipChannel struct {
+	glightning.Channel
+	InboundBaseMsat        *int64 `json:"inbound_fee_base_msat,omitempty"`
+	InboundFeePerMillionth *int64 `json:"inbound_fee_proportional_millionths,omitempty"`
+}
+
+// listChannelsWithInboundFees is like ListChannels, but it also returns the inbound fees by channel id.
+// A node advertises the inbound fee in the update of its own direction of the channel, but the fee
+// applies to the htlcs coming from its peer, so it's stored on the opposite direction.
+func (n *Node) listChannelsWithInboundFees() ([]*glightning.Channel, map[string]*graph.InboundFee, error) {
+	var result struct {
+		Channels []*gossipChannel `json:"channels"`
+	}
+	if err := n.lightning.Request(&glightning.ListChannelRequest{}, &result); err != nil {
+		return nil, nil, err
+	}
+
+	channels := make([]*glightning.Channel, 0, len(result.Channels))
+	fees := make(map[string]*graph.InboundFee)
+	for _, c := range result.Channels {
+		channels = append(channels, &c.Channel)
+		if c.InboundBaseMsat == nil && c.InboundFeePerMillionth == nil {
+			continue
+		}
+		fee := &graph.InboundFee{}
+		if c.InboundBaseMsat != nil {
+			fee.BaseMsat = *c.InboundBaseMsat
+		}
+		if c.InboundFeePerMillionth != nil {
+			fee.FeePerMillionth = *c.InboundFeePerMillionth
+		}
+		fees[c.ShortChannelId+"/"+util.GetDirection(c.Destination, c.Source)] = fee
+	}
+	return channels, fees, nil
+}
@@ -0,0 +1,94 @@
+package node
+
+import (
+	"github.com/elementsproject/glightning/glightning"
+	"sort"
+	"time"
+)
+
+const (
+	STUCK_HTLC_THRESHOLD = 10 * time.Minute
+	SENDPAY_PENDING      = "pending"
+	SENDPAY_COMPLETE     = "complete"
+	SENDPAY_FAILED       = "failed"
+)
+
+type StuckHtlc struct {
+	PaymentHash string `json:"payment_hash"`
+	Since       int64  `json:"since"`
+	Age         int64  `json:"age_seconds"`
+}
+
+// GetStuckHtlcs returns the payments initiated by circular that have been
+// in flight for longer than STUCK_HTLC_THRESHOLD, oldest first
+func (n *Node) GetStuckHtlcs() []StuckHtlc {
+	n.hashesLock.Lock()
+	defer n.hashesLock.Unlock()
+
+	result := make([]StuckHtlc, 0)
+	for hash, since := range n.inFlightHashes {
+		if time.Since(since) > STUCK_HTLC_THRESHOLD {
+			result = append(result, StuckHtlc{
+				PaymentHash: hash,
+				Since:       since.Unix(),
+				Age:         int64(time.Since(since).Seconds()),
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Since < result[j].Since
+	})
+	return result
+}
+
+// reconcilePayments restores the in-flight state after a restart.
+// Payments that are still pending are tracked again, while payments that
+// were resolved while we were offline are cleaned up from the database.
+func (n *Node) reconcilePayments() {
+	hashes, err := n.DB.ListPaymentHashes()
+	if err != nil {
+		n.Logln(glightning.Unusual, "unable to list payment hashes: ", err)
+		return
+	}
+
+	for _, hash := range hashes {
+		payments, err := n.lightning.ListSendPaysByHash(hash)
+		if err != nil {
+			n.Logln(glightning.Unusual, "unable to list sendpays for ", hash, ": ", err)
+			continue
+		}
+
+		if pendingSince, ok := getPendingSince(payments); ok {
+			n.Logln(glightning.Info, "payment ", hash, " is still in flight, tracking it again")
+			n.hashesLock.Lock()
+			n.inFlightHashes[hash] = pendingSince
+			n.hashesLock.Unlock()
+			continue
+		}
+
+		n.Logln(glightning.Debug, "payment ", hash, " has been resolved while offline, cleaning up")
+		if err := n.DB.Delete(hash); err != nil {
+			n.Logln(glightning.Unusual, err)
+		}
+		if err := n.DB.Delete(TIMEOUT_PREFIX + hash); err != nil {
+			n.Logln(glightning.Unusual, err)
+		}
+	}
+}
+
+// getPendingSince returns the creation time of the oldest pending part of a payment
+func getPendingSince(payments []glightning.SendPayFields) (time.Time, bool) {
+	var since time.Time
+	pending := false
+	for _, payment := range payments {
+		if payment.Status != SENDPAY_PENDING {
+			continue
+		}
+		createdAt := time.Unix(int64(payment.CreatedAt), 0)
+		if !pending || createdAt.Before(since) {
+			since = createdAt
+		}
+		pending = true
+	}
+	return since, pending
+}
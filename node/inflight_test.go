@@ -0,0 +1,22 @@
+package node
+
+import (
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetPendingSince(t *testing.T) {
+	_, pending := getPendingSince([]glightning.SendPayFields{
+		{Status: SENDPAY_COMPLETE, CreatedAt: 100},
+	})
+	assert.False(t, pending)
+
+	since, pending := getPendingSince([]glightning.SendPayFields{
+		{Status: "failed", CreatedAt: 50},
+		{Status: SENDPAY_PENDING, CreatedAt: 300},
+		{Status: SENDPAY_PENDING, CreatedAt: 200},
+	})
+	assert.True(t, pending)
+	assert.Equal(t, int64(200), since.Unix())
+}
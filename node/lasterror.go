@@ -0,0 +1,130 @@
+package node
+
+import (
+	"circular/util"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"sync"
+	"time"
+)
+
+const (
+	LAST_ERRORS_SIZE = 10 // per category
+
+	ERROR_ROUTE_NOT_FOUND = "route-not-found"
+	ERROR_TOO_EXPENSIVE   = "too-expensive"
+	ERROR_SENDPAY_FAILURE = "sendpay-failure"
+	ERROR_TIMEOUT         = "timeout"
+	ERROR_STALLED         = "stalled"
+)
+
+// ErrorEntry is a failure of a command, with the node and channel that caused it when known
+type ErrorEntry struct {
+	Time          time.Time `json:"time"`
+	Message       string    `json:"message"`
+	Command       string    `json:"command,omitempty"`
+	Params        any       `json:"params,omitempty"`
+	ErringNode    string    `json:"erring_node,omitempty"`
+	ErringChannel string    `json:"erring_channel,omitempty"`
+	FailCode      string    `json:"failcode,omitempty"`
+}
+
+// errorLog keeps the last LAST_ERRORS_SIZE errors of every category
+type errorLog struct {
+	lock    *sync.Mutex
+	entries map[string][]*ErrorEntry
+}
+
+func newErrorLog() *errorLog {
+	return &errorLog{
+		lock:    &sync.Mutex{},
+		entries: make(map[string][]*ErrorEntry),
+	}
+}
+
+func (l *errorLog) add(category string, entry *ErrorEntry) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries := append(l.entries[category], entry)
+	if len(entries) > LAST_ERRORS_SIZE {
+		entries = entries[len(entries)-LAST_ERRORS_SIZE:]
+	}
+	l.entries[category] = entries
+}
+
+// get returns the errors of category, or of every category if it's empty, newest first
+func (l *errorLog) get(category string) map[string][]*ErrorEntry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	result := make(map[string][]*ErrorEntry)
+	for c, entries := range l.entries {
+		if category != "" && c != category {
+			continue
+		}
+		reversed := make([]*ErrorEntry, len(entries))
+		for i, entry := range entries {
+			reversed[len(entries)-1-i] = entry
+		}
+		result[c] = reversed
+	}
+	return result
+}
+
+// RecordError remembers that command, called with params, failed with err
+func (n *Node) RecordError(category, command string, params any, err error) {
+	n.lastErrors.add(category, &ErrorEntry{
+		Time:    time.Now(),
+		Message: err.Error(),
+		Command: command,
+		Params:  params,
+	})
+}
+
+// RecordPaymentError remembers a failure returned by SendPay, attributing it to the
+// node and channel that reported it when lightningd tells us
+func (n *Node) RecordPaymentError(command string, params any, err error) {
+	category := ERROR_SENDPAY_FAILURE
+	switch err {
+	case util.ErrSendPayTimeout:
+		category = ERROR_TIMEOUT
+	case util.ErrSendPayStalled:
+		category = ERROR_STALLED
+	}
+	entry := &ErrorEntry{
+		Time:    time.Now(),
+		Message: err.Error(),
+		Command: command,
+		Params:  params,
+	}
+	var paymentError *glightning.PaymentError
+	if errors.As(err, &paymentError) && paymentError.Data != nil {
+		entry.ErringNode = paymentError.Data.ErringNode
+		entry.ErringChannel = paymentError.Data.ErringChannel
+		entry.FailCode = paymentError.Data.FailCodeName
+	}
+	n.lastErrors.add(category, entry)
+}
+
+type LastError struct {
+	Category string `json:"category,omitempty"`
+}
+
+func (l *LastError) Name() string {
+	return "circular-last-error"
+}
+
+func (l *LastError) New() interface{} {
+	return &LastError{}
+}
+
+func (l *LastError) Call() (jrpc2.Result, error) {
+	switch l.Category {
+	case "", ERROR_ROUTE_NOT_FOUND, ERROR_TOO_EXPENSIVE, ERROR_SENDPAY_FAILURE, ERROR_TIMEOUT, ERROR_STALLED:
+	default:
+		return nil, util.ErrInvalidErrorCategory
+	}
+	return GetNode().lastErrors.get(l.Category), nil
+}
@@ -0,0 +1,40 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestErrorLogIsBounded(t *testing.T) {
+	l := newErrorLog()
+	for i := 0; i < LAST_ERRORS_SIZE+5; i++ {
+		l.add(ERROR_ROUTE_NOT_FOUND, &ErrorEntry{Message: strconv.Itoa(i)})
+	}
+	l.add(ERROR_TIMEOUT, &ErrorEntry{Message: "timeout"})
+
+	entries := l.get("")
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, LAST_ERRORS_SIZE, len(entries[ERROR_ROUTE_NOT_FOUND]))
+	// newest first, the oldest ones were dropped
+	assert.Equal(t, strconv.Itoa(LAST_ERRORS_SIZE+4), entries[ERROR_ROUTE_NOT_FOUND][0].Message)
+	assert.Equal(t, "5", entries[ERROR_ROUTE_NOT_FOUND][LAST_ERRORS_SIZE-1].Message)
+
+	entries = l.get(ERROR_TIMEOUT)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "timeout", entries[ERROR_TIMEOUT][0].Message)
+}
+
+func TestRecordPaymentErrorCategories(t *testing.T) {
+	n := &Node{lastErrors: newErrorLog()}
+	n.RecordPaymentError("circular", nil, util.ErrSendPayTimeout)
+	n.RecordPaymentError("circular", nil, util.ErrSendPayStalled)
+	n.RecordPaymentError("circular", nil, util.ErrFirstHopFailure)
+
+	entries := n.lastErrors.get("")
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, util.ErrSendPayTimeout.Error(), entries[ERROR_TIMEOUT][0].Message)
+	assert.Equal(t, util.ErrSendPayStalled.Error(), entries[ERROR_STALLED][0].Message)
+	assert.Equal(t, util.ErrFirstHopFailure.Error(), entries[ERROR_SENDPAY_FAILURE][0].Message)
+}
@@ -0,0 +1,105 @@
+package node
+
+import (
+	"encoding/json"
+	"github.com/elementsproject/glightning/glightning"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const MOCK_RPC_FILE = "lightning-rpc"
+
+// rpcHandler answers a call of the mock lightningd with a result, or with an error
+type rpcHandler func(params json.RawMessage) (interface{}, error)
+
+type rpcRequest struct {
+	Id     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// newMockNode returns a node talking to a mock lightningd that answers with the given handlers.
+// The methods without a handler fail, and the logs of the node are discarded.
+func newMockNode(t *testing.T, handlers map[string]rpcHandler) *Node {
+	dir, err := os.MkdirTemp("", "circular")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	listener, err := net.Listen("unix", filepath.Join(dir, MOCK_RPC_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go serveMockLightning(listener, handlers)
+
+	lightning := glightning.NewLightning()
+	if err := lightning.StartUp(MOCK_RPC_FILE, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// the plugin is only used to log: its input is never written and its output is discarded
+	in, inWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		inWriter.Close()
+		out.Close()
+	})
+	plugin := glightning.NewPlugin(nil)
+	go plugin.Start(in, out)
+
+	n := &Node{}
+	n.lightning = lightning
+	n.plugin = plugin
+	return n
+}
+
+func serveMockLightning(listener net.Listener, handlers map[string]rpcHandler) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var request rpcRequest
+		if err := decoder.Decode(&request); err != nil {
+			return
+		}
+		response := rpcResponse{Version: "2.0", Id: request.Id}
+		handler, ok := handlers[request.Method]
+		if !ok {
+			response.Error = &rpcError{Code: -32601, Message: "unknown command " + request.Method}
+		} else if result, err := handler(request.Params); err != nil {
+			response.Error = &rpcError{Code: -1, Message: err.Error()}
+		} else {
+			response.Result = result
+		}
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
@@ -13,9 +13,13 @@ import (
 
 const (
 	CIRCULAR_DIR                     = "circular"
-	DEFAULT_PEER_REFRESH_INTERVAL    = 30  // seconds
-	DEFAULT_LIQUIDITY_RESET_INTERVAL = 300 // minutes
-	DEFAULT_RPC_TIMEOUT              = 60  // seconds
+	DEFAULT_PEER_REFRESH_INTERVAL    = 30   // seconds
+	DEFAULT_LIQUIDITY_RESET_INTERVAL = 300  // minutes
+	DEFAULT_RPC_TIMEOUT              = 60   // seconds
+	DEFAULT_MIN_AMOUNT               = 1000 // sats
+	DEFAULT_SAVE_INTERVAL            = 10   // minutes
+	DEFAULT_RETRY_JITTER             = 20   // percent
+	DEFAULT_CHUNK_MAX_OVERLAP        = 100  // percent
 )
 
 var (
@@ -27,15 +31,104 @@ type Node struct {
 	lightning           *glightning.Lightning
 	plugin              *glightning.Plugin
 	liquidityRefresh    time.Duration
+	agingDisabled       bool
+	agingLock           *sync.RWMutex
 	initLock            *sync.Mutex
+	graphRefreshLock    *sync.Mutex
+	aliasRefreshLock    *sync.Mutex
+	peersRefreshLock    *sync.Mutex
 	saveStats           bool
+	savePreimages       bool
+	strictGraphLoad     bool
+	reuseChannels       bool
+	checkGraph          bool
+	persistAliases      bool
+	warmUpSearch        bool
+	favoritePeers       []string
+	favoritesRefresh    time.Duration
+	savedAliasesVersion uint64
+	healthLock          *sync.RWMutex
+	graphStaleThreshold time.Duration
+	graphMaxAge         time.Duration
+	saveInterval        time.Duration
+	saveLock            *sync.Mutex
+	savedGraphVersion   uint64
+	maxChannels         int
+	minFinalCltv        uint
+	localBalanceSource  string
+	excludeDeadNodes    bool
+	deadNodesLock       *sync.RWMutex
+	deadNodes           map[string]bool
+	lastGraphRefresh    time.Time
+	refreshFailures     int
+	lastRefreshError    error
+	hashesLock          *sync.Mutex
+	inFlightHashes      map[string]time.Time
+	inFlightRoutes      map[string][]string
+	lastErrors          *errorLog
+	PreimageGenerator   PreimageGenerator
+	RouteHook           RouteHook
 	PeersLock           *sync.RWMutex
 	Id                  string
 	Peers               map[string]*glightning.Peer
 	Graph               *graph.Graph
+	RouteOptions        *graph.RouteOptions
+	MaxAlternateOuts    int
+	ExcludeTightestHop  bool
+	TimeoutRetry        bool
+	ChannelCooldown     time.Duration
+	Duplicates          string
+	DefaultMaxPPM       uint64
+	DefaultAttempts     int
+	cooldowns           *channelCooldowns
+	DailyFeeCap         uint64
+	spends              *feeSpends
+	QueueConcurrency    int
+	// QueueWaitForSelf holds the queued rebalances while the graph knows no channel of ours, see CheckSelfInGraph
+	QueueWaitForSelf    bool
+	MinAmount           uint64
+	ReduceToOutbound    bool
 	DB                  *Store
 	LiquidityUpdateChan chan *LiquidityUpdate
 	Stopped             bool
+
+	// MaxPPMScaleReference (msat) and MaxPPMScaleExponent scale maxppm by amount, see circular-maxppm-scale-reference
+	MaxPPMScaleReference uint64
+	MaxPPMScaleExponent  float64
+
+	// RouteMemory is the number of rebalances of a pair of channels that avoid the routes it used last,
+	// for at most RouteMemoryDecay, see circular-route-memory
+	RouteMemory      int
+	RouteMemoryDecay time.Duration
+	routeMemory      *routeMemory
+
+	// RebalanceDeadline bounds how long a rebalance command runs, retries included, 0 for no bound
+	RebalanceDeadline time.Duration
+
+	// ExploreRate is the percentage of the rebalances that prefer, by ExploreBias (ppm of the amount),
+	// the channels without evidence of their liquidity, see circular-explore-rate
+	ExploreRate int
+	ExploreBias uint64
+	exploration *exploration
+
+	// RetryDelay is the delay before retrying after a temporary failure, doubled at every attempt and spread
+	// by RetryJitter percent, see circular-retry-delay
+	RetryDelay  time.Duration
+	RetryJitter int
+
+	// RouteObjectives are the objectives of the route search tried in turn by the attempts of a rebalance,
+	// see circular-route-objective
+	RouteObjectives []string
+
+	// feeHistory records the fees advertised by the channels at every refresh, nil unless circular-fee-history
+	feeHistory *feeHistory
+
+	// ChunkMaxOverlap is the percentage of the hops of a chunk of a split rebalance that can go through
+	// the channels used by the previous chunks, see circular-chunk-max-overlap
+	ChunkMaxOverlap int
+
+	// maxStateSize (bytes) bounds the files saved to disk, 0 for no bound, see saveStateWithin
+	maxStateSize int64
 }
 
 func GetNode() *Node {
@@ -43,9 +136,26 @@ func GetNode() *Node {
 		rand.Seed(time.Now().UnixNano())
 		singleton = &Node{
 			initLock:            &sync.Mutex{},
+			agingLock:           &sync.RWMutex{},
+			graphRefreshLock:    &sync.Mutex{},
+			aliasRefreshLock:    &sync.Mutex{},
+			peersRefreshLock:    &sync.Mutex{},
+			saveLock:            &sync.Mutex{},
+			deadNodesLock:       &sync.RWMutex{},
+			healthLock:          &sync.RWMutex{},
+			hashesLock:          &sync.Mutex{},
+			inFlightHashes:      make(map[string]time.Time),
+			inFlightRoutes:      make(map[string][]string),
+			lastErrors:          newErrorLog(),
+			cooldowns:           newChannelCooldowns(),
+			spends:              newFeeSpends(),
+			routeMemory:         newRouteMemory(),
+			exploration:         newExploration(rand.NewSource(time.Now().UnixNano())),
+			PreimageGenerator:   &LocalPreimageGenerator{},
 			PeersLock:           &sync.RWMutex{},
 			Peers:               make(map[string]*glightning.Peer),
 			LiquidityUpdateChan: make(chan *LiquidityUpdate, 16),
+			RouteOptions:        graph.NewRouteOptions(),
 		}
 		go singleton.UpdateLiquidity()
 	})
@@ -63,28 +173,79 @@ func (n *Node) Init(lightning *glightning.Lightning, plugin *glightning.Plugin,
 
 	n.setOptions(lightning, plugin, options)
 
+	n.Logln(glightning.Debug, "checking the preimage generator")
+	n.checkEntropy()
+
 	n.Logln(glightning.Debug, "getting ID")
 	info, err := n.lightning.GetInfo()
 	if err != nil {
 		log.Fatalln("GetInfo failed in init, exiting")
 	}
 	n.Id = info.Id
+	n.RouteOptions.LocalNode = n.Id
+
+	n.Logln(glightning.Debug, "reading ", CLTV_FINAL_CONFIG)
+	n.loadMinFinalCltv()
 
 	n.Logln(glightning.Debug, "loading from file")
 	n.getGraphFromFile(err, config)
+	n.Graph.ReuseChannels = n.reuseChannels
+
+	n.Logln(glightning.Debug, "loading the allowlist")
+	if err = n.loadAllowlist(config.LightningDir + "/" + CIRCULAR_DIR); err != nil {
+		n.Logln(glightning.Unusual, "unable to load the allowlist: ", err)
+	}
+
+	n.Logln(glightning.Debug, "loading the aging parameters")
+	if err = n.loadAging(config.LightningDir + "/" + CIRCULAR_DIR); err != nil {
+		n.Logln(glightning.Unusual, "unable to load the aging parameters: ", err)
+	}
 
 	n.Logln(glightning.Debug, "refreshing graph")
-	if err = n.refreshGraph(); err != nil {
+	if _, err = n.refreshGraph(); err != nil {
 		log.Fatalln("RefreshGraph failed in init, exiting")
 	}
 
+	// listing the nodes is slow on big graphs: when the aliases were saved, refresh them in the background
+	loadedAliases := 0
+	if n.persistAliases {
+		n.Logln(glightning.Debug, "loading aliases from file")
+		if loadedAliases, err = n.loadAliases(config.LightningDir + "/" + CIRCULAR_DIR); err != nil {
+			n.Logln(glightning.Unusual, "unable to load the aliases: ", err)
+		}
+	}
+	if loadedAliases > 0 {
+		go func() {
+			if _, err := n.tryRefreshAliases(false); err != nil {
+				n.Logln(glightning.Unusual, "aliases refresh failed: ", err)
+			}
+		}()
+	} else {
+		n.Logln(glightning.Debug, "refreshing aliases")
+		// the channels are usable without the aliases, which will be listed again by the cron job
+		if _, err = n.refreshAliases(); err != nil {
+			n.Logln(glightning.Unusual, "aliases refresh failed in init, going on without them: ", err)
+		}
+	}
+
 	n.Logln(glightning.Debug, "refreshing peers")
-	if err = n.refreshPeers(); err != nil {
+	if _, err = n.refreshPeers(); err != nil {
 		log.Fatalln("RefreshPeers failed in init, exiting")
 	}
 
 	n.Logln(glightning.Debug, "opening database")
 	n.DB = NewDB(config.LightningDir + "/" + CIRCULAR_DIR)
+	n.loadFeeSpends()
+
+	n.Logln(glightning.Debug, "reconciling payments")
+	n.reconcilePayments()
+
+	if n.warmUpSearch {
+		n.warmUp()
+	}
+	if len(n.favoritePeers) > 0 {
+		n.warmFavoriteRoutes()
+	}
 
 	n.Logln(glightning.Debug, "setting up cronjobs")
 	n.setupCronJobs(options)
@@ -99,23 +260,305 @@ func (n *Node) getGraphFromFile(err error, config *glightning.Config) {
 		n.Logln(glightning.Unusual, err)
 		n.Graph = graph.NewGraph()
 	} else if err != nil {
-		// If we have an error, we're in trouble
-		n.Logln(glightning.Unusual, err)
+		// only in strict mode: the file is there but can't be read, don't silently start over
+		n.Logln(glightning.Unusual, err, ", refusing to start with a new graph because of circular-strict-graph-load")
 		log.Fatalln(err)
 	}
 }
 
+// nonNegativeOption returns the integer option name, or 0 if it's negative:
+// converted to an unsigned field, a negative value would read as a huge one
+func (n *Node) nonNegativeOption(options map[string]glightning.Option, name string) int {
+	value := options[name].GetValue().(int)
+	if value < 0 {
+		n.Logln(glightning.Unusual, name, " can't be negative, got ", value, ", using 0")
+		return 0
+	}
+	return value
+}
+
 func (n *Node) setOptions(lightning *glightning.Lightning, plugin *glightning.Plugin, options map[string]glightning.Option) {
 	n.lightning = lightning
 	n.plugin = plugin
 	n.Logln(glightning.Info, "initializing node")
 
+	profile := options["circular-profile"].GetValue().(string)
+	applied, err := ApplyProfile(profile, options)
+	if err != nil {
+		n.Logln(glightning.Unusual, err, ", ignoring the profile")
+	} else if profile != PROFILE_NONE {
+		n.Logln(glightning.Info, "profile ", profile, " sets: ", applied)
+	}
+
+	n.DefaultMaxPPM = uint64(n.nonNegativeOption(options, "circular-default-maxppm"))
+	n.DefaultAttempts = options["circular-default-attempts"].GetValue().(int)
+	n.Logln(glightning.Debug, "default maxppm: ", n.DefaultMaxPPM, ", default attempts: ", n.DefaultAttempts)
+
+	n.MaxPPMScaleReference = uint64(n.nonNegativeOption(options, "circular-maxppm-scale-reference")) * 1000
+	n.MaxPPMScaleExponent = float64(options["circular-maxppm-scale-exponent"].GetValue().(int)) / 100
+	n.Logln(glightning.Debug, "maxppm scale reference: ", n.MaxPPMScaleReference, ", exponent: ", n.MaxPPMScaleExponent)
+
 	n.liquidityRefresh = time.Duration(options["circular-liquidity-refresh"].GetValue().(int)) * time.Minute
 	n.Logln(glightning.Debug, "liquidity refresh interval: ", int(n.liquidityRefresh.Minutes()), " minutes")
 
+	n.agingDisabled = options["circular-disable-aging"].GetValue().(bool)
+	n.Logln(glightning.Debug, "aging disabled: ", n.agingDisabled)
+
 	n.saveStats = options["circular-save-stats"].GetValue().(bool)
 	n.Logln(glightning.Debug, "save stats: ", n.saveStats)
 
+	n.ReduceToOutbound = options["circular-reduce-to-outbound"].GetValue().(bool)
+	n.Logln(glightning.Debug, "reduce to outbound: ", n.ReduceToOutbound)
+
+	n.RebalanceDeadline = time.Duration(options["circular-rebalance-deadline"].GetValue().(int)) * time.Second
+	n.Logln(glightning.Debug, "rebalance deadline: ", n.RebalanceDeadline)
+
+	n.RetryDelay = time.Duration(options["circular-retry-delay"].GetValue().(int)) * time.Millisecond
+	n.RetryJitter = options["circular-retry-jitter"].GetValue().(int)
+	if n.RetryJitter < 0 || n.RetryJitter > 100 {
+		n.Logln(glightning.Unusual, "retry jitter must be between 0 and 100, got ", n.RetryJitter, ", using ", DEFAULT_RETRY_JITTER)
+		n.RetryJitter = DEFAULT_RETRY_JITTER
+	}
+	n.Logln(glightning.Debug, "retry delay: ", n.RetryDelay, ", jitter: ", n.RetryJitter, "%")
+
+	n.ChunkMaxOverlap = options["circular-chunk-max-overlap"].GetValue().(int)
+	if n.ChunkMaxOverlap < 0 || n.ChunkMaxOverlap > 100 {
+		n.Logln(glightning.Unusual, "chunk max overlap must be between 0 and 100, got ", n.ChunkMaxOverlap, ", using ", DEFAULT_CHUNK_MAX_OVERLAP)
+		n.ChunkMaxOverlap = DEFAULT_CHUNK_MAX_OVERLAP
+	}
+	n.Logln(glightning.Debug, "chunk max overlap: ", n.ChunkMaxOverlap, "%")
+
+	n.reuseChannels = options["circular-graph-reuse-channels"].GetValue().(bool)
+	n.Logln(glightning.Debug, "reuse channels: ", n.reuseChannels)
+
+	n.checkGraph = options["circular-check-graph"].GetValue().(bool)
+	n.Logln(glightning.Debug, "check graph: ", n.checkGraph)
+
+	n.strictGraphLoad = options["circular-strict-graph-load"].GetValue().(bool)
+	n.Logln(glightning.Debug, "strict graph load: ", n.strictGraphLoad)
+
+	n.savePreimages = options["circular-save-preimages"].GetValue().(bool)
+	n.Logln(glightning.Debug, "save preimages: ", n.savePreimages)
+
+	if options["circular-fee-history"].GetValue().(bool) {
+		retention := options["circular-fee-history-retention"].GetValue().(int)
+		if retention <= 0 {
+			n.Logln(glightning.Unusual, "fee history retention must be positive, got ", retention,
+				", using the default: ", DEFAULT_FEE_HISTORY_RETENTION)
+			retention = DEFAULT_FEE_HISTORY_RETENTION
+		}
+		n.feeHistory = newFeeHistory(CIRCULAR_DIR, time.Duration(retention)*24*time.Hour)
+		n.Logln(glightning.Debug, "fee history retention: ", retention, " days")
+	}
+
+	n.persistAliases = options["circular-save-aliases"].GetValue().(bool)
+	n.Logln(glightning.Debug, "save aliases: ", n.persistAliases)
+
+	n.maxStateSize = int64(options["circular-max-state-size"].GetValue().(int)) * 1000000
+	n.Logln(glightning.Debug, "max state size: ", n.maxStateSize, " bytes")
+
+	n.warmUpSearch = options["circular-warm-up"].GetValue().(bool)
+	n.Logln(glightning.Debug, "warm up: ", n.warmUpSearch)
+
+	favorites, invalid := parseFavoritePeers(options["circular-favorite-peers"].GetValue().(string))
+	for _, id := range invalid {
+		n.Logln(glightning.Unusual, "ignoring favorite peer ", id, ", it's not a node id")
+	}
+	n.favoritePeers = favorites
+	n.favoritesRefresh = time.Duration(options["circular-favorites-refresh"].GetValue().(int)) * time.Minute
+	if n.favoritesRefresh <= 0 {
+		n.favoritesRefresh = DEFAULT_FAVORITES_REFRESH * time.Minute
+	}
+	n.Logln(glightning.Debug, "favorite peers: ", len(n.favoritePeers), ", refreshed every ", int(n.favoritesRefresh.Minutes()), " minutes")
+
+	staleThreshold := options["circular-graph-stale-threshold"].GetValue().(int)
+	if staleThreshold <= 0 {
+		n.Logln(glightning.Unusual, "graph stale threshold must be positive, got ", staleThreshold,
+			", using the default: ", DEFAULT_GRAPH_STALE_THRESHOLD)
+		staleThreshold = DEFAULT_GRAPH_STALE_THRESHOLD
+	}
+	n.graphStaleThreshold = time.Duration(staleThreshold) * time.Minute
+	n.Logln(glightning.Debug, "graph stale threshold: ", int(n.graphStaleThreshold.Minutes()), " minutes")
+
+	n.graphMaxAge = time.Duration(n.nonNegativeOption(options, "circular-graph-max-age")) * time.Minute
+	n.Logln(glightning.Debug, "graph max age: ", int(n.graphMaxAge.Minutes()), " minutes")
+
+	n.saveInterval = time.Duration(options["circular-save-interval"].GetValue().(int)) * time.Minute
+	if n.saveInterval <= 0 {
+		n.Logln(glightning.Unusual, "circular-save-interval must be at least 1 minute, using the default")
+		n.saveInterval = DEFAULT_SAVE_INTERVAL * time.Minute
+	}
+	n.Logln(glightning.Debug, "save interval: ", int(n.saveInterval.Minutes()), " minutes")
+
+	n.maxChannels = options["circular-max-channels"].GetValue().(int)
+	n.Logln(glightning.Debug, "max channels: ", n.maxChannels)
+
+	n.RouteOptions.StrictPrivate = options["circular-strict-private"].GetValue().(bool)
+	n.Logln(glightning.Debug, "strict private: ", n.RouteOptions.StrictPrivate)
+
+	n.RouteOptions.MinCapacity = uint64(n.nonNegativeOption(options, "circular-min-capacity"))
+	n.RouteOptions.MaxCapacity = uint64(n.nonNegativeOption(options, "circular-max-capacity"))
+	n.RouteOptions.StrictCapacity = options["circular-strict-capacity"].GetValue().(bool)
+	n.Logln(glightning.Debug, "capacity range: ", n.RouteOptions.MinCapacity, "-", n.RouteOptions.MaxCapacity,
+		" sats, strict: ", n.RouteOptions.StrictCapacity)
+
+	n.RouteMemory = options["circular-route-memory"].GetValue().(int)
+	n.RouteMemoryDecay = time.Duration(options["circular-route-memory-decay"].GetValue().(int)) * time.Minute
+	n.RouteOptions.RecentRoutePenalty = uint64(n.nonNegativeOption(options, "circular-route-memory-penalty"))
+	n.Logln(glightning.Debug, "route memory: ", n.RouteMemory, " rebalances, decay: ", n.RouteMemoryDecay,
+		", penalty: ", n.RouteOptions.RecentRoutePenalty, "ppm")
+
+	n.RouteOptions.MaxHopDelay = uint(n.nonNegativeOption(options, "circular-max-hop-delay"))
+	n.Logln(glightning.Debug, "max hop delay: ", n.RouteOptions.MaxHopDelay, " blocks")
+
+	n.RouteOptions.Allowlist = options["circular-allowlist"].GetValue().(bool)
+	n.Logln(glightning.Debug, "allowlist: ", n.RouteOptions.Allowlist)
+
+	n.RouteOptions.AvoidLocalChannels = options["circular-avoid-local-channels"].GetValue().(bool)
+	n.Logln(glightning.Debug, "avoid local channels: ", n.RouteOptions.AvoidLocalChannels)
+
+	n.RouteOptions.PreFilter = options["circular-prefilter"].GetValue().(bool)
+	n.Logln(glightning.Debug, "prefilter: ", n.RouteOptions.PreFilter)
+
+	n.RouteOptions.SearchWorkers = options["circular-search-workers"].GetValue().(int)
+	n.Logln(glightning.Debug, "search workers: ", n.RouteOptions.SearchWorkers)
+
+	n.RouteOptions.CacheRoutes = options["circular-route-cache"].GetValue().(bool)
+	n.RouteOptions.AmountGranularity = uint64(n.nonNegativeOption(options, "circular-amount-granularity"))
+	n.Logln(glightning.Debug, "route cache: ", n.RouteOptions.CacheRoutes, ", amount granularity: ", n.RouteOptions.AmountGranularity, "msat")
+
+	n.RouteOptions.MinHopCost = uint64(n.nonNegativeOption(options, "circular-min-hop-cost"))
+	n.Logln(glightning.Debug, "min hop cost: ", n.RouteOptions.MinHopCost, "msat")
+
+	n.MinAmount = uint64(n.nonNegativeOption(options, "circular-min-amount")) * 1000
+	n.Logln(glightning.Debug, "min amount: ", n.MinAmount, "msat")
+
+	n.MaxAlternateOuts = options["circular-max-alternate-outs"].GetValue().(int)
+	n.Logln(glightning.Debug, "max alternate outgoing channels: ", n.MaxAlternateOuts)
+
+	n.ExcludeTightestHop = options["circular-exclude-tightest-hop"].GetValue().(bool)
+	n.Logln(glightning.Debug, "exclude tightest hop: ", n.ExcludeTightestHop)
+
+	n.TimeoutRetry = options["circular-timeout-retry"].GetValue().(bool)
+	n.Logln(glightning.Debug, "timeout retry: ", n.TimeoutRetry)
+
+	n.ChannelCooldown = time.Duration(options["circular-channel-cooldown"].GetValue().(int)) * time.Minute
+	n.Logln(glightning.Debug, "channel cooldown: ", n.ChannelCooldown)
+
+	n.DailyFeeCap = uint64(n.nonNegativeOption(options, "circular-daily-fee-cap")) * 1000
+	n.Logln(glightning.Debug, "daily fee cap: ", n.DailyFeeCap, "msat")
+
+	n.QueueConcurrency = options["circular-queue-concurrency"].GetValue().(int)
+	n.Logln(glightning.Debug, "queue concurrency: ", n.QueueConcurrency)
+
+	n.QueueWaitForSelf = options["circular-queue-wait-for-self"].GetValue().(bool)
+	n.Logln(glightning.Debug, "queue waits for self in graph: ", n.QueueWaitForSelf)
+
+	n.RouteOptions.ReliabilityWeight = uint64(n.nonNegativeOption(options, "circular-reliability-weight"))
+	n.Logln(glightning.Debug, "reliability weight: ", n.RouteOptions.ReliabilityWeight, "ppm")
+
+	n.RouteOptions.FeeVolatilityWeight = uint64(n.nonNegativeOption(options, "circular-fee-volatility-weight"))
+	n.Logln(glightning.Debug, "fee volatility weight: ", n.RouteOptions.FeeVolatilityWeight, "ppm")
+
+	n.RouteOptions.BaseFeeWeight = uint64(n.nonNegativeOption(options, "circular-base-fee-weight"))
+	n.RouteOptions.ProportionalFeeWeight = uint64(n.nonNegativeOption(options, "circular-proportional-fee-weight"))
+	n.Logln(glightning.Debug, "base fee weight: ", n.RouteOptions.BaseFeeWeight, "%, proportional fee weight: ",
+		n.RouteOptions.ProportionalFeeWeight, "%")
+
+	n.RouteOptions.MinLiquidityPercentile = options["circular-min-liquidity-percentile"].GetValue().(int)
+	if n.RouteOptions.MinLiquidityPercentile < 0 || n.RouteOptions.MinLiquidityPercentile > 100 {
+		n.Logln(glightning.Unusual, "min liquidity percentile must be between 0 and 100, got ",
+			n.RouteOptions.MinLiquidityPercentile, ", disabling it")
+		n.RouteOptions.MinLiquidityPercentile = 0
+	}
+	n.Logln(glightning.Debug, "min liquidity percentile: ", n.RouteOptions.MinLiquidityPercentile)
+
+	n.RouteOptions.RequireEvidence = options["circular-require-evidence"].GetValue().(bool)
+	unevidenced := options["circular-unevidenced-liquidity"].GetValue().(int)
+	if unevidenced < 0 || unevidenced > 100 {
+		n.Logln(glightning.Unusual, "unevidenced liquidity must be between 0 and 100, got ", unevidenced,
+			", using ", graph.DEFAULT_UNEVIDENCED_LIQUIDITY)
+		unevidenced = graph.DEFAULT_UNEVIDENCED_LIQUIDITY
+	}
+	n.RouteOptions.UnevidencedLiquidity = uint64(unevidenced)
+	n.Logln(glightning.Debug, "require evidence: ", n.RouteOptions.RequireEvidence,
+		", unevidenced liquidity: ", n.RouteOptions.UnevidencedLiquidity, "%")
+
+	n.ExploreRate = options["circular-explore-rate"].GetValue().(int)
+	if n.ExploreRate < 0 || n.ExploreRate > 100 {
+		n.Logln(glightning.Unusual, "explore rate must be between 0 and 100, got ", n.ExploreRate, ", disabling it")
+		n.ExploreRate = 0
+	}
+	n.ExploreBias = uint64(n.nonNegativeOption(options, "circular-explore-bias"))
+	n.Logln(glightning.Debug, "explore rate: ", n.ExploreRate, "%, bias: ", n.ExploreBias, "ppm")
+
+	n.RouteOptions.MissingFeesPenalty = uint64(n.nonNegativeOption(options, "circular-missing-fees-penalty"))
+	n.Logln(glightning.Debug, "missing fees penalty: ", n.RouteOptions.MissingFeesPenalty, "ppm")
+
+	n.RouteOptions.PreferredNodes = graph.ParsePreferredNodes(options["circular-preferred-nodes"].GetValue().(string))
+	n.RouteOptions.PreferredBias = uint64(n.nonNegativeOption(options, "circular-preferred-bias"))
+	n.Logln(glightning.Debug, "preferred nodes: ", len(n.RouteOptions.PreferredNodes), ", bias: ", n.RouteOptions.PreferredBias, "ppm")
+
+	n.RouteOptions.MaxRouteLength = options["circular-max-route-length"].GetValue().(int)
+	n.Logln(glightning.Debug, "max route length: ", n.RouteOptions.MaxRouteLength)
+
+	n.RouteOptions.DelayPadding = uint(n.nonNegativeOption(options, "circular-delay-padding"))
+	n.Logln(glightning.Debug, "delay padding: ", n.RouteOptions.DelayPadding, " blocks")
+
+	n.RouteOptions.StabilityCheck = options["circular-stability-check"].GetValue().(bool)
+	n.RouteOptions.StabilityDelay = time.Duration(options["circular-stability-delay"].GetValue().(int)) * time.Second
+	n.Logln(glightning.Debug, "stability check: ", n.RouteOptions.StabilityCheck, ", delay: ", n.RouteOptions.StabilityDelay)
+
+	n.RouteOptions.InboundFees = options["circular-inbound-fees"].GetValue().(bool)
+	n.Logln(glightning.Debug, "inbound fees: ", n.RouteOptions.InboundFees)
+
+	n.RouteOptions.AggregateParallel = options["circular-aggregate-parallel"].GetValue().(bool)
+	n.Logln(glightning.Debug, "aggregate parallel channels: ", n.RouteOptions.AggregateParallel)
+
+	tieBreak, err := graph.ParseTieBreak(options["circular-tie-break"].GetValue().(string))
+	if err != nil {
+		n.Logln(glightning.Unusual, err, ", using the default tie-break: ", graph.DEFAULT_TIE_BREAK)
+	} else {
+		n.RouteOptions.TieBreak = tieBreak
+	}
+	n.Logln(glightning.Debug, "tie-break: ", n.RouteOptions.TieBreak)
+
+	objectives, err := graph.ParseObjectives(options["circular-route-objective"].GetValue().(string))
+	if err != nil {
+		n.Logln(glightning.Unusual, err, ", using the default route objective: ", graph.DEFAULT_OBJECTIVES)
+		objectives, _ = graph.ParseObjectives(graph.DEFAULT_OBJECTIVES)
+	}
+	n.RouteObjectives = objectives
+	n.RouteOptions.Objective = objectives[0]
+	n.Logln(glightning.Debug, "route objectives: ", n.RouteObjectives)
+
+	n.excludeDeadNodes = options["circular-exclude-dead-nodes"].GetValue().(bool)
+	n.Logln(glightning.Debug, "exclude dead nodes: ", n.excludeDeadNodes)
+
+	localBalanceSource, err := ParseLocalBalanceSource(options["circular-local-balance"].GetValue().(string))
+	if err != nil {
+		n.Logln(glightning.Unusual, err, ", using the default local balance: ", DEFAULT_LOCAL_BALANCE)
+		localBalanceSource = DEFAULT_LOCAL_BALANCE
+	}
+	n.localBalanceSource = localBalanceSource
+	n.Logln(glightning.Debug, "local balance: ", n.localBalanceSource)
+
+	duplicates, err := ParseDuplicates(options["circular-duplicates"].GetValue().(string))
+	if err != nil {
+		n.Logln(glightning.Unusual, err, ", using the default duplicates: ", DEFAULT_DUPLICATES)
+		duplicates = DEFAULT_DUPLICATES
+	}
+	n.Duplicates = duplicates
+	n.Logln(glightning.Debug, "duplicates: ", n.Duplicates)
+
+	minProbability := options["circular-min-probability"].GetValue().(int)
+	if minProbability < 0 || minProbability > 100 {
+		n.Logln(glightning.Unusual, "min probability must be between 0 and 100, got ", minProbability, ", accepting any route")
+		minProbability = 0
+	}
+	n.RouteOptions.MinProbability = float64(minProbability) / 100
+	n.Logln(glightning.Debug, "min probability: ", minProbability, "%")
+
 	n.lightning.SetTimeout(DEFAULT_RPC_TIMEOUT)
 }
 
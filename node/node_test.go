@@ -0,0 +1,21 @@
+package node
+
+import (
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNonNegativeOption(t *testing.T) {
+	n := newMockNode(t, nil)
+	weight := glightning.NewIntOption("circular-reliability-weight", "", 0)
+	options := map[string]glightning.Option{weight.Name: weight}
+
+	weight.Val = 1000
+	assert.Equal(t, 1000, n.nonNegativeOption(options, weight.Name))
+	weight.Val = 0
+	assert.Equal(t, 0, n.nonNegativeOption(options, weight.Name))
+	// a negative weight would wrap around to a huge uint64
+	weight.Val = -1
+	assert.Equal(t, 0, n.nonNegativeOption(options, weight.Name))
+}
@@ -22,8 +22,15 @@ func (n *Node) SendPay(route *graph.Route, paymentHash string) (*glightning.Send
 	finalRoute := route.ToLightningRoute()
 
 	n.Logln(glightning.Debug, "sending payment")
+	n.trackRoute(paymentHash, route)
 	if _, err := n.lightning.SendPayLite(finalRoute, paymentHash); err != nil {
 		n.Logln(glightning.Unusual, err)
+		n.forgetRoute(paymentHash)
+		// the payment was never sent: forget its preimage and its hash, as when it resolves
+		if err := n.DB.Delete(paymentHash); err != nil {
+			n.Logln(glightning.Unusual, err)
+		}
+		n.releaseHash(paymentHash)
 		return nil, util.ErrFirstPeerNotReady
 	}
 
@@ -36,32 +43,48 @@ func (n *Node) SendPay(route *graph.Route, paymentHash string) (*glightning.Send
 
 		// in case of timeout, there's some work to do
 		if err.Error() == util.ErrSendPayTimeout.Error() {
-			return n.manageTimeout(paymentHash)
+			result, err := n.manageTimeout(paymentHash)
+			if !n.TimeoutRetry {
+				return result, err
+			}
+			return n.reconcileTimeout(paymentHash)
 		}
 
 		// in case of WIRE_FEE_INSUFFICIENT, we return only if the last hop is the one who originated the error
 		// in this way we make the rebalance fail if the last node changed fees, but treat
 		// WIRE_FEE_INSUFFICIENT errors along the path as a liquidity failure
+		// we need to get the full error
+		var paymentError *glightning.PaymentError
+		if !errors.As(err, &paymentError) || paymentError.Data == nil {
+			n.forgetRoute(paymentHash)
+			return nil, err
+		}
+		n.recordOutcome(paymentHash, erringIndex(paymentError.Data.ErringNode, paymentError.Data.ErringIndex), false)
+
 		if err.Error() == util.ErrWireFeeInsufficient.Error() {
-			// we need to get the full error
-			var paymentError *glightning.PaymentError
-			if errors.As(err, &paymentError) {
-				lastNode := finalRoute[len(finalRoute)-2].Id
-				if lastNode == paymentError.Data.ErringNode {
-					n.Logln(glightning.Debug, "last node is the node that caused the error")
-					return nil, util.ErrWireFeeInsufficient
-				}
+			lastNode := finalRoute[len(finalRoute)-2].Id
+			if lastNode == paymentError.Data.ErringNode {
+				n.Logln(glightning.Debug, "last node is the node that caused the error")
+				return nil, util.ErrWireFeeInsufficient
 			}
 		}
 
+		// erring index 0 is our own node: the payment didn't make it through the first hop
+		if paymentError.Data.ErringIndex == 0 {
+			n.Logln(glightning.Debug, "the first hop failed: ", paymentError.Data.FailCodeName)
+			return nil, util.ErrFirstHopFailure
+		}
+
 		return nil, err
 	}
+	n.recordOutcome(paymentHash, ERRING_INDEX_UNKNOWN, true)
 
 	return result, nil
 }
 
 func (n *Node) manageTimeout(paymentHash string) (*glightning.SendPayFields, error) {
 	// delete the preimage from the DB. In this way the payment will fail when the HTLC comes in
+	// The hash stays in flight until the htlc is resolved, so that a stuck htlc can be reported
 	n.Logln(glightning.Debug, "payment timed out, deleting preimage from database")
 	if err := n.DB.Delete(paymentHash); err != nil {
 		n.Logln(glightning.Unusual, err)
@@ -76,6 +99,50 @@ func (n *Node) manageTimeout(paymentHash string) (*glightning.SendPayFields, err
 	return nil, util.ErrSendPayTimeout
 }
 
+// reconcileTimeout checks with listsendpays how a payment that timed out ended up, once its preimage
+// has been deleted by manageTimeout. The payment can still have completed if we released the preimage
+// just before deleting it: it's then reported as a success, so that the rebalance isn't sent twice.
+// Only when every part failed does ErrSendPayStalled tell the caller that it's safe to retry:
+// a part that is still pending can complete if the fulfill is on its way back, so it's reported
+// as a timeout, which is not retried. The same happens if lightningd can't be asked.
+func (n *Node) reconcileTimeout(paymentHash string) (*glightning.SendPayFields, error) {
+	payments, err := n.lightning.ListSendPaysByHash(paymentHash)
+	if err != nil {
+		n.Logln(glightning.Unusual, "unable to reconcile the payment ", paymentHash, " that timed out: ", err)
+		return nil, util.ErrSendPayTimeout
+	}
+	if completed, ok := getCompleted(payments); ok {
+		n.Logln(glightning.Info, "the payment ", paymentHash, " completed after timing out")
+		return completed, nil
+	}
+	if !allFailed(payments) {
+		n.Logln(glightning.Info, "the payment ", paymentHash, " is still pending after timing out")
+		return nil, util.ErrSendPayTimeout
+	}
+	n.Logln(glightning.Debug, "the payment ", paymentHash, " failed, it can be retried")
+	return nil, util.ErrSendPayStalled
+}
+
+// getCompleted returns the part of a payment that completed, if any
+func getCompleted(payments []glightning.SendPayFields) (*glightning.SendPayFields, bool) {
+	for i := range payments {
+		if payments[i].Status == SENDPAY_COMPLETE {
+			return &payments[i], true
+		}
+	}
+	return nil, false
+}
+
+// allFailed tells if every part of a payment failed, so that none of them can still complete
+func allFailed(payments []glightning.SendPayFields) bool {
+	for _, payment := range payments {
+		if payment.Status != SENDPAY_FAILED {
+			return false
+		}
+	}
+	return len(payments) > 0
+}
+
 func (n *Node) deleteIfOurs(paymentHash string) error {
 	key := paymentHash
 	_, err := n.DB.Get(key)
@@ -95,6 +162,7 @@ func (n *Node) deleteIfOurs(paymentHash string) error {
 		n.Logln(glightning.Unusual, err)
 		return err
 	}
+	n.releaseHash(paymentHash)
 
 	return nil
 }
@@ -103,6 +171,7 @@ func (n *Node) OnPaymentFailure(sf *glightning.SendPayFailure) {
 	if err := n.deleteIfOurs(sf.Data.PaymentHash); err != nil {
 		return // this payment was not made by us
 	}
+	n.recordOutcome(sf.Data.PaymentHash, erringIndex(sf.Data.ErringNode, sf.Data.ErringIndex), false)
 
 	// save to db
 	if err := n.SaveToDb(FAILURE_PREFIX+sf.Data.PaymentHash, sf); err != nil {
@@ -123,6 +192,7 @@ func (n *Node) OnPaymentSuccess(ss *glightning.SendPaySuccess) {
 	if err := n.deleteIfOurs(ss.PaymentHash); err != nil {
 		return // this payment was not made by us
 	}
+	n.recordOutcome(ss.PaymentHash, ERRING_INDEX_UNKNOWN, true)
 
 	// save to db
 	if err := n.SaveToDb(SUCCESS_PREFIX+ss.PaymentHash, ss); err != nil {
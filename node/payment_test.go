@@ -0,0 +1,77 @@
+package node
+
+import (
+	"circular/util"
+	"encoding/json"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetCompletedReconcilesTimeouts(t *testing.T) {
+	// the payment is still in flight or failed: it can be retried
+	_, ok := getCompleted([]glightning.SendPayFields{
+		{Status: SENDPAY_PENDING, PaymentHash: "hash"},
+	})
+	assert.False(t, ok)
+	_, ok = getCompleted([]glightning.SendPayFields{
+		{Status: "failed", PaymentHash: "hash"},
+	})
+	assert.False(t, ok)
+	_, ok = getCompleted(nil)
+	assert.False(t, ok)
+
+	// the payment completed after the timeout: it must not be sent again
+	completed, ok := getCompleted([]glightning.SendPayFields{
+		{Status: "failed", PaymentHash: "hash", Id: 1},
+		{Status: SENDPAY_COMPLETE, PaymentHash: "hash", Id: 2},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), completed.Id)
+}
+
+func TestReconcileTimeoutOnlyRetriesFailedPayments(t *testing.T) {
+	parts := map[string][]glightning.SendPayFields{
+		"pending": {
+			{Status: SENDPAY_FAILED, PaymentHash: "pending", Id: 1},
+			{Status: SENDPAY_PENDING, PaymentHash: "pending", Id: 2},
+		},
+		"failed": {
+			{Status: SENDPAY_FAILED, PaymentHash: "failed", Id: 1},
+			{Status: SENDPAY_FAILED, PaymentHash: "failed", Id: 2},
+		},
+		"complete": {
+			{Status: SENDPAY_PENDING, PaymentHash: "complete", Id: 1},
+			{Status: SENDPAY_COMPLETE, PaymentHash: "complete", Id: 2},
+		},
+		"unknown": {},
+	}
+	n := newMockNode(t, map[string]rpcHandler{
+		"listsendpays": func(params json.RawMessage) (interface{}, error) {
+			var request struct {
+				PaymentHash string `json:"payment_hash"`
+			}
+			if err := json.Unmarshal(params, &request); err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"payments": parts[request.PaymentHash]}, nil
+		},
+	})
+
+	// a pending part can still complete: the rebalance must not be sent again
+	_, err := n.reconcileTimeout("pending")
+	assert.Equal(t, util.ErrSendPayTimeout, err)
+
+	// every part failed: it's safe to retry
+	_, err = n.reconcileTimeout("failed")
+	assert.Equal(t, util.ErrSendPayStalled, err)
+
+	// the payment completed after the timeout
+	completed, err := n.reconcileTimeout("complete")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), completed.Id)
+
+	// nothing is known about the payment
+	_, err = n.reconcileTimeout("unknown")
+	assert.Equal(t, util.ErrSendPayTimeout, err)
+}
@@ -6,18 +6,24 @@ import (
 	"github.com/elementsproject/glightning/glightning"
 )
 
-func (n *Node) GetBestPeerChannel(id string, metric func(*glightning.PeerChannel) uint64) *glightning.PeerChannel {
+func (n *Node) GetBestPeerChannel(id string, metric func(*glightning.PeerChannel) uint64) (*glightning.PeerChannel, error) {
 	n.PeersLock.RLock()
 	defer n.PeersLock.RUnlock()
 
-	channels := n.Peers[id].Channels
-	best := channels[0]
-	for _, channel := range channels {
+	peer, ok := n.Peers[id]
+	if !ok {
+		return nil, util.ErrNoPeer
+	}
+	if len(peer.Channels) == 0 {
+		return nil, util.ErrNoPeerChannel
+	}
+	best := peer.Channels[0]
+	for _, channel := range peer.Channels {
 		if metric(channel) > metric(best) {
 			best = channel
 		}
 	}
-	return best
+	return best, nil
 }
 
 func (n *Node) GetPeerChannelFromGraphChannel(graphChannel *graph.Channel) (*glightning.PeerChannel, error) {
@@ -79,7 +85,7 @@ func (n *Node) GetOutgoingChannelFromScid(scid string) (*graph.Channel, error) {
 	channelId := scid + "/" + util.GetDirection(n.Id, peer.Id)
 	channel, err := n.Graph.GetChannel(channelId)
 	if err == util.ErrNoChannel {
-		return nil, util.ErrNoOutgoingChannel
+		return nil, n.missingChannelError(util.ErrNoOutgoingChannel)
 	}
 	return channel, err
 }
@@ -93,11 +99,28 @@ func (n *Node) GetIncomingChannelFromScid(scid string) (*graph.Channel, error) {
 	channelId := scid + "/" + util.GetDirection(peer.Id, n.Id)
 	channel, err := n.Graph.GetChannel(channelId)
 	if err == util.ErrNoChannel {
-		return nil, util.ErrNoIncomingChannel
+		return nil, n.missingChannelError(util.ErrNoIncomingChannel)
 	}
 	return channel, err
 }
 
+// missingChannelError is err for a local channel missing from the graph, unless none of them is there
+func (n *Node) missingChannelError(missing error) error {
+	if err := n.CheckSelfInGraph(); err != nil {
+		return err
+	}
+	return missing
+}
+
+// CheckSelfInGraph fails if the graph doesn't know any channel of ours yet, for example before the
+// first gossip sync of a new node: no route can be found until it does
+func (n *Node) CheckSelfInGraph() error {
+	if !n.Graph.HasNode(n.Id) {
+		return util.ErrSelfNotInGraph
+	}
+	return nil
+}
+
 func (n *Node) UpdateChannelBalance(outPeer, inPeer, outScid, inScid string, amount uint64) {
 	n.PeersLock.Lock()
 	defer n.PeersLock.Unlock()
@@ -105,18 +128,25 @@ func (n *Node) UpdateChannelBalance(outPeer, inPeer, outScid, inScid string, amo
 	for _, channel := range n.Peers[outPeer].Channels {
 		if channel.ShortChannelId == outScid {
 			channel.MilliSatoshiToUs -= amount * 1000
+			channel.SpendableMilliSatoshi = subtractReserve(channel.SpendableMilliSatoshi, amount*1000, "")
+			channel.ReceivableMilliSatoshi += amount * 1000
 			break
 		}
 	}
 	for _, channel := range n.Peers[inPeer].Channels {
 		if channel.ShortChannelId == inScid {
 			channel.MilliSatoshiToUs += amount * 1000
+			channel.SpendableMilliSatoshi += amount * 1000
+			channel.ReceivableMilliSatoshi = subtractReserve(channel.ReceivableMilliSatoshi, amount*1000, "")
 			break
 		}
 	}
 }
 
 func (n *Node) OnConnect(c *glightning.ConnectEvent) {
+	// lightningd doesn't notify node announcements, a peer connecting is the best hint that its alias might have changed
+	go n.refreshAlias(c.PeerId)
+
 	n.PeersLock.Lock()
 	defer n.PeersLock.Unlock()
 
@@ -1,33 +1,124 @@
 package node
 
 import (
+	"circular/util"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
-	"math/rand"
+	"github.com/elementsproject/glightning/glightning"
+	"sync"
+	"time"
 )
 
+// PreimageGenerator is the source of the preimages used for rebalance payments.
+// The default is LocalPreimageGenerator, other implementations can delegate
+// to an external entropy source or produce deterministic vectors for tests.
+type PreimageGenerator interface {
+	Generate() (PreimageHashPair, error)
+}
+
+// LocalPreimageGenerator generates preimages with the local cryptographically secure random source
+type LocalPreimageGenerator struct{}
+
+func (g *LocalPreimageGenerator) Generate() (PreimageHashPair, error) {
+	return NewPreimageHashPair()
+}
+
+// DeterministicPreimageGenerator derives the preimages from a seed and a counter.
+// It must only be used in tests: anyone knowing the seed can claim the payments.
+type DeterministicPreimageGenerator struct {
+	lock    sync.Mutex
+	Seed    []byte
+	counter uint64
+}
+
+func NewDeterministicPreimageGenerator(seed []byte) *DeterministicPreimageGenerator {
+	return &DeterministicPreimageGenerator{
+		Seed: seed,
+	}
+}
+
+func (g *DeterministicPreimageGenerator) Generate() (PreimageHashPair, error) {
+	g.lock.Lock()
+	counter := g.counter
+	g.counter++
+	g.lock.Unlock()
+
+	buf := make([]byte, len(g.Seed)+8)
+	copy(buf, g.Seed)
+	binary.BigEndian.PutUint64(buf[len(g.Seed):], counter)
+	preimage := sha256.Sum256(buf)
+	hash := sha256.Sum256(preimage[:])
+
+	return PreimageHashPair{
+		Preimage: hex.EncodeToString(preimage[:]),
+		Hash:     hex.EncodeToString(hash[:]),
+	}, nil
+}
+
 type PreimageHashPair struct {
 	Preimage string `json:"preimage"`
 	Hash     string `json:"hash"`
 }
 
-func NewPreimageHashPair() PreimageHashPair {
+func NewPreimageHashPair() (PreimageHashPair, error) {
 	preimage := make([]byte, 32)
-	//fill the slice with random bytes
-	rand.Read(preimage)
+	// fill the slice with cryptographically secure random bytes
+	if _, err := rand.Read(preimage); err != nil {
+		return PreimageHashPair{}, err
+	}
 	hash := sha256.Sum256(preimage)
 
-	return PreimageHashPair{
+	pair := PreimageHashPair{
 		Preimage: hex.EncodeToString(preimage),
 		Hash:     hex.EncodeToString(hash[:]),
 	}
+
+	// don't keep the raw preimage around longer than needed
+	for i := range preimage {
+		preimage[i] = 0
+	}
+	return pair, nil
 }
 
 func (n *Node) GeneratePreimageHashPair() (string, error) {
-	pair := NewPreimageHashPair()
-	err := n.DB.Set(pair.Hash, []byte(pair.Preimage))
+	pair, err := n.PreimageGenerator.Generate()
 	if err != nil {
 		return "", err
 	}
+
+	if err := n.reserveHash(pair.Hash); err != nil {
+		n.Logln(glightning.Unusual, "CRITICAL: ", err, ": ", pair.Hash)
+		return "", err
+	}
+
+	err = n.DB.Set(pair.Hash, []byte(pair.Preimage))
+	if err != nil {
+		n.releaseHash(pair.Hash)
+		return "", err
+	}
 	return pair.Hash, nil
 }
+
+// reserveHash marks a payment hash as in-flight, failing if it is already in use.
+// A collision should never happen, but reusing a preimage across attempts would
+// allow an intermediate node to claim the funds of a retry.
+func (n *Node) reserveHash(hash string) error {
+	n.hashesLock.Lock()
+	defer n.hashesLock.Unlock()
+
+	if _, ok := n.inFlightHashes[hash]; ok {
+		return util.ErrPaymentHashCollision
+	}
+	n.inFlightHashes[hash] = time.Now()
+	return nil
+}
+
+// releaseHash removes a payment hash from the in-flight set once the payment is settled
+func (n *Node) releaseHash(hash string) {
+	n.hashesLock.Lock()
+	defer n.hashesLock.Unlock()
+
+	delete(n.inFlightHashes, hash)
+}
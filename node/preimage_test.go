@@ -0,0 +1,91 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentAttemptsHaveUniqueHashes(t *testing.T) {
+	n := &Node{
+		hashesLock:     &sync.Mutex{},
+		inFlightHashes: make(map[string]time.Time),
+	}
+
+	attempts := 256
+	pairs := make(chan PreimageHashPair, attempts)
+	errs := make(chan error, attempts)
+	wg := sync.WaitGroup{}
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pair, err := NewPreimageHashPair()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := n.reserveHash(pair.Hash); err != nil {
+				errs <- err
+				return
+			}
+			pairs <- pair
+		}()
+	}
+	wg.Wait()
+	close(pairs)
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for pair := range pairs {
+		assert.False(t, seen[pair.Hash])
+		seen[pair.Hash] = true
+
+		preimage, err := hex.DecodeString(pair.Preimage)
+		assert.NoError(t, err)
+		hash := sha256.Sum256(preimage)
+		assert.Equal(t, hex.EncodeToString(hash[:]), pair.Hash)
+	}
+	assert.Equal(t, attempts, len(seen))
+	assert.Equal(t, attempts, len(n.inFlightHashes))
+}
+
+func TestReserveHashCollision(t *testing.T) {
+	n := &Node{
+		hashesLock:     &sync.Mutex{},
+		inFlightHashes: make(map[string]time.Time),
+	}
+
+	assert.NoError(t, n.reserveHash("aa"))
+	assert.Error(t, n.reserveHash("aa"))
+	n.releaseHash("aa")
+	assert.NoError(t, n.reserveHash("aa"))
+}
+
+func TestDeterministicPreimageGenerator(t *testing.T) {
+	g1 := NewDeterministicPreimageGenerator([]byte("seed"))
+	g2 := NewDeterministicPreimageGenerator([]byte("seed"))
+
+	first, err := g1.Generate()
+	assert.NoError(t, err)
+	second, err := g1.Generate()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Hash, second.Hash)
+
+	// the same seed yields the same sequence
+	other, err := g2.Generate()
+	assert.NoError(t, err)
+	assert.Equal(t, first, other)
+
+	preimage, err := hex.DecodeString(first.Preimage)
+	assert.NoError(t, err)
+	hash := sha256.Sum256(preimage)
+	assert.Equal(t, hex.EncodeToString(hash[:]), first.Hash)
+}
@@ -0,0 +1,73 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"sort"
+)
+
+const (
+	// PROFILE_NONE leaves every option to its own value
+	PROFILE_NONE = ""
+	// PROFILE_ECONOMICAL spends as little as possible: low fee cap, cheapest routes, few retries
+	PROFILE_ECONOMICAL = "economical"
+	// PROFILE_AGGRESSIVE tries hard to move the liquidity: higher fee cap, more retries, reliable routes, probing
+	PROFILE_AGGRESSIVE = "aggressive"
+)
+
+// profiles are the values that each profile gives to the options
+var profiles = map[string]map[string]interface{}{
+	PROFILE_ECONOMICAL: {
+		"circular-default-maxppm":       5,
+		"circular-default-attempts":     1,
+		"circular-max-alternate-outs":   0,
+		"circular-exclude-tightest-hop": false,
+		"circular-reliability-weight":   0,
+		"circular-min-hop-cost":         0,
+		"circular-explore-rate":         0,
+	},
+	PROFILE_AGGRESSIVE: {
+		"circular-default-maxppm":       100,
+		"circular-default-attempts":     5,
+		"circular-max-alternate-outs":   3,
+		"circular-exclude-tightest-hop": true,
+		"circular-reliability-weight":   1000,
+		"circular-min-hop-cost":         0,
+		"circular-explore-rate":         10,
+	},
+}
+
+// ApplyProfile sets the options of profile that are still at their default value,
+// so that the options set explicitly win over the profile. It returns the options it set.
+func ApplyProfile(profile string, options map[string]glightning.Option) ([]string, error) {
+	if profile == PROFILE_NONE {
+		return nil, nil
+	}
+	values, ok := profiles[profile]
+	if !ok {
+		return nil, util.ErrInvalidProfile
+	}
+
+	applied := make([]string, 0, len(values))
+	for name, value := range values {
+		switch option := options[name].(type) {
+		case *glightning.IntOption:
+			if option.Val == option.Default {
+				option.Val = value.(int)
+				applied = append(applied, name)
+			}
+		case *glightning.BoolOption:
+			if option.Val == option.Default {
+				option.Val = value.(bool)
+				applied = append(applied, name)
+			}
+		case *glightning.StringOption:
+			if option.Val == option.Default {
+				option.Val = value.(string)
+				applied = append(applied, name)
+			}
+		}
+	}
+	sort.Strings(applied)
+	return applied, nil
+}
@@ -0,0 +1,84 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// newProfileOptions returns the options set by the profiles at their default values, like lightningd passes them
+func newProfileOptions() map[string]glightning.Option {
+	maxPPM := glightning.NewIntOption("circular-default-maxppm", "", 10)
+	attempts := glightning.NewIntOption("circular-default-attempts", "", 1)
+	alternates := glightning.NewIntOption("circular-max-alternate-outs", "", 0)
+	tightestHop := glightning.NewBoolOption("circular-exclude-tightest-hop", "", false)
+	reliability := glightning.NewIntOption("circular-reliability-weight", "", 0)
+	minHopCost := glightning.NewIntOption("circular-min-hop-cost", "", 0)
+	exploreRate := glightning.NewIntOption("circular-explore-rate", "", 0)
+	maxPPM.Val, attempts.Val = maxPPM.Default, attempts.Default
+	return map[string]glightning.Option{
+		maxPPM.Name:      maxPPM,
+		attempts.Name:    attempts,
+		alternates.Name:  alternates,
+		tightestHop.Name: tightestHop,
+		reliability.Name: reliability,
+		minHopCost.Name:  minHopCost,
+		exploreRate.Name: exploreRate,
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	resolved := func(options map[string]glightning.Option) map[string]interface{} {
+		result := make(map[string]interface{})
+		for name, option := range options {
+			result[name] = option.GetValue()
+		}
+		return result
+	}
+
+	options := newProfileOptions()
+	applied, err := ApplyProfile(PROFILE_NONE, options)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+	assert.Equal(t, 10, options["circular-default-maxppm"].GetValue())
+
+	options = newProfileOptions()
+	_, err = ApplyProfile(PROFILE_ECONOMICAL, options)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"circular-default-maxppm":       5,
+		"circular-default-attempts":     1,
+		"circular-max-alternate-outs":   0,
+		"circular-exclude-tightest-hop": false,
+		"circular-reliability-weight":   0,
+		"circular-min-hop-cost":         0,
+		"circular-explore-rate":         0,
+	}, resolved(options))
+
+	options = newProfileOptions()
+	applied, err = ApplyProfile(PROFILE_AGGRESSIVE, options)
+	assert.NoError(t, err)
+	assert.Len(t, applied, 7)
+	assert.Equal(t, map[string]interface{}{
+		"circular-default-maxppm":       100,
+		"circular-default-attempts":     5,
+		"circular-max-alternate-outs":   3,
+		"circular-exclude-tightest-hop": true,
+		"circular-reliability-weight":   1000,
+		"circular-min-hop-cost":         0,
+		"circular-explore-rate":         10,
+	}, resolved(options))
+
+	// an option set explicitly wins over the profile
+	options = newProfileOptions()
+	options["circular-default-maxppm"].(*glightning.IntOption).Val = 50
+	applied, err = ApplyProfile(PROFILE_AGGRESSIVE, options)
+	assert.NoError(t, err)
+	assert.NotContains(t, applied, "circular-default-maxppm")
+	assert.Equal(t, 50, options["circular-default-maxppm"].GetValue())
+	assert.Equal(t, 5, options["circular-default-attempts"].GetValue())
+
+	_, err = ApplyProfile("reckless", newProfileOptions())
+	assert.Equal(t, util.ErrInvalidProfile, err)
+}
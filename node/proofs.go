@@ -0,0 +1,132 @@
+package node
+
+import (
+	"bufio"
+	"circular/graph"
+	"circular/util"
+	"encoding/json"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	PROOFS_FILE = "proofs.jsonl"
+)
+
+// PaymentProof is what proves that a rebalance was paid: whoever knows the preimage of the hash paid it
+type PaymentProof struct {
+	PaymentHash string             `json:"payment_hash"`
+	Preimage    string             `json:"preimage"`
+	Route       *graph.PrettyRoute `json:"route"`
+	Timestamp   int64              `json:"timestamp"`
+}
+
+// proofsLock serializes the appends to PROOFS_FILE
+var proofsLock sync.Mutex
+
+// SaveProof appends the proof of the payment with hash to the data dir, if circular-save-preimages is enabled.
+// The file contains secrets, so it's readable only by the user running lightningd.
+func (n *Node) SaveProof(hash string, result *glightning.SendPayFields, route *graph.PrettyRoute) {
+	if !n.savePreimages {
+		return
+	}
+	if result == nil || result.PaymentPreimage == "" {
+		n.Logln(glightning.Unusual, "no preimage returned for the payment ", hash, ", not saving its proof")
+		return
+	}
+	proof := &PaymentProof{
+		PaymentHash: hash,
+		Preimage:    result.PaymentPreimage,
+		Route:       route,
+		Timestamp:   time.Now().Unix(),
+	}
+	if err := appendProof(CIRCULAR_DIR, proof); err != nil {
+		n.Logln(glightning.Unusual, "unable to save the proof of the payment ", hash, ": ", err)
+	}
+}
+
+func appendProof(dir string, proof *PaymentProof) error {
+	proofsLock.Lock()
+	defer proofsLock.Unlock()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(proof)
+	if err != nil {
+		return err
+	}
+	path := dir + "/" + PROOFS_FILE
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// the file could have been created with looser permissions
+	if err := f.Chmod(0600); err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// findProof looks for the proof of the payment with hash in the data dir, the latest if there are many
+func findProof(dir, hash string) (*PaymentProof, error) {
+	proofsLock.Lock()
+	defer proofsLock.Unlock()
+	f, err := os.Open(dir + "/" + PROOFS_FILE)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, util.ErrNoSuchPreimage
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var found *PaymentProof
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var proof PaymentProof
+		if err := json.Unmarshal(scanner.Bytes(), &proof); err != nil {
+			continue // a line cut by a crash
+		}
+		if proof.PaymentHash == hash {
+			found = &proof
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, util.ErrNoSuchPreimage
+	}
+	return found, nil
+}
+
+// Preimage returns the proof of payment of a successful rebalance by its payment hash
+type Preimage struct {
+	PaymentHash string `json:"hash"`
+}
+
+func (p *Preimage) Name() string {
+	return "circular-preimage"
+}
+
+func (p *Preimage) New() interface{} {
+	return &Preimage{}
+}
+
+func (p *Preimage) Call() (jrpc2.Result, error) {
+	if p.PaymentHash == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+	n := GetNode()
+	if !n.savePreimages {
+		return nil, util.ErrPreimagesNotSaved
+	}
+	return findProof(CIRCULAR_DIR, p.PaymentHash)
+}
@@ -0,0 +1,41 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestProofsRoundTrip(t *testing.T) {
+	dir := t.TempDir() + "/circular"
+
+	_, err := findProof(dir, "aa")
+	assert.Equal(t, util.ErrNoSuchPreimage, err)
+
+	assert.NoError(t, appendProof(dir, &PaymentProof{PaymentHash: "aa", Preimage: "01", Timestamp: 1}))
+	assert.NoError(t, appendProof(dir, &PaymentProof{PaymentHash: "bb", Preimage: "02", Timestamp: 2}))
+
+	proof, err := findProof(dir, "bb")
+	assert.NoError(t, err)
+	assert.Equal(t, "02", proof.Preimage)
+	assert.Equal(t, int64(2), proof.Timestamp)
+
+	_, err = findProof(dir, "cc")
+	assert.Equal(t, util.ErrNoSuchPreimage, err)
+
+	info, err := os.Stat(dir + "/" + PROOFS_FILE)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestProofsTightenPermissions(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(dir+"/"+PROOFS_FILE, nil, 0644))
+
+	assert.NoError(t, appendProof(dir, &PaymentProof{PaymentHash: "aa", Preimage: "01"}))
+
+	info, err := os.Stat(dir + "/" + PROOFS_FILE)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
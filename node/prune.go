@@ -0,0 +1,84 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"time"
+)
+
+const (
+	DEFAULT_BAN_DURATION = 60 // minutes
+)
+
+type PruneResult struct {
+	Scid string `json:"scid"`
+	// Pruned is the number of directions of the channel that were deleted from the graph
+	Pruned      int    `json:"pruned"`
+	BannedUntil string `json:"banned_until,omitempty"`
+}
+
+// PruneChannel deletes a channel known to be dead from the graph, until the next refresh
+type PruneChannel struct {
+	Scid string `json:"scid"`
+}
+
+func (p *PruneChannel) Name() string {
+	return "circular-prune-channel"
+}
+
+func (p *PruneChannel) New() interface{} {
+	return &PruneChannel{}
+}
+
+func (p *PruneChannel) Call() (jrpc2.Result, error) {
+	if p.Scid == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+	n := GetNode()
+	pruned := n.Graph.PruneChannel(p.Scid)
+	if pruned == 0 {
+		return nil, util.ErrChannelNotFound
+	}
+	n.Logln(glightning.Info, "pruned channel ", p.Scid, " from the graph")
+	return &PruneResult{
+		Scid:   p.Scid,
+		Pruned: pruned,
+	}, nil
+}
+
+// BanChannel deletes a channel from the graph and keeps it out of the refreshes for a while
+type BanChannel struct {
+	Scid     string `json:"scid"`
+	Duration int    `json:"minutes,omitempty"`
+}
+
+func (b *BanChannel) Name() string {
+	return "circular-ban-channel"
+}
+
+func (b *BanChannel) New() interface{} {
+	return &BanChannel{}
+}
+
+func (b *BanChannel) Call() (jrpc2.Result, error) {
+	if b.Scid == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+	if b.Duration < 0 {
+		return nil, util.ErrInvalidBanDuration
+	}
+	if b.Duration == 0 {
+		b.Duration = DEFAULT_BAN_DURATION
+	}
+	n := GetNode()
+	until := time.Now().Add(time.Duration(b.Duration) * time.Minute)
+	// the channel might not be in the graph yet, the ban keeps it out anyway
+	pruned := n.Graph.BanChannel(b.Scid, until)
+	n.Logln(glightning.Info, "banned channel ", b.Scid, " from the graph until ", until.Format(time.RFC3339))
+	return &PruneResult{
+		Scid:        b.Scid,
+		Pruned:      pruned,
+		BannedUntil: until.Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,129 @@
+package node
+
+import (
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"sync"
+	"time"
+)
+
+const (
+	REFRESH_DONE        = "refreshed"
+	REFRESH_IN_PROGRESS = "refresh already in progress"
+)
+
+type RefreshResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration,omitempty"`
+	Added    int    `json:"added"`
+	Removed  int    `json:"removed"`
+	Total    int    `json:"total"`
+	// Warning tells what went wrong in a part of the refresh that could be skipped, see refreshGraphThenAliases
+	Warning string `json:"warning,omitempty"`
+}
+
+func newRefreshResult(start time.Time, added, removed, total int) *RefreshResult {
+	return &RefreshResult{
+		Status:   REFRESH_DONE,
+		Duration: time.Since(start).String(),
+		Added:    added,
+		Removed:  removed,
+		Total:    total,
+	}
+}
+
+type RefreshGraph struct {
+	Wait bool `json:"wait,omitempty"`
+}
+
+func (r *RefreshGraph) Name() string {
+	return "circular-refresh-graph"
+}
+
+func (r *RefreshGraph) New() interface{} {
+	return &RefreshGraph{}
+}
+
+func (r *RefreshGraph) Call() (jrpc2.Result, error) {
+	return GetNode().tryRefreshGraphAndAliases(r.Wait)
+}
+
+// tryRefreshGraphAndAliases is the complete refresh expected from circular-refresh-graph: the channels, then the aliases.
+// The scheduled refreshes don't go through it: the cron jobs of the channels and of the aliases are separate,
+// so a failure of listnodes never affects the channels there.
+func (n *Node) tryRefreshGraphAndAliases(wait bool) (*RefreshResult, error) {
+	return refreshGraphThenAliases(
+		func() (*RefreshResult, error) { return n.tryRefreshGraph(wait) },
+		func() (*RefreshResult, error) { return n.tryRefreshAliases(wait) },
+		func(err error) {
+			n.Logln(glightning.Unusual, "aliases refresh failed, keeping the refreshed channels: ", err)
+		})
+}
+
+// refreshGraphThenAliases refreshes the channels, then the aliases. The aliases are only used for display:
+// when they can't be listed, the refreshed channels are kept, to be saved as usual, and the failure is
+// reported to onAliasesError and as a warning of the result. The aliases are refreshed again by the cron job.
+func refreshGraphThenAliases(refreshGraph, refreshAliases func() (*RefreshResult, error), onAliasesError func(error)) (*RefreshResult, error) {
+	result, err := refreshGraph()
+	if err != nil || result.Status != REFRESH_DONE {
+		return result, err
+	}
+	if _, err := refreshAliases(); err != nil {
+		onAliasesError(err)
+		result.Warning = "aliases not refreshed: " + err.Error()
+	}
+	return result, nil
+}
+
+type RefreshPeers struct {
+	Wait bool `json:"wait,omitempty"`
+}
+
+func (r *RefreshPeers) Name() string {
+	return "circular-refresh-peers"
+}
+
+func (r *RefreshPeers) New() interface{} {
+	return &RefreshPeers{}
+}
+
+func (r *RefreshPeers) Call() (jrpc2.Result, error) {
+	return GetNode().tryRefreshPeers(r.Wait)
+}
+
+// tryRefreshGraph refreshes the graph unless another refresh is running.
+// If wait is true, it waits for the running refresh to end and then refreshes again.
+func (n *Node) tryRefreshGraph(wait bool) (*RefreshResult, error) {
+	if !lockOrSkip(n.graphRefreshLock, wait) {
+		return &RefreshResult{Status: REFRESH_IN_PROGRESS}, nil
+	}
+	defer n.graphRefreshLock.Unlock()
+	return n.refreshGraph()
+}
+
+// tryRefreshAliases is the same as tryRefreshGraph, for the aliases of the nodes.
+// It has its own lock, so that aliases and channels can be refreshed at the same time.
+func (n *Node) tryRefreshAliases(wait bool) (*RefreshResult, error) {
+	if !lockOrSkip(n.aliasRefreshLock, wait) {
+		return &RefreshResult{Status: REFRESH_IN_PROGRESS}, nil
+	}
+	defer n.aliasRefreshLock.Unlock()
+	return n.refreshAliases()
+}
+
+// tryRefreshPeers is the same as tryRefreshGraph, for peers
+func (n *Node) tryRefreshPeers(wait bool) (*RefreshResult, error) {
+	if !lockOrSkip(n.peersRefreshLock, wait) {
+		return &RefreshResult{Status: REFRESH_IN_PROGRESS}, nil
+	}
+	defer n.peersRefreshLock.Unlock()
+	return n.refreshPeers()
+}
+
+func lockOrSkip(lock *sync.Mutex, wait bool) bool {
+	if wait {
+		lock.Lock()
+		return true
+	}
+	return lock.TryLock()
+}
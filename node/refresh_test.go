@@ -0,0 +1,85 @@
+package node
+
+import (
+	"circular/graph"
+	"encoding/json"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRefreshKeepsChannelsWhenListNodesFails(t *testing.T) {
+	a, b := "02"+strings.Repeat("aa", 32), "03"+strings.Repeat("bb", 32)
+	n := newMockNode(t, map[string]rpcHandler{
+		"listchannels": func(params json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{"channels": []*glightning.Channel{{
+				Source:         a,
+				Destination:    b,
+				ShortChannelId: "1x1x1",
+				Satoshis:       1000000,
+				AmountMsat:     "1000000000msat",
+				IsActive:       true,
+				LastUpdate:     uint(time.Now().Unix()),
+			}}}, nil
+		},
+		"listnodes": func(params json.RawMessage) (interface{}, error) {
+			return nil, errors.New("connection reset")
+		},
+	})
+	n.Graph = graph.NewGraph()
+	n.RouteOptions = graph.NewRouteOptions()
+	n.graphRefreshLock = &sync.Mutex{}
+	n.aliasRefreshLock = &sync.Mutex{}
+	n.healthLock = &sync.RWMutex{}
+
+	// the refresh of the channels is kept and the failure of the aliases is only a warning
+	result, err := n.tryRefreshGraphAndAliases(true)
+	assert.NoError(t, err)
+	assert.Equal(t, REFRESH_DONE, result.Status)
+	assert.Equal(t, 1, result.Added)
+	assert.Contains(t, result.Warning, "connection reset")
+
+	// and the graph is saved with the refreshed channels
+	dir := t.TempDir()
+	assert.NoError(t, n.SaveGraphToFile(dir, graph.FILE))
+	saved, _, _, err := readGraphFile(dir, graph.FILE, true)
+	assert.NoError(t, err)
+	assert.Contains(t, saved.Channels, "1x1x1/0")
+}
+
+func TestRefreshGraphThenAliasesFailsWithTheChannels(t *testing.T) {
+	listErr := errors.New("listchannels: connection reset")
+	_, err := refreshGraphThenAliases(func() (*RefreshResult, error) { return nil, listErr },
+		func() (*RefreshResult, error) { t.Fatal("aliases refreshed after a failed refresh"); return nil, nil },
+		func(err error) {})
+	assert.Equal(t, listErr, err)
+}
+
+func TestRefreshPeersRemovesForgottenPeers(t *testing.T) {
+	a, b, c := "02"+strings.Repeat("aa", 32), "03"+strings.Repeat("bb", 32), "02"+strings.Repeat("cc", 32)
+	n := newMockNode(t, map[string]rpcHandler{
+		"listpeers": func(params json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{"peers": []*glightning.Peer{{Id: b}, {Id: c}}}, nil
+		},
+	})
+	n.Id = a
+	n.Graph = graph.NewGraph()
+	n.PeersLock = &sync.RWMutex{}
+	n.peersRefreshLock = &sync.Mutex{}
+	n.Peers = map[string]*glightning.Peer{
+		b:                               {Id: b},
+		"03" + strings.Repeat("dd", 32): {Id: "03" + strings.Repeat("dd", 32)},
+	}
+
+	result, err := n.tryRefreshPeers(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, 2, result.Total)
+	assert.Contains(t, n.Peers, b)
+	assert.Contains(t, n.Peers, c)
+}
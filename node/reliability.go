@@ -0,0 +1,88 @@
+package node
+
+import (
+	"circular/graph"
+	"github.com/elementsproject/glightning/jrpc2"
+)
+
+type Reliability struct {
+	Id string `json:"id,omitempty"`
+}
+
+func (r *Reliability) Name() string {
+	return "circular-reliability"
+}
+
+func (r *Reliability) New() interface{} {
+	return &Reliability{}
+}
+
+func (r *Reliability) Call() (jrpc2.Result, error) {
+	scores := GetNode().Graph.GetReliability()
+	if r.Id == "" {
+		return scores, nil
+	}
+	for _, score := range scores {
+		if score.Id == r.Id {
+			return []graph.NodeScore{score}, nil
+		}
+	}
+	return []graph.NodeScore{}, nil
+}
+
+// ERRING_INDEX_UNKNOWN is the erring index of the failures that don't tell which hop failed
+const ERRING_INDEX_UNKNOWN = -1
+
+// erringIndex returns the position in the route of the node that failed a payment, 0 being our own node
+func erringIndex(erringNode string, index uint64) int {
+	if erringNode == "" {
+		return ERRING_INDEX_UNKNOWN
+	}
+	return int(index)
+}
+
+// trackRoute remembers the intermediate nodes of a payment, to score them when it resolves.
+// It must be called before sending the payment, so that it's tracked before any notification about it.
+func (n *Node) trackRoute(paymentHash string, route *graph.Route) {
+	nodes := make([]string, 0, len(route.Hops))
+	// the destination of the last hop is ourselves
+	for _, hop := range route.Hops[:len(route.Hops)-1] {
+		nodes = append(nodes, hop.Destination)
+	}
+
+	n.hashesLock.Lock()
+	defer n.hashesLock.Unlock()
+	n.inFlightRoutes[paymentHash] = nodes
+}
+
+// forgetRoute stops tracking a payment whose outcome can't be scored
+func (n *Node) forgetRoute(paymentHash string) {
+	n.hashesLock.Lock()
+	defer n.hashesLock.Unlock()
+	delete(n.inFlightRoutes, paymentHash)
+}
+
+// recordOutcome scores the nodes of a resolved payment, once: it's called both when sendpay returns
+// and when its notification comes. On a failure, the nodes before the one at erringIndex in the route
+// forwarded the payment, that one failed it, and the ones after it were never reached. Index 0 is our
+// own node, so nobody is credited when the first hop fails, and nothing is recorded when the index is unknown.
+func (n *Node) recordOutcome(paymentHash string, erringIndex int, success bool) {
+	n.hashesLock.Lock()
+	nodes, ok := n.inFlightRoutes[paymentHash]
+	delete(n.inFlightRoutes, paymentHash)
+	n.hashesLock.Unlock()
+	if !ok || (!success && erringIndex == ERRING_INDEX_UNKNOWN) {
+		return
+	}
+
+	for i, id := range nodes {
+		// the first intermediate node is at index 1 of the route
+		if !success && i+1 >= erringIndex {
+			if i+1 == erringIndex {
+				n.Graph.RecordOutcome(id, false)
+			}
+			return
+		}
+		n.Graph.RecordOutcome(id, true)
+	}
+}
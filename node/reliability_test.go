@@ -0,0 +1,54 @@
+package node
+
+import (
+	"circular/graph"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestRecordOutcomeOnlyCreditsForwardingNodes(t *testing.T) {
+	// a route through b, c and d, back to ourselves at index 4
+	route := &graph.Route{}
+	for _, id := range []string{"b", "c", "d", "self"} {
+		route.Hops = append(route.Hops, graph.RouteHop{
+			Channel: &graph.Channel{Channel: &glightning.Channel{Destination: id}},
+		})
+	}
+	outcomes := func(erringIndex int, success bool) map[string][2]float64 {
+		n := &Node{
+			Graph:          graph.NewGraph(),
+			hashesLock:     &sync.Mutex{},
+			inFlightRoutes: make(map[string][]string),
+		}
+		n.trackRoute("hash", route)
+		n.recordOutcome("hash", erringIndex, success)
+		// a second outcome for the same payment, from its notification, is ignored
+		n.recordOutcome("hash", erringIndex, success)
+		assert.Empty(t, n.inFlightRoutes)
+
+		result := make(map[string][2]float64)
+		for _, score := range n.Graph.GetReliability() {
+			// the outcomes decay from the moment they are recorded
+			result[score.Id] = [2]float64{math.Round(score.Attempts), math.Round(score.Successes)}
+		}
+		return result
+	}
+
+	assert.Equal(t, map[string][2]float64{"b": {1, 1}, "c": {1, 1}, "d": {1, 1}},
+		outcomes(ERRING_INDEX_UNKNOWN, true))
+
+	// c failed: b forwarded, d was never reached
+	assert.Equal(t, map[string][2]float64{"b": {1, 1}, "c": {1, 0}}, outcomes(2, false))
+
+	// our own node failed the first hop: nobody forwarded
+	assert.Empty(t, outcomes(0, false))
+
+	// we failed it at the end: everybody forwarded
+	assert.Equal(t, map[string][2]float64{"b": {1, 1}, "c": {1, 1}, "d": {1, 1}}, outcomes(4, false))
+
+	// nobody knows who failed
+	assert.Empty(t, outcomes(ERRING_INDEX_UNKNOWN, false))
+}
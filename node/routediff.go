@@ -0,0 +1,129 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/jrpc2"
+	"time"
+)
+
+const (
+	DEFAULT_ROUTE_DIFF_AMOUNT  = 200000 // sats
+	DEFAULT_ROUTE_DIFF_MAXHOPS = 8
+	// ROUTE_DIFF_TIMEOUT bounds the time given to each router
+	ROUTE_DIFF_TIMEOUT = 10 * time.Second
+)
+
+// RouteDiff runs two routers for the same query and compares their routes, without paying
+type RouteDiff struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Amount      uint64 `json:"amount,omitempty"`
+	A           string `json:"a,omitempty"`
+	B           string `json:"b,omitempty"`
+	MaxHops     int    `json:"maxhops,omitempty"`
+}
+
+// RouterResult is the route found by a router, or why it didn't find one
+type RouterResult struct {
+	Router   string             `json:"router"`
+	Route    *graph.PrettyRoute `json:"route,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Duration string             `json:"duration"`
+}
+
+type RouteDiffResult struct {
+	Amount uint64           `json:"amount"`
+	A      *RouterResult    `json:"a"`
+	B      *RouterResult    `json:"b"`
+	Diff   *graph.RouteDiff `json:"diff,omitempty"`
+}
+
+func (r *RouteDiff) Name() string {
+	return "circular-route-diff"
+}
+
+func (r *RouteDiff) New() interface{} {
+	return &RouteDiff{}
+}
+
+func (r *RouteDiff) Call() (jrpc2.Result, error) {
+	if r.Source == "" || r.Destination == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+	if r.Amount == 0 {
+		r.Amount = DEFAULT_ROUTE_DIFF_AMOUNT
+	}
+	if r.A == "" {
+		r.A = graph.ROUTER_DIJKSTRA
+	}
+	if r.B == "" {
+		r.B = graph.ROUTER_PROBABILITY
+	}
+	if r.MaxHops == 0 {
+		r.MaxHops = DEFAULT_ROUTE_DIFF_MAXHOPS
+	}
+	routerA, err := graph.GetRouter(r.A)
+	if err != nil {
+		return nil, err
+	}
+	routerB, err := graph.GetRouter(r.B)
+	if err != nil {
+		return nil, err
+	}
+
+	n := GetNode()
+	defer util.TimeTrack(time.Now(), "node.RouteDiff", n.Logf)
+	// like the rebalances, our node is not an intermediate hop
+	exclude := make(map[string]bool)
+	if n.Id != r.Source && n.Id != r.Destination {
+		exclude[n.Id] = true
+	}
+	search := func(router graph.Router) (*graph.Route, error) {
+		return router(n.Graph, r.Source, r.Destination, r.Amount*1000, exclude, r.MaxHops, n.RouteOptions)
+	}
+
+	a, routeA := runRouter(r.A, search, routerA, ROUTE_DIFF_TIMEOUT)
+	b, routeB := runRouter(r.B, search, routerB, ROUTE_DIFF_TIMEOUT)
+	result := &RouteDiffResult{
+		Amount: r.Amount,
+		A:      a,
+		B:      b,
+	}
+	if routeA != nil && routeB != nil {
+		result.Diff = graph.DiffRoutes(routeA, routeB)
+	}
+	return result, nil
+}
+
+// runRouter searches a route with router, giving up after timeout. The search can't be interrupted:
+// after the timeout it goes on in the background, and its route is discarded.
+func runRouter(name string, search func(graph.Router) (*graph.Route, error), router graph.Router,
+	timeout time.Duration) (*RouterResult, *graph.Route) {
+	type outcome struct {
+		route *graph.Route
+		err   error
+	}
+	start := time.Now()
+	done := make(chan outcome, 1)
+	go func() {
+		route, err := search(router)
+		done <- outcome{route, err}
+	}()
+
+	result := &RouterResult{Router: name}
+	select {
+	case o := <-done:
+		result.Duration = time.Since(start).String()
+		if o.err != nil {
+			result.Error = o.err.Error()
+			return result, nil
+		}
+		result.Route = graph.NewPrettyRoute(o.route, "")
+		return result, o.route
+	case <-time.After(timeout):
+		result.Duration = timeout.String()
+		result.Error = util.ErrRouterTimeout.Error()
+		return result, nil
+	}
+}
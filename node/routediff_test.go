@@ -0,0 +1,28 @@
+package node
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRunRouterTimesOut(t *testing.T) {
+	release := make(chan bool)
+	defer close(release)
+	slow := func(graph.Router) (*graph.Route, error) {
+		<-release
+		return nil, util.ErrNoRoute
+	}
+	result, route := runRouter(graph.ROUTER_DIJKSTRA, slow, nil, 10*time.Millisecond)
+	assert.Nil(t, route)
+	assert.Equal(t, util.ErrRouterTimeout.Error(), result.Error)
+
+	failing := func(graph.Router) (*graph.Route, error) {
+		return nil, util.ErrNoRoute
+	}
+	result, route = runRouter(graph.ROUTER_DIJKSTRA, failing, nil, time.Second)
+	assert.Nil(t, route)
+	assert.Equal(t, util.ErrNoRoute.Error(), result.Error)
+}
@@ -0,0 +1,93 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// ROUTE_MEMORY_SIZE is the number of routes remembered for each pair of channels
+	ROUTE_MEMORY_SIZE = 3
+)
+
+type rememberedRoute struct {
+	scids  []string
+	usedAt time.Time
+	// uses is the number of rebalances of the pair that will still avoid the route
+	uses int
+}
+
+// routeMemory remembers the last routes used by the successful rebalances of each pair of channels
+type routeMemory struct {
+	lock   *sync.Mutex
+	routes map[string][]*rememberedRoute
+}
+
+func newRouteMemory() *routeMemory {
+	return &routeMemory{
+		lock:   &sync.Mutex{},
+		routes: make(map[string][]*rememberedRoute),
+	}
+}
+
+func (m *routeMemory) remember(pair string, scids []string, now time.Time, uses int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	routes := append(m.routes[pair], &rememberedRoute{scids: scids, usedAt: now, uses: uses})
+	if len(routes) > ROUTE_MEMORY_SIZE {
+		routes = routes[len(routes)-ROUTE_MEMORY_SIZE:]
+	}
+	m.routes[pair] = routes
+}
+
+// recall returns the channels of the routes remembered for pair, weighted from 1 for a route just used
+// down to 0 for one used decay ago, and counts a use of each route. The routes that were used too long
+// ago or that were already avoided enough times are forgotten.
+func (m *routeMemory) recall(pair string, now time.Time, decay time.Duration) map[string]float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	channels := make(map[string]float64)
+	kept := make([]*rememberedRoute, 0, len(m.routes[pair]))
+	for _, route := range m.routes[pair] {
+		age := now.Sub(route.usedAt)
+		if age >= decay || route.uses <= 0 {
+			continue
+		}
+		weight := 1 - float64(age)/float64(decay)
+		for _, scid := range route.scids {
+			if weight > channels[scid] {
+				channels[scid] = weight
+			}
+		}
+		route.uses--
+		kept = append(kept, route)
+	}
+	if len(kept) == 0 {
+		delete(m.routes, pair)
+	} else {
+		m.routes[pair] = kept
+	}
+	return channels
+}
+
+func routePair(out, in string) string {
+	return out + "/" + in
+}
+
+// RememberRoute remembers the channels of the route used by a successful rebalance from out to in,
+// so that the next rebalances of the same pair prefer other routes, see circular-route-memory
+func (n *Node) RememberRoute(out, in string, scids []string) {
+	if n.RouteMemory <= 0 {
+		return
+	}
+	n.routeMemory.remember(routePair(out, in), scids, time.Now(), n.RouteMemory)
+}
+
+// RecallRoutes returns the channels of the last routes used from out to in, by how much they should be
+// avoided, and counts a rebalance of the pair. It's empty when the memory is disabled.
+func (n *Node) RecallRoutes(out, in string) map[string]float64 {
+	if n.RouteMemory <= 0 {
+		return nil
+	}
+	return n.routeMemory.recall(routePair(out, in), time.Now(), n.RouteMemoryDecay)
+}
@@ -0,0 +1,39 @@
+package node
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRouteMemory(t *testing.T) {
+	memory := newRouteMemory()
+	now := time.Now()
+	decay := time.Hour
+
+	assert.Empty(t, memory.recall("out/in", now, decay))
+
+	memory.remember("out/in", []string{"1x1x1", "2x2x2"}, now, 2)
+	memory.remember("out/in", []string{"2x2x2", "3x3x3"}, now.Add(-30*time.Minute), 2)
+
+	// the most recent use of a channel counts, and the weight fades with the age
+	channels := memory.recall("out/in", now, decay)
+	assert.Equal(t, 1.0, channels["1x1x1"])
+	assert.Equal(t, 1.0, channels["2x2x2"])
+	assert.InDelta(t, 0.5, channels["3x3x3"], 0.01)
+	assert.Empty(t, memory.recall("other/in", now, decay))
+
+	// the second rebalance of the pair is the last one to avoid the routes
+	assert.Len(t, memory.recall("out/in", now, decay), 3)
+	assert.Empty(t, memory.recall("out/in", now, decay))
+
+	// routes are forgotten after the decay, and only the last ones are kept
+	memory.remember("out/in", []string{"1x1x1"}, now.Add(-2*time.Hour), 5)
+	assert.Empty(t, memory.recall("out/in", now, decay))
+	for i := 0; i < ROUTE_MEMORY_SIZE+1; i++ {
+		memory.remember("out/in", []string{string(rune('a' + i))}, now, 5)
+	}
+	channels = memory.recall("out/in", now, decay)
+	assert.Len(t, channels, ROUTE_MEMORY_SIZE)
+	assert.NotContains(t, channels, "a")
+}
@@ -0,0 +1,38 @@
+package node
+
+import (
+	"circular/graph"
+	"github.com/elementsproject/glightning/glightning"
+)
+
+// saveGraph saves the graph to file if it changed since the last save: refreshes, pruning and the
+// liquidity learned from payment outcomes all change the version of the graph
+func (n *Node) saveGraph() {
+	n.saveLock.Lock()
+	defer n.saveLock.Unlock()
+
+	if n.maxStateSize > 0 {
+		n.saveStateWithin(CIRCULAR_DIR, n.maxStateSize)
+		return
+	}
+
+	if n.persistAliases {
+		if err := n.saveAliases(CIRCULAR_DIR); err != nil {
+			n.Logf(glightning.Unusual, "error saving aliases to file: %+v", err)
+		}
+	}
+
+	version := n.Graph.Version()
+	if version == n.savedGraphVersion {
+		n.Logln(glightning.Debug, "graph unchanged since the last save, skipping")
+		return
+	}
+
+	n.Logln(glightning.Debug, "saving graph to file")
+	if err := n.SaveGraphToFile(CIRCULAR_DIR, graph.FILE); err != nil {
+		n.Logf(glightning.Unusual, "error saving graph to file: %+v", err)
+		return
+	}
+	// changes made while saving might not be in the file, they will be saved next time
+	n.savedGraphVersion = version
+}
@@ -0,0 +1,170 @@
+package node
+
+import (
+	"circular/util"
+	"fmt"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/elementsproject/glightning/glightning"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SPEND_CAP_WINDOW is the rolling window over which the fees are added up for circular-daily-fee-cap
+	SPEND_CAP_WINDOW = 24 * time.Hour
+	// SPEND_PREFIX is the prefix of the fees paid, saved so that a restart doesn't reset the window
+	SPEND_PREFIX = "spend_"
+)
+
+type SpendCapStatus struct {
+	Cap       uint64 `json:"cap_msat"`
+	Spent     uint64 `json:"spent_msat"`
+	Remaining uint64 `json:"remaining_msat"`
+	// ResetsIn is how long until enough fees leave the window to allow new rebalances, when the cap is hit
+	ResetsIn int64 `json:"resets_in_seconds,omitempty"`
+}
+
+type feeSpend struct {
+	at  time.Time
+	fee uint64
+}
+
+// feeSpends remembers the fees paid by the successful rebalances during the last SPEND_CAP_WINDOW, oldest first
+type feeSpends struct {
+	lock   *sync.Mutex
+	spends []feeSpend
+}
+
+func newFeeSpends() *feeSpends {
+	return &feeSpends{
+		lock:   &sync.Mutex{},
+		spends: make([]feeSpend, 0),
+	}
+}
+
+func (s *feeSpends) add(now time.Time, fee uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.spends = append(s.spends, feeSpend{at: now, fee: fee})
+}
+
+// status adds up the fees paid in the window ending at now, forgetting the older ones.
+// When the total reaches limit, it also tells when it will go back under it.
+func (s *feeSpends) status(now time.Time, limit uint64) SpendCapStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for len(s.spends) > 0 && now.Sub(s.spends[0].at) >= SPEND_CAP_WINDOW {
+		s.spends = s.spends[1:]
+	}
+
+	result := SpendCapStatus{Cap: limit}
+	for _, spend := range s.spends {
+		result.Spent += spend.fee
+	}
+	if limit == 0 {
+		return result
+	}
+	if result.Spent < limit {
+		result.Remaining = limit - result.Spent
+		return result
+	}
+	// the oldest fees leave the window first
+	spent := result.Spent
+	for _, spend := range s.spends {
+		spent -= spend.fee
+		if spent < limit {
+			result.ResetsIn = int64(spend.at.Add(SPEND_CAP_WINDOW).Sub(now).Seconds())
+			break
+		}
+	}
+	return result
+}
+
+// RecordFee adds the fee (msat) of a successful rebalance to the daily spend, and saves it in the db
+func (n *Node) RecordFee(fee uint64) {
+	now := time.Now()
+	n.spends.add(now, fee)
+	if err := n.DB.Set(spendKey(now), []byte(strconv.FormatUint(fee, 10))); err != nil {
+		n.Logln(glightning.Unusual, "error saving the fee of a rebalance: ", err)
+	}
+}
+
+// spendKey is the key of a fee paid at, padded so that the keys are sorted by time
+func spendKey(at time.Time) string {
+	return fmt.Sprintf("%s%020d", SPEND_PREFIX, at.UnixNano())
+}
+
+// ListFeeSpends returns the fees saved by RecordFee since since, oldest first
+func (s *Store) ListFeeSpends(since time.Time) ([]feeSpend, error) {
+	result := make([]feeSpend, 0)
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(SPEND_PREFIX)
+		for it.Seek([]byte(spendKey(since))); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			nanos, err := strconv.ParseInt(strings.TrimPrefix(string(item.Key()), SPEND_PREFIX), 10, 64)
+			if err != nil {
+				return err
+			}
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			fee, err := strconv.ParseUint(string(v), 10, 64)
+			if err != nil {
+				return err
+			}
+			result = append(result, feeSpend{at: time.Unix(0, nanos), fee: fee})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// loadFeeSpends restores the fees paid during the last SPEND_CAP_WINDOW from the db
+func (n *Node) loadFeeSpends() {
+	spends, err := n.DB.ListFeeSpends(time.Now().Add(-SPEND_CAP_WINDOW))
+	if err != nil {
+		n.Logln(glightning.Unusual, "error loading the fees paid in the last 24h: ", err)
+		return
+	}
+	n.spends.lock.Lock()
+	defer n.spends.lock.Unlock()
+	n.spends.spends = spends
+}
+
+func (n *Node) GetSpendCap() SpendCapStatus {
+	return n.spends.status(time.Now(), n.DailyFeeCap)
+}
+
+// CheckSpendCap fails if the fees paid during the last day reached circular-daily-fee-cap
+func (n *Node) CheckSpendCap() error {
+	if n.DailyFeeCap == 0 {
+		return nil
+	}
+	status := n.GetSpendCap()
+	if status.Remaining > 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d msat spent in the last 24h out of %d msat, new rebalances are allowed again in %s",
+		util.ErrDailyFeeCapReached, status.Spent, status.Cap, time.Duration(status.ResetsIn)*time.Second)
+}
+
+// CheckAttemptFee fails if paying fee (msat) would go over what is left of circular-daily-fee-cap
+func (n *Node) CheckAttemptFee(fee uint64) error {
+	if n.DailyFeeCap == 0 {
+		return nil
+	}
+	status := n.GetSpendCap()
+	if fee <= status.Remaining {
+		return nil
+	}
+	return fmt.Errorf("%w: the route costs %d msat, only %d msat are left out of %d msat",
+		util.ErrDailyFeeCapReached, fee, status.Remaining, status.Cap)
+}
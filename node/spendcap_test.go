@@ -0,0 +1,59 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFeeSpendsRollingWindow(t *testing.T) {
+	s := newFeeSpends()
+	now := time.Unix(1700000000, 0)
+	limit := uint64(10000)
+
+	s.add(now, 4000)
+	s.add(now.Add(2*time.Hour), 3000)
+	status := s.status(now.Add(3*time.Hour), limit)
+	assert.Equal(t, uint64(7000), status.Spent)
+	assert.Equal(t, uint64(3000), status.Remaining)
+	assert.Equal(t, int64(0), status.ResetsIn)
+
+	// the cap is hit: the first spend must leave the window to go back under it
+	s.add(now.Add(4*time.Hour), 5000)
+	status = s.status(now.Add(5*time.Hour), limit)
+	assert.Equal(t, uint64(12000), status.Spent)
+	assert.Equal(t, uint64(0), status.Remaining)
+	assert.Equal(t, int64((19 * time.Hour).Seconds()), status.ResetsIn)
+
+	// after a day the first spend is forgotten
+	status = s.status(now.Add(SPEND_CAP_WINDOW), limit)
+	assert.Equal(t, uint64(8000), status.Spent)
+	assert.Equal(t, uint64(2000), status.Remaining)
+
+	// without a cap the spend is still reported
+	status = s.status(now.Add(SPEND_CAP_WINDOW), 0)
+	assert.Equal(t, uint64(8000), status.Spent)
+	assert.Equal(t, uint64(0), status.Remaining)
+}
+
+func TestFeeSpendsSurviveRestart(t *testing.T) {
+	db := NewDB(t.TempDir())
+	n := &Node{DB: db, spends: newFeeSpends(), DailyFeeCap: 10000}
+	// an old fee, out of the window
+	assert.NoError(t, db.Set(spendKey(time.Now().Add(-SPEND_CAP_WINDOW-time.Hour)), []byte("5000")))
+	n.RecordFee(4000)
+	n.RecordFee(3000)
+
+	restarted := &Node{DB: db, spends: newFeeSpends(), DailyFeeCap: 10000}
+	restarted.loadFeeSpends()
+	status := restarted.GetSpendCap()
+	assert.Equal(t, uint64(7000), status.Spent)
+	assert.Equal(t, uint64(3000), status.Remaining)
+
+	// an attempt can't pay more than what is left
+	assert.NoError(t, restarted.CheckAttemptFee(3000))
+	assert.ErrorIs(t, restarted.CheckAttemptFee(3001), util.ErrDailyFeeCapReached)
+	restarted.DailyFeeCap = 0
+	assert.NoError(t, restarted.CheckAttemptFee(3001))
+}
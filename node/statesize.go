@@ -0,0 +1,162 @@
+package node
+
+import (
+	"circular/graph"
+	"encoding/json"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+	"os"
+	"time"
+)
+
+// stateSizes are the sizes, in bytes, of the files that circular keeps: the graph, its previous version,
+// the aliases and the fee history
+type stateSizes struct {
+	Graph    int64
+	Previous int64
+	Aliases  int64
+	History  int64
+}
+
+// stateTrim is what has to go to keep the files under circular-max-state-size
+type stateTrim struct {
+	Aliases bool
+	// History compacts the fee history to the current fees, see trimFeeHistory
+	History  bool
+	Previous bool
+	// GraphLimit is the size the graph has to fit in, leaving out the oldest liquidity beliefs, 0 if it fits already
+	GraphLimit int64
+}
+
+// planStateTrim decides what to leave out of the files so that they fit in maxSize: first the aliases, which are
+// only used for display, then the fee history, then the previous version of the graph and last the oldest
+// liquidity beliefs. The fee history is counted as if it went away entirely, the few lines that are left
+// are taken from the size of the graph, see saveStateWithin.
+func planStateTrim(sizes stateSizes, maxSize int64) stateTrim {
+	var trim stateTrim
+	total := sizes.Graph + sizes.Previous + sizes.Aliases + sizes.History
+	if total <= maxSize {
+		return trim
+	}
+	trim.Aliases = sizes.Aliases > 0
+	total -= sizes.Aliases
+	if total <= maxSize {
+		return trim
+	}
+	trim.History = sizes.History > 0
+	total -= sizes.History
+	if total <= maxSize {
+		return trim
+	}
+	trim.Previous = sizes.Previous > 0
+	total -= sizes.Previous
+	if total <= maxSize {
+		return trim
+	}
+	trim.GraphLimit = maxSize
+	return trim
+}
+
+// fileSize is the size of the file at path, 0 if it doesn't exist
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// removeFile removes the file at path and tells whether there was one
+func (n *Node) removeFile(path string) bool {
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		n.Logln(glightning.Unusual, "error removing ", path, ": ", err)
+	}
+	return err == nil
+}
+
+// trimFeeHistory drops the fee history older than now, keeping only the current fee of every direction.
+// It returns the size of the fee history that is left.
+func (n *Node) trimFeeHistory(now time.Time) int64 {
+	dropped, err := n.feeHistory.pruneBefore(now.Unix())
+	if err != nil {
+		n.Logln(glightning.Unusual, "unable to trim the fee history: ", err)
+	} else {
+		n.Logln(glightning.Unusual, "trimmed the fee history to the current fees, ", dropped, " lines")
+	}
+	return fileSize(n.feeHistory.dir + "/" + FEE_HISTORY_FILE)
+}
+
+// saveStateWithin saves the graph and the aliases to dir like saveGraph does, trimming them to fit in maxSize
+// bytes together with the previous version of the graph and the fee history, see planStateTrim.
+// The caller must hold saveLock.
+func (n *Node) saveStateWithin(dir string, maxSize int64) {
+	graphPath := dir + "/" + graph.FILE
+	sizes := stateSizes{Graph: fileSize(graphPath), Previous: fileSize(graphPath + ".old")}
+	if n.feeHistory != nil {
+		sizes.History = fileSize(n.feeHistory.dir + "/" + FEE_HISTORY_FILE)
+	}
+
+	version := n.Graph.Version()
+	var data []byte
+	if version != n.savedGraphVersion {
+		var err error
+		if data, err = n.marshalGraph(); err != nil {
+			n.Logf(glightning.Unusual, "error saving graph to file: %+v", err)
+			return
+		}
+		// the current file becomes the previous version
+		sizes.Graph, sizes.Previous = int64(len(data)), sizes.Graph
+	}
+	if n.persistAliases {
+		aliases, err := json.Marshal(n.Graph.GetAliases())
+		if err == nil {
+			sizes.Aliases = int64(len(aliases))
+		}
+	}
+
+	trim := planStateTrim(sizes, maxSize)
+	if trim.Aliases || trim.History || trim.Previous || trim.GraphLimit > 0 {
+		n.Logf(glightning.Unusual, "the state files take %d bytes, over circular-max-state-size of %d bytes",
+			sizes.Graph+sizes.Previous+sizes.Aliases+sizes.History, maxSize)
+	}
+	if trim.Aliases {
+		if n.removeFile(dir + "/" + ALIASES_FILE) {
+			n.Logln(glightning.Unusual, "trimmed the aliases, ", sizes.Aliases, " bytes")
+		}
+		// they will be saved again once they fit
+		n.savedAliasesVersion = 0
+	} else if n.persistAliases {
+		if err := n.saveAliases(dir); err != nil {
+			n.Logf(glightning.Unusual, "error saving aliases to file: %+v", err)
+		}
+	}
+	history := sizes.History
+	if trim.History {
+		history = n.trimFeeHistory(time.Now())
+	}
+	if trim.Previous && n.removeFile(graphPath+".old") {
+		n.Logln(glightning.Unusual, "trimmed the previous version of the graph, ", sizes.Previous, " bytes")
+	}
+	if trim.GraphLimit > 0 {
+		trimmed, dropped, err := n.Graph.MarshalWithin(trim.GraphLimit - history)
+		if err != nil {
+			n.Logf(glightning.Unusual, "error saving graph to file: %+v", err)
+			return
+		}
+		n.Logln(glightning.Unusual, "trimmed the liquidity beliefs of the ", dropped, " channels learned the longest ago, ",
+			sizes.Graph-int64(len(trimmed)), " bytes")
+		data = trimmed
+	}
+	if data == nil {
+		n.Logln(glightning.Debug, "graph unchanged since the last save, skipping")
+		return
+	}
+
+	n.Logln(glightning.Debug, "saving graph to file")
+	if err := replaceGraphFile(dir, graph.FILE, data, !trim.Previous); err != nil {
+		n.Logf(glightning.Unusual, "error saving graph to file: %+v", err)
+		return
+	}
+	n.savedGraphVersion = version
+}
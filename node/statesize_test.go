@@ -0,0 +1,32 @@
+package node
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPlanStateTrim(t *testing.T) {
+	sizes := stateSizes{Graph: 600, Previous: 500, Aliases: 200}
+
+	// everything fits
+	assert.Equal(t, stateTrim{}, planStateTrim(sizes, 1300))
+	// the aliases go first
+	assert.Equal(t, stateTrim{Aliases: true}, planStateTrim(sizes, 1200))
+	assert.Equal(t, stateTrim{Aliases: true}, planStateTrim(sizes, 1100))
+	// then the previous version of the graph
+	assert.Equal(t, stateTrim{Aliases: true, Previous: true}, planStateTrim(sizes, 1000))
+	assert.Equal(t, stateTrim{Aliases: true, Previous: true}, planStateTrim(sizes, 600))
+	// and last the graph itself
+	assert.Equal(t, stateTrim{Aliases: true, Previous: true, GraphLimit: 599}, planStateTrim(sizes, 599))
+
+	// missing files are not trimmed
+	assert.Equal(t, stateTrim{Previous: true}, planStateTrim(stateSizes{Graph: 600, Previous: 500}, 1000))
+	assert.Equal(t, stateTrim{GraphLimit: 500}, planStateTrim(stateSizes{Graph: 600}, 500))
+
+	// the fee history goes after the aliases and before the previous version of the graph
+	sizes.History = 300
+	assert.Equal(t, stateTrim{Aliases: true}, planStateTrim(sizes, 1400))
+	assert.Equal(t, stateTrim{Aliases: true, History: true}, planStateTrim(sizes, 1100))
+	assert.Equal(t, stateTrim{Aliases: true, History: true, Previous: true}, planStateTrim(sizes, 1000))
+	assert.Equal(t, stateTrim{Aliases: true, History: true, Previous: true, GraphLimit: 599}, planStateTrim(sizes, 599))
+}
@@ -10,10 +10,20 @@ import (
 )
 
 type Stats struct {
-	GraphStats *graph.Stats                `json:"graph_stats"`
-	Successes  []glightning.SendPaySuccess `json:"successes"`
-	Failures   []glightning.SendPayFailure `json:"failures"`
-	Routes     []graph.PrettyRoute         `json:"routes"`
+	GraphStats  *graph.Stats                `json:"graph_stats"`
+	GraphHealth *GraphHealth                `json:"graph_health"`
+	Successes   []glightning.SendPaySuccess `json:"successes"`
+	Failures    []glightning.SendPayFailure `json:"failures"`
+	Routes      []graph.PrettyRoute         `json:"routes"`
+	StuckHtlcs  []StuckHtlc                 `json:"stuck_htlcs"`
+	Cooldowns   []CooldownStatus            `json:"cooldowns"`
+	SpendCap    SpendCapStatus              `json:"daily_fee_cap"`
+	// CapacityFiltered is the number of public channels outside the capacity range of the searches
+	CapacityFiltered int `json:"capacity_filtered_channels"`
+	// DelayFiltered is the number of public channels with a delay over the maximum of the searches
+	DelayFiltered int `json:"delay_filtered_channels"`
+	// Exploration tells how the rebalances exploring the channels without evidence improved their coverage
+	Exploration ExplorationStatus `json:"exploration"`
 }
 
 func (s *Stats) Name() string {
@@ -49,10 +59,18 @@ func (n *Node) GetStats() *Stats {
 	}
 
 	return &Stats{
-		GraphStats: n.Graph.GetStats(),
-		Successes:  successes,
-		Failures:   failures,
-		Routes:     routes,
+		GraphStats:  n.Graph.GetStats(),
+		GraphHealth: n.GetGraphHealth(),
+		Successes:   successes,
+		Failures:    failures,
+		Routes:      routes,
+		StuckHtlcs:  n.GetStuckHtlcs(),
+		Cooldowns:   n.GetCooldowns(),
+		SpendCap:    n.GetSpendCap(),
+
+		CapacityFiltered: n.Graph.CountOutsideCapacityRange(n.RouteOptions),
+		DelayFiltered:    n.Graph.CountOverMaxHopDelay(n.RouteOptions),
+		Exploration:      n.GetExploration(),
 	}
 }
 
@@ -60,9 +78,24 @@ func (s *Stats) String() string {
 	var result string
 	result += "Node stats:" + "\n"
 	result += s.GraphStats.String() + "\n"
+	result += s.GraphHealth.String() + "\n"
 	result += "successes: " + strconv.Itoa(len(s.Successes)) + "\n"
 	result += "failures: " + strconv.Itoa(len(s.Failures)) + "\n"
 	result += "routes: " + strconv.Itoa(len(s.Routes)) + "\n"
+	result += "stuck htlcs: " + strconv.Itoa(len(s.StuckHtlcs)) + "\n"
+	result += "channels cooling down: " + strconv.Itoa(len(s.Cooldowns)) + "\n"
+	result += "channels outside the capacity range: " + strconv.Itoa(s.CapacityFiltered) + "\n"
+	result += "channels over the maximum delay: " + strconv.Itoa(s.DelayFiltered) + "\n"
+	if s.SpendCap.Cap > 0 {
+		result += "daily fee budget remaining: " + strconv.FormatUint(s.SpendCap.Remaining/1000, 10) + "sats\n"
+	}
+
+	if s.Exploration.Rate > 0 {
+		result += "explorations: " + strconv.Itoa(s.Exploration.Explorations) +
+			", channels probed: " + strconv.Itoa(s.Exploration.ProbedChannels) +
+			", channels with evidence: " + strconv.Itoa(s.Exploration.StartEvidenced) +
+			" -> " + strconv.Itoa(s.Exploration.EvidencedChannels) + "/" + strconv.Itoa(s.Exploration.PublicChannels) + "\n"
+	}
 
 	var totalMoved uint64 = 0
 	for _, success := range s.Successes {
@@ -0,0 +1,16 @@
+package node
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"time"
+)
+
+// warmUp runs a throwaway route search, so that the node is responsive right after a restart
+func (n *Node) warmUp() {
+	defer util.TimeTrack(time.Now(), "node.warmUp", n.Logf)
+	n.Logln(glightning.Debug, "warming up the route search")
+	if hops := n.Graph.WarmUp(n.Id, n.RouteOptions); hops == 0 {
+		n.Logln(glightning.Debug, "the warm-up search found no route, which is fine")
+	}
+}
@@ -0,0 +1,62 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+)
+
+// switchOutChannel replaces the outgoing channel with the unused local channel that has the
+// most liquidity, returning false if there are no alternates left to try
+func (r *Rebalance) switchOutChannel() bool {
+	if r.triedOuts == nil {
+		r.triedOuts = map[string]bool{r.OutChannel.ShortChannelId: true}
+	}
+	if len(r.triedOuts) > r.MaxAlternates {
+		return false
+	}
+
+	alternate := r.getAlternateOutChannel()
+	if alternate == nil {
+		return false
+	}
+	r.triedOuts[alternate.ShortChannelId] = true
+	r.Node.Logln(glightning.Info, "first hop failed on ", r.OutChannel.ShortChannelId,
+		", trying alternate outgoing channel ", alternate.ShortChannelId)
+	r.OutChannel = alternate
+	return true
+}
+
+func (r *Rebalance) getAlternateOutChannel() *graph.Channel {
+	var (
+		best     *glightning.PeerChannel
+		bestPeer string
+	)
+	r.Node.PeersLock.RLock()
+	for _, peer := range r.Node.Peers {
+		// a route out to the peer of the incoming channel would be a circle through a single peer
+		if !peer.Connected || peer.Id == r.InChannel.Source {
+			continue
+		}
+		for _, channel := range peer.Channels {
+			if channel.State != NORMAL || r.Node.LocalBalance(channel) < r.Amount ||
+				channel.ShortChannelId == r.InChannel.ShortChannelId || r.triedOuts[channel.ShortChannelId] {
+				continue
+			}
+			if best == nil || r.Node.LocalBalance(channel) > r.Node.LocalBalance(best) {
+				best = channel
+				bestPeer = peer.Id
+			}
+		}
+	}
+	r.Node.PeersLock.RUnlock()
+
+	if best == nil {
+		return nil
+	}
+	channel, err := r.Node.GetGraphChannelFromPeerChannel(best, util.GetDirection(r.Node.Id, bestPeer))
+	if err != nil {
+		return nil
+	}
+	return channel
+}
@@ -0,0 +1,63 @@
+package rebalance
+
+import (
+	"circular/graph/graphtest"
+	"circular/node"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestAlternateOutChannel(t *testing.T) {
+	self, alice, bob, carol, dave := graphtest.NodeId(0), graphtest.NodeId(1), graphtest.NodeId(2), graphtest.NodeId(3), graphtest.NodeId(4)
+	amount := uint64(100000000)
+	g := graphtest.NewGraph(
+		graphtest.NewChannel(self, alice, "1x1x1", 1000000, 0, 0, 40),
+		graphtest.NewChannel(self, bob, "2x2x2", 1000000, 0, 0, 40),
+		graphtest.NewChannel(self, bob, "3x3x3", 1000000, 0, 0, 40),
+		graphtest.NewChannel(self, carol, "4x4x4", 1000000, 0, 0, 40),
+		graphtest.NewChannel(self, dave, "5x5x5", 1000000, 0, 0, 40),
+	)
+	in := graphtest.NewChannel(bob, self, "3x3x3", 1000000, 0, 0, 40)
+	peerChannel := func(scid string, toUs uint64) *glightning.PeerChannel {
+		return &glightning.PeerChannel{ShortChannelId: scid, State: NORMAL, MilliSatoshiToUs: toUs}
+	}
+	r := &Rebalance{
+		Node: &node.Node{
+			Id:        self,
+			Graph:     g,
+			PeersLock: &sync.RWMutex{},
+			Peers: map[string]*glightning.Peer{
+				alice: {Id: alice, Connected: true, Channels: []*glightning.PeerChannel{peerChannel("1x1x1", 2*amount)}},
+				// the peer of the incoming channel, with the most liquidity on another channel
+				bob: {Id: bob, Connected: true, Channels: []*glightning.PeerChannel{peerChannel("2x2x2", 9*amount), peerChannel("3x3x3", 9*amount)}},
+				// not enough liquidity for the amount
+				carol: {Id: carol, Connected: true, Channels: []*glightning.PeerChannel{peerChannel("4x4x4", amount-1)}},
+				// offline
+				dave: {Id: dave, Channels: []*glightning.PeerChannel{peerChannel("5x5x5", 8*amount)}},
+			},
+		},
+		InChannel:     in,
+		Amount:        amount,
+		MaxAlternates: 1,
+	}
+	r.OutChannel, _ = r.Node.GetOutgoingChannelFromScid("1x1x1")
+
+	// no route may go out to the peer it comes back from
+	r.triedOuts = map[string]bool{}
+	alternate := r.getAlternateOutChannel()
+	assert.NotNil(t, alternate)
+	assert.Equal(t, "1x1x1", alternate.ShortChannelId)
+
+	// nor through a channel already tried
+	r.triedOuts = map[string]bool{"1x1x1": true}
+	assert.Nil(t, r.getAlternateOutChannel())
+	assert.False(t, r.switchOutChannel())
+
+	// the bound counts the first outgoing channel
+	r.triedOuts = nil
+	r.MaxAlternates = 0
+	assert.False(t, r.switchOutChannel())
+	assert.Equal(t, map[string]bool{"1x1x1": true}, r.triedOuts)
+}
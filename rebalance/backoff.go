@@ -0,0 +1,45 @@
+package rebalance
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"time"
+)
+
+const (
+	// MAX_RETRY_BACKOFF caps the delay between two attempts, before the jitter
+	MAX_RETRY_BACKOFF = 30 * time.Second
+)
+
+// retryDelay is the delay before the attempt that follows attempt: base, doubled at every attempt up to
+// MAX_RETRY_BACKOFF, then moved at random by up to jitter percent of it in either direction, so that
+// the rebalances that failed together don't all retry together
+func retryDelay(base time.Duration, attempt, jitter int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	delay := base
+	for i := 1; i < attempt && delay < MAX_RETRY_BACKOFF; i++ {
+		delay *= 2
+	}
+	if delay > MAX_RETRY_BACKOFF {
+		delay = MAX_RETRY_BACKOFF
+	}
+	spread := uint64(delay) * uint64(jitter) / 100
+	return time.Duration(util.RandRange(uint64(delay)-spread, uint64(delay)+spread+1))
+}
+
+// waitBeforeRetry waits the retry delay after attempt failed, see circular-retry-delay, or less if the deadline comes first
+func (r *Rebalance) waitBeforeRetry(attempt int) {
+	delay := retryDelay(r.Node.RetryDelay, attempt, r.Node.RetryJitter)
+	if delay == 0 {
+		return
+	}
+	r.Node.Logln(glightning.Debug, "waiting ", delay, " before the next attempt")
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-r.ctx.Done():
+	}
+}
@@ -0,0 +1,36 @@
+package rebalance
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetryDelayJitter(t *testing.T) {
+	base := time.Second
+	for i := 0; i < 1000; i++ {
+		delay := retryDelay(base, 1, 20)
+		assert.GreaterOrEqual(t, delay, 800*time.Millisecond)
+		assert.LessOrEqual(t, delay, 1200*time.Millisecond)
+
+		// the delay doubles at every attempt, and so does the jitter
+		delay = retryDelay(base, 3, 20)
+		assert.GreaterOrEqual(t, delay, 3200*time.Millisecond)
+		assert.LessOrEqual(t, delay, 4800*time.Millisecond)
+
+		// up to a cap
+		delay = retryDelay(base, 20, 50)
+		assert.GreaterOrEqual(t, delay, MAX_RETRY_BACKOFF/2)
+		assert.LessOrEqual(t, delay, MAX_RETRY_BACKOFF*3/2)
+	}
+
+	// the delays are spread, not all the same
+	delays := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		delays[retryDelay(base, 1, 20)] = true
+	}
+	assert.Greater(t, len(delays), 50)
+
+	assert.Equal(t, base, retryDelay(base, 1, 0))
+	assert.Equal(t, time.Duration(0), retryDelay(0, 3, 20))
+}
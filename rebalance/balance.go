@@ -0,0 +1,250 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/node"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"sync"
+)
+
+const (
+	DEFAULT_BALANCE_TARGET = 0.5
+
+	BALANCE_DRAIN = "drain"
+	BALANCE_FILL  = "fill"
+	BALANCE_NONE  = "none"
+)
+
+// balancing contains the channels involved in a running circular-balance,
+// so that two of them can't work on the same channel at the same time
+var balancing = struct {
+	lock  sync.Mutex
+	scids map[string]bool
+}{scids: make(map[string]bool)}
+
+type Balance struct {
+	Scid           string     `json:"scid"`
+	Target         *float64   `json:"target,omitempty"`
+	MaxPPM         uint64     `json:"maxppm,omitempty"`
+	Attempts       int        `json:"attempts,omitempty"`
+	MaxHops        *int       `json:"maxhops,omitempty"`
+	FinalCltv      uint       `json:"finalcltv,omitempty"`
+	Format         string     `json:"format,omitempty"`
+	IgnoreCooldown bool       `json:"ignorecooldown,omitempty"`
+	Node           *node.Node `json:"-"`
+}
+
+// BalancePlan is what circular-balance derived from the imbalance of the channel
+type BalancePlan struct {
+	Scid            string  `json:"scid"`
+	Ratio           float64 `json:"ratio"`
+	Target          float64 `json:"target"`
+	Direction       string  `json:"direction"`
+	Complement      string  `json:"complement,omitempty"`
+	ComplementRatio float64 `json:"complement_ratio,omitempty"`
+	Amount          uint64  `json:"amount"`
+}
+
+type BalanceResult struct {
+	Plan   *BalancePlan `json:"plan"`
+	Result *Result      `json:"result,omitempty"`
+}
+
+func (r *Balance) Name() string {
+	return "circular-balance"
+}
+
+func (r *Balance) New() interface{} {
+	return &Balance{}
+}
+
+func (r *Balance) Call() (jrpc2.Result, error) {
+	r.Node = node.GetNode()
+	if r.Scid == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+	if err := graph.ValidateRouteFormat(r.Format); err != nil {
+		return nil, err
+	}
+	target, err := balanceTargetOrDefault(r.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, candidates, err := r.getChannels()
+	if err != nil {
+		return nil, err
+	}
+	plan := planBalance(channel, candidates, target, r.Node.MinAmount)
+	r.Node.Logf(glightning.Info, "balance plan for %s: %+v", r.Scid, *plan)
+	if plan.Direction == BALANCE_NONE {
+		return &BalanceResult{Plan: plan}, nil
+	}
+
+	if !lockBalancing(plan.Scid, plan.Complement) {
+		return nil, util.ErrChannelBeingBalanced
+	}
+	defer unlockBalancing(plan.Scid, plan.Complement)
+
+	outScid, inScid := plan.Scid, plan.Complement
+	if plan.Direction == BALANCE_FILL {
+		outScid, inScid = inScid, outScid
+	}
+	outgoingChannel, err := r.Node.GetOutgoingChannelFromScid(outScid)
+	if err != nil {
+		return nil, err
+	}
+	incomingChannel, err := r.Node.GetIncomingChannelFromScid(inScid)
+	if err != nil {
+		return nil, err
+	}
+
+	rebalance := NewRebalance(outgoingChannel, incomingChannel, plan.Amount, r.MaxPPM, r.Attempts, maxHopsOrDefault(r.MaxHops))
+	rebalance.FinalCltv = r.FinalCltv
+	rebalance.Command = r.Name()
+	rebalance.IgnoreCooldown = r.IgnoreCooldown
+
+	if err := rebalance.Setup(); err != nil {
+		return nil, err
+	}
+	result, err := rebalance.RunDeduplicated()
+	if err != nil {
+		return nil, err
+	}
+	result.formatRoute(r.Format)
+	return &BalanceResult{Plan: plan, Result: result}, nil
+}
+
+// balanceTargetOrDefault distinguishes an explicit target=0 (a full drain) from a missing parameter
+func balanceTargetOrDefault(target *float64) (float64, error) {
+	if target == nil {
+		return DEFAULT_BALANCE_TARGET, nil
+	}
+	if *target < 0 || *target > 1 {
+		return 0, util.ErrBalanceTargetInvalid
+	}
+	return *target, nil
+}
+
+// getChannels returns the channel to balance and the channels that can be used to balance it:
+// the other channels in normal state with connected peers
+func (r *Balance) getChannels() (*glightning.PeerChannel, []*glightning.PeerChannel, error) {
+	r.Node.PeersLock.RLock()
+	defer r.Node.PeersLock.RUnlock()
+
+	var channel *glightning.PeerChannel
+	candidates := make([]*glightning.PeerChannel, 0)
+	for _, peer := range r.Node.Peers {
+		for _, c := range peer.Channels {
+			if c.ShortChannelId == r.Scid {
+				if !peer.Connected {
+					return nil, nil, util.ErrPeerDisconnected
+				}
+				if c.State != NORMAL {
+					return nil, nil, util.ErrChannelNotInNormalState
+				}
+				channel = c
+				continue
+			}
+			if peer.Connected && c.State == NORMAL {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	if channel == nil {
+		return nil, nil, util.ErrNoPeerChannel
+	}
+	return channel, candidates, nil
+}
+
+// planBalance decides whether channel has to be drained or filled to reach the target ratio of
+// local balance, and picks among candidates the channel with the opposite imbalance that can take
+// the most of it. The amount is what brings the closest of the two channels to the target.
+func planBalance(channel *glightning.PeerChannel, candidates []*glightning.PeerChannel, target float64, minAmount uint64) *BalancePlan {
+	plan := &BalancePlan{
+		Scid:      channel.ShortChannelId,
+		Ratio:     localRatio(channel),
+		Target:    target,
+		Direction: BALANCE_NONE,
+	}
+
+	// positive if the channel has more local balance than the target
+	excess := func(c *glightning.PeerChannel) int64 {
+		return int64(c.MilliSatoshiToUs) - int64(target*float64(c.MilliSatoshiTotal))
+	}
+
+	needed := excess(channel)
+	direction := BALANCE_DRAIN
+	if needed < 0 {
+		needed = -needed
+		direction = BALANCE_FILL
+	}
+	if uint64(needed) < minAmount {
+		return plan
+	}
+
+	var (
+		complement *glightning.PeerChannel
+		available  int64
+	)
+	for _, c := range candidates {
+		// a complement of a channel to drain must be filled, and the other way around
+		room := -excess(c)
+		if direction == BALANCE_FILL {
+			room = -room
+		}
+		if room > available {
+			complement, available = c, room
+		}
+	}
+	if complement == nil {
+		return plan
+	}
+
+	amount := needed
+	if available < amount {
+		amount = available
+	}
+	// amounts are whole sats
+	amount /= 1000
+	if uint64(amount)*1000 < minAmount {
+		return plan
+	}
+
+	plan.Direction = direction
+	plan.Complement = complement.ShortChannelId
+	plan.ComplementRatio = localRatio(complement)
+	plan.Amount = uint64(amount)
+	return plan
+}
+
+func localRatio(c *glightning.PeerChannel) float64 {
+	if c.MilliSatoshiTotal == 0 {
+		return 0
+	}
+	return float64(c.MilliSatoshiToUs) / float64(c.MilliSatoshiTotal)
+}
+
+func lockBalancing(scids ...string) bool {
+	balancing.lock.Lock()
+	defer balancing.lock.Unlock()
+	for _, scid := range scids {
+		if balancing.scids[scid] {
+			return false
+		}
+	}
+	for _, scid := range scids {
+		balancing.scids[scid] = true
+	}
+	return true
+}
+
+func unlockBalancing(scids ...string) {
+	balancing.lock.Lock()
+	defer balancing.lock.Unlock()
+	for _, scid := range scids {
+		delete(balancing.scids, scid)
+	}
+}
@@ -0,0 +1,75 @@
+package rebalance
+
+import (
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newBalanceTestChannel(scid string, local, total uint64) *glightning.PeerChannel {
+	return &glightning.PeerChannel{ShortChannelId: scid, MilliSatoshiToUs: local * 1000, MilliSatoshiTotal: total * 1000}
+}
+
+func TestPlanBalance(t *testing.T) {
+	minAmount := uint64(1000000)
+	full := newBalanceTestChannel("1x1x1", 900000, 1000000)
+	empty := newBalanceTestChannel("2x2x2", 100000, 1000000)
+	smallEmpty := newBalanceTestChannel("3x3x3", 0, 200000)
+	balanced := newBalanceTestChannel("4x4x4", 500000, 1000000)
+
+	// the full channel is drained into the channel that can take the most
+	plan := planBalance(full, []*glightning.PeerChannel{smallEmpty, empty, balanced}, 0.5, minAmount)
+	assert.Equal(t, BALANCE_DRAIN, plan.Direction)
+	assert.Equal(t, "2x2x2", plan.Complement)
+	assert.Equal(t, uint64(400000), plan.Amount)
+	assert.Equal(t, 0.9, plan.Ratio)
+	assert.Equal(t, 0.1, plan.ComplementRatio)
+
+	// the amount is limited by what the complement can take
+	plan = planBalance(full, []*glightning.PeerChannel{smallEmpty, balanced}, 0.5, minAmount)
+	assert.Equal(t, "3x3x3", plan.Complement)
+	assert.Equal(t, uint64(100000), plan.Amount)
+
+	// the empty channel is filled from the full one, up to a different target
+	plan = planBalance(empty, []*glightning.PeerChannel{full, balanced}, 0.3, minAmount)
+	assert.Equal(t, BALANCE_FILL, plan.Direction)
+	assert.Equal(t, "1x1x1", plan.Complement)
+	assert.Equal(t, uint64(200000), plan.Amount)
+
+	// nothing to do
+	plan = planBalance(balanced, []*glightning.PeerChannel{full, empty}, 0.5, minAmount)
+	assert.Equal(t, BALANCE_NONE, plan.Direction)
+	assert.Empty(t, plan.Complement)
+
+	// no channel with the opposite imbalance
+	plan = planBalance(full, []*glightning.PeerChannel{balanced}, 0.5, minAmount)
+	assert.Equal(t, BALANCE_NONE, plan.Direction)
+}
+
+func TestLockBalancing(t *testing.T) {
+	assert.True(t, lockBalancing("1x1x1", "2x2x2"))
+	assert.False(t, lockBalancing("3x3x3", "2x2x2"))
+	// a failed lock doesn't hold anything
+	assert.True(t, lockBalancing("3x3x3"))
+	unlockBalancing("1x1x1", "2x2x2")
+	unlockBalancing("3x3x3")
+	assert.True(t, lockBalancing("2x2x2"))
+	unlockBalancing("2x2x2")
+}
+
+func TestBalanceTargetOrDefault(t *testing.T) {
+	target, err := balanceTargetOrDefault(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, DEFAULT_BALANCE_TARGET, target)
+
+	// an explicit 0 drains the channel instead of being replaced by the default
+	zero := 0.0
+	target, err = balanceTargetOrDefault(&zero)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, target)
+
+	invalid := 1.5
+	_, err = balanceTargetOrDefault(&invalid)
+	assert.Equal(t, util.ErrBalanceTargetInvalid, err)
+}
@@ -9,13 +9,23 @@ import (
 )
 
 type RebalanceByNode struct {
-	OutNode  string     `json:"outnode"`
-	InNode   string     `json:"innode"`
-	Amount   uint64     `json:"amount,omitempty"`
-	MaxPPM   uint64     `json:"maxppm,omitempty"`
-	Attempts int        `json:"attempts,omitempty"`
-	MaxHops  int        `json:"maxhops,omitempty"`
-	Node     *node.Node `json:"-"`
+	OutNode        string     `json:"outnode"`
+	InNode         string     `json:"innode"`
+	OutScid        string     `json:"outscid,omitempty"`
+	InScid         string     `json:"inscid,omitempty"`
+	Amount         uint64     `json:"amount,omitempty"`
+	MaxPPM         uint64     `json:"maxppm,omitempty"`
+	Attempts       int        `json:"attempts,omitempty"`
+	MaxHops        *int       `json:"maxhops,omitempty"`
+	FinalCltv      uint       `json:"finalcltv,omitempty"`
+	Maximize       bool       `json:"maximize,omitempty"`
+	Explain        bool       `json:"explain,omitempty"`
+	Format         string     `json:"format,omitempty"`
+	IgnoreCooldown bool       `json:"ignorecooldown,omitempty"`
+	MinCapacity    uint64     `json:"mincapacity,omitempty"`
+	MaxCapacity    uint64     `json:"maxcapacity,omitempty"`
+	Async          bool       `json:"async,omitempty"`
+	Node           *node.Node `json:"-"`
 }
 
 func (r *RebalanceByNode) Name() string {
@@ -26,18 +36,106 @@ func (r *RebalanceByNode) New() interface{} {
 	return &RebalanceByNode{}
 }
 
-func (r *RebalanceByNode) getBestOutgoingChannel() (*graph.Channel, error) {
-	bestScid := r.Node.GetBestPeerChannel(r.OutNode, func(channel *glightning.PeerChannel) uint64 {
-		return channel.MilliSatoshiToUs
-	}).ShortChannelId
-	return r.Node.GetOutgoingChannelFromScid(bestScid)
+// legCandidate is one of the parallel channels from the incoming peer, with what we can receive through it
+type legCandidate struct {
+	channel *graph.Channel
+	balance uint64
 }
 
-func (r *RebalanceByNode) getBestIncomingChannel() (*graph.Channel, error) {
-	bestScid := r.Node.GetBestPeerChannel(r.InNode, func(channel *glightning.PeerChannel) uint64 {
-		return channel.MilliSatoshiTotal - channel.MilliSatoshiToUs
-	}).ShortChannelId
-	return r.Node.GetIncomingChannelFromScid(bestScid)
+// cheapestLeg picks, among the parallel channels from the incoming peer, the one whose fee for amount is the
+// lowest, since we pay it, among the ones that can carry amount. If none can, the one with the most balance.
+func cheapestLeg(candidates []legCandidate, amount uint64) *graph.Channel {
+	var best, fullest *legCandidate
+	for i := range candidates {
+		candidate := &candidates[i]
+		if fullest == nil || candidate.balance > fullest.balance {
+			fullest = candidate
+		}
+		if candidate.balance < amount || !candidate.channel.IsWithinHtlcBounds(amount) {
+			continue
+		}
+		if best == nil {
+			best = candidate
+			continue
+		}
+		fee, bestFee := candidate.channel.ComputeFee(amount), best.channel.ComputeFee(amount)
+		if fee < bestFee || (fee == bestFee && candidate.balance > best.balance) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		best = fullest
+	}
+	if best == nil {
+		return nil
+	}
+	return best.channel
+}
+
+// pinnedChannel returns the channel with scid, which must be a channel with peer
+func (r *RebalanceByNode) pinnedChannel(scid, peer string, get func(string) (*graph.Channel, error)) (*graph.Channel, error) {
+	channelPeer, err := r.Node.GetChannelPeerFromScid(scid)
+	if err != nil {
+		return nil, err
+	}
+	if channelPeer.Id != peer {
+		return nil, util.ErrScidNotWithPeer
+	}
+	return get(scid)
+}
+
+// getBestOutgoingChannel returns the channel pinned with outscid or, among the channels with the outgoing
+// peer other than exclude, the one with the most local balance. Its fee isn't paid, we are the ones forwarding.
+func (r *RebalanceByNode) getBestOutgoingChannel(exclude string) (*graph.Channel, error) {
+	if r.OutScid != "" {
+		return r.pinnedChannel(r.OutScid, r.OutNode, r.Node.GetOutgoingChannelFromScid)
+	}
+	best, err := r.Node.GetBestPeerChannel(r.OutNode, func(channel *glightning.PeerChannel) uint64 {
+		if channel.ShortChannelId == exclude {
+			return 0
+		}
+		return r.Node.LocalBalance(channel)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if best.ShortChannelId == exclude {
+		return nil, util.ErrNoOutgoingChannel
+	}
+	return r.Node.GetOutgoingChannelFromScid(best.ShortChannelId)
+}
+
+// getBestIncomingChannel returns the channel pinned with inscid or, among the channels from the incoming
+// peer other than exclude, the cheapest one that can receive amount, see cheapestLeg
+func (r *RebalanceByNode) getBestIncomingChannel(exclude string, amount uint64) (*graph.Channel, error) {
+	if r.InScid != "" {
+		return r.pinnedChannel(r.InScid, r.InNode, r.Node.GetIncomingChannelFromScid)
+	}
+	candidates := make([]legCandidate, 0)
+	r.Node.PeersLock.RLock()
+	// the peer might have been forgotten by a refresh since validatePeers
+	peer, ok := r.Node.Peers[r.InNode]
+	if !ok {
+		r.Node.PeersLock.RUnlock()
+		return nil, util.ErrNoPeer
+	}
+	for _, channel := range peer.Channels {
+		if channel.ShortChannelId == exclude || channel.State != NORMAL {
+			continue
+		}
+		graphChannel, err := r.Node.GetGraphChannelFromPeerChannel(channel, util.GetDirection(r.InNode, r.Node.Id))
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, legCandidate{channel: graphChannel, balance: r.Node.RemoteBalance(channel)})
+	}
+	r.Node.PeersLock.RUnlock()
+
+	best := cheapestLeg(candidates, amount)
+	if best == nil {
+		return nil, util.ErrNoIncomingChannel
+	}
+	return best, nil
 }
 
 func (r *RebalanceByNode) Call() (jrpc2.Result, error) {
@@ -45,30 +143,60 @@ func (r *RebalanceByNode) Call() (jrpc2.Result, error) {
 	if r.InNode == "" || r.OutNode == "" {
 		return nil, util.ErrNoRequiredParameter
 	}
+	if err := graph.ValidateRouteFormat(r.Format); err != nil {
+		return nil, err
+	}
 
 	err := r.validatePeers()
 	if err != nil {
 		return nil, err
 	}
 
-	// get channels from the nodes
-	outgoingChannel, err := r.getBestOutgoingChannel()
+	// get channels from the nodes, they can be two parallel channels with the same peer
+	outgoingChannel, err := r.getBestOutgoingChannel(r.InScid)
 	if err != nil {
 		return nil, err
 	}
-	incomingChannel, err := r.getBestIncomingChannel()
+	amount := r.Amount * 1000
+	if amount == 0 {
+		amount = DEFAULT_AMOUNT
+	}
+	incomingChannel, err := r.getBestIncomingChannel(outgoingChannel.ShortChannelId, amount)
 	if err != nil {
 		return nil, err
 	}
 
-	rebalance := NewRebalance(outgoingChannel, incomingChannel, r.Amount, r.MaxPPM, r.Attempts, r.MaxHops)
+	// between two channels with the same peer, the direct route is the only one
+	maxHops := maxHopsOrDefault(r.MaxHops)
+	if r.InNode == r.OutNode {
+		maxHops = 0
+	}
+	rebalance := NewRebalance(outgoingChannel, incomingChannel, r.Amount, r.MaxPPM, r.Attempts, maxHops)
+	rebalance.MaxAlternates = r.Node.MaxAlternateOuts
+	rebalance.FinalCltv = r.FinalCltv
+	rebalance.Maximize = r.Maximize
+	rebalance.Explain = r.Explain
+	rebalance.Command = r.Name()
+	rebalance.IgnoreCooldown = r.IgnoreCooldown
+	rebalance.MinCapacity = r.MinCapacity
+	rebalance.MaxCapacity = r.MaxCapacity
 
 	err = rebalance.Setup()
 	if err != nil {
 		return nil, err
 	}
 
-	return rebalance.Run(), nil
+	if r.Async {
+		progress := rebalance.RunAsync(r.Format)
+		return &progress, nil
+	}
+
+	result, err := rebalance.RunDeduplicated()
+	if err != nil {
+		return nil, err
+	}
+	result.formatRoute(r.Format)
+	return result, nil
 }
 
 func (r *RebalanceByNode) validatePeers() error {
@@ -79,10 +207,6 @@ func (r *RebalanceByNode) validatePeers() error {
 	if r.InNode == r.Node.Id || r.OutNode == r.Node.Id {
 		return util.ErrSelfNode
 	}
-	//validate that the nodes are not the same
-	if r.InNode == r.OutNode {
-		return util.ErrSameIncomingAndOutgoingNode
-	}
 
 	//validate that the nodes are actually peers
 	if _, ok := r.Node.Peers[r.InNode]; !ok {
@@ -91,5 +215,14 @@ func (r *RebalanceByNode) validatePeers() error {
 	if _, ok := r.Node.Peers[r.OutNode]; !ok {
 		return util.ErrNoPeer
 	}
+
+	//validate that the nodes are not the same, unless we have parallel channels with it
+	if r.InNode == r.OutNode {
+		r.Node.PeersLock.RLock()
+		defer r.Node.PeersLock.RUnlock()
+		if len(r.Node.Peers[r.InNode].Channels) < 2 {
+			return util.ErrSameIncomingAndOutgoingNode
+		}
+	}
 	return nil
 }
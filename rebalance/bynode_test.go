@@ -0,0 +1,57 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/node"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestCheapestLegAmongDuplicateChannels(t *testing.T) {
+	self := "020000000000000000000000000000000000000000000000000000000000000000"
+	peer := "020000000000000000000000000000000000000000000000000000000000000001"
+	channel := func(scid string, feeRate uint64) *graph.Channel {
+		return graph.NewChannel(&glightning.Channel{Source: peer, Destination: self, ShortChannelId: scid,
+			FeePerMillionth: feeRate, HtlcMaximumMilliSatoshis: "1000000000msat"}, 0, 0)
+	}
+	amount := uint64(100000000)
+	expensive, cheap, cheapest := channel("1x1x1", 500), channel("2x2x2", 100), channel("3x3x3", 10)
+
+	// the cheapest channel from the peer that can receive the amount
+	candidates := []legCandidate{
+		{channel: expensive, balance: 3 * amount},
+		{channel: cheap, balance: 2 * amount},
+		{channel: cheapest, balance: amount / 2},
+	}
+	assert.Equal(t, cheap, cheapestLeg(candidates, amount))
+
+	// none can receive it, the one with the most balance
+	assert.Equal(t, expensive, cheapestLeg(candidates, 4*amount))
+	assert.Nil(t, cheapestLeg(nil, amount))
+
+	// two channels with the same peer make a direct route
+	out := graph.NewChannel(&glightning.Channel{Source: self, Destination: peer, ShortChannelId: "4x4x4", Delay: 40}, 0, 0)
+	route, err := newDirectRoute(out, cheap, amount, graph.INITIAL_DELAY, 0, graph.NewGraph())
+	assert.NoError(t, err)
+	assert.Equal(t, cheap.ComputeFee(amount), route.Fee())
+}
+
+func TestBestChannelsOfAForgottenPeer(t *testing.T) {
+	r := &RebalanceByNode{
+		OutNode: "020000000000000000000000000000000000000000000000000000000000000001",
+		InNode:  "020000000000000000000000000000000000000000000000000000000000000002",
+		Node: &node.Node{
+			PeersLock: &sync.RWMutex{},
+			Peers:     map[string]*glightning.Peer{},
+		},
+	}
+
+	// the peers were validated, then forgotten by a refresh
+	_, err := r.getBestOutgoingChannel("")
+	assert.Equal(t, util.ErrNoPeer, err)
+	_, err = r.getBestIncomingChannel("", 100000000)
+	assert.Equal(t, util.ErrNoPeer, err)
+}
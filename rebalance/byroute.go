@@ -0,0 +1,93 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/node"
+	"circular/util"
+	"github.com/elementsproject/glightning/jrpc2"
+)
+
+const (
+	ROUTE_COMPUTED = "computed"
+	ROUTE_SENT     = "sent"
+)
+
+type RouteByScids struct {
+	Scids     []string   `json:"scids"`
+	Amount    uint64     `json:"amount,omitempty"`
+	FinalCltv uint       `json:"finalcltv,omitempty"`
+	Send      bool       `json:"send,omitempty"`
+	Format    string     `json:"format,omitempty"`
+	Node      *node.Node `json:"-"`
+}
+
+type RouteByScidsResult struct {
+	Status       string             `json:"status"`
+	Route        *graph.PrettyRoute `json:"route,omitempty"`
+	RouteText    string             `json:"route_text,omitempty"`
+	SendPayRoute []graph.SendPayHop `json:"sendpay_route,omitempty"`
+}
+
+func newRouteByScidsResult(status string, route *graph.PrettyRoute, format string) *RouteByScidsResult {
+	switch format {
+	case "", graph.ROUTE_FORMAT_JSON:
+		return &RouteByScidsResult{Status: status, Route: route}
+	case graph.ROUTE_FORMAT_SENDPAY:
+		return &RouteByScidsResult{Status: status, SendPayRoute: route.SendPayRoute()}
+	}
+	return &RouteByScidsResult{Status: status, RouteText: route.Format(format)}
+}
+
+func (r *RouteByScids) Name() string {
+	return "circular-route-scids"
+}
+
+func (r *RouteByScids) New() interface{} {
+	return &RouteByScids{}
+}
+
+func (r *RouteByScids) Call() (jrpc2.Result, error) {
+	r.Node = node.GetNode()
+	if len(r.Scids) == 0 {
+		return nil, util.ErrNoRequiredParameter
+	}
+	if err := graph.ValidateRouteFormat(r.Format); err != nil {
+		return nil, err
+	}
+
+	// convert to msatoshi
+	amount := r.Amount * 1000
+	if amount == 0 {
+		amount = DEFAULT_AMOUNT
+	}
+	if r.FinalCltv == 0 {
+		r.FinalCltv = defaultFinalCltv(r.Node.MinFinalCltv())
+	}
+	if err := validateFinalCltv(r.FinalCltv, r.Node.MinFinalCltv()); err != nil {
+		return nil, err
+	}
+
+	route, err := r.Node.Graph.NewRouteFromScids(r.Node.Id, r.Scids, amount, r.FinalCltv)
+	if err != nil {
+		return nil, err
+	}
+	if err := route.CheckLength(r.Node.RouteOptions.MaxRouteLength); err != nil {
+		return nil, err
+	}
+	if err := route.CheckFeeBounds(); err != nil {
+		return nil, err
+	}
+
+	if !r.Send {
+		return newRouteByScidsResult(ROUTE_COMPUTED, withGraphAge(r.Node, graph.NewPrettyRoute(route, "")), r.Format), nil
+	}
+
+	if route.Destination != r.Node.Id {
+		return nil, util.ErrRouteNotCircular
+	}
+	prettyRoute, err := sendRoute(r.Node, route, r.Name(), *r)
+	if err != nil {
+		return nil, err
+	}
+	return newRouteByScidsResult(ROUTE_SENT, prettyRoute, r.Format), nil
+}
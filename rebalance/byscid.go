@@ -1,19 +1,29 @@
 package rebalance
 
 import (
+	"circular/graph"
 	"circular/node"
 	"circular/util"
+	"encoding/json"
 	"github.com/elementsproject/glightning/jrpc2"
 )
 
 type RebalanceByScid struct {
-	OutScid  string     `json:"outscid"`
-	InScid   string     `json:"inscid"`
-	Amount   uint64     `json:"amount,omitempty"`
-	MaxPPM   uint64     `json:"maxppm,omitempty"`
-	Attempts int        `json:"attempts,omitempty"`
-	MaxHops  int        `json:"maxhops,omitempty"`
-	Node     *node.Node `json:"-"`
+	OutScid        string          `json:"outscid"`
+	InScid         string          `json:"inscid"`
+	Amount         json.RawMessage `json:"amount,omitempty"`
+	MaxPPM         uint64          `json:"maxppm,omitempty"`
+	Attempts       int             `json:"attempts,omitempty"`
+	MaxHops        *int            `json:"maxhops,omitempty"`
+	FinalCltv      uint            `json:"finalcltv,omitempty"`
+	Maximize       bool            `json:"maximize,omitempty"`
+	Explain        bool            `json:"explain,omitempty"`
+	Format         string          `json:"format,omitempty"`
+	IgnoreCooldown bool            `json:"ignorecooldown,omitempty"`
+	MinCapacity    uint64          `json:"mincapacity,omitempty"`
+	MaxCapacity    uint64          `json:"maxcapacity,omitempty"`
+	Async          bool            `json:"async,omitempty"`
+	Node           *node.Node      `json:"-"`
 }
 
 func (r *RebalanceByScid) Name() string {
@@ -29,6 +39,9 @@ func (r *RebalanceByScid) Call() (jrpc2.Result, error) {
 	if r.InScid == "" || r.OutScid == "" {
 		return nil, util.ErrNoRequiredParameter
 	}
+	if err := graph.ValidateRouteFormat(r.Format); err != nil {
+		return nil, err
+	}
 
 	outgoingChannel, err := r.Node.GetOutgoingChannelFromScid(r.OutScid)
 	if err != nil {
@@ -40,12 +53,37 @@ func (r *RebalanceByScid) Call() (jrpc2.Result, error) {
 		return nil, err
 	}
 
-	rebalance := NewRebalance(outgoingChannel, incomingChannel, r.Amount, r.MaxPPM, r.Attempts, r.MaxHops)
+	amount, err := parseAmount(r.Amount, outgoingChannel.Satoshis, r.Node.MinAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	rebalance := NewRebalance(outgoingChannel, incomingChannel, amount, r.MaxPPM, r.Attempts, maxHopsOrDefault(r.MaxHops))
+	rebalance.MaxAlternates = r.Node.MaxAlternateOuts
+	rebalance.FinalCltv = r.FinalCltv
+	rebalance.Maximize = r.Maximize
+	rebalance.Explain = r.Explain
+	rebalance.Command = r.Name()
+	rebalance.IgnoreCooldown = r.IgnoreCooldown
+	rebalance.MinCapacity = r.MinCapacity
+	rebalance.MaxCapacity = r.MaxCapacity
 
 	err = rebalance.Setup()
 	if err != nil {
 		return nil, err
 	}
 
-	return rebalance.Run(), nil
+	if r.Async {
+		progress := rebalance.RunAsync(r.Format)
+		return &progress, nil
+	}
+
+	resolved := rebalance.Amount
+	result, err := rebalance.RunDeduplicated()
+	if err != nil {
+		return nil, err
+	}
+	result.ResolvedAmount = resolved
+	result.formatRoute(r.Format)
+	return result, nil
 }
@@ -0,0 +1,50 @@
+package rebalance
+
+import (
+	"circular/util"
+	"context"
+	"strconv"
+)
+
+// WithContext makes the rebalance stop before its next attempt once ctx is done,
+// like the chunks of a parallel rebalance do at the deadline of the whole command
+func (r *Rebalance) WithContext(ctx context.Context) *Rebalance {
+	r.ctx = ctx
+	return r
+}
+
+// startDeadline bounds the rebalance to Node.RebalanceDeadline from now, within the context it was given.
+// The returned function releases the context and must be called when the rebalance is over.
+func (r *Rebalance) startDeadline() context.CancelFunc {
+	if r.ctx == nil {
+		r.ctx = context.Background()
+	}
+	if r.Node.RebalanceDeadline <= 0 {
+		return func() {}
+	}
+	var cancel context.CancelFunc
+	r.ctx, cancel = context.WithTimeout(r.ctx, r.Node.RebalanceDeadline)
+	return cancel
+}
+
+// deadlineExceeded tells whether the rebalance is past its deadline
+func (r *Rebalance) deadlineExceeded() bool {
+	return r.ctx != nil && r.ctx.Err() != nil
+}
+
+// deadlineResult is the result of a rebalance that stopped at its deadline after attempts attempts.
+// The parts that settled before it, through aggregated parallel channels, are reported as delivered.
+func (r *Rebalance) deadlineResult(attempts int) *Result {
+	result := NewResult("failure", r.Amount/1000, r.OutChannel.Destination, r.InChannel.Source)
+	result.Attempts = uint64(attempts)
+	result.DeadlineExceeded = true
+	result.Message = util.ErrRebalanceDeadline.Error() + " after " + strconv.Itoa(attempts) + " attempts."
+	if progress, ok := getProgress(r.Id); ok {
+		result.Delivered = progress.Delivered
+		result.Fee = progress.Fee
+	} else {
+		result.Delivered = r.delivered
+		result.Fee = r.deliveredFee
+	}
+	return result
+}
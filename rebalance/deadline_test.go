@@ -0,0 +1,56 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/node"
+	"circular/util"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRebalanceDeadline(t *testing.T) {
+	r := newProgressTestRebalance()
+	r.OutChannel.Destination = "out"
+	r.InChannel.Source = "in"
+	r.Node = &node.Node{RebalanceDeadline: 20 * time.Millisecond}
+	r.trackProgress(PROGRESS_RUNNING)
+
+	cancel := r.startDeadline()
+	defer cancel()
+	assert.False(t, r.deadlineExceeded())
+
+	// a part that settled before the deadline is not forgotten
+	part := &graph.Route{Amount: 40000000}
+	r.settleProgress(part, &graph.PrettyRoute{Fee: 1500})
+
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, r.deadlineExceeded())
+	_, err := r.runAttempt(3)
+	assert.Equal(t, util.ErrRebalanceDeadline, err)
+
+	result := r.deadlineResult(2)
+	assert.Equal(t, "failure", result.Status)
+	assert.True(t, result.DeadlineExceeded)
+	assert.Equal(t, uint64(2), result.Attempts)
+	assert.Equal(t, uint64(40000000), result.Delivered)
+	assert.Equal(t, uint64(1500), result.Fee)
+}
+
+func TestRebalanceDeadlineOfTheCommand(t *testing.T) {
+	// the chunks of a parallel rebalance stop with the command, whatever their own deadline
+	ctx, cancel := context.WithCancel(context.Background())
+	r := newProgressTestRebalance().WithContext(ctx)
+	stop := r.startDeadline()
+	defer stop()
+	assert.False(t, r.deadlineExceeded())
+	cancel()
+	assert.True(t, r.deadlineExceeded())
+
+	// without a deadline, a rebalance never exceeds it
+	r = newProgressTestRebalance()
+	stop = r.startDeadline()
+	defer stop()
+	assert.False(t, r.deadlineExceeded())
+}
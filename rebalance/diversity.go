@@ -0,0 +1,126 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"github.com/elementsproject/glightning/glightning"
+	"strings"
+	"sync"
+)
+
+// ChunkDiversity keeps the channels used by the chunks of a split rebalance that succeeded, so that the next
+// chunks go through other channels: a route that was just used is probably depleted in that direction.
+// It is shared by the chunks, which run concurrently.
+type ChunkDiversity struct {
+	// maxOverlap is the highest fraction of the hops of a route that can go through the channels already used
+	maxOverlap float64
+	lock       sync.Mutex
+	used       map[string]bool
+	routes     map[string]bool
+	report     DiversityReport
+}
+
+// DiversityReport tells how diverse the routes of the chunks ended up. The overlap of a chunk is the fraction
+// of the hops between the peers of its route that were used by the chunks that succeeded before it.
+type DiversityReport struct {
+	Chunks         int     `json:"chunks"`
+	DistinctRoutes int     `json:"distinct_routes"`
+	MaxOverlap     float64 `json:"max_overlap"`
+	AverageOverlap float64 `json:"average_overlap"`
+	// Fallbacks are the chunks that reused the channels of other chunks beyond the limit, for lack of another route
+	Fallbacks int `json:"fallbacks"`
+}
+
+// NewChunkDiversity allows up to maxOverlap percent of the hops of a chunk to go through the channels
+// of the previous chunks
+func NewChunkDiversity(maxOverlap int) *ChunkDiversity {
+	return &ChunkDiversity{
+		maxOverlap: float64(maxOverlap) / 100,
+		used:       make(map[string]bool),
+		routes:     make(map[string]bool),
+	}
+}
+
+// usedChannels returns a copy of the channels used so far
+func (d *ChunkDiversity) usedChannels() map[string]bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	used := make(map[string]bool, len(d.used))
+	for scid := range d.used {
+		used[scid] = true
+	}
+	return used
+}
+
+// Record adds the route of a chunk that succeeded, with the local legs
+func (d *ChunkDiversity) Record(route *graph.Route) {
+	if len(route.Hops) < 2 {
+		return
+	}
+	inner := graph.Route{Hops: route.Hops[1 : len(route.Hops)-1]}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	overlap := inner.Overlap(d.used)
+	total := d.report.AverageOverlap*float64(d.report.Chunks) + overlap
+	d.report.Chunks++
+	d.report.AverageOverlap = total / float64(d.report.Chunks)
+	if overlap > d.report.MaxOverlap {
+		d.report.MaxOverlap = overlap
+	}
+	for _, hop := range inner.Hops {
+		d.used[hop.ShortChannelId] = true
+	}
+	d.routes[strings.Join(route.Scids(), ",")] = true
+	d.report.DistinctRoutes = len(d.routes)
+}
+
+func (d *ChunkDiversity) fallback() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.report.Fallbacks++
+}
+
+// Report returns how diverse the routes of the chunks recorded so far are
+func (d *ChunkDiversity) Report() DiversityReport {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.report
+}
+
+// WithDiversity makes the rebalance one of the chunks sharing d
+func (r *Rebalance) WithDiversity(d *ChunkDiversity) *Rebalance {
+	r.diversity = d
+	return r
+}
+
+// diversify returns route if it doesn't reuse too many channels of the previous chunks,
+// otherwise a route avoiding them. If there is none, route is used anyway.
+func (r *Rebalance) diversify(route *graph.Route, exclude map[string]bool, maxHops int, maxPPM uint64, options *graph.RouteOptions) *graph.Route {
+	if r.diversity == nil {
+		return route
+	}
+	diverse, ok := diverseRoute(r.Node.Graph, route, r.Amount, exclude, maxHops, maxPPM, options,
+		r.diversity.usedChannels(), r.diversity.maxOverlap)
+	if !ok {
+		r.diversity.fallback()
+		r.Node.Logln(glightning.Info, "no route avoiding the channels of the previous chunks, reusing them")
+	}
+	return diverse
+}
+
+// diverseRoute searches a route between the ends of route that avoids the channels in used, unless route
+// already overlaps them by at most maxOverlap. It returns false, and route, if there is no such route under maxPPM.
+func diverseRoute(g *graph.Graph, route *graph.Route, amount uint64, exclude map[string]bool, maxHops int, maxPPM uint64,
+	options *graph.RouteOptions, used map[string]bool, maxOverlap float64) (*graph.Route, bool) {
+	if route.Overlap(used) <= maxOverlap {
+		return route, true
+	}
+	avoiding := *options
+	// the cached routes didn't avoid anything
+	avoiding.CacheRoutes = false
+	avoiding.ExcludedChannels = used
+	other, err := g.GetRoute(route.Source, route.Destination, amount, exclude, maxHops, &avoiding)
+	if err != nil || other.FeePPM() > maxPPM {
+		return route, false
+	}
+	return other, true
+}
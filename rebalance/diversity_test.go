@@ -0,0 +1,70 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/graph/graphtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChunksTakeDistinctRoutes(t *testing.T) {
+	id := graphtest.NodeId
+	us, out, in, cheap, other := id(0), id(1), id(2), id(3), id(4)
+	g := graph.NewGraph()
+	outChannel := graphtest.AddChannel(g, graphtest.NewChannel(us, out, "1x1x1", 1000000, 0, 0, 40))
+	inChannel := graphtest.AddChannel(g, graphtest.NewChannel(in, us, "2x2x2", 1000000, 0, 0, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(in, out, "3x3x3", 1000000, 0, 0, 40))
+	// two ways from out to in, one cheaper than the other
+	graphtest.AddChannel(g, graphtest.NewChannel(out, cheap, "4x4x4", 1000000, 0, 10, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(cheap, in, "5x5x5", 1000000, 0, 10, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(out, other, "6x6x6", 1000000, 0, 50, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(other, in, "7x7x7", 1000000, 0, 50, 40))
+
+	amount := uint64(100000000)
+	options := graph.NewRouteOptions()
+	options.CacheRoutes = false
+	exclude := map[string]bool{us: true}
+	search := func() *graph.Route {
+		route, err := g.GetRoute(out, in, amount, exclude, 8, options)
+		assert.NoError(t, err)
+		return route
+	}
+
+	// the first chunk takes the cheapest route
+	diversity := NewChunkDiversity(0)
+	first, ok := diverseRoute(g, search(), amount, exclude, 8, 1000, options, diversity.usedChannels(), diversity.maxOverlap)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"4x4x4", "5x5x5"}, first.Scids())
+	sent := *first
+	sent.Prepend(outChannel)
+	sent.Append(inChannel)
+	diversity.Record(&sent)
+
+	// the second one avoids it, even if it's cheaper
+	second, ok := diverseRoute(g, search(), amount, exclude, 8, 1000, options, diversity.usedChannels(), diversity.maxOverlap)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"6x6x6", "7x7x7"}, second.Scids())
+	sent = *second
+	sent.Prepend(outChannel)
+	sent.Append(inChannel)
+	diversity.Record(&sent)
+
+	report := diversity.Report()
+	assert.Equal(t, 2, report.Chunks)
+	assert.Equal(t, 2, report.DistinctRoutes)
+	assert.Equal(t, 0.0, report.MaxOverlap)
+
+	// without another route, or within the overlap allowed, the cheapest route is kept
+	third, ok := diverseRoute(g, search(), amount, exclude, 8, 1000, options, diversity.usedChannels(), diversity.maxOverlap)
+	assert.False(t, ok)
+	assert.Equal(t, []string{"4x4x4", "5x5x5"}, third.Scids())
+	third, ok = diverseRoute(g, search(), amount, exclude, 8, 1000, options, diversity.usedChannels(), 1)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"4x4x4", "5x5x5"}, third.Scids())
+
+	// a route avoiding the used channels that is too expensive is not a way out either
+	used := map[string]bool{"4x4x4": true}
+	expensive, ok := diverseRoute(g, search(), amount, exclude, 8, 10, options, used, 0)
+	assert.False(t, ok)
+	assert.Equal(t, []string{"4x4x4", "5x5x5"}, expensive.Scids())
+}
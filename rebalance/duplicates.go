@@ -0,0 +1,76 @@
+package rebalance
+
+import (
+	"circular/node"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"sync"
+)
+
+// rebalanceKey identifies identical rebalances
+type rebalanceKey struct {
+	outScid string
+	inScid  string
+	amount  uint64
+}
+
+// inFlightRebalance is a running rebalance, done is closed when its result is ready
+type inFlightRebalance struct {
+	done    chan struct{}
+	result  *Result
+	waiters int
+}
+
+// inFlight contains the rebalances started by the commands, so that identical ones
+// submitted at the same time don't move the liquidity twice
+var inFlight = struct {
+	lock       sync.Mutex
+	rebalances map[rebalanceKey]*inFlightRebalance
+}{rebalances: make(map[rebalanceKey]*inFlightRebalance)}
+
+// RunDeduplicated runs the rebalance, unless an identical one is already in flight:
+// then, depending on the duplicates setting of the node, it fails, returns the result
+// of the one in flight, or runs anyway
+func (r *Rebalance) RunDeduplicated() (*Result, error) {
+	key := rebalanceKey{r.OutChannel.ShortChannelId, r.InChannel.ShortChannelId, r.Amount}
+	result, err := runDeduplicated(key, r.Node.Duplicates, r.Run)
+	if err == nil && result.Coalesced {
+		r.Node.Logln(glightning.Info, "identical rebalance already in flight from ", key.outScid,
+			" to ", key.inScid, ", returning its result")
+	}
+	return result, err
+}
+
+func runDeduplicated(key rebalanceKey, mode string, run func() *Result) (*Result, error) {
+	if mode == node.DUPLICATES_ALLOW {
+		return run(), nil
+	}
+
+	inFlight.lock.Lock()
+	if running, ok := inFlight.rebalances[key]; ok {
+		if mode != node.DUPLICATES_COALESCE {
+			inFlight.lock.Unlock()
+			return nil, util.ErrDuplicateRebalance
+		}
+		running.waiters++
+		inFlight.lock.Unlock()
+		<-running.done
+		// a copy, the caller might change it while formatting it
+		result := *running.result
+		result.Coalesced = true
+		return &result, nil
+	}
+	running := &inFlightRebalance{done: make(chan struct{})}
+	inFlight.rebalances[key] = running
+	inFlight.lock.Unlock()
+
+	defer func() {
+		inFlight.lock.Lock()
+		delete(inFlight.rebalances, key)
+		inFlight.lock.Unlock()
+		close(running.done)
+	}()
+	running.result = run()
+	result := *running.result
+	return &result, nil
+}
@@ -0,0 +1,85 @@
+package rebalance
+
+import (
+	"circular/node"
+	"circular/util"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runTwice submits two identical rebalances concurrently, the second one while the first one is in flight,
+// and returns their results, their errors and how many times the rebalance actually ran
+func runTwice(t *testing.T, mode string) ([]*Result, []error, int) {
+	key := rebalanceKey{"1x1x1", "2x2x2", 100000000}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	lock := &sync.Mutex{}
+	runs := 0
+	run := func() *Result {
+		lock.Lock()
+		runs++
+		lock.Unlock()
+		started <- struct{}{}
+		<-release
+		return NewResult("success", 100000, "out", "in")
+	}
+
+	results := make([]*Result, 2)
+	errs := make([]error, 2)
+	first, second := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(first)
+		results[0], errs[0] = runDeduplicated(key, mode, run)
+	}()
+	<-started
+	go func() {
+		defer close(second)
+		results[1], errs[1] = runDeduplicated(key, mode, run)
+	}()
+
+	switch mode {
+	case node.DUPLICATES_REJECT:
+		// the second one doesn't wait for the first one
+		<-second
+	case node.DUPLICATES_COALESCE:
+		assert.Eventually(t, func() bool {
+			inFlight.lock.Lock()
+			defer inFlight.lock.Unlock()
+			return inFlight.rebalances[key] != nil && inFlight.rebalances[key].waiters == 1
+		}, time.Second, time.Millisecond)
+	case node.DUPLICATES_ALLOW:
+		<-started
+	}
+	close(release)
+	<-first
+	<-second
+	return results, errs, runs
+}
+
+func TestRunDeduplicated(t *testing.T) {
+	results, errs, runs := runTwice(t, node.DUPLICATES_REJECT)
+	assert.Equal(t, 1, runs)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, "success", results[0].Status)
+	assert.ErrorIs(t, errs[1], util.ErrDuplicateRebalance)
+
+	results, errs, runs = runTwice(t, node.DUPLICATES_COALESCE)
+	assert.Equal(t, 1, runs)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.False(t, results[0].Coalesced)
+	assert.True(t, results[1].Coalesced)
+	assert.Equal(t, results[0].Amount, results[1].Amount)
+	// each caller gets its own copy
+	assert.NotSame(t, results[0], results[1])
+
+	_, errs, runs = runTwice(t, node.DUPLICATES_ALLOW)
+	assert.Equal(t, 2, runs)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+
+	// nothing is left in flight
+	assert.Empty(t, inFlight.rebalances)
+}
@@ -0,0 +1,69 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/node"
+	"circular/util"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+)
+
+// params are the parameters of the rebalance as reported by circular-last-error
+func (r *Rebalance) params() map[string]any {
+	return map[string]any{
+		"outscid":   r.OutChannel.ShortChannelId,
+		"inscid":    r.InChannel.ShortChannelId,
+		"amount":    r.Amount / 1000,
+		"maxppm":    r.MaxPPM,
+		"attempts":  r.Attempts,
+		"maxhops":   r.MaxHops,
+		"finalcltv": r.FinalCltv,
+	}
+}
+
+// recordRouteError records why no route could be used for the rebalance
+func (r *Rebalance) recordRouteError(err error) {
+	if err == nil {
+		return
+	}
+	category := node.ERROR_ROUTE_NOT_FOUND
+	if errors.As(err, &util.ErrRouteTooExpensive{}) {
+		category = node.ERROR_TOO_EXPENSIVE
+	}
+	r.Node.RecordError(category, r.Command, r.params(), err)
+}
+
+// excludeTightestHop excludes from the next attempts the node blamed for the failure of route,
+// see tightestHopNode. It returns false if there's no node that can be excluded.
+func (r *Rebalance) excludeTightestHop(route *graph.Route) bool {
+	if route == nil {
+		return false
+	}
+	id, ok := tightestHopNode(route, r.OutChannel.Destination, r.InChannel.Source)
+	if !ok {
+		return false
+	}
+	if r.excluded == nil {
+		r.excluded = make(map[string]bool)
+	}
+	r.excluded[id] = true
+	r.Node.Logln(glightning.Info, "excluding ", r.Node.Graph.GetAlias(id), " from the next attempts, it's on the tightest hop of the failed route")
+	return true
+}
+
+// tightestHopNode is the node forwarding through the tightest hop of route or, if that's the peer
+// of our outgoing channel, the node it forwards to. The peers of our channels can't be excluded.
+func tightestHopNode(route *graph.Route, outPeer, inPeer string) (string, bool) {
+	i := route.TightestHop()
+	if i == -1 {
+		return "", false
+	}
+	id := route.Hops[i].Source
+	if id == outPeer {
+		id = route.Hops[i].Destination
+	}
+	if id == inPeer {
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,88 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"github.com/elementsproject/glightning/glightning"
+)
+
+const (
+	// MAXIMIZE_ITERATIONS bounds the number of route searches of the binary search on the amount
+	MAXIMIZE_ITERATIONS = 16
+	// MAXIMIZE_GRANULARITY is the precision of the search, in msat
+	MAXIMIZE_GRANULARITY = 1000
+)
+
+// capAmountToBalances lowers the amount to what the local legs can carry: in maximize mode
+// the amount is an upper bound, not what we want to move
+func (r *Rebalance) capAmountToBalances() error {
+	inChannel, err := r.Node.GetPeerChannelFromGraphChannel(r.InChannel)
+	if err != nil {
+		return err
+	}
+	outChannel, err := r.Node.GetPeerChannelFromGraphChannel(r.OutChannel)
+	if err != nil {
+		return err
+	}
+
+	if remote := r.Node.RemoteBalance(inChannel); remote < r.Amount {
+		r.Amount = remote
+	}
+	if local := r.Node.LocalBalance(outChannel); local < r.Amount {
+		r.Amount = local
+	}
+	// amounts are whole sats
+	r.Amount -= r.Amount % 1000
+	return nil
+}
+
+// maximizeAmount sets the amount to the largest one, between the minimum amount and the current one,
+// for which a route within maxppm exists
+func (r *Rebalance) maximizeAmount(maxHops int) (*graph.Route, error) {
+	upper := r.Amount
+	amount, route, err := searchMaxAmount(r.Node.MinAmount, upper, MAXIMIZE_GRANULARITY, MAXIMIZE_ITERATIONS,
+		func(amount uint64) (*graph.Route, error) {
+			r.Amount = amount
+			return r.getRoute(maxHops)
+		})
+	if err != nil {
+		r.Amount = upper
+		return nil, err
+	}
+
+	r.Amount = amount
+	r.Node.Logln(glightning.Info, "largest amount found within ", r.MaxPPM, "ppm is ", amount/1000, " sats out of ", upper/1000)
+	return route, nil
+}
+
+// searchMaxAmount binary searches the largest amount in [low, high] for which find returns a route,
+// up to granularity msat and using at most iterations calls of find.
+// It assumes that if an amount can be routed then any smaller amount can be too, which holds for
+// liquidity and htlc maximums but not for base fees: if low can't be routed within the fee cap
+// because base fees weigh too much on it, only high is tried.
+func searchMaxAmount(low, high, granularity uint64, iterations int,
+	find func(amount uint64) (*graph.Route, error)) (uint64, *graph.Route, error) {
+	route, err := find(high)
+	if err == nil || low >= high {
+		return high, route, err
+	}
+	best, err := find(low)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// low is always routable, high never is
+	for i := 2; i < iterations && high-low > granularity; i++ {
+		mid := low + (high-low)/2
+		mid -= mid % granularity
+		if mid <= low {
+			break
+		}
+		route, err := find(mid)
+		if err != nil {
+			high = mid
+			continue
+		}
+		low, best = mid, route
+	}
+	return low, best, nil
+}
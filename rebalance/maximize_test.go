@@ -0,0 +1,73 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newMaximizeTestFind(in, out *graph.Channel, maxPPM uint64, calls *int) func(amount uint64) (*graph.Route, error) {
+	return func(amount uint64) (*graph.Route, error) {
+		*calls++
+		route, err := newDirectRoute(out, in, amount, graph.INITIAL_DELAY, 0, graph.NewGraph())
+		if err != nil {
+			return nil, err
+		}
+		for _, hop := range route.Hops[1:] {
+			if !hop.Channel.CanForward(hop.MilliSatoshi) {
+				return nil, util.ErrNoRoute
+			}
+		}
+		if route.FeePPM() > maxPPM {
+			return nil, util.NewRouteTooExpensiveError(route.FeePPM(), maxPPM)
+		}
+		return route, nil
+	}
+}
+
+func TestSearchMaxAmount(t *testing.T) {
+	self := "020000000000000000000000000000000000000000000000000000000000000000"
+	peer := "020000000000000000000000000000000000000000000000000000000000000001"
+	out := graph.NewChannel(&glightning.Channel{Source: self, Destination: peer, ShortChannelId: "1x1x1",
+		IsActive: true, HtlcMaximumMilliSatoshis: "10000000000msat"}, 10000000000, 0)
+	// the peer can only send back 1234567 sats, and only 1000000 sats per htlc
+	in := graph.NewChannel(&glightning.Channel{Source: peer, Destination: self, ShortChannelId: "2x2x2",
+		IsActive: true, FeePerMillionth: 10, HtlcMaximumMilliSatoshis: "1000000000msat"}, 1234567000, 0)
+
+	calls := 0
+	find := newMaximizeTestFind(in, out, 10, &calls)
+
+	// the search relies on routability being monotone in the amount: check it on a linear scan
+	step := uint64(50000000)
+	largest := uint64(0)
+	for amount := step; amount <= 2000000000; amount += step {
+		_, err := find(amount)
+		if err == nil {
+			assert.Equal(t, amount-step, largest, "amount %d is routable but a smaller one is not", amount)
+			largest = amount
+		}
+	}
+	assert.Equal(t, uint64(1000000000), largest)
+
+	calls = 0
+	amount, route, err := searchMaxAmount(1000000, 2000000000, MAXIMIZE_GRANULARITY, MAXIMIZE_ITERATIONS, find)
+	assert.NoError(t, err)
+	assert.NotNil(t, route)
+	assert.Equal(t, amount, route.Amount)
+	assert.LessOrEqual(t, calls, MAXIMIZE_ITERATIONS)
+	assert.LessOrEqual(t, amount, uint64(1000000000))
+	assert.Greater(t, amount, uint64(1000000000)-(2000000000>>(MAXIMIZE_ITERATIONS-2)))
+	assert.Equal(t, uint64(0), amount%MAXIMIZE_GRANULARITY)
+
+	// the whole upper bound is routable
+	amount, _, err = searchMaxAmount(1000000, 500000000, MAXIMIZE_GRANULARITY, MAXIMIZE_ITERATIONS, find)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(500000000), amount)
+
+	// too expensive for any amount
+	_, _, err = searchMaxAmount(1000000, 500000000, MAXIMIZE_GRANULARITY, MAXIMIZE_ITERATIONS,
+		newMaximizeTestFind(in, out, 5, &calls))
+	assert.ErrorAs(t, err, &util.ErrRouteTooExpensive{})
+}
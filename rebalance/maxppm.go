@@ -0,0 +1,42 @@
+package rebalance
+
+import (
+	"circular/util"
+	"math"
+)
+
+const (
+	// MAXPPM_SCALE_LIMIT bounds how much the scaling can raise or lower maxppm
+	MAXPPM_SCALE_LIMIT = 10.0
+)
+
+// scaleMaxPPM scales maxppm by amount as maxppm * (reference / amount) ^ exponent, so that amounts smaller than
+// reference are allowed a higher ppm and larger ones a lower ppm. The factor is kept within
+// [1/MAXPPM_SCALE_LIMIT, MAXPPM_SCALE_LIMIT]. A zero reference or amount leaves maxppm as it is.
+func scaleMaxPPM(maxppm, amount, reference uint64, exponent float64) uint64 {
+	if reference == 0 || amount == 0 {
+		return maxppm
+	}
+	factor := math.Pow(float64(reference)/float64(amount), exponent)
+	factor = math.Max(1/MAXPPM_SCALE_LIMIT, math.Min(MAXPPM_SCALE_LIMIT, factor))
+	return uint64(math.Round(float64(maxppm) * factor))
+}
+
+// isMaxPPMScaled tells whether maxppm is scaled by amount, see circular-maxppm-scale-reference
+func (r *Rebalance) isMaxPPMScaled() bool {
+	return r.Node.MaxPPMScaleReference > 0
+}
+
+// effectiveMaxPPM is the maxppm that applies to the current amount of r
+func (r *Rebalance) effectiveMaxPPM() uint64 {
+	return scaleMaxPPM(r.MaxPPM, r.Amount, r.Node.MaxPPMScaleReference, r.Node.MaxPPMScaleExponent)
+}
+
+// attemptMaxPPM is effectiveMaxPPM, lowered so that the fee of an attempt fits in what is left of circular-daily-fee-cap
+func (r *Rebalance) attemptMaxPPM() uint64 {
+	maxPPM := r.effectiveMaxPPM()
+	if r.Node.DailyFeeCap == 0 || r.Amount == 0 {
+		return maxPPM
+	}
+	return util.Min(maxPPM, r.Node.GetSpendCap().Remaining*1000000/r.Amount)
+}
@@ -0,0 +1,27 @@
+package rebalance
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScaleMaxPPM(t *testing.T) {
+	reference := uint64(1000000000) // 1M sats
+
+	// flat when disabled
+	assert.Equal(t, uint64(100), scaleMaxPPM(100, 10000000, 0, 0.5))
+
+	// unchanged at the reference amount
+	assert.Equal(t, uint64(100), scaleMaxPPM(100, reference, reference, 0.5))
+
+	// small amounts are allowed a higher ppm
+	assert.Equal(t, uint64(200), scaleMaxPPM(100, reference/4, reference, 0.5))
+	assert.Equal(t, uint64(400), scaleMaxPPM(100, reference/4, reference, 1))
+
+	// large amounts a lower one
+	assert.Equal(t, uint64(50), scaleMaxPPM(100, reference*4, reference, 0.5))
+
+	// the factor is bounded at both ends
+	assert.Equal(t, uint64(1000), scaleMaxPPM(100, 1000, reference, 1))
+	assert.Equal(t, uint64(10), scaleMaxPPM(100, reference*1000, reference, 1))
+}
@@ -0,0 +1,60 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/util"
+	"errors"
+	"github.com/elementsproject/glightning/glightning"
+)
+
+// FIRST_MAX_HOPS is the maxHops of the first search of a rebalance: a single channel between the two peers
+const FIRST_MAX_HOPS = 3
+
+// attemptObjective is the objective of the route search of attempt, the objectives taking turns
+func attemptObjective(objectives []string, attempt int) string {
+	if len(objectives) == 0 {
+		return graph.OBJECTIVE_CHEAPEST
+	}
+	return objectives[(attempt-1)%len(objectives)]
+}
+
+// routeObjective is the objective of the current attempt, or the one of the node outside of the attempts
+func (r *Rebalance) routeObjective() string {
+	if r.objective != "" {
+		return r.objective
+	}
+	return r.Node.RouteOptions.Objective
+}
+
+// getRoute searches the route with the objective of the current attempt. The shortest route is the one with
+// the fewest hops that fits in the fee cap, not the shortest one whatever its fees, see fewestHopsRoute.
+func (r *Rebalance) getRoute(maxHops int) (*graph.Route, error) {
+	if r.routeObjective() != graph.OBJECTIVE_SHORTEST || maxHops == 0 {
+		return r.findRoute(maxHops)
+	}
+	objective := r.objective
+	r.objective = graph.OBJECTIVE_CHEAPEST
+	defer func() {
+		r.objective = objective
+	}()
+	return fewestHopsRoute(maxHops, func(hops int) (*graph.Route, error) {
+		r.Node.Logln(glightning.Debug, "looking for the cheapest route with at most ", hops, " hops")
+		return r.findRoute(hops)
+	})
+}
+
+// fewestHopsRoute raises the maxHops given to find, which searches the cheapest route within them, from
+// FIRST_MAX_HOPS up to maxHops. The first route that fits in the fee cap is the one with the fewest hops
+// that does, and the cheapest among them.
+func fewestHopsRoute(maxHops int, find func(maxHops int) (*graph.Route, error)) (*graph.Route, error) {
+	hops := FIRST_MAX_HOPS
+	if maxHops < hops {
+		hops = maxHops
+	}
+	for ; ; hops++ {
+		route, err := find(hops)
+		if hops >= maxHops || (err != util.ErrNoRoute && !errors.As(err, &util.ErrRouteTooExpensive{})) {
+			return route, err
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/graph/graphtest"
+	"circular/util"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAttemptObjective(t *testing.T) {
+	assert.Equal(t, graph.OBJECTIVE_CHEAPEST, attemptObjective(nil, 1))
+
+	objectives := []string{graph.OBJECTIVE_SHORTEST, graph.OBJECTIVE_CHEAPEST}
+	assert.Equal(t, graph.OBJECTIVE_SHORTEST, attemptObjective(objectives, 1))
+	assert.Equal(t, graph.OBJECTIVE_CHEAPEST, attemptObjective(objectives, 2))
+	assert.Equal(t, graph.OBJECTIVE_SHORTEST, attemptObjective(objectives, 3))
+}
+
+func TestFewestHopsRouteWithinTheFeeCap(t *testing.T) {
+	id := graphtest.NodeId
+	a, b, c, d, e := id(1), id(2), id(3), id(4), id(5)
+	// a -> d pays 1000 ppm in one hop, a -> b -> d 2 * 200 ppm in two and a -> c -> e -> d 3 * 10 ppm in three
+	g := graph.NewGraph()
+	graphtest.AddChannel(g, graphtest.NewChannel(a, d, "1x1x1", 1000000, 0, 1000, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(a, b, "2x2x2", 1000000, 0, 200, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(b, d, "3x3x3", 1000000, 0, 200, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(a, c, "4x4x4", 1000000, 0, 10, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(c, e, "5x5x5", 1000000, 0, 10, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(e, d, "6x6x6", 1000000, 0, 10, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(d, a, "9x9x9", 1000000, 0, 0, 40))
+	amount := uint64(100000000)
+	options := graph.NewRouteOptions()
+	options.CacheRoutes = false
+	find := func(maxPPM uint64) func(maxHops int) (*graph.Route, error) {
+		return func(maxHops int) (*graph.Route, error) {
+			route, err := g.GetRoute(a, d, amount, map[string]bool{}, maxHops, options)
+			if err != nil {
+				return nil, err
+			}
+			if route.FeePPM() > maxPPM {
+				return nil, util.NewRouteTooExpensiveError(route.FeePPM(), maxPPM)
+			}
+			return route, nil
+		}
+	}
+
+	// the single hop is too expensive, the route with two hops fits, not the cheapest one with three
+	route, err := fewestHopsRoute(10, find(500))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2x2x2", "3x3x3"}, route.Scids())
+
+	route, err = fewestHopsRoute(10, find(1000))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1x1x1"}, route.Scids())
+
+	route, err = fewestHopsRoute(10, find(100))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"4x4x4", "5x5x5", "6x6x6"}, route.Scids())
+
+	// nothing fits within the hops
+	_, err = fewestHopsRoute(4, find(100))
+	assert.True(t, errors.As(err, &util.ErrRouteTooExpensive{}))
+}
@@ -0,0 +1,48 @@
+package parallel
+
+// The fee budget is shared by all the chunks of a parallel rebalance.
+// Every chunk in flight is assumed to cost as much as maxPPM allows until its result
+// comes back, so that chunks running concurrently can't spend more than the budget.
+
+// reservedFees is the worst case cost of the chunks in flight
+func (r *AbstractRebalance) reservedFees() uint64 {
+	return (r.InFlightAmount / r.splitAmount) * (r.splitAmount * r.maxPPM / 1000000)
+}
+
+// remainingBudget is the part of the budget that is neither spent nor reserved
+func (r *AbstractRebalance) remainingBudget() uint64 {
+	used := r.FeesSpent + r.reservedFees()
+	if used >= r.feeBudget {
+		return 0
+	}
+	return r.feeBudget - used
+}
+
+// canAffordChunk returns false when the remaining budget can't pay for a chunk at the cheapest
+// ppm seen so far. Before the first success, any budget left is worth a try.
+func (r *AbstractRebalance) canAffordChunk() bool {
+	if r.feeBudget == 0 {
+		return true
+	}
+	budgetPPM := r.remainingBudget() * 1000000 / r.splitAmount
+	return budgetPPM > 0 && budgetPPM >= r.cheapestPPM
+}
+
+// chunkMaxPPM caps maxPPM so that the next chunk can't exceed the remaining budget
+func (r *AbstractRebalance) chunkMaxPPM() uint64 {
+	if r.feeBudget == 0 {
+		return r.maxPPM
+	}
+	budgetPPM := r.remainingBudget() * 1000000 / r.splitAmount
+	if budgetPPM < r.maxPPM {
+		return budgetPPM
+	}
+	return r.maxPPM
+}
+
+func (r *AbstractRebalance) addFees(fee, ppm uint64) {
+	r.FeesSpent += fee
+	if r.cheapestPPM == 0 || ppm < r.cheapestPPM {
+		r.cheapestPPM = ppm
+	}
+}
@@ -0,0 +1,53 @@
+package parallel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFeeBudgetRunsOutMidSplit(t *testing.T) {
+	r := &AbstractRebalance{
+		amount:      1000000000, // 1M sats in 10 chunks
+		splitAmount: 100000000,
+		maxPPM:      500,
+		feeBudget:   100000, // 100 sats
+	}
+
+	// nothing spent yet: the first chunk can use the whole maxppm
+	assert.True(t, r.canAffordChunk())
+	assert.Equal(t, uint64(500), r.chunkMaxPPM())
+
+	// one chunk in flight reserves 50 sats, the next one can only use what's left
+	r.InFlightAmount = r.splitAmount
+	assert.True(t, r.canAffordChunk())
+	assert.Equal(t, uint64(500), r.chunkMaxPPM())
+
+	// two in flight reserve the whole budget
+	r.InFlightAmount = 2 * r.splitAmount
+	assert.False(t, r.canAffordChunk())
+
+	// both succeed at 400 ppm, 80 sats spent
+	r.InFlightAmount = 0
+	r.AmountRebalanced = 2 * r.splitAmount
+	r.addFees(40000, 400)
+	r.addFees(40000, 400)
+
+	// 20 sats are left, which can't pay for a chunk at 400 ppm
+	assert.False(t, r.canAffordChunk())
+	assert.Less(t, r.AmountRebalanced, r.amount)
+
+	// a cheaper chunk would still fit in the budget
+	r.cheapestPPM = 200
+	assert.True(t, r.canAffordChunk())
+	assert.Equal(t, uint64(200), r.chunkMaxPPM())
+}
+
+func TestNoFeeBudget(t *testing.T) {
+	r := &AbstractRebalance{
+		splitAmount: 100000000,
+		maxPPM:      500,
+	}
+	r.addFees(1000000, 10000)
+	assert.True(t, r.canAffordChunk())
+	assert.Equal(t, uint64(500), r.chunkMaxPPM())
+}
@@ -21,6 +21,11 @@ func (r *AbstractRebalance) FindCandidates(exclude string) error {
 		}
 
 		for _, peerChannel := range p.Channels {
+			// the channels cooling down from a previous rebalance are left alone
+			if !r.ignoreCooldown && r.Node.CooldownRemaining(peerChannel.ShortChannelId) > 0 {
+				r.Node.Logln(glightning.Debug, "skipping candidate cooling down:", peerChannel.ShortChannelId)
+				continue
+			}
 			// let's see if this channel is a candidate
 			if r.IsGoodCandidate(peerChannel) {
 				direction := r.GetCandidateDirection(p.Id)
@@ -102,6 +107,17 @@ func (r *AbstractRebalance) FireCandidates() {
 	r.Node.Logln(glightning.Debug, "AmountRebalanced: ", r.AmountRebalanced, ", InFlightAmount: ", r.InFlightAmount, ", Total amount:", r.amount)
 	r.Node.Logln(glightning.Debug, "Carry on: ", carryOn, ", splits in flight: ", splitsInFlight)
 	for carryOn && splitsInFlight < r.splits {
+		// the chunks in flight are waited for, their results are part of the result of the command
+		if r.ctx.Err() != nil {
+			r.Node.Logln(glightning.Info, "deadline exceeded, waiting for the chunks in flight")
+			r.DeadlineExceeded = true
+			break
+		}
+		if !r.canAffordChunk() {
+			r.Node.Logln(glightning.Info, "fee budget exhausted, spent ", r.FeesSpent, "msat out of ", r.feeBudget, "msat")
+			r.BudgetExhausted = true
+			break
+		}
 		candidate, err := r.GetNextCandidate()
 		if err != nil {
 			// no candidate left
@@ -4,6 +4,7 @@ import (
 	"circular/graph"
 	"circular/node"
 	rebalance2 "circular/rebalance"
+	"context"
 	"github.com/elementsproject/glightning/glightning"
 	"github.com/gammazero/deque"
 	"sync"
@@ -34,12 +35,23 @@ type AbstractRebalance struct {
 	maxPPM              uint64
 	splits              int
 	splitAmount         uint64
+	feeBudget           uint64
+	FeesSpent           uint64
+	cheapestPPM         uint64
+	BudgetExhausted     bool
 	attempts            int
 	maxHops             int
+	ignoreCooldown      bool
+	// ctx ends at the deadline of the command: no chunk is fired after it, see circular-rebalance-deadline
+	ctx              context.Context
+	cancel           context.CancelFunc
+	DeadlineExceeded bool
+	// diversity keeps the channels used by the chunks, so that the next ones avoid them, see circular-chunk-max-overlap
+	diversity *rebalance2.ChunkDiversity
 	RebalanceMethods
 }
 
-func (r *AbstractRebalance) Init(amount, maxppm, splitamount uint64, splits, attempts, maxhops int) {
+func (r *AbstractRebalance) Init(amount, maxppm, splitamount, feebudget uint64, splits, attempts, maxhops int) {
 	r.Node = node.GetNode()
 	r.AmountLock = &sync.Mutex{}
 	r.QueueLock = &sync.Mutex{}
@@ -49,9 +61,16 @@ func (r *AbstractRebalance) Init(amount, maxppm, splitamount uint64, splits, att
 	r.amount = amount
 	r.maxPPM = maxppm
 	r.splitAmount = splitamount
+	r.feeBudget = feebudget
 	r.splits = splits
 	r.attempts = attempts
 	r.maxHops = maxhops
+	if r.Node.RebalanceDeadline > 0 {
+		r.ctx, r.cancel = context.WithTimeout(context.Background(), r.Node.RebalanceDeadline)
+	} else {
+		r.ctx, r.cancel = context.WithCancel(context.Background())
+	}
+	r.diversity = rebalance2.NewChunkDiversity(r.Node.ChunkMaxOverlap)
 	r.setGenericDefaults()
 	r.Node.Logln(glightning.Debug, "AbstractRebalance initialized")
 }
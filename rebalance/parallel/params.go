@@ -3,6 +3,8 @@ package parallel
 import (
 	"circular/rebalance"
 	"circular/util"
+	"fmt"
+	"time"
 )
 
 const (
@@ -22,10 +24,10 @@ func (r *AbstractRebalance) setGenericDefaults() {
 		r.splitAmount = DEFAULT_SPLIT_AMOUNT
 	}
 	if r.maxPPM == 0 {
-		r.maxPPM = rebalance.DEFAULT_MAXPPM
+		r.maxPPM = rebalance.DefaultMaxPPM(r.Node)
 	}
 	if r.attempts <= 0 {
-		r.attempts = rebalance.DEFAULT_ATTEMPTS
+		r.attempts = rebalance.DefaultAttempts(r.Node)
 	}
 	if r.maxHops <= 0 {
 		r.maxHops = rebalance.DEFAULT_MAXHOPS
@@ -33,10 +35,12 @@ func (r *AbstractRebalance) setGenericDefaults() {
 
 	r.AmountRebalanced = 0
 	r.InFlightAmount = 0
+	r.FeesSpent = 0
 
 	// convert to msat
 	r.amount *= 1000
 	r.splitAmount *= 1000
+	r.feeBudget *= 1000
 }
 
 func (r *AbstractRebalance) validateGenericParameters() error {
@@ -46,5 +50,20 @@ func (r *AbstractRebalance) validateGenericParameters() error {
 	if r.amount%r.splitAmount != 0 {
 		return util.ErrAmountNotMultipleOfSplitAmount
 	}
+	if r.splitAmount < r.Node.MinAmount {
+		return util.NewAmountTooSmallError(r.splitAmount, r.Node.MinAmount)
+	}
+	return nil
+}
+
+// checkCooldown fails if the target channel is cooling down from a previous rebalance, unless the cooldown is ignored
+func (r *AbstractRebalance) checkCooldown() error {
+	if r.ignoreCooldown {
+		return nil
+	}
+	if remaining := r.Node.CooldownRemaining(r.TargetChannel.ShortChannelId); remaining > 0 {
+		return fmt.Errorf("%w: %s can be used again in %s", util.ErrChannelCoolingDown,
+			r.TargetChannel.ShortChannelId, remaining.Round(time.Second))
+	}
 	return nil
 }
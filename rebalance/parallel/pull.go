@@ -26,6 +26,8 @@ type RebalancePull struct {
 	DepleteUpToAmount  uint64   `json:"depleteuptoamount,omitempty"`
 	Attempts           int      `json:"attempts,omitempty"`
 	MaxHops            int      `json:"maxhops,omitempty"`
+	FeeBudget          uint64   `json:"feebudget,omitempty"`
+	IgnoreCooldown     bool     `json:"ignorecooldown,omitempty"`
 	AbstractRebalance
 }
 
@@ -42,7 +44,7 @@ func (r *RebalancePull) Call() (jrpc2.Result, error) {
 	if r.InScid == "" {
 		return nil, util.ErrNoRequiredParameter
 	}
-	r.Init(r.Amount, r.MaxPPM, r.SplitAmount, r.Splits, r.Attempts, r.MaxHops)
+	r.Init(r.Amount, r.MaxPPM, r.SplitAmount, r.FeeBudget, r.Splits, r.Attempts, r.MaxHops)
 
 	r.CandidatesList = r.OutList
 	if r.CandidatesList != nil {
@@ -63,6 +65,13 @@ func (r *RebalancePull) Call() (jrpc2.Result, error) {
 		return nil, err
 	}
 	r.TargetChannel = incomingChannel
+	r.ignoreCooldown = r.IgnoreCooldown
+	if err = r.checkCooldown(); err != nil {
+		return nil, err
+	}
+	if err = r.Node.CheckSpendCap(); err != nil {
+		return nil, err
+	}
 
 	if err = r.FindCandidates(r.TargetChannel.Source); err != nil {
 		return nil, err
@@ -105,7 +114,10 @@ func (r *RebalancePull) CanUseChannel(channel *glightning.PeerChannel) error {
 
 func (r *RebalancePull) Fire(candidate *graph.Channel) {
 	r.Node.Logln(glightning.Debug, "Firing candidate: ", candidate.ShortChannelId, " for attempts: ", r.attempts)
-	rebalance := rebalance2.NewRebalance(candidate, r.TargetChannel, r.splitAmount, r.maxPPM, r.attempts, r.maxHops)
+	rebalance := rebalance2.NewRebalance(candidate, r.TargetChannel, r.splitAmount, r.chunkMaxPPM(), r.attempts, r.maxHops)
+	rebalance.Command = r.Name()
+	rebalance.WithContext(r.ctx)
+	rebalance.WithDiversity(r.diversity)
 
 	go func() {
 		r.RebalanceResultChan <- rebalance.Run()
@@ -24,8 +24,12 @@ type RebalancePush struct {
 	SplitAmount     uint64   `json:"splitamount,omitempty"`
 	Attempts        int      `json:"attempts,omitempty"`
 	MaxHops         int      `json:"maxhops,omitempty"`
+	FeeBudget       uint64   `json:"feebudget,omitempty"`
 	FillUpToPercent float64  `json:"filluptopercent,omitempty"`
 	FillUpToAmount  uint64   `json:"filluptoamount,omitempty"`
+	IgnoreCooldown  bool     `json:"ignorecooldown,omitempty"`
+	// Targets are the nodes that our future payments should reach cheaply, see sortCandidatesByReach
+	Targets []string `json:"targets,omitempty"`
 	AbstractRebalance
 }
 
@@ -42,7 +46,7 @@ func (r *RebalancePush) Call() (jrpc2.Result, error) {
 	if r.OutScid == "" {
 		return nil, util.ErrNoRequiredParameter
 	}
-	r.Init(r.Amount, r.MaxPPM, r.SplitAmount, r.Splits, r.Attempts, r.MaxHops)
+	r.Init(r.Amount, r.MaxPPM, r.SplitAmount, r.FeeBudget, r.Splits, r.Attempts, r.MaxHops)
 
 	r.CandidatesList = r.InList
 	if r.CandidatesList != nil {
@@ -62,10 +66,21 @@ func (r *RebalancePush) Call() (jrpc2.Result, error) {
 		return nil, err
 	}
 	r.TargetChannel = outgoingChannel
+	r.ignoreCooldown = r.IgnoreCooldown
+	if err = r.checkCooldown(); err != nil {
+		return nil, err
+	}
+	if err = r.Node.CheckSpendCap(); err != nil {
+		return nil, err
+	}
 
 	if err = r.FindCandidates(r.TargetChannel.Destination); err != nil {
 		return nil, err
 	}
+	if len(r.Targets) > 0 {
+		r.Node.Logln(glightning.Info, "ranking candidates by how cheaply they reach ", len(r.Targets), " targets")
+		r.sortCandidatesByReach(r.Targets)
+	}
 
 	r.FireCandidates()
 	return r.WaitForResult()
@@ -114,7 +129,10 @@ func (r *RebalancePush) CanUseChannel(channel *glightning.PeerChannel) error {
 
 func (r *RebalancePush) Fire(candidate *graph.Channel) {
 	r.Node.Logln(glightning.Debug, "Firing candidate: ", candidate.ShortChannelId, " for attempts: ", r.attempts)
-	rebalance := rebalance2.NewRebalance(r.TargetChannel, candidate, r.splitAmount, r.maxPPM, r.attempts, r.maxHops)
+	rebalance := rebalance2.NewRebalance(r.TargetChannel, candidate, r.splitAmount, r.chunkMaxPPM(), r.attempts, r.maxHops)
+	rebalance.Command = r.Name()
+	rebalance.WithContext(r.ctx)
+	rebalance.WithDiversity(r.diversity)
 
 	go func() {
 		r.RebalanceResultChan <- rebalance.Run()
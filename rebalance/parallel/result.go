@@ -2,6 +2,7 @@ package parallel
 
 import (
 	"circular/rebalance"
+	"circular/util"
 	"fmt"
 	"github.com/elementsproject/glightning/glightning"
 	"github.com/elementsproject/glightning/jrpc2"
@@ -17,7 +18,13 @@ type Result struct {
 	RebalancedAmount uint64             `json:"rebalanced_amount"`
 	Attempts         uint64             `json:"attempts"`
 	Time             string             `json:"time"`
+	FeeBudget        uint64             `json:"fee_budget_msat,omitempty"`
+	FeesSpent        uint64             `json:"fees_spent_msat"`
+	Message          string             `json:"message,omitempty"`
+	DeadlineExceeded bool               `json:"deadline_exceeded,omitempty"`
 	Successes        map[string]Success `json:"successes"`
+	// Diversity tells how many different channels the chunks that succeeded went through
+	Diversity *rebalance.DiversityReport `json:"diversity,omitempty"`
 }
 
 func NewResult(target uint64) *Result {
@@ -70,8 +77,23 @@ func (r *AbstractRebalance) WaitForResult() (jrpc2.Result, error) {
 	}
 
 	// rebalance is over
+	r.cancel()
 	r.Result.Attempts = r.TotalAttempts
 	r.Result.Time = fmt.Sprintf("%.3fs", float64(time.Since(start).Milliseconds())/1000)
+	r.Result.FeeBudget = r.feeBudget
+	r.Result.FeesSpent = r.FeesSpent
+	if report := r.diversity.Report(); report.Chunks > 0 {
+		r.Result.Diversity = &report
+	}
+	if r.BudgetExhausted && r.AmountRebalanced < r.amount {
+		r.Result.Message = fmt.Sprintf("fee budget exhausted: rebalanced %d out of %d sats, spending %.3f out of %.3f sats in fees",
+			r.AmountRebalanced/1000, r.amount/1000, float64(r.FeesSpent)/1000, float64(r.feeBudget)/1000)
+	}
+	if r.DeadlineExceeded && r.AmountRebalanced < r.amount {
+		r.Result.DeadlineExceeded = true
+		r.Result.Message = fmt.Sprintf("%s: rebalanced %d out of %d sats, spending %.3f sats in fees",
+			util.ErrRebalanceDeadline, r.AmountRebalanced/1000, r.amount/1000, float64(r.FeesSpent)/1000)
+	}
 	return r.Result, nil
 }
 
@@ -82,6 +104,7 @@ func (r *AbstractRebalance) UpdateAmounts(result *rebalance.Result) {
 	r.InFlightAmount -= r.splitAmount
 	if result.Status == "success" {
 		r.AmountRebalanced += r.splitAmount
+		r.addFees(result.Fee, result.PPM)
 
 		// not really a good way to do it, but we need to do this to make sure we don't
 		// overshoot the Deplete/Fill amount. This is necessary because otherwise the
@@ -89,5 +112,9 @@ func (r *AbstractRebalance) UpdateAmounts(result *rebalance.Result) {
 		outScid := result.Route.Hops[0].ShortChannelId
 		inScid := result.Route.Hops[len(result.Route.Hops)-1].ShortChannelId
 		r.Node.UpdateChannelBalance(result.Out, result.In, outScid, inScid, result.Amount)
+	} else if result.Delivered > 0 {
+		// the parts of a split route that settled moved part of the split anyway
+		r.AmountRebalanced += result.Delivered
+		r.addFees(result.Fee, result.Fee*1000000/result.Delivered)
 	}
 }
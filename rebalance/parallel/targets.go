@@ -0,0 +1,69 @@
+package parallel
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/gammazero/deque"
+	"sort"
+)
+
+const (
+	// TARGET_UNREACHABLE_PPM is what a target that can't be reached from a peer costs, and the most a reachable one can
+	TARGET_UNREACHABLE_PPM = 10000
+)
+
+// reachCostPPM is what forwarding amount from peer to each of targets would cost, in ppm of amount, added up over
+// the targets. Every target costs the fees of the cheapest route found from peer, the fee of the channel of peer
+// included, since our payments would go through it; a target that can't be reached costs TARGET_UNREACHABLE_PPM.
+// The peer itself costs nothing.
+func reachCostPPM(g *graph.Graph, peer string, targets []string, amount uint64, exclude map[string]bool, maxHops int, options *graph.RouteOptions) uint64 {
+	var cost uint64
+	for _, target := range targets {
+		if target == peer {
+			continue
+		}
+		route, err := g.GetRoute(peer, target, amount, exclude, maxHops, options)
+		if err != nil {
+			cost += TARGET_UNREACHABLE_PPM
+			continue
+		}
+		cost += util.Min(route.FeePPM(), TARGET_UNREACHABLE_PPM)
+	}
+	return cost
+}
+
+// rankByReach sorts the incoming candidates by the reach cost of their peers towards targets, cheapest first,
+// keeping the original order between equal costs. It returns the cost of every peer too.
+func rankByReach(g *graph.Graph, candidates []*graph.Channel, targets []string, amount uint64, exclude map[string]bool, maxHops int, options *graph.RouteOptions) ([]*graph.Channel, map[string]uint64) {
+	costs := make(map[string]uint64)
+	for _, candidate := range candidates {
+		if _, ok := costs[candidate.Source]; !ok {
+			costs[candidate.Source] = reachCostPPM(g, candidate.Source, targets, amount, exclude, maxHops, options)
+		}
+	}
+	ranked := append([]*graph.Channel{}, candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return costs[ranked[i].Source] < costs[ranked[j].Source]
+	})
+	return ranked, costs
+}
+
+// sortCandidatesByReach puts first the candidates whose peers can forward our future payments to targets the cheapest
+func (r *AbstractRebalance) sortCandidatesByReach(targets []string) {
+	r.QueueLock.Lock()
+	defer r.QueueLock.Unlock()
+
+	candidates := make([]*graph.Channel, 0, r.Candidates.Len())
+	for r.Candidates.Len() > 0 {
+		candidates = append(candidates, r.Candidates.PopFront())
+	}
+	exclude := map[string]bool{r.Node.Id: true}
+	ranked, costs := rankByReach(r.Node.Graph, candidates, targets, r.splitAmount, exclude, r.maxHops, r.Node.RouteOptions)
+
+	r.Candidates = deque.New[*graph.Channel]()
+	for _, candidate := range ranked {
+		r.Node.Logln(glightning.Debug, "candidate ", candidate.ShortChannelId, " reaches the targets for ", costs[candidate.Source], "ppm")
+		r.Candidates.PushBack(candidate)
+	}
+}
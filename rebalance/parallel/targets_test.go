@@ -0,0 +1,45 @@
+package parallel
+
+import (
+	"circular/graph"
+	"circular/graph/graphtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRankByReach(t *testing.T) {
+	id := graphtest.NodeId
+	us, cheap, expensive, isolated, target := id(0), id(1), id(2), id(3), id(4)
+	g := graph.NewGraph()
+	candidates := []*graph.Channel{
+		graphtest.AddChannel(g, graphtest.NewChannel(isolated, us, "1x1x1", 1000000, 0, 1, 40)),
+		graphtest.AddChannel(g, graphtest.NewChannel(expensive, us, "2x2x2", 1000000, 0, 1, 40)),
+		graphtest.AddChannel(g, graphtest.NewChannel(cheap, us, "3x3x3", 1000000, 0, 1, 40)),
+	}
+	graphtest.AddChannel(g, graphtest.NewChannel(us, isolated, "1x1x1", 1000000, 0, 1, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(us, expensive, "2x2x2", 1000000, 0, 1, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(us, cheap, "3x3x3", 1000000, 0, 1, 40))
+	// both reach the target directly, one of them for much less
+	graphtest.AddChannel(g, graphtest.NewChannel(cheap, target, "4x4x4", 1000000, 0, 10, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(target, cheap, "4x4x4", 1000000, 0, 10, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(expensive, target, "5x5x5", 1000000, 0, 2000, 40))
+	graphtest.AddChannel(g, graphtest.NewChannel(target, expensive, "5x5x5", 1000000, 0, 2000, 40))
+
+	options := graph.NewRouteOptions()
+	options.CacheRoutes = false
+	exclude := map[string]bool{us: true}
+	ranked, costs := rankByReach(g, candidates, []string{target}, 100000000, exclude, 8, options)
+
+	if assert.Len(t, ranked, 3) {
+		assert.Equal(t, "3x3x3", ranked[0].ShortChannelId)
+		assert.Equal(t, "2x2x2", ranked[1].ShortChannelId)
+		assert.Equal(t, "1x1x1", ranked[2].ShortChannelId)
+	}
+	assert.Equal(t, uint64(10), costs[cheap])
+	assert.Equal(t, uint64(2000), costs[expensive])
+	// the only way from the isolated peer goes through us, which is excluded
+	assert.Equal(t, uint64(TARGET_UNREACHABLE_PPM), costs[isolated])
+
+	// a target that is the peer itself costs nothing
+	assert.Equal(t, uint64(0), reachCostPPM(g, target, []string{target}, 100000000, exclude, 8, options))
+}
@@ -2,8 +2,14 @@ package rebalance
 
 import (
 	"circular/graph"
+	"circular/node"
 	"circular/util"
+	"encoding/json"
+	"fmt"
 	"github.com/elementsproject/glightning/glightning"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -14,6 +20,45 @@ const (
 	DEFAULT_MAXHOPS  = 8
 )
 
+// parseAmount resolves the amount parameter, in sats: a number, or a percentage of capacity (sats)
+// such as "20%", which can't resolve below minAmount (msat). It returns 0 when the amount is missing,
+// so that the default is used.
+func parseAmount(raw json.RawMessage, capacity, minAmount uint64) (uint64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		// not a string, it must be a number of sats
+		var amount uint64
+		if err := json.Unmarshal(raw, &amount); err != nil {
+			return 0, util.ErrInvalidAmount
+		}
+		return amount, nil
+	}
+
+	text = strings.TrimSpace(text)
+	if !strings.HasSuffix(text, "%") {
+		amount, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return 0, util.ErrInvalidAmount
+		}
+		return amount, nil
+	}
+	percentage, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(text, "%")), 64)
+	if err != nil || percentage <= 0 {
+		return 0, util.ErrInvalidAmount
+	}
+	if percentage > 100 {
+		return 0, util.ErrAmountAboveCapacity
+	}
+	amount := uint64(float64(capacity) * percentage / 100)
+	if amount == 0 || amount*1000 < minAmount {
+		return 0, util.NewAmountTooSmallError(amount*1000, minAmount)
+	}
+	return amount, nil
+}
+
 func (r *Rebalance) checkConnections(inChannel, outChannel *glightning.PeerChannel) error {
 	//validate that the channels are in normal state
 	if inChannel.State != NORMAL {
@@ -35,15 +80,95 @@ func (r *Rebalance) checkConnections(inChannel, outChannel *glightning.PeerChann
 
 func (r *Rebalance) checkLiquidity(inChannel, outChannel *glightning.PeerChannel) error {
 	//validate that the amount is less than the liquidity of the channels
-	if (inChannel.MilliSatoshiTotal - inChannel.MilliSatoshiToUs) < r.Amount {
+	if err := r.fitAmountToOutbound(r.Node.LocalBalance(outChannel)); err != nil {
+		return err
+	}
+	if r.Node.RemoteBalance(inChannel) < r.Amount {
 		return util.ErrIncomingChannelDepleted
 	}
-	if (outChannel.MilliSatoshiToUs) < r.Amount {
-		return util.ErrOutgoingChannelDepleted
+	return nil
+}
+
+// availableOutbound is what we can send through the outgoing channel: the balance from listpeers or,
+// if the channel isn't among the peers, the liquidity believed by the graph
+func (r *Rebalance) availableOutbound() uint64 {
+	if outChannel, err := r.Node.GetPeerChannelFromGraphChannel(r.OutChannel); err == nil {
+		return r.Node.LocalBalance(outChannel)
+	}
+	return r.OutChannel.Liquidity
+}
+
+// fitAmountToOutbound fails if the amount is more than available, or lowers it to available
+// with circular-reduce-to-outbound
+func (r *Rebalance) fitAmountToOutbound(available uint64) error {
+	amount, err := fitOutbound(r.Amount, available, r.Node.MinAmount, r.Node.ReduceToOutbound)
+	if err != nil {
+		return err
+	}
+	if amount < r.Amount {
+		r.Node.Logln(glightning.Info, "reducing the amount from ", r.Amount/1000, " to ", amount/1000,
+			" sats, the local balance of ", r.OutChannel.ShortChannelId)
+		r.Amount = amount
+	}
+	return nil
+}
+
+// fitOutbound returns amount if available is enough to send it. Otherwise, if reduce is true, it returns
+// available in whole sats, as long as it's at least minAmount.
+func fitOutbound(amount, available, minAmount uint64, reduce bool) (uint64, error) {
+	if amount <= available {
+		return amount, nil
+	}
+	if !reduce {
+		return 0, fmt.Errorf("%w: %d msat available, %d msat needed", util.ErrInsufficientLocalOutbound, available, amount)
+	}
+	reduced := available - available%1000
+	if reduced < minAmount {
+		return 0, fmt.Errorf("%w: %d msat available, less than the minimum amount of %d msat",
+			util.ErrInsufficientLocalOutbound, available, minAmount)
+	}
+	return reduced, nil
+}
+
+// checkCooldown fails if one of the channels of the rebalance is cooling down from a previous rebalance
+func (r *Rebalance) checkCooldown() error {
+	for _, scid := range []string{r.OutChannel.ShortChannelId, r.InChannel.ShortChannelId} {
+		if remaining := r.Node.CooldownRemaining(scid); remaining > 0 {
+			return fmt.Errorf("%w: %s can be used again in %s", util.ErrChannelCoolingDown, scid, remaining.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+func validateChannels(out, in *graph.Channel) error {
+	if out.ShortChannelId == in.ShortChannelId {
+		return util.ErrSameIncomingAndOutgoingChannel
+	}
+	return nil
+}
+
+func validateAmount(amount, minAmount uint64) error {
+	if amount < minAmount {
+		return util.NewAmountTooSmallError(amount, minAmount)
+	}
+	return nil
+}
+
+func validateFinalCltv(finalCltv, minFinalCltv uint) error {
+	if finalCltv < minFinalCltv {
+		return fmt.Errorf("%w: %d, less than %d", util.ErrFinalCltvTooLow, finalCltv, minFinalCltv)
 	}
 	return nil
 }
 
+// defaultFinalCltv is graph.INITIAL_DELAY, unless lightningd requires more on the last hop
+func defaultFinalCltv(minFinalCltv uint) uint {
+	if minFinalCltv > graph.INITIAL_DELAY {
+		return minFinalCltv
+	}
+	return graph.INITIAL_DELAY
+}
+
 func (r *Rebalance) validateLiquidityParameters(out, in *graph.Channel) error {
 	r.Node.Logln(glightning.Debug, "validating liquidity parameters")
 
@@ -76,6 +201,30 @@ func (r *Rebalance) validateLiquidityParameters(out, in *graph.Channel) error {
 	return nil
 }
 
+// maxHopsOrDefault distinguishes an explicit maxhops=0 (direct route only) from a missing parameter
+func maxHopsOrDefault(maxHops *int) int {
+	if maxHops == nil {
+		return DEFAULT_MAXHOPS
+	}
+	return *maxHops
+}
+
+// DefaultMaxPPM is the maxppm of the rebalances that don't set one, see circular-default-maxppm
+func DefaultMaxPPM(n *node.Node) uint64 {
+	if n.DefaultMaxPPM > 0 {
+		return n.DefaultMaxPPM
+	}
+	return DEFAULT_MAXPPM
+}
+
+// DefaultAttempts is the number of attempts of the rebalances that don't set one, see circular-default-attempts
+func DefaultAttempts(n *node.Node) int {
+	if n.DefaultAttempts > 0 {
+		return n.DefaultAttempts
+	}
+	return DEFAULT_ATTEMPTS
+}
+
 func (r *Rebalance) setDefaults() {
 	//convert to msatoshi
 	r.Amount *= 1000
@@ -84,14 +233,17 @@ func (r *Rebalance) setDefaults() {
 		r.Node.Logln(glightning.Debug, "amount not provided, using default value", r.Amount)
 	}
 	if r.MaxPPM == 0 {
-		r.MaxPPM = DEFAULT_MAXPPM
+		r.MaxPPM = DefaultMaxPPM(r.Node)
 		r.Node.Logln(glightning.Debug, "maxPPM not provided, using default value", r.MaxPPM)
 	}
 	if r.Attempts <= 0 {
-		r.Attempts = DEFAULT_ATTEMPTS
+		r.Attempts = DefaultAttempts(r.Node)
 		r.Node.Logln(glightning.Debug, "attempts not provided, using default value", r.Attempts)
 	}
-	if r.MaxHops <= 0 {
+	if r.FinalCltv == 0 {
+		r.FinalCltv = defaultFinalCltv(r.Node.MinFinalCltv())
+	}
+	if r.MaxHops < 0 {
 		r.MaxHops = DEFAULT_MAXHOPS
 		r.Node.Logln(glightning.Debug, "maxHops not provided, using default value", r.MaxHops)
 	}
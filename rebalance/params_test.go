@@ -0,0 +1,102 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/util"
+	"encoding/json"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateChannels(t *testing.T) {
+	self := "020000000000000000000000000000000000000000000000000000000000000000"
+	peer := "020000000000000000000000000000000000000000000000000000000000000001"
+	out := graph.NewChannel(&glightning.Channel{Source: self, Destination: peer, ShortChannelId: "1x1x1"}, 0, 0)
+	in := graph.NewChannel(&glightning.Channel{Source: peer, Destination: self, ShortChannelId: "1x1x1"}, 0, 0)
+	other := graph.NewChannel(&glightning.Channel{Source: peer, Destination: self, ShortChannelId: "2x2x2"}, 0, 0)
+
+	assert.Equal(t, util.ErrSameIncomingAndOutgoingChannel, validateChannels(out, in))
+	assert.NoError(t, validateChannels(out, other))
+}
+
+func TestValidateFinalCltv(t *testing.T) {
+	assert.ErrorIs(t, validateFinalCltv(graph.MIN_FINAL_CLTV-1, graph.MIN_FINAL_CLTV), util.ErrFinalCltvTooLow)
+	assert.NoError(t, validateFinalCltv(graph.MIN_FINAL_CLTV, graph.MIN_FINAL_CLTV))
+	assert.NoError(t, validateFinalCltv(graph.INITIAL_DELAY, graph.MIN_FINAL_CLTV))
+	// a node with a higher cltv-final
+	assert.ErrorIs(t, validateFinalCltv(graph.MIN_FINAL_CLTV, 40), util.ErrFinalCltvTooLow)
+
+	assert.Equal(t, uint(graph.INITIAL_DELAY), defaultFinalCltv(graph.MIN_FINAL_CLTV))
+	assert.Equal(t, uint(200), defaultFinalCltv(200))
+}
+
+func TestValidateAmount(t *testing.T) {
+	err := validateAmount(999000, 1000000)
+	assert.Equal(t, util.NewAmountTooSmallError(999000, 1000000), err)
+	assert.Contains(t, err.Error(), "base fees")
+	assert.NoError(t, validateAmount(1000000, 1000000))
+}
+
+func TestParseAmount(t *testing.T) {
+	capacity, minAmount := uint64(5000000), uint64(50000000)
+	parse := func(raw string) (uint64, error) {
+		return parseAmount(json.RawMessage(raw), capacity, minAmount)
+	}
+
+	amount, err := parse("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), amount)
+	amount, err = parse("200000")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(200000), amount)
+	amount, err = parse(`"200000"`)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(200000), amount)
+
+	// percentages of the capacity of the outgoing channel
+	amount, err = parse(`"20%"`)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000000), amount)
+	amount, err = parse(`"2.5 %"`)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(125000), amount)
+	amount, err = parse(`"100%"`)
+	assert.NoError(t, err)
+	assert.Equal(t, capacity, amount)
+
+	_, err = parse(`"101%"`)
+	assert.Equal(t, util.ErrAmountAboveCapacity, err)
+	_, err = parse(`"0.5%"`)
+	assert.Equal(t, util.NewAmountTooSmallError(25000000, minAmount), err)
+	for _, invalid := range []string{`"0%"`, `"-5%"`, `"abc%"`, `"twenty"`, "-1", "1.5", "true"} {
+		_, err = parse(invalid)
+		assert.Equal(t, util.ErrInvalidAmount, err, invalid)
+	}
+}
+
+func TestFitOutbound(t *testing.T) {
+	minAmount := uint64(50000000)
+
+	// enough local balance, the amount is kept in both modes
+	for _, reduce := range []bool{false, true} {
+		amount, err := fitOutbound(100000000, 100000000, minAmount, reduce)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(100000000), amount)
+	}
+
+	// fail fast
+	_, err := fitOutbound(100000000, 80000500, minAmount, false)
+	assert.ErrorIs(t, err, util.ErrInsufficientLocalOutbound)
+	assert.Contains(t, err.Error(), "80000500 msat available")
+
+	// reduced to the balance, in whole sats
+	amount, err := fitOutbound(100000000, 80000500, minAmount, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(80000000), amount)
+
+	// unless the balance is below the minimum amount
+	_, err = fitOutbound(100000000, 40000000, minAmount, true)
+	assert.ErrorIs(t, err, util.ErrInsufficientLocalOutbound)
+	assert.Contains(t, err.Error(), "minimum amount")
+}
@@ -0,0 +1,227 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/util"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	PROGRESS_PENDING = "pending"
+	PROGRESS_RUNNING = "running"
+	PROGRESS_DONE    = "done"
+	// MAX_PROGRESS is the number of rebalances whose progress is kept
+	MAX_PROGRESS = 100
+	// PROGRESS_RETENTION is how long the progress of a finished rebalance can still be polled
+	PROGRESS_RETENTION = time.Hour
+)
+
+// Progress is how far a rebalance got: the parts are the payments sent, more than one per attempt
+// when the route goes through aggregated parallel channels
+type Progress struct {
+	Id           string  `json:"id"`
+	Command      string  `json:"command"`
+	OutScid      string  `json:"outscid"`
+	InScid       string  `json:"inscid"`
+	Amount       uint64  `json:"amount_msat"`
+	Status       string  `json:"status"`
+	Cancelled    bool    `json:"cancelled,omitempty"`
+	Attempt      int     `json:"attempt"`
+	PartsSent    int     `json:"parts_sent"`
+	PartsSettled int     `json:"parts_settled"`
+	Delivered    uint64  `json:"delivered_msat"`
+	Fee          uint64  `json:"fee_msat"`
+	StartedAt    int64   `json:"started_at"`
+	FinishedAt   int64   `json:"finished_at,omitempty"`
+	Result       *Result `json:"result,omitempty"`
+	// PartAmounts are the amounts delivered by the parts sent, in msat, see graph.Route.Split
+	PartAmounts []uint64 `json:"part_amounts_msat,omitempty"`
+}
+
+// progresses contains the progress of the rebalances by id
+var progresses = struct {
+	lock    sync.Mutex
+	entries map[string]*Progress
+}{entries: make(map[string]*Progress)}
+
+func newRebalanceId() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(id)
+}
+
+// trackProgress starts tracking the progress of r with status, if it isn't tracked yet.
+// It returns a copy of the progress.
+func (r *Rebalance) trackProgress(status string) Progress {
+	progresses.lock.Lock()
+	defer progresses.lock.Unlock()
+	if progress, ok := progresses.entries[r.Id]; ok {
+		return *progress
+	}
+	pruneProgress(time.Now())
+	progress := &Progress{
+		Id:        r.Id,
+		Command:   r.Command,
+		OutScid:   r.OutChannel.ShortChannelId,
+		InScid:    r.InChannel.ShortChannelId,
+		Amount:    r.Amount,
+		Status:    status,
+		StartedAt: time.Now().Unix(),
+	}
+	progresses.entries[r.Id] = progress
+	return *progress
+}
+
+// pruneProgress drops the finished rebalances older than PROGRESS_RETENTION and, if there are still
+// MAX_PROGRESS of them, the ones that finished first. The caller must hold the lock.
+func pruneProgress(now time.Time) {
+	finished := make([]*Progress, 0)
+	for id, progress := range progresses.entries {
+		if progress.Status != PROGRESS_DONE {
+			continue
+		}
+		if now.Sub(time.Unix(progress.FinishedAt, 0)) > PROGRESS_RETENTION {
+			delete(progresses.entries, id)
+			continue
+		}
+		finished = append(finished, progress)
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt < finished[j].FinishedAt
+	})
+	for i := 0; len(progresses.entries) >= MAX_PROGRESS && i < len(finished); i++ {
+		delete(progresses.entries, finished[i].Id)
+	}
+}
+
+// updateProgress applies update to the progress of r, if it's tracked
+func (r *Rebalance) updateProgress(update func(*Progress)) {
+	progresses.lock.Lock()
+	defer progresses.lock.Unlock()
+	if progress, ok := progresses.entries[r.Id]; ok {
+		update(progress)
+	}
+}
+
+// settleProgress records that the payment of route settled
+func (r *Rebalance) settleProgress(route *graph.Route, pretty *graph.PrettyRoute) {
+	r.updateProgress(func(p *Progress) {
+		p.PartsSettled++
+		p.Delivered += route.Amount
+		p.Fee += pretty.Fee
+	})
+}
+
+// finishProgress records the result of r
+func (r *Rebalance) finishProgress(result *Result) {
+	r.updateProgress(func(p *Progress) {
+		p.Status = PROGRESS_DONE
+		p.FinishedAt = time.Now().Unix()
+		p.Result = result
+	})
+}
+
+// isCancelled tells whether circular-cancel was called on r
+func (r *Rebalance) isCancelled() bool {
+	progresses.lock.Lock()
+	defer progresses.lock.Unlock()
+	progress, ok := progresses.entries[r.Id]
+	return ok && progress.Cancelled
+}
+
+func getProgress(id string) (Progress, bool) {
+	progresses.lock.Lock()
+	defer progresses.lock.Unlock()
+	progress, ok := progresses.entries[id]
+	if !ok {
+		return Progress{}, false
+	}
+	return *progress, true
+}
+
+// cancelProgress marks the rebalance with id as cancelled: it stops before its next attempt
+func cancelProgress(id string) (Progress, error) {
+	progresses.lock.Lock()
+	defer progresses.lock.Unlock()
+	progress, ok := progresses.entries[id]
+	if !ok {
+		return Progress{}, util.ErrNoSuchRebalance
+	}
+	if progress.Status == PROGRESS_DONE {
+		return Progress{}, util.ErrRebalanceFinished
+	}
+	progress.Cancelled = true
+	return *progress, nil
+}
+
+// RunAsync starts the rebalance in the background and returns its progress right away.
+// The result, with the route in format, is in the progress once it's done.
+func (r *Rebalance) RunAsync(format string) Progress {
+	progress := r.trackProgress(PROGRESS_PENDING)
+	go func() {
+		result, err := r.RunDeduplicated()
+		if err != nil {
+			result = NewResult("failure", r.Amount/1000, r.OutChannel.Destination, r.InChannel.Source)
+			result.Message = err.Error()
+			result.Id = r.Id
+		}
+		result.formatRoute(format)
+		r.finishProgress(result)
+	}()
+	r.Node.Logln(glightning.Info, "rebalance ", r.Id, " from ", progress.OutScid, " to ", progress.InScid, " started")
+	return progress
+}
+
+type ProgressRequest struct {
+	Id string `json:"id"`
+}
+
+func (p *ProgressRequest) Name() string {
+	return "circular-progress"
+}
+
+func (p *ProgressRequest) New() interface{} {
+	return &ProgressRequest{}
+}
+
+func (p *ProgressRequest) Call() (jrpc2.Result, error) {
+	if p.Id == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+	progress, ok := getProgress(p.Id)
+	if !ok {
+		return nil, util.ErrNoSuchRebalance
+	}
+	return &progress, nil
+}
+
+type Cancel struct {
+	Id string `json:"id"`
+}
+
+func (c *Cancel) Name() string {
+	return "circular-cancel"
+}
+
+func (c *Cancel) New() interface{} {
+	return &Cancel{}
+}
+
+func (c *Cancel) Call() (jrpc2.Result, error) {
+	if c.Id == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+	progress, err := cancelProgress(c.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
@@ -0,0 +1,71 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/node"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newProgressTestRebalance() *Rebalance {
+	return &Rebalance{
+		Id:         newRebalanceId(),
+		OutChannel: &graph.Channel{Channel: &glightning.Channel{ShortChannelId: "1x1x1"}},
+		InChannel:  &graph.Channel{Channel: &glightning.Channel{ShortChannelId: "2x2x2"}},
+		Amount:     100000000,
+		Command:    "circular",
+		Node:       &node.Node{},
+	}
+}
+
+func TestProgressCancel(t *testing.T) {
+	r := newProgressTestRebalance()
+	progress := r.trackProgress(PROGRESS_PENDING)
+	assert.Equal(t, PROGRESS_PENDING, progress.Status)
+	assert.False(t, r.isCancelled())
+
+	r.updateProgress(func(p *Progress) {
+		p.Attempt = 2
+	})
+	progress, err := cancelProgress(r.Id)
+	assert.NoError(t, err)
+	assert.True(t, progress.Cancelled)
+	assert.Equal(t, 2, progress.Attempt)
+	assert.True(t, r.isCancelled())
+
+	r.finishProgress(NewResult("failure", 100000, "out", "in"))
+	_, err = cancelProgress(r.Id)
+	assert.Equal(t, util.ErrRebalanceFinished, err)
+	_, err = cancelProgress("unknown")
+	assert.Equal(t, util.ErrNoSuchRebalance, err)
+}
+
+func TestProgressIsBounded(t *testing.T) {
+	now := time.Now()
+	progresses.lock.Lock()
+	progresses.entries = make(map[string]*Progress)
+	progresses.entries["old"] = &Progress{Id: "old", Status: PROGRESS_DONE, FinishedAt: now.Add(-2 * PROGRESS_RETENTION).Unix()}
+	progresses.entries["running"] = &Progress{Id: "running", Status: PROGRESS_RUNNING}
+	for i := 0; i < MAX_PROGRESS; i++ {
+		id := strconv.Itoa(i)
+		progresses.entries[id] = &Progress{Id: id, Status: PROGRESS_DONE, FinishedAt: now.Unix() - int64(MAX_PROGRESS-i)}
+	}
+	progresses.lock.Unlock()
+
+	newProgressTestRebalance().trackProgress(PROGRESS_RUNNING)
+
+	_, ok := getProgress("old")
+	assert.False(t, ok)
+	_, ok = getProgress("running")
+	assert.True(t, ok)
+	// the rebalances that finished first make room for the new one
+	_, ok = getProgress("0")
+	assert.False(t, ok)
+	_, ok = getProgress(strconv.Itoa(MAX_PROGRESS - 1))
+	assert.True(t, ok)
+	assert.Equal(t, MAX_PROGRESS, len(progresses.entries))
+}
@@ -0,0 +1,323 @@
+package rebalance
+
+import (
+	"circular/node"
+	"circular/util"
+	"container/heap"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	QUEUE_STATUS_PENDING = "pending"
+	QUEUE_STATUS_RUNNING = "running"
+	QUEUE_STATUS_DONE    = "done"
+	// QUEUE_HISTORY is the number of finished rebalances kept for circular-queue
+	QUEUE_HISTORY = 20
+	// QUEUE_HOLD_RETRY is how often a held queue checks whether it can start its rebalances
+	QUEUE_HOLD_RETRY = 30 * time.Second
+)
+
+var (
+	queue     *rebalanceQueue
+	queueOnce sync.Once
+)
+
+// QueuedRebalance is a rebalance waiting in the queue, running or finished
+type QueuedRebalance struct {
+	Id int `json:"id"`
+	// RebalanceId is the id of the rebalance in circular-progress and circular-cancel
+	RebalanceId string `json:"rebalance_id"`
+	OutScid     string `json:"outscid"`
+	InScid      string `json:"inscid"`
+	// Amount is in sats, as requested
+	Amount     uint64  `json:"amount"`
+	Priority   int     `json:"priority"`
+	Status     string  `json:"status"`
+	EnqueuedAt int64   `json:"enqueued_at"`
+	StartedAt  int64   `json:"started_at,omitempty"`
+	Result     *Result `json:"result,omitempty"`
+	run        func() *Result
+	index      int
+}
+
+// queuedHeap implements heap.Interface: the highest priority comes first, then the oldest request
+type queuedHeap []*QueuedRebalance
+
+func (h queuedHeap) Len() int { return len(h) }
+
+func (h queuedHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].Id < h[j].Id
+}
+
+func (h queuedHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *queuedHeap) Push(x any) {
+	item := x.(*QueuedRebalance)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *queuedHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[0 : n-1]
+	return item
+}
+
+// rebalanceQueue runs the queued rebalances by priority, at most concurrency at a time
+type rebalanceQueue struct {
+	lock        *sync.Mutex
+	concurrency int
+	nextId      int
+	pending     queuedHeap
+	running     map[int]*QueuedRebalance
+	history     []*QueuedRebalance
+	// hold keeps the pending rebalances from starting while it's true, see circular-queue-wait-for-self.
+	// holdTimer dispatches them again later, while they are held.
+	hold      func() bool
+	holdTimer *time.Timer
+}
+
+func newRebalanceQueue(concurrency int) *rebalanceQueue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &rebalanceQueue{
+		lock:        &sync.Mutex{},
+		concurrency: concurrency,
+		pending:     make(queuedHeap, 0),
+		running:     make(map[int]*QueuedRebalance),
+		history:     make([]*QueuedRebalance, 0, QUEUE_HISTORY),
+	}
+}
+
+func getQueue() *rebalanceQueue {
+	queueOnce.Do(func() {
+		n := node.GetNode()
+		queue = newRebalanceQueue(n.QueueConcurrency)
+		if n.QueueWaitForSelf {
+			queue.hold = func() bool {
+				return n.CheckSelfInGraph() != nil
+			}
+		}
+	})
+	return queue
+}
+
+// push adds item to the queue and starts it if there is a free slot.
+// It returns a copy of item as it was queued.
+func (q *rebalanceQueue) push(item *QueuedRebalance) QueuedRebalance {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.nextId++
+	item.Id = q.nextId
+	item.Status = QUEUE_STATUS_PENDING
+	item.EnqueuedAt = time.Now().Unix()
+	heap.Push(&q.pending, item)
+	q.dispatch()
+	return *item
+}
+
+// dispatch starts the pending rebalances with the highest priority while there are free slots.
+// While the queue is held, it tries again every QUEUE_HOLD_RETRY instead. The caller must hold the lock.
+func (q *rebalanceQueue) dispatch() {
+	if q.pending.Len() > 0 && q.isHeld() {
+		if q.holdTimer == nil {
+			q.holdTimer = time.AfterFunc(QUEUE_HOLD_RETRY, func() {
+				q.lock.Lock()
+				defer q.lock.Unlock()
+				q.holdTimer = nil
+				q.dispatch()
+			})
+		}
+		return
+	}
+	for len(q.running) < q.concurrency && q.pending.Len() > 0 {
+		item := heap.Pop(&q.pending).(*QueuedRebalance)
+		item.Status = QUEUE_STATUS_RUNNING
+		item.StartedAt = time.Now().Unix()
+		q.running[item.Id] = item
+		go func() {
+			result := item.run()
+			q.finish(item, result)
+		}()
+	}
+}
+
+func (q *rebalanceQueue) isHeld() bool {
+	return q.hold != nil && q.hold()
+}
+
+func (q *rebalanceQueue) finish(item *QueuedRebalance, result *Result) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	item.Status = QUEUE_STATUS_DONE
+	item.Result = result
+	delete(q.running, item.Id)
+	if len(q.history) == QUEUE_HISTORY {
+		q.history = q.history[1:]
+	}
+	q.history = append(q.history, item)
+	q.dispatch()
+}
+
+// snapshot returns the running rebalances, the pending ones in the order they will run
+// and the last finished ones
+func (q *rebalanceQueue) snapshot() *QueueResult {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	result := &QueueResult{
+		Concurrency: q.concurrency,
+		Held:        q.pending.Len() > 0 && q.isHeld(),
+		Running:     make([]QueuedRebalance, 0, len(q.running)),
+		Pending:     make([]QueuedRebalance, 0, q.pending.Len()),
+		Finished:    make([]QueuedRebalance, 0, len(q.history)),
+	}
+	for _, item := range q.running {
+		result.Running = append(result.Running, *item)
+	}
+	for _, item := range q.pending {
+		result.Pending = append(result.Pending, *item)
+	}
+	sort.Slice(result.Pending, func(i, j int) bool {
+		return queuedHeap{&result.Pending[i], &result.Pending[j]}.Less(0, 1)
+	})
+	sort.Slice(result.Running, func(i, j int) bool {
+		return result.Running[i].Id < result.Running[j].Id
+	})
+	for i := len(q.history) - 1; i >= 0; i-- {
+		result.Finished = append(result.Finished, *q.history[i])
+	}
+	return result
+}
+
+// depletionPriority is the priority of a rebalance filling a channel with the given balances:
+// the emptier the channel on our side, the higher the priority, from 0 to 100
+func depletionPriority(local, remote uint64) int {
+	if local+remote == 0 {
+		return 0
+	}
+	return int(100 - local*100/(local+remote))
+}
+
+type Enqueue struct {
+	OutScid  string `json:"outscid"`
+	InScid   string `json:"inscid"`
+	Amount   uint64 `json:"amount,omitempty"`
+	MaxPPM   uint64 `json:"maxppm,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+	MaxHops  *int   `json:"maxhops,omitempty"`
+	Priority *int   `json:"priority,omitempty"`
+	// IgnoreCooldown queues the rebalance even if its channels are cooling down
+	IgnoreCooldown bool `json:"ignorecooldown,omitempty"`
+}
+
+func (e *Enqueue) Name() string {
+	return "circular-enqueue"
+}
+
+func (e *Enqueue) New() interface{} {
+	return &Enqueue{}
+}
+
+func (e *Enqueue) Call() (jrpc2.Result, error) {
+	n := node.GetNode()
+	if e.InScid == "" || e.OutScid == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+
+	outgoingChannel, err := n.GetOutgoingChannelFromScid(e.OutScid)
+	if err != nil {
+		return nil, err
+	}
+	incomingChannel, err := n.GetIncomingChannelFromScid(e.InScid)
+	if err != nil {
+		return nil, err
+	}
+
+	rebalance := NewRebalance(outgoingChannel, incomingChannel, e.Amount, e.MaxPPM, e.Attempts, maxHopsOrDefault(e.MaxHops))
+	rebalance.MaxAlternates = n.MaxAlternateOuts
+	rebalance.Command = e.Name()
+	rebalance.IgnoreCooldown = e.IgnoreCooldown
+	// the balances, the peers and the cooldown are checked when the rebalance leaves the queue
+	if err := rebalance.validateParameters(); err != nil {
+		return nil, err
+	}
+	if err := validateAmount(rebalance.Amount, n.MinAmount); err != nil {
+		return nil, err
+	}
+
+	rebalance.trackProgress(PROGRESS_PENDING)
+	item := &QueuedRebalance{
+		RebalanceId: rebalance.Id,
+		OutScid:     e.OutScid,
+		InScid:      e.InScid,
+		Amount:      rebalance.Amount / 1000,
+		run: func() *Result {
+			// the channels might have changed while this one was waiting
+			if err := rebalance.checkState(); err != nil {
+				failure := NewResult("failure", rebalance.Amount/1000, outgoingChannel.Destination, incomingChannel.Source)
+				failure.Message = err.Error()
+				rebalance.finishProgress(failure)
+				return failure
+			}
+			result, err := rebalance.RunDeduplicated()
+			if err != nil {
+				failure := NewResult("failure", rebalance.Amount/1000, outgoingChannel.Destination, incomingChannel.Source)
+				failure.Message = err.Error()
+				rebalance.finishProgress(failure)
+				return failure
+			}
+			// Run wasn't called for a coalesced rebalance, its progress is still pending
+			if result.Coalesced {
+				rebalance.finishProgress(result)
+			}
+			return result
+		},
+	}
+	if e.Priority != nil {
+		item.Priority = *e.Priority
+	} else if channel, err := n.GetPeerChannelFromGraphChannel(incomingChannel); err == nil {
+		item.Priority = depletionPriority(n.LocalBalance(channel), n.RemoteBalance(channel))
+	}
+	queued := getQueue().push(item)
+	n.Logln(glightning.Info, "rebalance ", queued.Id, " from ", e.OutScid, " to ", e.InScid, " queued with priority ", queued.Priority)
+	return &queued, nil
+}
+
+type QueueResult struct {
+	Concurrency int `json:"concurrency"`
+	// Held is set while the pending rebalances wait for the graph to know a channel of ours
+	Held     bool              `json:"held,omitempty"`
+	Running  []QueuedRebalance `json:"running"`
+	Pending  []QueuedRebalance `json:"pending"`
+	Finished []QueuedRebalance `json:"finished"`
+}
+
+type Queue struct{}
+
+func (q *Queue) Name() string {
+	return "circular-queue"
+}
+
+func (q *Queue) New() interface{} {
+	return &Queue{}
+}
+
+func (q *Queue) Call() (jrpc2.Result, error) {
+	return getQueue().snapshot(), nil
+}
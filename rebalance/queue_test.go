@@ -0,0 +1,91 @@
+package rebalance
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestQueueRunsByPriority(t *testing.T) {
+	q := newRebalanceQueue(1)
+	release := make(chan struct{})
+	order := make(chan string, 4)
+	job := func(name string, priority int, wait bool) *QueuedRebalance {
+		return &QueuedRebalance{
+			OutScid:  name,
+			Priority: priority,
+			run: func() *Result {
+				if wait {
+					<-release
+				}
+				order <- name
+				return NewResult("success", 0, "", "")
+			},
+		}
+	}
+
+	// the first one starts right away and keeps the only slot busy
+	first := q.push(job("first", 0, true))
+	assert.Equal(t, QUEUE_STATUS_RUNNING, first.Status)
+	low := q.push(job("low", 1, false))
+	assert.Equal(t, QUEUE_STATUS_PENDING, low.Status)
+	q.push(job("high-1", 10, false))
+	q.push(job("high-2", 10, false))
+
+	// the pending ones are listed in the order they will run: by priority, then oldest first
+	snapshot := q.snapshot()
+	assert.Len(t, snapshot.Running, 1)
+	assert.Equal(t, []string{"high-1", "high-2", "low"},
+		[]string{snapshot.Pending[0].OutScid, snapshot.Pending[1].OutScid, snapshot.Pending[2].OutScid})
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return len(q.snapshot().Finished) == 4
+	}, time.Second, time.Millisecond)
+	close(order)
+	result := make([]string, 0, 4)
+	for name := range order {
+		result = append(result, name)
+	}
+	assert.Equal(t, []string{"first", "high-1", "high-2", "low"}, result)
+
+	snapshot = q.snapshot()
+	assert.Len(t, snapshot.Running, 0)
+	assert.Len(t, snapshot.Pending, 0)
+	// newest first
+	assert.Equal(t, "low", snapshot.Finished[0].OutScid)
+	assert.Equal(t, QUEUE_STATUS_DONE, snapshot.Finished[0].Status)
+}
+
+func TestQueueHeld(t *testing.T) {
+	q := newRebalanceQueue(1)
+	held := true
+	q.hold = func() bool { return held }
+	done := make(chan struct{})
+	item := q.push(&QueuedRebalance{run: func() *Result {
+		close(done)
+		return NewResult("success", 0, "", "")
+	}})
+
+	// the rebalance waits while the queue is held
+	assert.Equal(t, QUEUE_STATUS_PENDING, item.Status)
+	assert.True(t, q.snapshot().Held)
+	assert.NotNil(t, q.holdTimer)
+
+	// and starts once it isn't anymore
+	held = false
+	q.lock.Lock()
+	q.holdTimer.Stop()
+	q.holdTimer = nil
+	q.dispatch()
+	q.lock.Unlock()
+	<-done
+	assert.False(t, q.snapshot().Held)
+}
+
+func TestDepletionPriority(t *testing.T) {
+	assert.Equal(t, 100, depletionPriority(0, 1000))
+	assert.Equal(t, 50, depletionPriority(500, 500))
+	assert.Equal(t, 0, depletionPriority(1000, 0))
+	assert.Equal(t, 0, depletionPriority(0, 0))
+}
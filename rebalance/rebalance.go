@@ -4,6 +4,7 @@ import (
 	"circular/graph"
 	"circular/node"
 	"circular/util"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/elementsproject/glightning/glightning"
@@ -11,30 +12,106 @@ import (
 )
 
 type Rebalance struct {
+	// Id identifies the rebalance in circular-progress and circular-cancel
+	Id         string
 	OutChannel *graph.Channel
 	InChannel  *graph.Channel
 	Amount     uint64
 	MaxPPM     uint64
 	Attempts   int
 	MaxHops    int
-	Node       *node.Node
+	// FinalCltv is the delay of the last hop, to ourselves
+	FinalCltv uint
+	// MaxAlternates is the number of other outgoing channels to try when the first hop fails
+	MaxAlternates int
+	// Maximize treats Amount as an upper bound and moves the largest amount that fits in MaxPPM
+	Maximize bool
+	// Explain adds to the result the reasons why no route was found, if that's why the rebalance failed
+	Explain bool
+	// IgnoreCooldown allows the rebalance on channels that are still cooling down from a previous one
+	IgnoreCooldown bool
+	// MinCapacity and MaxCapacity (sats), when set, replace the capacity range of the node for this rebalance
+	MinCapacity uint64
+	MaxCapacity uint64
+	// Command is the name of the RPC that started the rebalance, reported in circular-last-error
+	Command   string
+	triedOuts map[string]bool
+	// excluded are the nodes blamed for the failures of previous attempts
+	excluded  map[string]bool
+	lastRoute *graph.Route
+	// recentChannels are the channels of the last routes of the same pair, see Node.RecallRoutes
+	recentChannels map[string]float64
+	Node           *node.Node
+	// exploring rebalances prefer the channels without evidence, see Node.ShouldExplore.
+	// probed are the channels without evidence that their attempts went through, see Node.RecordExploration
+	exploring bool
+	probed    map[string]bool
+	// ctx ends at the deadline of the command, see startDeadline
+	ctx context.Context
+	// diversity keeps the channels used by the other chunks of the same split rebalance, see WithDiversity
+	diversity *ChunkDiversity
+	// objective is the objective of the route search of the current attempt, see Node.RouteObjectives
+	objective string
+	// delivered and deliveredFee (msat) were moved by the parts of split routes that settled
+	// in attempts that failed, see sendParts
+	delivered    uint64
+	deliveredFee uint64
 }
 
 func NewRebalance(outChannel, inChannel *graph.Channel, amount, maxppm uint64, attempts, maxHops int) *Rebalance {
 	return &Rebalance{
+		Id:         newRebalanceId(),
 		OutChannel: outChannel,
 		InChannel:  inChannel,
 		Amount:     amount,
 		MaxPPM:     maxppm,
 		Attempts:   attempts,
 		MaxHops:    maxHops,
+		Command:    "circular",
 		Node:       node.GetNode(),
 	}
 }
 
 func (r *Rebalance) Setup() error {
+	if err := r.validateParameters(); err != nil {
+		return err
+	}
+	return r.checkState()
+}
+
+// validateParameters sets the defaults and checks the parameters that don't depend on the state of the channels
+func (r *Rebalance) validateParameters() error {
 	r.setDefaults()
 
+	if err := validateChannels(r.OutChannel, r.InChannel); err != nil {
+		return err
+	}
+
+	if err := validateFinalCltv(r.FinalCltv, r.Node.MinFinalCltv()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkState checks that the channels can be rebalanced right now: the cooldown, the balances and the peers
+func (r *Rebalance) checkState() error {
+	if r.Maximize {
+		if err := r.capAmountToBalances(); err != nil {
+			return err
+		}
+	}
+
+	if !r.IgnoreCooldown {
+		if err := r.checkCooldown(); err != nil {
+			return err
+		}
+	}
+
+	if err := validateAmount(r.Amount, r.Node.MinAmount); err != nil {
+		return err
+	}
+
 	if err := r.validateLiquidityParameters(r.OutChannel, r.InChannel); err != nil {
 		return err
 	}
@@ -42,22 +119,93 @@ func (r *Rebalance) Setup() error {
 	return nil
 }
 
+// Run runs the rebalance, tracking its progress
 func (r *Rebalance) Run() *Result {
+	r.trackProgress(PROGRESS_RUNNING)
+	r.updateProgress(func(p *Progress) {
+		p.Status = PROGRESS_RUNNING
+	})
+	cancel := r.startDeadline()
+	defer cancel()
+	var result *Result
+	if r.isCancelled() {
+		result = NewResult("failure", r.Amount/1000, r.OutChannel.Destination, r.InChannel.Source)
+		result.Message = util.ErrRebalanceCancelled.Error()
+	} else {
+		r.recentChannels = r.Node.RecallRoutes(r.OutChannel.ShortChannelId, r.InChannel.ShortChannelId)
+		r.exploring = r.Node.ShouldExplore()
+		r.probed = make(map[string]bool)
+		result = r.run()
+		if result.Status == "success" && r.lastRoute != nil {
+			r.Node.RememberRoute(r.OutChannel.ShortChannelId, r.InChannel.ShortChannelId, r.lastRoute.Scids())
+			if r.diversity != nil {
+				r.diversity.Record(r.lastRoute)
+			}
+		}
+	}
+	result.Id = r.Id
+	if r.isMaxPPMScaled() {
+		result.EffectiveMaxPPM = r.effectiveMaxPPM()
+	}
+	r.finishProgress(result)
+	return result
+}
+
+func (r *Rebalance) run() *Result {
 	var (
-		maxHops   = 3
+		maxHops   = FIRST_MAX_HOPS
 		i         = 1
 		lastError = ""
+		lastErr   error
 	)
+	if r.MaxHops == 0 {
+		// only the direct route through the common peer is allowed
+		maxHops = 0
+	}
+	// checked here rather than in Setup, so that circular-whatif and the queue are not refused in advance
+	if err := r.Node.CheckSpendCap(); err != nil {
+		failure := NewResult("failure", r.Amount/1000, r.OutChannel.Destination, r.InChannel.Source)
+		failure.Message = err.Error()
+		return failure
+	}
+	if r.Maximize {
+		if _, err := r.maximizeAmount(r.MaxHops); err != nil {
+			r.recordRouteError(err)
+			failure := NewResult("failure", r.Amount/1000, r.OutChannel.Destination, r.InChannel.Source)
+			failure.Message = "unable to find a route for any amount within " + strconv.FormatUint(r.MaxPPM, 10) +
+				" ppm: " + err.Error()
+			return failure
+		}
+	}
 	for i <= r.Attempts {
 		if maxHops > r.MaxHops {
+			r.recordRouteError(lastErr)
 			lastError = " Unable to find a route with less than " +
 				strconv.Itoa(r.MaxHops) + " hops. " + lastError
 			break
 		}
 		r.Node.Logln(glightning.Debug, "===================== ATTEMPT ", i, " =====================")
+		attempt := i
+		r.updateProgress(func(p *Progress) {
+			p.Attempt = attempt
+		})
+		if len(r.Node.RouteObjectives) > 1 {
+			r.objective = attemptObjective(r.Node.RouteObjectives, i)
+			r.Node.Logln(glightning.Debug, "looking for the ", r.objective, " route")
+		}
 
 		result, err := r.runAttempt(maxHops)
 
+		// the first hop failed, another outgoing channel might work
+		if (err == util.ErrFirstHopFailure || err == util.ErrFirstPeerNotReady) && r.switchOutChannel() {
+			lastError = err.Error()
+			continue
+		}
+
+		if err == util.ErrRebalanceDeadline {
+			return r.deadlineResult(i - 1)
+		}
+
 		// success
 		if err == nil {
 			result.Attempts = uint64(i)
@@ -69,6 +217,7 @@ func (r *Rebalance) Run() *Result {
 		if err == util.ErrNoRoute {
 			r.Node.Logln(glightning.Debug, "no route found with at most ", maxHops, " hops, increasing max hops to ", maxHops+1)
 			lastError = err.Error()
+			lastErr = err
 			maxHops += 1
 			continue
 		}
@@ -77,10 +226,29 @@ func (r *Rebalance) Run() *Result {
 		if errors.As(err, &util.ErrRouteTooExpensive{}) {
 			r.Node.Logln(glightning.Debug, err, ", increasing max hops to ", maxHops+1)
 			lastError = err.Error()
+			lastErr = err
 			maxHops += 1
 			continue
 		}
 
+		// the payment timed out and didn't complete, retry without the hop most likely to have stalled it
+		if err == util.ErrSendPayStalled {
+			lastError = err.Error()
+			if !r.excludeTightestHop(r.lastRoute) {
+				break
+			}
+			i++
+			continue
+		}
+
+		// the payment failed without telling where, the tightest hop is the most likely culprit
+		if (err == util.ErrUnattributedFailure || err == util.ErrSendPayTimeout) &&
+			r.Node.ExcludeTightestHop && r.excludeTightestHop(r.lastRoute) {
+			lastError = err.Error()
+			i++
+			continue
+		}
+
 		// sendpay timeout
 		if err == util.ErrSendPayTimeout {
 			lastError = "rebalancing timed out after " +
@@ -95,10 +263,13 @@ func (r *Rebalance) Run() *Result {
 			break
 		}
 
-		if err != util.ErrTemporaryFailure {
+		if err != util.ErrTemporaryFailure && err != util.ErrUnattributedFailure {
 			lastError = err.Error()
 			break
 		}
+		if i < r.Attempts {
+			r.waitBeforeRetry(i)
+		}
 		i++
 	}
 
@@ -106,6 +277,10 @@ func (r *Rebalance) Run() *Result {
 	failure.Attempts = uint64(i - 1)
 	failure.Message = "rebalance failed after " + strconv.Itoa(int(failure.Attempts)) + " attempts."
 	failure.Message += lastError
+	r.addDelivered(failure)
+	if r.Explain && lastErr == util.ErrNoRoute {
+		failure.Explanation = r.explainNoRoute()
+	}
 
 	return failure
 }
@@ -114,22 +289,42 @@ func (r *Rebalance) runAttempt(maxHops int) (*Result, error) {
 	if r.Node.Stopped {
 		return nil, util.ErrCircularStopped
 	}
-	
+
+	if r.isCancelled() {
+		return nil, util.ErrRebalanceCancelled
+	}
+
+	// the attempts already sent are over, the deadline only prevents new ones
+	if r.deadlineExceeded() {
+		return nil, util.ErrRebalanceDeadline
+	}
+
 	if err := r.validateLiquidityParameters(r.OutChannel, r.InChannel); err != nil {
 		return nil, err
 	}
 
+	if err := r.Node.CheckSpendCap(); err != nil {
+		return nil, err
+	}
+
 	route, err := r.tryRoute(maxHops)
 	if err != nil {
 		return nil, err
 	}
 
-	result := NewResult("success", r.Amount/1000,
+	// the parts of split routes that settled in previous attempts are part of the rebalance
+	result := NewResult("success", (r.Amount+r.delivered)/1000,
 		r.OutChannel.Destination, r.InChannel.Source)
 
-	result.Fee = route.Fee
+	result.OutScid = r.OutChannel.ShortChannelId
+	result.Fee = route.Fee + r.deliveredFee
 	result.PPM = route.FeePPM
+	if r.delivered > 0 {
+		result.PPM = result.Fee * 1000000 / (r.Amount + r.delivered)
+	}
 	result.Route = route
+	r.Node.MarkRebalanced(r.OutChannel.ShortChannelId, r.InChannel.ShortChannelId)
+	r.Node.RecordFee(route.Fee)
 	result.Message = fmt.Sprintf("successfully rebalanced %d sats from %s to %s at %d ppm. Total fees paid: %.3f sats",
 		result.Amount, r.Node.Graph.GetAlias(r.OutChannel.Destination), r.Node.Graph.GetAlias(r.InChannel.Source),
 		result.PPM, float64(result.Fee)/1000)
@@ -3,16 +3,34 @@ package rebalance
 import "circular/graph"
 
 type Result struct {
-	Status     string             `json:"status"`
-	Message    string             `json:"message"`
-	Amount     uint64             `json:"amount"`
-	Out        string             `json:"out"`
-	In         string             `json:"in"`
-	Attempts   uint64             `json:"attempts"`
-	Fee        uint64             `json:"fee,omitempty"`
-	PPM        uint64             `json:"ppm,omitempty"`
-	Route      *graph.PrettyRoute `json:"route,omitempty"`
-	FormatHint string             `json:"format-hint,omitempty"`
+	Id          string             `json:"id,omitempty"`
+	Status      string             `json:"status"`
+	Message     string             `json:"message"`
+	Amount      uint64             `json:"amount"`
+	Out         string             `json:"out"`
+	In          string             `json:"in"`
+	OutScid     string             `json:"outscid,omitempty"`
+	Attempts    uint64             `json:"attempts"`
+	Fee         uint64             `json:"fee,omitempty"`
+	PPM         uint64             `json:"ppm,omitempty"`
+	Route       *graph.PrettyRoute `json:"route,omitempty"`
+	RouteText   string             `json:"route_text,omitempty"`
+	Explanation *graph.Explanation `json:"explanation,omitempty"`
+	FormatHint  string             `json:"format-hint,omitempty"`
+	// ResolvedAmount is the amount requested, in msat, once a percentage of the capacity is resolved
+	ResolvedAmount uint64 `json:"resolved_amount_msat,omitempty"`
+	// EffectiveMaxPPM is the maxppm that applied to the amount, when it's scaled by amount
+	EffectiveMaxPPM uint64 `json:"effective_maxppm,omitempty"`
+	// StaleGraph is set when the route was found on a stale graph, see graph.PrettyRoute
+	StaleGraph bool `json:"stale_graph,omitempty"`
+	// Coalesced is set on the result of an identical rebalance that was already in flight
+	Coalesced bool `json:"coalesced,omitempty"`
+	// SendPayRoute replaces the route with ROUTE_FORMAT_SENDPAY
+	SendPayRoute []graph.SendPayHop `json:"sendpay_route,omitempty"`
+	// DeadlineExceeded is set when the rebalance stopped at its deadline, having delivered Delivered (msat).
+	// A failed rebalance also reports as Delivered what the parts of split routes that settled moved.
+	DeadlineExceeded bool   `json:"deadline_exceeded,omitempty"`
+	Delivered        uint64 `json:"delivered_msat,omitempty"`
 }
 
 func NewResult(status string, amount uint64, src, dst string) *Result {
@@ -23,3 +41,20 @@ func NewResult(status string, amount uint64, src, dst string) *Result {
 		In:     dst,
 	}
 }
+
+// formatRoute replaces the route with its text form, or its sendpay form, unless format is ROUTE_FORMAT_JSON
+func (r *Result) formatRoute(format string) {
+	if r.Route == nil {
+		return
+	}
+	r.StaleGraph = r.Route.StaleGraph
+	if format == "" || format == graph.ROUTE_FORMAT_JSON {
+		return
+	}
+	if format == graph.ROUTE_FORMAT_SENDPAY {
+		r.SendPayRoute = r.Route.SendPayRoute()
+	} else {
+		r.RouteText = r.Route.Format(format)
+	}
+	r.Route = nil
+}
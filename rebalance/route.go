@@ -4,68 +4,263 @@ import (
 	"circular/graph"
 	"circular/node"
 	"circular/util"
+	"errors"
 	"github.com/elementsproject/glightning/glightning"
 	"time"
 )
 
-func (r *Rebalance) getRoute(maxHops int) (*graph.Route, error) {
+const (
+	STABILITY_CHECK_ATTEMPTS = 3
+)
+
+// explainNoRoute tells why getRoute found no route between the peers of the two channels with at most MaxHops
+func (r *Rebalance) explainNoRoute() *graph.Explanation {
+	exclude := map[string]bool{r.Node.Id: true}
+	return r.Node.Graph.Explain(r.OutChannel.Destination, r.InChannel.Source, r.Amount, exclude, r.MaxHops, r.routeOptions())
+}
+
+// routeOptions are the route options of the node, with the capacity range of the rebalance if it has one,
+// biased towards the channels without evidence if it explores and with the objective of the attempt
+func (r *Rebalance) routeOptions() *graph.RouteOptions {
+	objective := r.routeObjective()
+	if r.MinCapacity == 0 && r.MaxCapacity == 0 && len(r.recentChannels) == 0 && !r.exploring &&
+		objective == r.Node.RouteOptions.Objective {
+		return r.Node.RouteOptions
+	}
+	options := *r.Node.RouteOptions
+	if r.exploring {
+		options = *r.Node.ExploreOptions(&options)
+	}
+	// the cached routes were found with the range of the node and without the recent routes
+	options.CacheRoutes = false
+	options.RecentChannels = r.recentChannels
+	options.Objective = objective
+	if r.MinCapacity > 0 {
+		options.MinCapacity = r.MinCapacity
+	}
+	if r.MaxCapacity > 0 {
+		options.MaxCapacity = r.MaxCapacity
+	}
+	return &options
+}
+
+// findRoute searches the route of the rebalance with the objective of routeObjective, see getRoute
+func (r *Rebalance) findRoute(maxHops int) (*graph.Route, error) {
 	defer util.TimeTrack(time.Now(), "rebalance.getRoute", r.Node.Logf)
 	exclude := make(map[string]bool)
 	exclude[r.Node.Id] = true
+	for id := range r.excluded {
+		exclude[id] = true
+	}
+
+	// a route can't carry more than what the outgoing channel can send
+	if err := r.fitAmountToOutbound(r.availableOutbound()); err != nil {
+		return nil, err
+	}
 
 	src := r.OutChannel.Destination
 	dst := r.InChannel.Source
+	if excluded := r.Node.ExcludeDeadNodes(exclude, src, dst); excluded > 0 {
+		r.Node.Logln(glightning.Debug, "excluding ", excluded, " nodes that look offline")
+	}
+
+	maxPPM := r.attemptMaxPPM()
+	options := r.routeOptions()
+	if options.StrictPrivate && (!r.OutChannel.IsPublic || !r.InChannel.IsPublic) {
+		return nil, util.ErrPrivateChannelNotAllowed
+	}
+
+	if options.StrictCapacity && (options.IsOutsideCapacityRange(r.OutChannel) || options.IsOutsideCapacityRange(r.InChannel)) {
+		return nil, util.ErrChannelOutsideCapacityRange
+	}
+
+	if r.Node.IsGraphStale() {
+		r.Node.Logln(glightning.Unusual, "warning: looking for a route on a stale graph, last successful refresh was at ",
+			r.Node.GetGraphHealth().LastRefresh)
+	}
+	if err := r.Node.CheckSelfInGraph(); err != nil {
+		return nil, err
+	}
+
+	// maxHops=0 means that only the two local legs can be used
+	if maxHops == 0 {
+		r.Node.Logln(glightning.Debug, "building a direct route through ", r.Node.Graph.GetAlias(src))
+		route, err := newDirectRoute(r.OutChannel, r.InChannel, r.Amount, r.FinalCltv, r.Node.RouteOptions.DelayPadding, r.Node.Graph)
+		if err != nil {
+			return nil, err
+		}
+		if err := route.CheckHtlcBounds(); err != nil {
+			return nil, err
+		}
+		if err := route.CheckFeeBounds(); err != nil {
+			return nil, err
+		}
+		if route.FeePPM() > maxPPM {
+			return nil, util.NewRouteTooExpensiveError(route.FeePPM(), maxPPM)
+		}
+		if err := r.Node.CheckAttemptFee(route.Fee()); err != nil {
+			return nil, err
+		}
+		return route, nil
+	}
+	// there is no route to look for from a peer to itself
+	if src == dst {
+		return nil, util.ErrSamePeer
+	}
 
 	r.Node.Logln(glightning.Debug, "looking for a route from ", r.Node.Graph.GetAlias(src), " to ", r.Node.Graph.GetAlias(dst))
-	route, err := r.Node.Graph.GetRoute(src, dst, r.Amount, exclude, maxHops)
-	if err != nil {
+	route, ok := r.Node.Graph.GetFavoriteRoute(src, dst, r.Amount, exclude, maxHops, options)
+	var err error
+	if ok {
+		r.Node.Logln(glightning.Debug, "using the route found in advance to ", r.Node.Graph.GetAlias(dst))
+	} else if route, err = r.Node.Graph.GetRoute(src, dst, r.Amount, exclude, maxHops, options); err != nil {
 		return nil, err
 	}
+	if r.Node.RouteOptions.StabilityCheck {
+		route, err = r.checkStability(route, exclude, maxHops)
+		if err != nil {
+			return nil, err
+		}
+	}
+	route = r.diversify(route, exclude, maxHops, maxPPM, options)
 
+	route.FinalCltv = r.FinalCltv
+	route.DelayPadding = r.Node.RouteOptions.DelayPadding
 	route.Prepend(r.OutChannel)
 	route.Append(r.InChannel)
 
-	if route.FeePPM() > r.MaxPPM {
-		return nil, util.NewRouteTooExpensiveError(route.FeePPM(), r.MaxPPM)
+	if err := route.CheckLength(r.Node.RouteOptions.MaxRouteLength); err != nil {
+		return nil, err
+	}
+
+	// the local legs were not checked by the pathfinding, and they carry all the fees
+	if err := route.CheckHtlcBounds(); err != nil {
+		return nil, err
+	}
+	if err := route.CheckFeeBounds(); err != nil {
+		return nil, err
+	}
+
+	if baseFees := route.BaseFees(); baseFees > route.Fee()/2 {
+		r.Node.Logf(glightning.Unusual, "warning: base fees are %d msat out of %d msat of fees, the amount is small for this route",
+			baseFees, route.Fee())
+	}
+
+	// every part of a split route pays the base fees of all its hops
+	if feePPM := route.SplitFee() * 1000000 / route.Amount; feePPM > maxPPM {
+		return nil, util.NewRouteTooExpensiveError(feePPM, maxPPM)
+	}
+	// the ppm is rounded down, the fee must fit in the daily cap to the msat
+	if err := r.Node.CheckAttemptFee(route.SplitFee()); err != nil {
+		return nil, err
 	}
 
 	return route, nil
 }
 
+// newDirectRoute concatenates the two local legs, which must share the peer
+func newDirectRoute(out, in *graph.Channel, amount uint64, finalCltv, delayPadding uint, g *graph.Graph) (*graph.Route, error) {
+	if out.Destination != in.Source {
+		return nil, util.ErrNoCommonPeer
+	}
+	route := graph.NewRoute(out.Destination, in.Source, amount, []graph.RouteHop{}, g)
+	route.FinalCltv = finalCltv
+	route.DelayPadding = delayPadding
+	route.Append(out)
+	route.Append(in)
+	return route, nil
+}
+
+// checkStability searches the route again after a short delay and returns it only
+// if consecutive searches agree. This avoids sending on a graph that's mid-update.
+func (r *Rebalance) checkStability(route *graph.Route, exclude map[string]bool, maxHops int) (*graph.Route, error) {
+	for i := 0; i < STABILITY_CHECK_ATTEMPTS; i++ {
+		time.Sleep(r.Node.RouteOptions.StabilityDelay)
+
+		next, err := r.Node.Graph.GetRoute(route.Source, route.Destination, r.Amount, exclude, maxHops, r.routeOptions())
+		if err != nil {
+			return nil, err
+		}
+		if route.IsEquivalent(next) {
+			return next, nil
+		}
+		r.Node.Logln(glightning.Debug, "route changed between consecutive searches, retrying")
+		route = next
+	}
+	return nil, util.ErrUnstableRoute
+}
+
 func (r *Rebalance) tryRoute(maxHops int) (*graph.PrettyRoute, error) {
-	paymentSecretHash, err := r.Node.GeneratePreimageHashPair()
+	r.Node.Logln(glightning.Debug, "generating route")
+	route, err := r.getRoute(maxHops)
 	if err != nil {
 		return nil, err
 	}
 
-	r.Node.Logln(glightning.Debug, "generating route")
-	route, err := r.getRoute(maxHops)
+	if err := r.Node.CheckRoute(route); err != nil {
+		r.Node.Logln(glightning.Info, err)
+		return nil, err
+	}
+	r.lastRoute = route
+	if r.exploring {
+		r.Node.RecordExploration(route, r.probed)
+	}
+
+	if parts := route.Split(); len(parts) > 1 {
+		return r.sendParts(route, parts)
+	}
+	r.updateProgress(func(p *Progress) {
+		p.PartsSent++
+	})
+	pretty, err := sendRoute(r.Node, route, r.Command, r.params())
+	if err == nil {
+		r.settleProgress(route, pretty)
+	}
+	return pretty, err
+}
+
+// withGraphAge adds to pretty the age of the graph of n
+func withGraphAge(n *node.Node, pretty *graph.PrettyRoute) *graph.PrettyRoute {
+	pretty.SetGraphAge(n.GraphAge())
+	return pretty
+}
+
+// sendRoute pays ourselves through route with a new preimage, saving the route to the DB.
+// Failures are recorded for circular-last-error as failures of command called with params.
+func sendRoute(n *node.Node, route *graph.Route, command string, params any) (*graph.PrettyRoute, error) {
+	paymentSecretHash, err := n.GeneratePreimageHashPair()
 	if err != nil {
 		return nil, err
 	}
 
-	prettyRoute := graph.NewPrettyRoute(route, paymentSecretHash)
+	prettyRoute := withGraphAge(n, graph.NewPrettyRoute(route, paymentSecretHash))
 
 	// save route to DB
-	if err := r.Node.SaveToDb(node.ROUTE_PREFIX+paymentSecretHash, prettyRoute); err != nil {
-		r.Node.Logln(glightning.Unusual, "unable to save route to db: ", err)
+	if err := n.SaveToDb(node.ROUTE_PREFIX+paymentSecretHash, prettyRoute); err != nil {
+		n.Logln(glightning.Unusual, "unable to save route to db: ", err)
 	}
-	r.Node.Logln(glightning.Debug, prettyRoute)
-	r.Node.Logln(glightning.Info, prettyRoute.Simple())
+	n.Logln(glightning.Debug, prettyRoute)
+	n.Logln(glightning.Info, prettyRoute.Simple())
 
-	_, err = r.Node.SendPay(route, paymentSecretHash)
+	result, err := n.SendPay(route, paymentSecretHash)
 	if err != nil {
-		if err == util.ErrSendPayTimeout {
+		n.RecordPaymentError(command, params, err)
+		if err == util.ErrSendPayTimeout || err == util.ErrSendPayStalled {
 			return nil, err
 		}
 		if err == util.ErrWireFeeInsufficient {
 			return nil, err
 		}
-		if err == util.ErrFirstPeerNotReady {
+		if err == util.ErrFirstPeerNotReady || err == util.ErrFirstHopFailure {
 			return nil, err
 		}
+		var paymentError *glightning.PaymentError
+		if !errors.As(err, &paymentError) || paymentError.Data == nil {
+			return nil, util.ErrUnattributedFailure
+		}
 		return nil, util.ErrTemporaryFailure
 	}
+	n.SaveProof(paymentSecretHash, result, prettyRoute)
 
 	return prettyRoute, nil
 }
@@ -0,0 +1,115 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewDirectRoute(t *testing.T) {
+	self := "020000000000000000000000000000000000000000000000000000000000000000"
+	peer := "020000000000000000000000000000000000000000000000000000000000000001"
+	other := "020000000000000000000000000000000000000000000000000000000000000002"
+	out := graph.NewChannel(&glightning.Channel{Source: self, Destination: peer, ShortChannelId: "1x1x1", Delay: 40}, 0, 0)
+	in := graph.NewChannel(&glightning.Channel{Source: peer, Destination: self, ShortChannelId: "2x2x2",
+		BaseFeeMillisatoshi: 1000, FeePerMillionth: 100, Delay: 80}, 0, 0)
+	amount := uint64(100000000)
+
+	route, err := newDirectRoute(out, in, amount, graph.INITIAL_DELAY, 0, graph.NewGraph())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(route.Hops))
+	// we only pay the fee of the peer forwarding back to us through the incoming channel
+	assert.Equal(t, in.ComputeFee(amount), route.Fee())
+	assert.Equal(t, amount+in.ComputeFee(amount), route.Hops[0].MilliSatoshi)
+	assert.Equal(t, amount, route.Hops[1].MilliSatoshi)
+	assert.Equal(t, uint(graph.INITIAL_DELAY)+in.Delay, route.Hops[0].Delay)
+	assert.Equal(t, uint(graph.INITIAL_DELAY), route.Hops[1].Delay)
+
+	notShared := graph.NewChannel(&glightning.Channel{Source: other, Destination: self, ShortChannelId: "3x3x3"}, 0, 0)
+	_, err = newDirectRoute(out, notShared, amount, graph.INITIAL_DELAY, 0, graph.NewGraph())
+	assert.Equal(t, util.ErrNoCommonPeer, err)
+}
+
+func TestMaxHopsOrDefault(t *testing.T) {
+	zero := 0
+	assert.Equal(t, DEFAULT_MAXHOPS, maxHopsOrDefault(nil))
+	assert.Equal(t, 0, maxHopsOrDefault(&zero))
+}
+
+func TestTightestHopNode(t *testing.T) {
+	self := "020000000000000000000000000000000000000000000000000000000000000000"
+	out := "020000000000000000000000000000000000000000000000000000000000000001"
+	a := "020000000000000000000000000000000000000000000000000000000000000002"
+	b := "020000000000000000000000000000000000000000000000000000000000000003"
+	in := "020000000000000000000000000000000000000000000000000000000000000004"
+	channel := func(src, dst, scid string, liquidity uint64) *graph.Channel {
+		return graph.NewChannel(&glightning.Channel{Source: src, Destination: dst, ShortChannelId: scid}, liquidity, 0)
+	}
+	amount := uint64(100000000)
+	hops := []graph.RouteHop{
+		{Channel: channel(out, a, "2x2x2", 5*amount), MilliSatoshi: amount},
+		{Channel: channel(a, b, "3x3x3", 2*amount), MilliSatoshi: amount},
+		{Channel: channel(b, in, "4x4x4", 3*amount), MilliSatoshi: amount},
+	}
+	route := graph.NewRoute(out, in, amount, hops, graph.NewGraph())
+	route.Prepend(channel(self, out, "1x1x1", 0))
+	route.Append(channel(in, self, "5x5x5", 0))
+
+	// the local legs have no liquidity, but they are not blamed: a->b is the tightest
+	assert.Equal(t, 2, route.TightestHop())
+	id, ok := tightestHopNode(route, out, in)
+	assert.True(t, ok)
+	assert.Equal(t, a, id)
+
+	// out->a is the tightest, but out can't be excluded, a forwards the htlc next
+	route.Hops[1].Liquidity = amount
+	id, ok = tightestHopNode(route, out, in)
+	assert.True(t, ok)
+	assert.Equal(t, a, id)
+
+	// with a single intermediate hop, the only nodes on it are the peers of our channels
+	direct := graph.NewRoute(out, in, amount, []graph.RouteHop{{Channel: channel(out, in, "6x6x6", amount), MilliSatoshi: amount}}, graph.NewGraph())
+	direct.Prepend(channel(self, out, "1x1x1", 0))
+	direct.Append(channel(in, self, "5x5x5", 0))
+	_, ok = tightestHopNode(direct, out, in)
+	assert.False(t, ok)
+}
+
+func TestSendPayRouteMatchesSendPay(t *testing.T) {
+	self := "020000000000000000000000000000000000000000000000000000000000000000"
+	out := "020000000000000000000000000000000000000000000000000000000000000001"
+	a := "020000000000000000000000000000000000000000000000000000000000000002"
+	in := "020000000000000000000000000000000000000000000000000000000000000003"
+	channel := func(src, dst, scid string, base, ppm uint64, delay uint) *graph.Channel {
+		return graph.NewChannel(&glightning.Channel{Source: src, Destination: dst, ShortChannelId: scid,
+			BaseFeeMillisatoshi: base, FeePerMillionth: ppm, Delay: delay}, 0, 0)
+	}
+	amount := uint64(100000000)
+	hops := []graph.RouteHop{
+		{Channel: channel(out, a, "2x2x2", 1000, 100, 40), MilliSatoshi: amount},
+		{Channel: channel(a, in, "3x3x3", 0, 500, 144), MilliSatoshi: amount},
+	}
+	// the same steps as getRoute
+	route := graph.NewRoute(out, in, amount, hops, graph.NewGraph())
+	route.FinalCltv = 50
+	route.DelayPadding = 6
+	route.Prepend(channel(self, out, "1x1x1", 0, 0, 40))
+	route.Append(channel(in, self, "4x4x4", 2000, 10, 80))
+
+	sent := route.ToLightningRoute()
+	result := NewResult("success", amount/1000, out, in)
+	result.Route = graph.NewPrettyRoute(route, "")
+	result.formatRoute(graph.ROUTE_FORMAT_SENDPAY)
+	assert.Nil(t, result.Route)
+	assert.Equal(t, len(sent), len(result.SendPayRoute))
+	for i, hop := range result.SendPayRoute {
+		assert.Equal(t, sent[i].Id, hop.Id)
+		assert.Equal(t, sent[i].ShortChannelId, hop.Channel)
+		assert.Equal(t, sent[i].Delay, hop.Delay)
+		assert.Equal(t, sent[i].MilliSatoshi, hop.AmountMsat)
+	}
+	assert.Equal(t, self, result.SendPayRoute[len(sent)-1].Id)
+	assert.Equal(t, amount, result.SendPayRoute[len(sent)-1].AmountMsat)
+}
@@ -0,0 +1,86 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"fmt"
+	"github.com/elementsproject/glightning/glightning"
+	"strings"
+)
+
+type partOutcome struct {
+	route  *graph.Route
+	pretty *graph.PrettyRoute
+	err    error
+}
+
+// sendParts sends at the same time the routes that route was split into, one for each of the parallel
+// channels aggregated by the pathfinding. Every part is a payment of its own, with its own preimage,
+// so a part can settle while another one fails: in that case what was delivered is paid for and reported
+// in the result, see addDelivered, the amount of the rebalance is lowered by it, and the attempt fails
+// like a route that failed.
+func (r *Rebalance) sendParts(route *graph.Route, parts []*graph.Route) (*graph.PrettyRoute, error) {
+	amounts := make([]uint64, len(parts))
+	for i, part := range parts {
+		amounts[i] = part.Amount
+	}
+	r.Node.Logln(glightning.Info, "sending ", len(parts), " parts through parallel channels, of ", amounts, " msat")
+	outcomes := make(chan partOutcome, len(parts))
+	r.updateProgress(func(p *Progress) {
+		p.PartsSent += len(parts)
+		p.PartAmounts = append(p.PartAmounts, amounts...)
+	})
+	for _, part := range parts {
+		go func(part *graph.Route) {
+			pretty, err := sendRoute(r.Node, part, r.Command, r.params())
+			outcomes <- partOutcome{route: part, pretty: pretty, err: err}
+		}(part)
+	}
+
+	var (
+		delivered uint64
+		fee       uint64
+		hashes    []string
+		firstErr  error
+	)
+	for range parts {
+		outcome := <-outcomes
+		if outcome.err != nil {
+			if firstErr == nil {
+				firstErr = outcome.err
+			}
+			continue
+		}
+		r.settleProgress(outcome.route, outcome.pretty)
+		delivered += outcome.route.Amount
+		fee += outcome.pretty.Fee
+		hashes = append(hashes, outcome.pretty.PaymentHash)
+	}
+
+	if firstErr != nil {
+		if delivered > 0 {
+			r.Node.Logln(glightning.Info, len(hashes), " of ", len(parts), " parts succeeded, delivering ", delivered,
+				"msat, the rest of the rebalance is ", r.Amount-delivered, "msat")
+			r.Node.RecordFee(fee)
+			r.delivered += delivered
+			r.deliveredFee += fee
+			r.Amount -= delivered
+		}
+		return nil, firstErr
+	}
+
+	pretty := withGraphAge(r.Node, graph.NewPrettyRoute(route, strings.Join(hashes, ",")))
+	pretty.Fee = fee
+	pretty.FeePPM = fee * 1000000 / route.Amount
+	return pretty, nil
+}
+
+// addDelivered adds to the failure result what the parts of split routes that settled moved, and their fees
+func (r *Rebalance) addDelivered(result *Result) {
+	if r.delivered == 0 {
+		return
+	}
+	result.Delivered = r.delivered
+	result.Fee = r.deliveredFee
+	result.Message += fmt.Sprintf(" The parts that settled delivered %d sats, paying %.3f sats of fees.",
+		r.delivered/1000, float64(r.deliveredFee)/1000)
+}
@@ -0,0 +1,28 @@
+package rebalance
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFailureReportsDeliveredParts(t *testing.T) {
+	r := newProgressTestRebalance()
+	failure := NewResult("failure", 0, "out", "in")
+	r.addDelivered(failure)
+	assert.Equal(t, uint64(0), failure.Delivered)
+	assert.Equal(t, uint64(0), failure.Fee)
+
+	// the parts that settled in a failed attempt are reported with their fees
+	r.delivered = 40000000
+	r.deliveredFee = 1500
+	failure = NewResult("failure", 0, "out", "in")
+	r.addDelivered(failure)
+	assert.Equal(t, uint64(40000000), failure.Delivered)
+	assert.Equal(t, uint64(1500), failure.Fee)
+	assert.Contains(t, failure.Message, "40000 sats")
+
+	// as they are when the deadline is exceeded without tracking the progress
+	result := r.deadlineResult(1)
+	assert.Equal(t, uint64(40000000), result.Delivered)
+	assert.Equal(t, uint64(1500), result.Fee)
+}
@@ -0,0 +1,126 @@
+package rebalance
+
+import (
+	"circular/node"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"math"
+	"sort"
+)
+
+const (
+	DEFAULT_TOPK_CHANNELS = 10
+)
+
+type TopKChannels struct {
+	K      int        `json:"k,omitempty"`
+	Target *float64   `json:"target,omitempty"`
+	Node   *node.Node `json:"-"`
+}
+
+// ImbalancedChannel is a local channel with how far its local balance is from the target ratio
+type ImbalancedChannel struct {
+	Scid      string `json:"scid"`
+	PeerId    string `json:"peer_id"`
+	Alias     string `json:"alias,omitempty"`
+	Connected bool   `json:"connected"`
+	Capacity  uint64 `json:"capacity_msat"`
+	// Local is the balance reported by listpeers, Believed the liquidity of the graph towards the peer,
+	// missing if the channel isn't in the graph yet
+	Local     uint64  `json:"local_msat"`
+	Believed  *uint64 `json:"believed_msat,omitempty"`
+	Ratio     float64 `json:"ratio"`
+	Imbalance float64 `json:"imbalance"`
+	Direction string  `json:"direction"`
+}
+
+type TopKChannelsResult struct {
+	Target   float64              `json:"target"`
+	Total    int                  `json:"total"`
+	Channels []*ImbalancedChannel `json:"channels"`
+}
+
+func (r *TopKChannels) Name() string {
+	return "circular-topk-channels"
+}
+
+func (r *TopKChannels) New() interface{} {
+	return &TopKChannels{}
+}
+
+func (r *TopKChannels) Call() (jrpc2.Result, error) {
+	r.Node = node.GetNode()
+	if r.K <= 0 {
+		r.K = DEFAULT_TOPK_CHANNELS
+	}
+	target, err := balanceTargetOrDefault(r.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := r.getChannels(target)
+	return &TopKChannelsResult{
+		Target:   target,
+		Total:    len(channels),
+		Channels: topImbalanced(channels, r.K),
+	}, nil
+}
+
+// getChannels returns the local channels in normal state, with their imbalance from the target
+func (r *TopKChannels) getChannels(target float64) []*ImbalancedChannel {
+	r.Node.PeersLock.RLock()
+	defer r.Node.PeersLock.RUnlock()
+
+	channels := make([]*ImbalancedChannel, 0)
+	for _, peer := range r.Node.Peers {
+		for _, c := range peer.Channels {
+			if c.State != NORMAL {
+				continue
+			}
+			channel := newImbalancedChannel(c, target)
+			channel.PeerId = peer.Id
+			channel.Alias = r.Node.Graph.GetAlias(peer.Id)
+			channel.Connected = peer.Connected
+			if graphChannel, err := r.Node.Graph.GetChannel(c.ShortChannelId + "/" + util.GetDirection(r.Node.Id, peer.Id)); err == nil {
+				believed := graphChannel.Liquidity
+				channel.Believed = &believed
+			}
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+func newImbalancedChannel(c *glightning.PeerChannel, target float64) *ImbalancedChannel {
+	ratio := localRatio(c)
+	direction := BALANCE_NONE
+	if ratio > target {
+		direction = BALANCE_DRAIN
+	} else if ratio < target {
+		direction = BALANCE_FILL
+	}
+	return &ImbalancedChannel{
+		Scid:      c.ShortChannelId,
+		Capacity:  c.MilliSatoshiTotal,
+		Local:     c.MilliSatoshiToUs,
+		Ratio:     ratio,
+		Imbalance: math.Abs(ratio - target),
+		Direction: direction,
+	}
+}
+
+// topImbalanced returns the k most imbalanced channels, most imbalanced first
+func topImbalanced(channels []*ImbalancedChannel, k int) []*ImbalancedChannel {
+	sorted := append([]*ImbalancedChannel{}, channels...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Imbalance != sorted[j].Imbalance {
+			return sorted[i].Imbalance > sorted[j].Imbalance
+		}
+		return sorted[i].Scid < sorted[j].Scid
+	})
+	if len(sorted) > k {
+		sorted = sorted[:k]
+	}
+	return sorted
+}
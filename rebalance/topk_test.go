@@ -0,0 +1,39 @@
+package rebalance
+
+import (
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTopImbalanced(t *testing.T) {
+	channels := make([]*ImbalancedChannel, 0)
+	for scid, toUs := range map[string]uint64{
+		"1x1x1": 500000000,
+		"2x2x2": 900000000,
+		"3x3x3": 50000000,
+		"4x4x4": 400000000,
+	} {
+		channels = append(channels, newImbalancedChannel(&glightning.PeerChannel{
+			ShortChannelId:    scid,
+			MilliSatoshiToUs:  toUs,
+			MilliSatoshiTotal: 1000000000,
+		}, 0.5))
+	}
+
+	top := topImbalanced(channels, 3)
+	if assert.Len(t, top, 3) {
+		assert.Equal(t, "3x3x3", top[0].Scid)
+		assert.Equal(t, BALANCE_FILL, top[0].Direction)
+		assert.InDelta(t, 0.45, top[0].Imbalance, 1e-9)
+		assert.Equal(t, "2x2x2", top[1].Scid)
+		assert.Equal(t, BALANCE_DRAIN, top[1].Direction)
+		assert.Equal(t, "4x4x4", top[2].Scid)
+	}
+	assert.Len(t, topImbalanced(channels, 10), 4)
+
+	// the target changes what is imbalanced
+	balanced := newImbalancedChannel(&glightning.PeerChannel{MilliSatoshiToUs: 900000000, MilliSatoshiTotal: 1000000000}, 0.9)
+	assert.Equal(t, BALANCE_NONE, balanced.Direction)
+	assert.InDelta(t, 0, balanced.Imbalance, 1e-9)
+}
@@ -0,0 +1,176 @@
+package rebalance
+
+import (
+	"circular/graph"
+	"circular/node"
+	"circular/util"
+	"github.com/elementsproject/glightning/glightning"
+	"github.com/elementsproject/glightning/jrpc2"
+	"math"
+	"strings"
+)
+
+const (
+	DEFAULT_WHATIF_MAXPPM = 1000
+	DEFAULT_WHATIF_POINTS = 5
+	WHATIF_MAX_POINTS     = 20
+)
+
+// WhatIf shows which route circular would use for a rebalance at different values of maxppm, without paying
+type WhatIf struct {
+	OutScid   string     `json:"outscid"`
+	InScid    string     `json:"inscid"`
+	Amount    uint64     `json:"amount,omitempty"`
+	MinPPM    uint64     `json:"minppm,omitempty"`
+	MaxPPM    uint64     `json:"maxppm,omitempty"`
+	Points    int        `json:"points,omitempty"`
+	MaxHops   *int       `json:"maxhops,omitempty"`
+	FinalCltv uint       `json:"finalcltv,omitempty"`
+	Node      *node.Node `json:"-"`
+}
+
+// WhatIfRow is the route that a rebalance with MaxPPM would try first. Unlocked is true when it's
+// a different route than the one of the previous row, i.e. the higher budget makes a new route usable.
+type WhatIfRow struct {
+	MaxPPM   uint64 `json:"maxppm"`
+	Feasible bool   `json:"feasible"`
+	Unlocked bool   `json:"unlocked,omitempty"`
+	Hops     int    `json:"hops,omitempty"`
+	Fee      uint64 `json:"fee,omitempty"`
+	PPM      uint64 `json:"ppm,omitempty"`
+	Route    string `json:"route,omitempty"`
+}
+
+type WhatIfResult struct {
+	Amount uint64       `json:"amount"`
+	Rows   []*WhatIfRow `json:"rows"`
+	// GraphAge and StaleGraph are the age of the graph the routes were found on, see graph.PrettyRoute
+	GraphAge   int64 `json:"graph_age_seconds"`
+	StaleGraph bool  `json:"stale_graph,omitempty"`
+}
+
+func (w *WhatIf) Name() string {
+	return "circular-whatif"
+}
+
+func (w *WhatIf) New() interface{} {
+	return &WhatIf{}
+}
+
+func (w *WhatIf) Call() (jrpc2.Result, error) {
+	w.Node = node.GetNode()
+	if w.InScid == "" || w.OutScid == "" {
+		return nil, util.ErrNoRequiredParameter
+	}
+	if w.MaxPPM == 0 {
+		w.MaxPPM = DEFAULT_WHATIF_MAXPPM
+	}
+	if w.MinPPM > w.MaxPPM {
+		return nil, util.ErrInvalidPPMRange
+	}
+
+	outgoingChannel, err := w.Node.GetOutgoingChannelFromScid(w.OutScid)
+	if err != nil {
+		return nil, err
+	}
+	incomingChannel, err := w.Node.GetIncomingChannelFromScid(w.InScid)
+	if err != nil {
+		return nil, err
+	}
+
+	rebalance := NewRebalance(outgoingChannel, incomingChannel, w.Amount, w.MaxPPM, 1, maxHopsOrDefault(w.MaxHops))
+	rebalance.FinalCltv = w.FinalCltv
+	rebalance.Command = w.Name()
+	// nothing is paid
+	rebalance.IgnoreCooldown = true
+	if err := rebalance.Setup(); err != nil {
+		return nil, err
+	}
+
+	routes := rebalance.cheapestRoutes()
+	result := &WhatIfResult{Amount: rebalance.Amount / 1000}
+	age, stale := w.Node.GraphAge()
+	result.GraphAge, result.StaleGraph = int64(age.Seconds()), stale
+	var previous *graph.Route
+	for _, maxPPM := range sweepPoints(w.MinPPM, w.MaxPPM, w.Points) {
+		row := &WhatIfRow{MaxPPM: maxPPM}
+		if route := firstRouteWithin(routes, maxPPM); route != nil {
+			row.Feasible = true
+			row.Unlocked = route != previous
+			row.Hops = len(route.Hops)
+			row.Fee = route.Fee()
+			row.PPM = route.FeePPM()
+			row.Route = routeScids(route)
+			previous = route
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, nil
+}
+
+// cheapestRoutes returns the cheapest route for every number of hops that Run tries, in the same order.
+// The entry is nil if there is no route with that many hops.
+func (r *Rebalance) cheapestRoutes() []*graph.Route {
+	maxPPM := r.MaxPPM
+	r.MaxPPM = math.MaxUint64
+	defer func() { r.MaxPPM = maxPPM }()
+
+	hopLimits := []int{0}
+	if r.MaxHops != 0 {
+		hopLimits = []int{}
+		for maxHops := 3; maxHops <= r.MaxHops; maxHops++ {
+			hopLimits = append(hopLimits, maxHops)
+		}
+	}
+
+	routes := make([]*graph.Route, 0, len(hopLimits))
+	for _, maxHops := range hopLimits {
+		route, err := r.getRoute(maxHops)
+		if err != nil {
+			r.Node.Logln(glightning.Debug, "no route with at most ", maxHops, " hops: ", err)
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// firstRouteWithin returns the route that Run would pick with maxPPM: the first one that costs at most maxPPM
+func firstRouteWithin(routes []*graph.Route, maxPPM uint64) *graph.Route {
+	for _, route := range routes {
+		if route != nil && route.FeePPM() <= maxPPM {
+			return route
+		}
+	}
+	return nil
+}
+
+// sweepPoints returns up to points values evenly spread between min and max, both included
+func sweepPoints(min, max uint64, points int) []uint64 {
+	if points <= 0 {
+		points = DEFAULT_WHATIF_POINTS
+	}
+	if points > WHATIF_MAX_POINTS {
+		points = WHATIF_MAX_POINTS
+	}
+	if points == 1 || min == max {
+		return []uint64{max}
+	}
+
+	result := make([]uint64, 0, points)
+	for i := 0; i < points; i++ {
+		value := min + (max-min)*uint64(i)/uint64(points-1)
+		if len(result) > 0 && result[len(result)-1] == value {
+			continue
+		}
+		result = append(result, value)
+	}
+	return result
+}
+
+func routeScids(route *graph.Route) string {
+	scids := make([]string, 0, len(route.Hops))
+	for _, hop := range route.Hops {
+		scids = append(scids, hop.ShortChannelId)
+	}
+	return strings.Join(scids, " -> ")
+}
@@ -0,0 +1,15 @@
+package rebalance
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSweepPoints(t *testing.T) {
+	assert.Equal(t, []uint64{0, 250, 500, 750, 1000}, sweepPoints(0, 1000, 0))
+	assert.Equal(t, []uint64{10, 20}, sweepPoints(10, 20, 2))
+	assert.Equal(t, []uint64{100}, sweepPoints(100, 100, 5))
+	// close values are not repeated
+	assert.Equal(t, []uint64{0, 1, 2, 3}, sweepPoints(0, 3, 10))
+	assert.Len(t, sweepPoints(0, 1000000, 1000), WHATIF_MAX_POINTS)
+}
@@ -21,27 +21,118 @@ func (e ErrRouteTooExpensive) Error() string {
 	return fmt.Sprintf("route too expensive. Cheapest route found was %d ppm, but maxppm is %d", e.FeePPM, e.MaxPPM)
 }
 
+type ErrAmountTooSmall struct {
+	Amount    uint64
+	MinAmount uint64
+}
+
+func NewAmountTooSmallError(amount, minAmount uint64) ErrAmountTooSmall {
+	return ErrAmountTooSmall{
+		Amount:    amount,
+		MinAmount: minAmount,
+	}
+}
+
+func (e ErrAmountTooSmall) Error() string {
+	return fmt.Sprintf("amount too small. %d sats is less than the minimum of %d sats: "+
+		"on small amounts the base fees of the hops dominate, making the rebalance disproportionately expensive",
+		e.Amount/1000, e.MinAmount/1000)
+}
+
+type ErrRouteTooLong struct {
+	Length    int
+	MaxLength int
+}
+
+func NewRouteTooLongError(length, maxLength int) ErrRouteTooLong {
+	return ErrRouteTooLong{
+		Length:    length,
+		MaxLength: maxLength,
+	}
+}
+
+func (e ErrRouteTooLong) Error() string {
+	return fmt.Sprintf("route too long. The route found has %d hops, but the maximum is %d", e.Length, e.MaxLength)
+}
+
 var (
 	ErrSendPayTimeout      = errors.New("200:Timed out while waiting")
 	ErrTemporaryFailure    = errors.New("204:failed: WIRE_TEMPORARY_CHANNEL_FAILURE (reply from remote)")
 	ErrWireFeeInsufficient = errors.New("204:failed: WIRE_FEE_INSUFFICIENT (reply from remote)")
+	ErrSendPayStalled      = errors.New("the payment timed out and listsendpays confirms that it failed")
 
 	ErrNoRequiredParameter         = errors.New("missing required parameter")
 	ErrSelfNode                    = errors.New("one of the nodes is self")
 	ErrNoPeers                     = errors.New("no peers yet")
 	ErrSameIncomingAndOutgoingNode = errors.New("incoming and outgoing nodes are the same")
 	ErrNoPeerChannel               = errors.New("not a peer or peer channel")
+	ErrInvalidAmount               = errors.New("the amount must be a number of sats or a percentage of the capacity of the outgoing channel, like 20%")
+	ErrAmountAboveCapacity         = errors.New("the amount can't be more than 100% of the capacity of the outgoing channel")
+	ErrScidNotWithPeer             = errors.New("the channel is not with the given peer")
 	ErrNoSuchNode                  = errors.New("no such node")
 	ErrNoPeer                      = errors.New("no peer")
 	ErrFirstPeerNotReady           = errors.New("first peer not ready")
+	ErrFirstHopFailure             = errors.New("the first hop of the route failed")
+	ErrUnattributedFailure         = errors.New("the payment failed without telling which hop failed")
+	ErrPrivateChannelNotAllowed    = errors.New("private channels are not allowed in routes in strict mode")
+	ErrChannelOutsideCapacityRange = errors.New("the capacity of a local channel is outside the capacity range in strict mode")
 	ErrCircularStopped             = errors.New("circular has been stopped. Use 'circular-resume' to resume activity")
+	ErrNoSuchRebalance             = errors.New("no such rebalance, or it finished too long ago")
+	ErrRebalanceFinished           = errors.New("the rebalance has already finished")
+	ErrRebalanceCancelled          = errors.New("the rebalance has been cancelled")
+	ErrRebalanceDeadline           = errors.New("deadline exceeded, see circular-rebalance-deadline")
+	ErrPaymentHashCollision        = errors.New("payment hash collision, refusing to reuse a preimage")
+	ErrPreimagesNotSaved           = errors.New("preimages are not saved, enable circular-save-preimages")
+	ErrNoSuchPreimage              = errors.New("no preimage saved for this payment hash")
+	// ErrEntropyCheckFailed is wrapped with the reason the preimage generator is not trusted
+	ErrEntropyCheckFailed = errors.New("preimage generator self-test failed")
+
+	ErrNoGraphToLoad   = errors.New("no graph to load")
+	ErrUnreadableGraph = errors.New("unable to read the graph from")
+	// ErrInconsistentGraph is wrapped with what is inconsistent
+	ErrInconsistentGraph = errors.New("the channels and the adjacency list of the graph disagree")
+	ErrNoRoute           = errors.New("no route")
+	// ErrMalformedChannel is wrapped with the channel and what is wrong with it
+	ErrMalformedChannel = errors.New("malformed channel")
 
-	ErrNoGraphToLoad = errors.New("no graph to load")
-	ErrNoRoute       = errors.New("no route")
+	ErrRouteRejected             = errors.New("the route was rejected by the route hook")
+	ErrInvalidRouteFormat        = errors.New("invalid route format, it must be one of: json, simple, detailed, aliases, sendpay")
+	ErrInvalidPPMRange           = errors.New("minppm can't be greater than maxppm")
+	ErrInvalidLocalBalanceSource = errors.New("invalid local balance, it must be one of: to-us, to-us-minus-reserve, spendable")
+	ErrInvalidDuplicates         = errors.New("invalid duplicates, it must be one of: reject, coalesce, allow")
+	ErrInvalidProfile            = errors.New("invalid profile, it must be one of: economical, aggressive")
+	ErrInvalidLiquidityRefresh   = errors.New("invalid liquidity refresh, it must be at least 1 minute")
+	ErrInvalidBanDuration        = errors.New("invalid ban duration, it must be a positive number of minutes")
+	ErrAllowlistDisconnected     = errors.New("the allowlist disconnects the source from the destination")
+	ErrRouteTooUnlikely          = errors.New("no route found with a probability of success above the minimum")
+	ErrUnknownRouter             = errors.New("unknown router, it must be one of: cached, dijkstra, probability")
+	ErrRouterTimeout             = errors.New("the router didn't find a route in time")
+	ErrUnstableRoute             = errors.New("the route changed between consecutive searches, the graph is probably being updated")
+	ErrUnsupportedBeliefsVersion = errors.New("unsupported version of the beliefs file")
+	ErrInvalidExportFormat       = errors.New("invalid format, it must be one of: json, csv")
+	ErrInvalidSortField          = errors.New("invalid sort field, it must be one of: ppm, liquidity, capacity")
+	ErrInvalidErrorCategory      = errors.New("invalid category, it must be one of: route-not-found, too-expensive, sendpay-failure, timeout, stalled")
+	ErrInvalidTieBreak           = errors.New("invalid tie-break criterion, it must be one of: hops, liquidity, scid")
+	ErrZeroAmount                = errors.New("amount must be greater than zero")
+	ErrHopCannotCarryAmount      = errors.New("a hop in the route cannot carry the amount")
+
+	ErrSameIncomingAndOutgoingChannel = errors.New("incoming and outgoing channels are the same")
+	ErrFinalCltvTooLow                = errors.New("finalcltv is lower than the cltv-final of lightningd, the minimum delta it accepts on the last hop")
+	ErrDiscontinuousRoute             = errors.New("the route is not continuous")
+	ErrUnusableHop                    = errors.New("a hop of the route cannot forward the amount")
+	ErrRouteNotCircular               = errors.New("only routes that start and end at our node can be sent")
+	ErrNoCommonPeer                   = errors.New("maxhops=0 requires the outgoing and incoming channels to be with the same peer")
+	ErrSameSourceAndDestination       = errors.New("source and destination of the route are the same node")
+	ErrSamePeer                       = errors.New("the outgoing and incoming channels are with the same peer, there is no route to look for between them: use maxhops=0 to rebalance through that peer")
 
 	ErrAmountLessThanSplitAmount      = errors.New("amount is less than split amount")
 	ErrAmountNotMultipleOfSplitAmount = errors.New("amount is not a multiple of split amount")
 	ErrDepleteUpToPercentInvalid      = errors.New("deplete up to percent invalid, it must be between 0 and 1")
+	ErrBalanceTargetInvalid           = errors.New("target invalid, it must be between 0 and 1")
+	ErrChannelBeingBalanced           = errors.New("the channel, or the one chosen to balance it, is already being balanced")
+	ErrChannelCoolingDown             = errors.New("the channel was rebalanced recently and is cooling down")
+	ErrDuplicateRebalance             = errors.New("an identical rebalance (same channels and amount) is already in flight")
+	ErrDailyFeeCapReached             = errors.New("the daily fee cap has been reached")
 
 	ErrNoChannel               = errors.New("no channel")
 	ErrNoCandidates            = errors.New("no candidates")
@@ -49,6 +140,8 @@ var (
 	ErrChannelFilled           = errors.New("channel is filled")
 	ErrIncomingChannelDepleted = errors.New("incoming channel does not have enough remote balance")
 	ErrOutgoingChannelDepleted = errors.New("outgoing channel does not have enough local balance")
+	// ErrInsufficientLocalOutbound is wrapped with the balance that is available
+	ErrInsufficientLocalOutbound = errors.New("insufficient local outbound on the outgoing channel")
 
 	ErrNoOutgoingChannel               = errors.New("no outgoing channel")
 	ErrNoIncomingChannel               = errors.New("no incoming channel")
@@ -59,6 +152,19 @@ var (
 	ErrChannelNotFound         = errors.New("channel not found")
 	ErrOppositeChannelNotFound = errors.New("opposite channel not found")
 
+	ErrPeerDisconnected         = errors.New("peer is disconnected")
 	ErrIncomingPeerDisconnected = errors.New("incoming peer is disconnected")
 	ErrOutgoingPeerDisconnected = errors.New("outgoing peer is disconnected")
+
+	// ErrSelfNotInGraph is returned instead of the missing local channels, or of no route, when there is nothing to route on yet
+	ErrSelfNotInGraph = errors.New("our node is not in the graph yet, no channel of ours is known: " +
+		"wait for the gossip to sync or run circular-refresh-graph")
+
+	// ErrFeeTooLarge is returned for the routes with a fee that lightningd can't represent, see graph.MAX_HOP_FEE
+	ErrFeeTooLarge = errors.New("fee is too large for lightningd")
+
+	ErrInvalidObjective = errors.New("invalid route objective, it must be a comma separated list of: cheapest, shortest")
+
+	ErrFeeHistoryDisabled = errors.New("fee history is not recorded, enable circular-fee-history")
+	ErrMalformedFeeEntry  = errors.New("malformed fee history entry")
 )
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"runtime"
@@ -16,6 +17,15 @@ func All(v []bool) bool {
 	return true
 }
 
+// IsNodeId tells whether id is a compressed public key: 33 bytes in hex, starting with 02 or 03
+func IsNodeId(id string) bool {
+	if len(id) != 66 || (!strings.HasPrefix(id, "02") && !strings.HasPrefix(id, "03")) {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
 func GetDirection(from, to string) string {
 	if from < to {
 		return "0"